@@ -8,7 +8,15 @@ import (
 	"github.com/open-source/dynatrace-plugin-datasource/pkg/plugin"
 )
 
+// Set by the build from package.json and git, see build.BuildAll
+var (
+	version string
+	commit  string
+)
+
 func main() {
+	plugin.SetBuildInfo(version, commit)
+
 	// Start listening to requests sent from Grafana. This call is blocking so
 	// it won't finish until Grafana shuts down the process or the plugin choose
 	// to exit by itself using os.Exit. Manage automatically manages life cycle
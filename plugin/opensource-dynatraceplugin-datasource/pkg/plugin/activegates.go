@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// DynatraceActiveGatesResponse represents the response of /api/v2/activeGates
+type DynatraceActiveGatesResponse struct {
+	ActiveGates []DynatraceActiveGate `json:"activeGates"`
+}
+
+type DynatraceActiveGate struct {
+	Id                 string                      `json:"id"`
+	Hostname           string                      `json:"hostname"`
+	Type               string                      `json:"type"`
+	Version            string                      `json:"version"`
+	OsType             string                      `json:"osType"`
+	OsArchitecture     string                      `json:"osArchitecture"`
+	NetworkZone        string                      `json:"networkZone"`
+	Group              string                      `json:"group"`
+	AutoUpdateStatus   string                      `json:"autoUpdateStatus"`
+	OfflineSince       *int64                      `json:"offlineSince"`
+	Containerized      bool                        `json:"containerized"`
+	Modules            []DynatraceActiveGateModule `json:"modules"`
+	AutoUpdateSettings *struct {
+		EffectiveSetting string `json:"effectiveSetting"`
+	} `json:"autoUpdateSettings"`
+}
+
+type DynatraceActiveGateModule struct {
+	Type          string `json:"type"`
+	Enabled       bool   `json:"enabled"`
+	Misconfigured bool   `json:"misconfigured"`
+	Version       string `json:"version"`
+}
+
+// queryActiveGates returns one row per ActiveGate with its version, OS,
+// enabled modules, connectivity and auto-update status.
+func (d *Datasource) queryActiveGates(ctx context.Context) backend.DataResponse {
+	log.DefaultLogger.Debug("Querying Dynatrace ActiveGates")
+
+	var resp DynatraceActiveGatesResponse
+	if err := d.get(ctx, "/api/v2/activeGates", nil, &resp); err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace ActiveGates: %v", err))
+	}
+
+	frame := activeGatesFrame(resp.ActiveGates)
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		ExecutedQueryString:    "ActiveGates",
+	}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// activeGatesFrame converts ActiveGates into a table frame with one row per gate.
+func activeGatesFrame(gates []DynatraceActiveGate) *data.Frame {
+	frame := data.NewFrame("activeGates",
+		data.NewField("id", nil, []string{}),
+		data.NewField("hostname", nil, []string{}),
+		data.NewField("type", nil, []string{}),
+		data.NewField("version", nil, []string{}),
+		data.NewField("os", nil, []string{}),
+		data.NewField("networkZone", nil, []string{}),
+		data.NewField("group", nil, []string{}),
+		data.NewField("modules", nil, []string{}),
+		data.NewField("misconfiguredModules", nil, []string{}),
+		data.NewField("online", nil, []bool{}),
+		data.NewField("offlineSince", nil, []*time.Time{}),
+		data.NewField("autoUpdateStatus", nil, []string{}),
+		data.NewField("autoUpdateSetting", nil, []string{}),
+		data.NewField("containerized", nil, []bool{}),
+	)
+
+	for _, g := range gates {
+		var enabled, misconfigured []string
+		for _, m := range g.Modules {
+			if m.Enabled {
+				enabled = append(enabled, m.Type)
+			}
+			if m.Misconfigured {
+				misconfigured = append(misconfigured, m.Type)
+			}
+		}
+		sort.Strings(enabled)
+		sort.Strings(misconfigured)
+
+		var offlineSince *time.Time
+		if g.OfflineSince != nil {
+			t := time.UnixMilli(*g.OfflineSince)
+			offlineSince = &t
+		}
+
+		autoUpdateSetting := ""
+		if g.AutoUpdateSettings != nil {
+			autoUpdateSetting = g.AutoUpdateSettings.EffectiveSetting
+		}
+
+		osName := strings.TrimSpace(fmt.Sprintf("%s %s", g.OsType, g.OsArchitecture))
+
+		frame.AppendRow(
+			g.Id,
+			g.Hostname,
+			g.Type,
+			g.Version,
+			osName,
+			g.NetworkZone,
+			g.Group,
+			strings.Join(enabled, ", "),
+			strings.Join(misconfigured, ", "),
+			offlineSince == nil,
+			offlineSince,
+			g.AutoUpdateStatus,
+			autoUpdateSetting,
+			g.Containerized,
+		)
+	}
+
+	return frame
+}
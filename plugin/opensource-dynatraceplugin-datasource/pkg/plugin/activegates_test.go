@@ -0,0 +1,46 @@
+package plugin
+
+import (
+	"testing"
+)
+
+func TestActiveGatesFrame(t *testing.T) {
+	offline := int64(1700000000000)
+	frame := activeGatesFrame([]DynatraceActiveGate{
+		{
+			Id:               "0x1",
+			Hostname:         "ag-1",
+			OsType:           "LINUX",
+			OsArchitecture:   "X86",
+			AutoUpdateStatus: "UP2DATE",
+			Modules: []DynatraceActiveGateModule{
+				{Type: "SYNTHETIC", Enabled: true},
+				{Type: "KUBERNETES", Enabled: true, Misconfigured: true},
+				{Type: "AWS", Enabled: false},
+			},
+		},
+		{Id: "0x2", Hostname: "ag-2", OfflineSince: &offline},
+	})
+
+	if rows, _ := frame.RowLen(); rows != 2 {
+		t.Fatalf("expected 2 rows, got %d", rows)
+	}
+
+	modules, _ := frame.FieldByName("modules")
+	if got := modules.At(0); got != "KUBERNETES, SYNTHETIC" {
+		t.Errorf("modules = %v", got)
+	}
+	misconfigured, _ := frame.FieldByName("misconfiguredModules")
+	if got := misconfigured.At(0); got != "KUBERNETES" {
+		t.Errorf("misconfiguredModules = %v", got)
+	}
+	os, _ := frame.FieldByName("os")
+	if got := os.At(0); got != "LINUX X86" {
+		t.Errorf("os = %v", got)
+	}
+
+	online, _ := frame.FieldByName("online")
+	if online.At(0) != true || online.At(1) != false {
+		t.Errorf("online = %v, %v", online.At(0), online.At(1))
+	}
+}
@@ -0,0 +1,159 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// advancedParams are the metrics query parameters accepted in advanced mode.
+var advancedParams = map[string]bool{
+	"metricSelector": true,
+	"resolution":     true,
+	"from":           true,
+	"to":             true,
+	"entitySelector": true,
+	"mzSelector":     true,
+}
+
+// queryAdvanced executes a metrics query whose parameters are given verbatim
+// in QueryText, either as a JSON object or as a URL query string, e.g.
+//
+//	{"metricSelector": "builtin:host.cpu.usage", "mzSelector": "mzName(\"prod\")"}
+//	metricSelector=builtin:host.cpu.usage&resolution=1h&from=now-7d
+//
+// from and to override the query's time range. They are resolved once here,
+// so that every request derived from the query, e.g. the chunks of a split
+// selector or shifted baseline weeks, gets its own range rather than the
+// verbatim parameters.
+func (d *Datasource) queryAdvanced(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	params, err := parseAdvancedQuery(qm.QueryText)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid advanced query: %v", err))
+	}
+
+	if params.Has("from") || params.Has("to") {
+		fromMs, toMs, err := timeRange(qm, query)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+		now := time.Now()
+		if from := params.Get("from"); from != "" {
+			if fromMs, err = parseAdvancedTime(from, now); err != nil {
+				return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid advanced query: from: %v", err))
+			}
+		}
+		if to := params.Get("to"); to != "" {
+			if toMs, err = parseAdvancedTime(to, now); err != nil {
+				return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid advanced query: to: %v", err))
+			}
+		}
+		qm.UseDashboardTime = false
+		qm.CustomFrom = strconv.FormatInt(fromMs, 10)
+		qm.CustomTo = strconv.FormatInt(toMs, 10)
+	}
+
+	qm.MetricSelector = params.Get("metricSelector")
+	qm.MetricId = ""
+	qm.Resolution = params.Get("resolution")
+	for _, key := range []string{"metricSelector", "resolution", "from", "to"} {
+		params.Del(key)
+	}
+	qm.metricsParams = params
+
+	return d.queryMetrics(ctx, query, qm)
+}
+
+// relativeTime matches the relative timeframes of the Dynatrace API, e.g.
+// "now" or "now-7d".
+var relativeTime = regexp.MustCompile(`^now(?:-(\d+)([smhdwMy]))?$`)
+
+// parseAdvancedTime converts a from or to parameter, given in milliseconds,
+// as an ISO 8601 timestamp or relative to now, into milliseconds.
+func parseAdvancedTime(s string, now time.Time) (int64, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ms, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UnixMilli(), nil
+	}
+	m := relativeTime.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("unsupported time %q: use milliseconds, ISO 8601 or now-<amount><unit>", s)
+	}
+	if m[1] == "" {
+		return now.UnixMilli(), nil
+	}
+	n, _ := strconv.Atoi(m[1])
+	switch m[2] {
+	case "s":
+		now = now.Add(-time.Duration(n) * time.Second)
+	case "m":
+		now = now.Add(-time.Duration(n) * time.Minute)
+	case "h":
+		now = now.Add(-time.Duration(n) * time.Hour)
+	case "d":
+		now = now.AddDate(0, 0, -n)
+	case "w":
+		now = now.AddDate(0, 0, -7*n)
+	case "M":
+		now = now.AddDate(0, -n, 0)
+	case "y":
+		now = now.AddDate(-n, 0, 0)
+	}
+	return now.UnixMilli(), nil
+}
+
+// parseAdvancedQuery parses and validates the parameters of an advanced query.
+func parseAdvancedQuery(text string) (url.Values, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("queryText is required")
+	}
+
+	params := url.Values{}
+	if strings.HasPrefix(text, "{") {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &fields); err != nil {
+			return nil, err
+		}
+		for key, value := range fields {
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("parameter %s must be a string", key)
+			}
+			params.Set(key, s)
+		}
+	} else {
+		parsed, err := url.ParseQuery(text)
+		if err != nil {
+			return nil, err
+		}
+		params = parsed
+	}
+
+	var unknown []string
+	for key, values := range params {
+		if !advancedParams[key] {
+			unknown = append(unknown, key)
+		} else if len(values) > 1 {
+			return nil, fmt.Errorf("parameter %s is given more than once", key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unsupported parameters: %s", strings.Join(unknown, ", "))
+	}
+	if params.Get("metricSelector") == "" {
+		return nil, fmt.Errorf("metricSelector is required")
+	}
+	return params, nil
+}
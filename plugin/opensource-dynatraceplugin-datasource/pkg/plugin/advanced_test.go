@@ -0,0 +1,119 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestParseAdvancedQuery(t *testing.T) {
+	for _, valid := range []string{
+		`{"metricSelector": "builtin:host.cpu.usage", "mzSelector": "mzName(\"prod\")"}`,
+		`metricSelector=builtin:host.cpu.usage&resolution=1h&from=now-7d`,
+	} {
+		if _, err := parseAdvancedQuery(valid); err != nil {
+			t.Errorf("parseAdvancedQuery(%s): %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{
+		``,
+		`{"resolution": "1h"}`,
+		`{"metricSelector": "m", "pageSize": 5}`,
+		`metricSelector=m&fields=x`,
+		`metricSelector=a&metricSelector=b`,
+	} {
+		if _, err := parseAdvancedQuery(invalid); err == nil {
+			t.Errorf("parseAdvancedQuery(%s) should fail", invalid)
+		}
+	}
+}
+
+func TestQueryAdvanced(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		for key, want := range map[string]string{
+			"metricSelector": "builtin:host.cpu.usage",
+			"mzSelector":     `mzName("prod")`,
+			"resolution":     "1h",
+		} {
+			if got := query.Get(key); got != want {
+				t.Errorf("%s = %q, want %q", key, got, want)
+			}
+		}
+		// from is resolved by the plugin instead of being passed on verbatim
+		from, err := strconv.ParseInt(query.Get("from"), 10, 64)
+		if want := time.Now().AddDate(0, 0, -7).UnixMilli(); err != nil || from < want-time.Minute.Milliseconds() || from > want {
+			t.Errorf("from = %q, want about %d", query.Get("from"), want)
+		}
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {}, "timestamps": [1000], "values": [1.5]}
+		]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeAdvanced,
+		JSON:      []byte(`{"queryText": "{\"metricSelector\": \"builtin:host.cpu.usage\", \"mzSelector\": \"mzName(\\\"prod\\\")\", \"resolution\": \"1h\", \"from\": \"now-7d\"}", "useDashboardTime": true}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if len(resp.Frames) != 1 {
+		t.Errorf("expected one series, got %d frames", len(resp.Frames))
+	}
+}
+
+func TestQueryAdvancedTimeRangePerRequest(t *testing.T) {
+	var mu sync.Mutex
+	froms := map[string]bool{}
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		froms[req.URL.Query().Get("from")] = true
+		mu.Unlock()
+		if values := req.URL.Query()["from"]; len(values) != 1 {
+			t.Errorf("from = %v, want a single value", values)
+		}
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {}, "timestamps": [1000], "values": [1.5]}
+		]}]}`))
+	})
+
+	// The baseline queries each shifted week, which must not all get the
+	// advanced from
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeAdvanced,
+		JSON:      []byte(`{"queryText": "metricSelector=builtin:host.cpu.usage&resolution=1h&from=now-1d&to=now", "baseline": true, "baselineWeeks": 2}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if len(froms) != 3 {
+		t.Errorf("expected the query and two shifted weeks to have distinct ranges, got %v", froms)
+	}
+}
+
+func TestParseAdvancedTime(t *testing.T) {
+	now := time.Date(2024, 3, 8, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"1709899200000":        time.UnixMilli(1709899200000),
+		"2024-03-01T00:00:00Z": time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		"now":                  now,
+		"now-2h":               now.Add(-2 * time.Hour),
+		"now-7d":               now.AddDate(0, 0, -7),
+		"now-1M":               now.AddDate(0, -1, 0),
+	}
+	for s, want := range tests {
+		if got, err := parseAdvancedTime(s, now); err != nil || got != want.UnixMilli() {
+			t.Errorf("parseAdvancedTime(%q) = %d, %v, want %d", s, got, err, want.UnixMilli())
+		}
+	}
+	if _, err := parseAdvancedTime("yesterday", now); err == nil {
+		t.Error("expected an error for an unsupported time")
+	}
+}
@@ -0,0 +1,145 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// DynatraceAlertingProfileStubs is the response of the alerting profiles list endpoint
+type DynatraceAlertingProfileStubs struct {
+	Values []DynatraceAlertingProfileStub `json:"values"`
+}
+
+type DynatraceAlertingProfileStub struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// DynatraceAlertingProfile is a single alerting profile of the configuration API
+type DynatraceAlertingProfile struct {
+	Id               string                         `json:"id"`
+	DisplayName      string                         `json:"displayName"`
+	ManagementZoneId *int64                         `json:"managementZoneId"`
+	Rules            []DynatraceAlertingProfileRule `json:"rules"`
+}
+
+type DynatraceAlertingProfileRule struct {
+	SeverityLevel  string                   `json:"severityLevel"`
+	DelayInMinutes int                      `json:"delayInMinutes"`
+	TagFilter      DynatraceAlertingTagRule `json:"tagFilter"`
+}
+
+type DynatraceAlertingTagRule struct {
+	IncludeMode string         `json:"includeMode"` // NONE, INCLUDE_ANY or INCLUDE_ALL
+	TagFilters  []DynatraceTag `json:"tagFilters"`
+}
+
+// handleAlertingProfiles serves GET /alertingProfiles, listing the configured
+// alerting profiles sorted by name.
+func (d *Datasource) handleAlertingProfiles(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var profiles DynatraceAlertingProfileStubs
+	if err := d.get(req.Context(), "/api/config/v1/alertingProfiles", nil, &profiles); err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	values := profiles.Values
+	if values == nil {
+		values = []DynatraceAlertingProfileStub{}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+
+	writeJSON(rw, http.StatusOK, values)
+}
+
+// fetchAlertingProfile returns an alerting profile including its rules.
+func (d *Datasource) fetchAlertingProfile(ctx context.Context, id string) (*DynatraceAlertingProfile, error) {
+	var profile DynatraceAlertingProfile
+	if err := d.get(ctx, "/api/config/v1/alertingProfiles/"+url.PathEscape(id), nil, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// problemSelector narrows a problem selector to the management zone of the profile.
+func (p *DynatraceAlertingProfile) problemSelector(selector string) string {
+	if p.ManagementZoneId == nil {
+		return selector
+	}
+	zone := fmt.Sprintf(`managementZoneIds("%d")`, *p.ManagementZoneId)
+	if selector == "" {
+		return zone
+	}
+	return selector + "," + zone
+}
+
+// matches reports whether the profile would alert on the problem as of now:
+// a rule for the problem's severity must exist, the problem must have lasted
+// at least the rule's delay and its entity tags must pass the tag filter.
+func (p *DynatraceAlertingProfile) matches(problem DynatraceProblem, now time.Time) bool {
+	for _, rule := range p.Rules {
+		if rule.SeverityLevel != problem.SeverityLevel {
+			continue
+		}
+
+		end := now
+		if problem.EndTime > 0 {
+			end = time.UnixMilli(problem.EndTime)
+		}
+		if end.Sub(time.UnixMilli(problem.StartTime)) < time.Duration(rule.DelayInMinutes)*time.Minute {
+			continue
+		}
+
+		if rule.TagFilter.matches(problem.EntityTags) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether tags satisfy the tag filter of a rule.
+func (r DynatraceAlertingTagRule) matches(tags []DynatraceTag) bool {
+	if len(r.TagFilters) == 0 {
+		return true
+	}
+
+	found := 0
+	for _, filter := range r.TagFilters {
+		for _, tag := range tags {
+			if tagMatches(filter, tag) {
+				found++
+				break
+			}
+		}
+	}
+
+	switch r.IncludeMode {
+	case "INCLUDE_ANY":
+		return found > 0
+	case "INCLUDE_ALL":
+		return found == len(r.TagFilters)
+	default:
+		return true
+	}
+}
+
+// tagMatches reports whether tag satisfies filter. Filters without a value
+// match any value of the key.
+func tagMatches(filter, tag DynatraceTag) bool {
+	if filter.Key != tag.Key {
+		return false
+	}
+	if filter.Context != "" && tag.Context != "" && filter.Context != tag.Context {
+		return false
+	}
+	return filter.Value == "" || filter.Value == tag.Value
+}
@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestHandleAlertingProfiles(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/config/v1/alertingProfiles" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		_, _ = rw.Write([]byte(`{"values": [{"id": "b", "name": "Payments"}, {"id": "a", "name": "Default"}]}`))
+	})
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/alertingProfiles", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var profiles []DynatraceAlertingProfileStub
+	if err := json.Unmarshal(rec.Body.Bytes(), &profiles); err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 || profiles[0].Name != "Default" {
+		t.Errorf("expected profiles sorted by name, got %v", profiles)
+	}
+}
+
+func TestAlertingProfileMatches(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	profile := DynatraceAlertingProfile{Rules: []DynatraceAlertingProfileRule{
+		{SeverityLevel: "AVAILABILITY"},
+		{SeverityLevel: "PERFORMANCE", DelayInMinutes: 30},
+		{SeverityLevel: "ERROR", TagFilter: DynatraceAlertingTagRule{
+			IncludeMode: "INCLUDE_ALL",
+			TagFilters:  []DynatraceTag{{Context: "CONTEXTLESS", Key: "team", Value: "payments"}, {Key: "prod"}},
+		}},
+	}}
+
+	started := func(minutesAgo int) int64 {
+		return now.Add(-time.Duration(minutesAgo) * time.Minute).UnixMilli()
+	}
+	tests := []struct {
+		name    string
+		problem DynatraceProblem
+		want    bool
+	}{
+		{"severity without rule", DynatraceProblem{SeverityLevel: "RESOURCE_CONTENTION", StartTime: started(60)}, false},
+		{"rule without delay", DynatraceProblem{SeverityLevel: "AVAILABILITY", StartTime: started(1)}, true},
+		{"delay not reached", DynatraceProblem{SeverityLevel: "PERFORMANCE", StartTime: started(10)}, false},
+		{"delay reached", DynatraceProblem{SeverityLevel: "PERFORMANCE", StartTime: started(45)}, true},
+		{"closed before delay", DynatraceProblem{SeverityLevel: "PERFORMANCE", StartTime: started(45), EndTime: started(40)}, false},
+		{"all tags present", DynatraceProblem{SeverityLevel: "ERROR", EntityTags: []DynatraceTag{
+			{Context: "CONTEXTLESS", Key: "team", Value: "payments"}, {Context: "CONTEXTLESS", Key: "prod"},
+		}}, true},
+		{"tag missing", DynatraceProblem{SeverityLevel: "ERROR", EntityTags: []DynatraceTag{
+			{Context: "CONTEXTLESS", Key: "team", Value: "payments"},
+		}}, false},
+	}
+	for _, tt := range tests {
+		if got := profile.matches(tt.problem, now); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueryProblemsByAlertingProfile(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/config/v1/alertingProfiles/profile-1":
+			_, _ = rw.Write([]byte(`{"id": "profile-1", "managementZoneId": 42, "rules": [{"severityLevel": "AVAILABILITY"}]}`))
+		case "/api/v2/problems":
+			if got := req.URL.Query().Get("problemSelector"); got != `status("open"),managementZoneIds("42")` {
+				t.Errorf("problemSelector = %q", got)
+			}
+			_, _ = rw.Write([]byte(`{"problems": [
+				{"problemId": "P-1", "severityLevel": "AVAILABILITY", "startTime": 1700000000000, "endTime": -1},
+				{"problemId": "P-2", "severityLevel": "PERFORMANCE", "startTime": 1700000000000, "endTime": -1}
+			]}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblems,
+		JSON:      []byte(`{"problemSelector": "status(\"open\")", "alertingProfile": "profile-1", "useDashboardTime": true}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if rows, _ := resp.Frames[0].RowLen(); rows != 1 {
+		t.Errorf("expected only the problem matching the profile, got %d rows", rows)
+	}
+}
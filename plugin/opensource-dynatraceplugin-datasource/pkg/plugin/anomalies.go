@@ -0,0 +1,154 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// anomalyWindow is the time an anomaly event raised by a metric event was
+// open, on an entity or, when entityId is empty, the whole metric.
+type anomalyWindow struct {
+	entityId string
+	start    int64
+	end      int64 // -1 while the event is open
+}
+
+// metricAnomalies holds the anomaly windows of a metric and the static
+// threshold of the metric event that raised them, if any.
+type metricAnomalies struct {
+	windows   []anomalyWindow
+	threshold *float64
+}
+
+// metricEventTypes maps the event types of metric event templates to the
+// event types of the events API.
+var metricEventTypes = map[string]string{
+	"AVAILABILITY": "AVAILABILITY_EVENT",
+	"CUSTOM_ALERT": "CUSTOM_ALERT",
+	"ERROR":        "ERROR_EVENT",
+	"INFO":         "CUSTOM_INFO",
+	"RESOURCE":     "RESOURCE_CONTENTION_EVENT",
+	"SLOWDOWN":     "PERFORMANCE_EVENT",
+}
+
+// fetchMetricAnomalies finds the enabled metric events defined on the metric
+// a selector starts with, and the events they raised in the time range.
+// Events are attributed to a metric event by the settings object ID in their
+// properties, falling back to the configured event title.
+func (d *Datasource) fetchMetricAnomalies(ctx context.Context, metricSelector string, fromMs, toMs int64) (*metricAnomalies, error) {
+	metricKey := selectorMetricKey.FindString(metricSelector)
+	if metricKey == "" {
+		return &metricAnomalies{}, nil
+	}
+
+	configs, err := d.fetchMetricEvents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	anomalies := &metricAnomalies{}
+	objectIds := map[string]bool{}
+	titles := map[string]bool{}
+	eventTypes := map[string]bool{}
+	allTypes := false
+	for _, c := range configs {
+		if !c.Enabled || selectorMetricKey.FindString(c.MetricSelector) != metricKey {
+			continue
+		}
+		objectIds[c.ObjectId] = true
+		if c.Title != "" {
+			titles[c.Title] = true
+		}
+		if eventType, ok := metricEventTypes[c.EventType]; ok {
+			eventTypes[eventType] = true
+		} else {
+			allTypes = true
+		}
+		if anomalies.threshold == nil && c.Model == "STATIC_THRESHOLD" {
+			anomalies.threshold = c.Threshold
+		}
+	}
+	if len(objectIds) == 0 {
+		return anomalies, nil
+	}
+
+	quoted := make([]string, 0, len(eventTypes))
+	for eventType := range eventTypes {
+		quoted = append(quoted, fmt.Sprintf("%q", eventType))
+	}
+	sort.Strings(quoted)
+	eventSelector := ""
+	if !allTypes {
+		eventSelector = fmt.Sprintf("eventType(%s)", strings.Join(quoted, ","))
+	}
+
+	events, err := d.fetchEvents(ctx, eventSelector, "", fromMs, toMs)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if !objectIds[e.property("dt.settings.object_id")] && !titles[e.Title] {
+			continue
+		}
+		window := anomalyWindow{start: e.StartTime, end: e.EndTime}
+		if e.EntityId != nil {
+			window.entityId = e.EntityId.EntityId.Id
+		}
+		anomalies.windows = append(anomalies.windows, window)
+	}
+	return anomalies, nil
+}
+
+// addAnomalyFields appends an "anomalous" field marking the times of a
+// series frame at which an anomaly was open on one of the series' entities,
+// and a "threshold" field when the metric event has a static threshold.
+func (a *metricAnomalies) addAnomalyFields(frame *data.Frame, dimensions map[string]string, name string, labels data.Labels) {
+	timeField, _ := frame.FieldByName("time")
+	if timeField == nil {
+		return
+	}
+
+	entities := map[string]bool{}
+	for _, value := range dimensions {
+		entities[value] = true
+	}
+	var windows []anomalyWindow
+	for _, w := range a.windows {
+		if w.entityId == "" || entities[w.entityId] {
+			windows = append(windows, w)
+		}
+	}
+
+	anomalous := make([]bool, timeField.Len())
+	for i := range anomalous {
+		t, ok := timeField.ConcreteAt(i)
+		if !ok {
+			continue
+		}
+		ts := t.(time.Time).UnixMilli()
+		for _, w := range windows {
+			if w.start <= ts && (w.end < 0 || ts < w.end) {
+				anomalous[i] = true
+				break
+			}
+		}
+	}
+	anomalousField := data.NewField("anomalous", labels, anomalous)
+	anomalousField.Config = &data.FieldConfig{DisplayNameFromDS: name + " (anomalous)"}
+	frame.Fields = append(frame.Fields, anomalousField)
+
+	if a.threshold != nil {
+		thresholds := make([]float64, timeField.Len())
+		for i := range thresholds {
+			thresholds[i] = *a.threshold
+		}
+		thresholdField := data.NewField("threshold", labels, thresholds)
+		thresholdField.Config = &data.FieldConfig{DisplayNameFromDS: name + " (threshold)"}
+		frame.Fields = append(frame.Fields, thresholdField)
+	}
+}
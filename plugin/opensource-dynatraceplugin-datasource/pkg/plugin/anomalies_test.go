@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryMetricsAnomalies(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v2/settings/objects":
+			_, _ = rw.Write([]byte(metricEventsResponse))
+		case "/api/v2/events":
+			if got := req.URL.Query().Get("eventSelector"); got != `eventType("RESOURCE_CONTENTION_EVENT")` {
+				t.Errorf("eventSelector = %q", got)
+			}
+			_, _ = rw.Write([]byte(`{"events": [
+				{"title": "CPU above 90%", "startTime": 120000, "endTime": 240000, "entityId": {"entityId": {"id": "HOST-1"}}},
+				{"title": "CPU above 90%", "startTime": 60000, "endTime": -1, "entityId": {"entityId": {"id": "HOST-2"}}},
+				{"title": "Unrelated", "startTime": 0, "endTime": -1}
+			]}`))
+		case "/api/v2/metrics/query":
+			_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+				{"dimensionMap": {"dt.entity.host": "HOST-1"}, "timestamps": [60000, 120000, 180000, 240000], "values": [50, 95, 97, 60]}
+			]}]}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		TimeRange: backend.TimeRange{From: time.UnixMilli(0), To: time.UnixMilli(300000)},
+		JSON:      []byte(`{"useDashboardTime": true, "metricSelector": "builtin:host.cpu.usage:splitBy(\"dt.entity.host\")", "resolution": "1m", "anomalies": true}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	anomalous, _ := frame.FieldByName("anomalous")
+	if anomalous == nil {
+		t.Fatal("expected an anomalous field")
+	}
+	want := []bool{false, true, true, false}
+	for i, w := range want {
+		if got := anomalous.At(i); got != w {
+			t.Errorf("anomalous[%d] = %v, want %v", i, got, w)
+		}
+	}
+	threshold, _ := frame.FieldByName("threshold")
+	if threshold == nil || threshold.At(0) != 90.0 {
+		t.Errorf("expected the static threshold of the metric event")
+	}
+}
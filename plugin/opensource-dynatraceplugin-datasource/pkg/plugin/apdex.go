@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Metrics of the "apdex" query type: the Apdex rating of a web application
+// and its user actions per Apdex category.
+const (
+	apdexMetric         = "builtin:apps.web.apdex.userType"
+	apdexCountMetric    = "builtin:apps.web.actionCount.category"
+	apdexCategoryDimKey = "Apdex category"
+)
+
+// apdexCategories are the Apdex categories in the order of their fields.
+var apdexCategories = []string{"satisfied", "tolerating", "frustrated"}
+
+// queryApdex returns the Apdex rating of a web application together with
+// its satisfied, tolerating and frustrated user action counts as a single
+// frame, instead of assembling the RUM selectors by hand.
+func (d *Datasource) queryApdex(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	if !strings.HasPrefix(qm.ApplicationId, "APPLICATION-") {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "applicationId must be a web application (APPLICATION-...)")
+	}
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	resolution := qm.Resolution
+	if resolution == "" {
+		resolution = "5m"
+	}
+
+	filter := fmt.Sprintf(`filter(eq("dt.entity.application",%q))`, qm.ApplicationId)
+	selector := fmt.Sprintf(`%s:%s:splitBy():avg,%s:%s:splitBy(%q):sum`, apdexMetric, filter, apdexCountMetric, filter, apdexCategoryDimKey)
+	resp, err := d.queryDynatraceAPI(ctx, selector, fromMs, toMs, resolution, nil)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace API: %v", err))
+	}
+
+	frame := apdexFrame(resp)
+	frame.Meta = &data.FrameMeta{ExecutedQueryString: selector}
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// apdexFrame joins the Apdex rating and the category counts of a response
+// on their timestamps.
+func apdexFrame(resp *DynatraceMetricsResponse) *data.Frame {
+	names := append([]string{"apdex"}, apdexCategories...)
+	columns := map[string]map[int64]float64{}
+	timestamps := map[int64]bool{}
+	add := func(column string, dataSet DynatraceMetricData) {
+		if columns[column] == nil {
+			columns[column] = map[int64]float64{}
+		}
+		for i, ts := range dataSet.Timestamps {
+			if i < len(dataSet.Values) && dataSet.Values[i] != nil {
+				columns[column][ts] = *dataSet.Values[i]
+				timestamps[ts] = true
+			}
+		}
+	}
+	for _, result := range resp.Result {
+		for _, dataSet := range result.Data {
+			if strings.HasPrefix(result.MetricId, apdexMetric) {
+				add("apdex", dataSet)
+			} else if strings.HasPrefix(result.MetricId, apdexCountMetric) {
+				add(strings.ToLower(dataSet.DimensionMap[apdexCategoryDimKey]), dataSet)
+			}
+		}
+	}
+
+	sorted := make([]int64, 0, len(timestamps))
+	for ts := range timestamps {
+		sorted = append(sorted, ts)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	times := make([]time.Time, len(sorted))
+	for i, ts := range sorted {
+		times[i] = time.UnixMilli(ts)
+	}
+
+	frame := data.NewFrame("apdex", data.NewField("time", nil, times))
+	for _, name := range names {
+		values := make([]*float64, len(sorted))
+		for i, ts := range sorted {
+			if v, ok := columns[name][ts]; ok {
+				values[i] = &v
+			}
+		}
+		field := data.NewField(name, nil, values)
+		if name == "apdex" {
+			field.Config = &data.FieldConfig{DisplayNameFromDS: "Apdex"}
+		}
+		frame.Fields = append(frame.Fields, field)
+	}
+	return frame
+}
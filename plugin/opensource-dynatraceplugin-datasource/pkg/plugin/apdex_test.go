@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryApdex(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		selector := req.URL.Query().Get("metricSelector")
+		if strings.Count(selector, `filter(eq("dt.entity.application","APPLICATION-1"))`) != 2 {
+			t.Errorf("selector %q should filter both metrics by the application", selector)
+		}
+		_, _ = rw.Write([]byte(`{"result": [
+			{"metricId": "builtin:apps.web.apdex.userType:filter(...)", "data": [
+				{"dimensionMap": {}, "timestamps": [60000, 120000], "values": [0.94, 0.81]}
+			]},
+			{"metricId": "builtin:apps.web.actionCount.category:filter(...)", "data": [
+				{"dimensionMap": {"Apdex category": "SATISFIED"}, "timestamps": [60000, 120000], "values": [90, 70]},
+				{"dimensionMap": {"Apdex category": "FRUSTRATED"}, "timestamps": [120000], "values": [12]}
+			]}
+		]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeApdex,
+		JSON:      []byte(`{"useDashboardTime": true, "applicationId": "APPLICATION-1"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	want := []string{"time", "apdex", "satisfied", "tolerating", "frustrated"}
+	for i, name := range want {
+		if frame.Fields[i].Name != name {
+			t.Errorf("field %d = %s, want %s", i, frame.Fields[i].Name, name)
+		}
+	}
+	if got := *frame.Fields[1].At(1).(*float64); got != 0.81 {
+		t.Errorf("apdex = %v, want 0.81", got)
+	}
+	if got := frame.Fields[4].At(0).(*float64); got != nil {
+		t.Errorf("frustrated without data = %v, want null", *got)
+	}
+	if got := *frame.Fields[4].At(1).(*float64); got != 12 {
+		t.Errorf("frustrated = %v, want 12", got)
+	}
+}
+
+func TestQueryApdexRequiresApplication(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s", req.URL)
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeApdex,
+		JSON:      []byte(`{"useDashboardTime": true, "applicationId": "SERVICE-1"}`),
+	})
+	if resp.Error == nil {
+		t.Fatal("expected an error for a non-application entity")
+	}
+}
@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// endpointScopes maps environment API path prefixes to the token scope they
+// require. The longest matching prefix wins, so ingest endpoints can require
+// a different scope than their read counterparts.
+var endpointScopes = map[string]string{
+	"/api/v2/metrics":                  "metrics.read",
+	"/api/v2/metrics/ingest":           "metrics.ingest",
+	"/api/v2/problems":                 "problems.read",
+	"/api/v2/logs":                     "logs.read",
+	"/api/v2/entities":                 "entities.read",
+	"/api/v2/events":                   "events.read",
+	"/api/v2/events/ingest":            "events.ingest",
+	"/api/v2/slo":                      "slo.read",
+	"/api/v2/releases":                 "releases.read",
+	"/api/v2/securityProblems":         "securityProblems.read",
+	"/api/v2/attacks":                  "attacks.read",
+	"/api/v2/activeGates":              "activeGates.read",
+	"/api/v2/networkZones":             "networkZones.read",
+	"/api/v2/networkZoneSettings":      "networkZones.read",
+	"/api/v2/settings":                 "settings.read",
+	"/api/v2/synthetic/locations":      "syntheticLocations.read",
+	"/api/v1/userSessionQueryLanguage": "DTAQLAccess",
+}
+
+// endpointScope returns the token scope required by an environment API path,
+// or an empty string for unknown endpoints.
+func endpointScope(path string) string {
+	scope, matched := "", ""
+	for prefix, s := range endpointScopes {
+		if len(prefix) <= len(matched) || !strings.HasPrefix(path, prefix) {
+			continue
+		}
+		// Match whole path segments only
+		if rest := path[len(prefix):]; rest != "" && rest[0] != '/' {
+			continue
+		}
+		scope, matched = s, prefix
+	}
+	return scope
+}
+
+// authErrorMessage explains a 401 or 403 response, naming the scope the
+// endpoint requires instead of echoing the response body. It returns an empty
+// string for other responses.
+func (e *apiError) authErrorMessage() string {
+	switch e.StatusCode {
+	case http.StatusUnauthorized:
+		return "token was rejected by Dynatrace (status 401); check that the token is valid and not expired"
+	case http.StatusForbidden:
+		if e.scope == "" {
+			return ""
+		}
+		return fmt.Sprintf("token is missing scope %s (status 403); add it to the API token", e.scope)
+	default:
+		return ""
+	}
+}
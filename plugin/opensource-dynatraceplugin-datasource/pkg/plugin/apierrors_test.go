@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestEndpointScope(t *testing.T) {
+	tests := map[string]string{
+		"/api/v2/metrics/query":        "metrics.read",
+		"/api/v2/metrics/ingest":       "metrics.ingest",
+		"/api/v2/problems/P-1":         "problems.read",
+		"/api/v2/logs/search":          "logs.read",
+		"/api/v2/entities":             "entities.read",
+		"/api/v2/networkZoneSettings":  "networkZones.read",
+		"/api/v2/apiTokens/lookup":     "",
+		"/api/v2/metricsUnknownFuture": "",
+	}
+	for path, want := range tests {
+		if got := endpointScope(path); got != want {
+			t.Errorf("endpointScope(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestQueryForbiddenNamesScope(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusForbidden)
+		_, _ = rw.Write([]byte(`{"error": {"code": 403, "message": "Token is missing required scope."}}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeLogs,
+		JSON:      []byte(`{"logQuery": "error"}`),
+	})
+	if resp.Error == nil {
+		t.Fatal("expected an error")
+	}
+	want := "token is missing scope logs.read (status 403); add it to the API token"
+	if got := resp.Error.Error(); !strings.Contains(got, want) {
+		t.Errorf("error = %q, want it to contain %q", got, want)
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  apiError
+		want string
+	}{
+		{apiError{StatusCode: http.StatusUnauthorized, Body: "invalid"}, "token was rejected by Dynatrace (status 401); check that the token is valid and not expired"},
+		{apiError{StatusCode: http.StatusForbidden, Body: "denied", scope: "problems.read"}, "token is missing scope problems.read (status 403); add it to the API token"},
+		{apiError{StatusCode: http.StatusForbidden, Body: "denied"}, "Dynatrace API returned status 403: denied"},
+		{apiError{StatusCode: http.StatusBadRequest, Body: "bad", scope: "metrics.read"}, "Dynatrace API returned status 400: bad"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// API versions a query can target with its apiVersion field.
+const (
+	// apiVersionV2 uses the environment API v2 with the API token (default)
+	apiVersionV2 = "v2"
+
+	// apiVersionPlatform sends environment API requests through the classic
+	// environment API of the Dynatrace platform, authenticated with the
+	// platform token
+	apiVersionPlatform = "platform"
+)
+
+// platformEnvironmentApiPath is the prefix of the environment API on the
+// Dynatrace platform.
+const platformEnvironmentApiPath = "/platform/classic/environment-api"
+
+type apiVersionKey struct{}
+
+// validateApiVersion checks the apiVersion of a query, returning it with the
+// default applied.
+func validateApiVersion(apiVersion string) (string, error) {
+	switch apiVersion {
+	case "", apiVersionV2:
+		return apiVersionV2, nil
+	case apiVersionPlatform:
+		return apiVersionPlatform, nil
+	case "v1":
+		return "", fmt.Errorf("apiVersion v1 is not supported: the v1 endpoints return different data, use %q or %q", apiVersionV2, apiVersionPlatform)
+	default:
+		return "", fmt.Errorf("invalid apiVersion %q: must be %q or %q", apiVersion, apiVersionV2, apiVersionPlatform)
+	}
+}
+
+// withApiVersion makes environment API requests made with ctx target the
+// given API version.
+func withApiVersion(ctx context.Context, apiVersion string) context.Context {
+	return context.WithValue(ctx, apiVersionKey{}, apiVersion)
+}
+
+// apiVersionFromContext returns the API version of ctx, v2 by default.
+func apiVersionFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(apiVersionKey{}).(string); ok {
+		return v
+	}
+	return apiVersionV2
+}
+
+// platformApiPath maps an environment API path to its platform equivalent,
+// reporting false for paths the platform doesn't serve.
+func platformApiPath(path string) (string, bool) {
+	if !strings.HasPrefix(path, "/api/v2/") {
+		return "", false
+	}
+	return platformEnvironmentApiPath + strings.TrimPrefix(path, "/api"), true
+}
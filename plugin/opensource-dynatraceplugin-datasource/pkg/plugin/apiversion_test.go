@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryPlatformApiVersion(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/platform/classic/environment-api/v2/problems" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer platform-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		_, _ = rw.Write([]byte(`{"problems": [{"problemId": "P-1"}]}`))
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblems,
+		JSON:      []byte(`{"apiVersion": "platform"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+}
+
+func TestValidateApiVersion(t *testing.T) {
+	for apiVersion, valid := range map[string]bool{"": true, "v2": true, "platform": true, "v1": false, "v3": false} {
+		if _, err := validateApiVersion(apiVersion); (err == nil) != valid {
+			t.Errorf("validateApiVersion(%q) error = %v", apiVersion, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// attackFields are the optional attack fields requested from the attacks API.
+const attackFields = "+attacker,+request,+entrypoint,+vulnerability"
+
+// DynatraceAttacksResponse represents a page of /api/v2/attacks
+type DynatraceAttacksResponse struct {
+	TotalCount  int               `json:"totalCount"`
+	NextPageKey *string           `json:"nextPageKey"`
+	Attacks     []DynatraceAttack `json:"attacks"`
+}
+
+type DynatraceAttack struct {
+	AttackId   string `json:"attackId"`
+	DisplayId  string `json:"displayId"`
+	Type       string `json:"attackType"`
+	State      string `json:"state"`
+	Timestamp  int64  `json:"timestamp"`
+	Technology string `json:"technology"`
+	Attacker   struct {
+		SourceIp string `json:"sourceIp"`
+		Location struct {
+			Country string `json:"country"`
+			City    string `json:"city"`
+		} `json:"location"`
+	} `json:"attacker"`
+	Request struct {
+		Url           string `json:"url"`
+		RequestMethod string `json:"requestMethod"`
+	} `json:"request"`
+	Entrypoint struct {
+		EntrypointFunction struct {
+			ClassName    string `json:"className"`
+			FunctionName string `json:"functionName"`
+		} `json:"entrypointFunction"`
+	} `json:"entrypoint"`
+	Vulnerability struct {
+		VulnerabilityId string `json:"vulnerabilityId"`
+		DisplayName     string `json:"displayName"`
+	} `json:"vulnerability"`
+}
+
+// entryPoint returns the function through which the attack entered the
+// application, e.g. "com.shop.CartServlet.doPost".
+func (a DynatraceAttack) entryPoint() string {
+	fn := a.Entrypoint.EntrypointFunction
+	if fn.ClassName == "" {
+		return fn.FunctionName
+	}
+	return fn.ClassName + "." + fn.FunctionName
+}
+
+// queryAttacks lists the application attacks detected in the query time
+// range as a table, newest first as returned by the API.
+func (d *Datasource) queryAttacks(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	attacks, err := d.fetchAttacks(ctx, qm.AttackSelector, fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace attacks: %v", err))
+	}
+
+	frame := attacksFrame(attacks)
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		ExecutedQueryString:    fmt.Sprintf("Attacks: %s", qm.AttackSelector),
+	}
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// fetchAttacks walks all pages of /api/v2/attacks for the given time range.
+func (d *Datasource) fetchAttacks(ctx context.Context, attackSelector string, fromMs, toMs int64) ([]DynatraceAttack, error) {
+	params := url.Values{}
+	params.Set("from", fmt.Sprintf("%d", fromMs))
+	params.Set("to", fmt.Sprintf("%d", toMs))
+	params.Set("pageSize", "500")
+	params.Set("fields", attackFields)
+	if attackSelector != "" {
+		params.Set("attackSelector", attackSelector)
+	}
+
+	log.DefaultLogger.Debug("Querying Dynatrace attacks", "attackSelector", d.logPayload(attackSelector), "from", fromMs, "to", toMs)
+
+	var attacks []DynatraceAttack
+	err := d.getAllPages(ctx, "/api/v2/attacks", params, func(body []byte) error {
+		var page DynatraceAttacksResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		attacks = append(attacks, page.Attacks...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attacks, nil
+}
+
+// attacksFrame converts attacks into a table with one row per attack.
+func attacksFrame(attacks []DynatraceAttack) *data.Frame {
+	frame := data.NewFrame("attacks",
+		data.NewField("time", nil, []time.Time{}),
+		data.NewField("displayId", nil, []string{}),
+		data.NewField("type", nil, []string{}),
+		data.NewField("status", nil, []string{}),
+		data.NewField("sourceIp", nil, []string{}),
+		data.NewField("country", nil, []string{}),
+		data.NewField("entryPoint", nil, []string{}),
+		data.NewField("url", nil, []string{}),
+		data.NewField("technology", nil, []string{}),
+		data.NewField("vulnerability", nil, []string{}),
+		data.NewField("attackId", nil, []string{}),
+	)
+
+	for _, a := range attacks {
+		frame.AppendRow(
+			time.UnixMilli(a.Timestamp),
+			a.DisplayId,
+			a.Type,
+			a.State,
+			a.Attacker.SourceIp,
+			a.Attacker.Location.Country,
+			a.entryPoint(),
+			a.Request.Url,
+			a.Technology,
+			a.Vulnerability.DisplayName,
+			a.AttackId,
+		)
+	}
+
+	return frame
+}
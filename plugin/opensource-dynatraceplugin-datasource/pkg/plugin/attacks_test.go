@@ -0,0 +1,52 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryAttacks(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/attacks" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+			return
+		}
+		query := req.URL.Query()
+		if query.Get("attackSelector") != `state("EXPLOITED")` || query.Get("from") == "" || query.Get("fields") != attackFields {
+			t.Errorf("unexpected parameters %v", query)
+		}
+		_, _ = rw.Write([]byte(`{"attacks": [{
+			"attackId": "A1", "displayId": "A-1", "attackType": "SQL_INJECTION", "state": "EXPLOITED", "timestamp": 1000,
+			"attacker": {"sourceIp": "203.0.113.7", "location": {"country": "Portugal"}},
+			"request": {"url": "https://shop/cart"},
+			"entrypoint": {"entrypointFunction": {"className": "com.shop.CartServlet", "functionName": "doPost"}}
+		}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeAttacks,
+		JSON:      []byte(`{"useDashboardTime": true, "attackSelector": "state(\"EXPLOITED\")"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	frame := resp.Frames[0]
+	if rows, _ := frame.RowLen(); rows != 1 {
+		t.Fatalf("expected 1 attack, got %d", rows)
+	}
+	for name, want := range map[string]string{
+		"type":       "SQL_INJECTION",
+		"sourceIp":   "203.0.113.7",
+		"entryPoint": "com.shop.CartServlet.doPost",
+		"status":     "EXPLOITED",
+	} {
+		field, _ := frame.FieldByName(name)
+		if got := field.At(0); got != want {
+			t.Errorf("%s = %v, want %s", name, got, want)
+		}
+	}
+}
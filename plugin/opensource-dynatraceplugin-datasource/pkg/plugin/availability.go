@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// availabilityUp is the state of entities without an active availability event.
+const availabilityUp = "up"
+
+// availabilityStates maps availability event types to the state of their
+// entity while the event is active.
+var availabilityStates = map[string]string{
+	"AVAILABILITY_EVENT":          "down",
+	"HOST_CONNECTION_FAILED":      "down",
+	"HOST_CONNECTION_LOST":        "down",
+	"HOST_NO_CONNECTION":          "down",
+	"HOST_TIMEOUT":                "down",
+	"HOST_OF_SERVICE_UNAVAILABLE": "down",
+	"PROCESS_CRASHED":             "down",
+	"HOST_SHUTDOWN":               "shutdown",
+	"HOST_GRACEFULLY_SHUTDOWN":    "shutdown",
+	"HOST_MAINTENANCE":            "maintenance",
+	"MONITORING_UNAVAILABLE":      "unmonitored",
+}
+
+// availabilityPriority orders states when several events overlap; the most
+// severe state wins.
+var availabilityPriority = map[string]int{"down": 4, "shutdown": 3, "maintenance": 2, "unmonitored": 1}
+
+// availabilityEventSelector selects the event types of availabilityStates.
+func availabilityEventSelector() string {
+	types := make([]string, 0, len(availabilityStates))
+	for eventType := range availabilityStates {
+		types = append(types, fmt.Sprintf("%q", eventType))
+	}
+	sort.Strings(types)
+	return fmt.Sprintf("eventType(%s)", strings.Join(types, ","))
+}
+
+// queryAvailability returns the availability state of the selected entities
+// over time as a wide frame for the state timeline panel, with one field per
+// entity that is "up" unless an availability event was active.
+func (d *Datasource) queryAvailability(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	if qm.EntitySelector == "" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "entitySelector is required")
+	}
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	entities, err := d.fetchEntities(ctx, qm.EntitySelector, "", fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace entities: %v", err))
+	}
+	events, err := d.fetchEvents(ctx, availabilityEventSelector(), qm.EntitySelector, fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace events: %v", err))
+	}
+
+	frame := availabilityFrame(entities, events, fromMs, toMs)
+	frame.Meta = &data.FrameMeta{ExecutedQueryString: fmt.Sprintf("Availability: %s", qm.EntitySelector)}
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// availabilityFrame computes the state of every entity at the start of the
+// range and at each start and end of its events.
+func availabilityFrame(entities []DynatraceEntity, events []DynatraceEvent, fromMs, toMs int64) *data.Frame {
+	sort.Slice(entities, func(i, j int) bool { return entities[i].DisplayName < entities[j].DisplayName })
+
+	byEntity := map[string][]DynatraceEvent{}
+	timestampSet := map[int64]bool{fromMs: true}
+	for _, e := range events {
+		if e.EntityId == nil || availabilityStates[e.EventType] == "" {
+			continue
+		}
+		id := e.EntityId.EntityId.Id
+		byEntity[id] = append(byEntity[id], e)
+		if e.StartTime > fromMs && e.StartTime <= toMs {
+			timestampSet[e.StartTime] = true
+		}
+		if e.EndTime > fromMs && e.EndTime <= toMs {
+			timestampSet[e.EndTime] = true
+		}
+	}
+
+	timestamps := make([]int64, 0, len(timestampSet))
+	for ts := range timestampSet {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	times := make([]time.Time, len(timestamps))
+	for i, ts := range timestamps {
+		times[i] = time.UnixMilli(ts)
+	}
+
+	frame := data.NewFrame("availability", data.NewField("time", nil, times))
+	for _, entity := range entities {
+		states := make([]string, len(timestamps))
+		for i, ts := range timestamps {
+			states[i] = availabilityAt(byEntity[entity.EntityId], ts)
+		}
+		name := entity.DisplayName
+		if name == "" {
+			name = entity.EntityId
+		}
+		field := data.NewField("state", data.Labels{"entityId": entity.EntityId}, states)
+		field.Config = &data.FieldConfig{DisplayNameFromDS: name}
+		frame.Fields = append(frame.Fields, field)
+	}
+	return frame
+}
+
+// availabilityAt returns the most severe state of the events active at ts.
+// Open events report an end time of -1.
+func availabilityAt(events []DynatraceEvent, ts int64) string {
+	state := availabilityUp
+	for _, e := range events {
+		if e.StartTime > ts || (e.EndTime > 0 && e.EndTime <= ts) {
+			continue
+		}
+		if s := availabilityStates[e.EventType]; availabilityPriority[s] > availabilityPriority[state] {
+			state = s
+		}
+	}
+	return state
+}
@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryAvailability(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v2/entities":
+			_, _ = rw.Write([]byte(`{"entities": [
+				{"entityId": "HOST-2", "displayName": "web-2"},
+				{"entityId": "HOST-1", "displayName": "web-1"}
+			]}`))
+		case "/api/v2/events":
+			if !strings.Contains(req.URL.Query().Get("eventSelector"), `"HOST_MAINTENANCE"`) {
+				t.Errorf("eventSelector = %q", req.URL.Query().Get("eventSelector"))
+			}
+			_, _ = rw.Write([]byte(`{"events": [
+				{"eventType": "HOST_MAINTENANCE", "startTime": 2000, "endTime": 6000, "entityId": {"entityId": {"id": "HOST-1"}}},
+				{"eventType": "HOST_CONNECTION_LOST", "startTime": 3000, "endTime": 4000, "entityId": {"entityId": {"id": "HOST-1"}}},
+				{"eventType": "HOST_SHUTDOWN", "startTime": 5000, "endTime": -1, "entityId": {"entityId": {"id": "HOST-2"}}}
+			]}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeAvailability,
+		TimeRange: backend.TimeRange{From: time.UnixMilli(1000), To: time.UnixMilli(10000)},
+		JSON:      []byte(`{"useDashboardTime": true, "entitySelector": "type(HOST)"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	if len(frame.Fields) != 3 {
+		t.Fatalf("expected time and a field per host, got %d fields", len(frame.Fields))
+	}
+	if frame.Fields[1].Labels["entityId"] != "HOST-1" {
+		t.Errorf("expected hosts sorted by name, got %v first", frame.Fields[1].Labels)
+	}
+
+	// Timestamps: 1000, 2000, 3000, 4000, 5000, 6000
+	want := map[string][]string{
+		"HOST-1": {"up", "maintenance", "down", "maintenance", "maintenance", "up"},
+		"HOST-2": {"up", "up", "up", "up", "shutdown", "shutdown"},
+	}
+	for _, field := range frame.Fields[1:] {
+		expected := want[field.Labels["entityId"]]
+		if field.Len() != len(expected) {
+			t.Fatalf("%s has %d states, want %d", field.Labels["entityId"], field.Len(), len(expected))
+		}
+		for i, state := range expected {
+			if got := field.At(i); got != state {
+				t.Errorf("%s state %d = %v, want %s", field.Labels["entityId"], i, got, state)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Reference weeks of the expected range: the same hours of the preceding
+// weeks are treated as normal behavior.
+const (
+	defaultBaselineWeeks = 4
+	maxBaselineWeeks     = 12
+)
+
+// baselineDeviations is the width of the expected range in standard deviations.
+const baselineDeviations = 2
+
+const week = 7 * 24 * time.Hour
+
+// baselineReferences maps series, keyed by metric ID and dimensionKey, to
+// the values seen at each timestamp in the reference weeks, shifted into
+// the queried range.
+type baselineReferences map[string]map[int64][]float64
+
+// fetchWeekOverWeekReferences queries the selector over each of the preceding
+// weeks for a week-over-week band. This is not the Davis baseline: the
+// metrics API doesn't expose Davis baselines or forecasts, so the expected
+// range is the spread of the metric at the same time of week in its own
+// history. Each request gets its own shifted range; from, to and resolution
+// in extra are never sent, see queryMetricsEndpoint. Null buckets of a
+// reference week don't count as a reference value. A notice tells the user
+// what the band is and what it cost in API calls.
+func (d *Datasource) fetchWeekOverWeekReferences(ctx context.Context, metricSelector string, fromMs, toMs int64, weeks int, resolution string, extra url.Values) (baselineReferences, error) {
+	if weeks <= 0 {
+		weeks = defaultBaselineWeeks
+	}
+
+	references := baselineReferences{}
+	for k := 1; k <= weeks; k++ {
+		shift := int64(k) * week.Milliseconds()
+		resp, err := d.queryDynatraceAPI(ctx, metricSelector, fromMs-shift, toMs-shift, resolution, extra)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range resp.Result {
+			for _, dataSet := range result.Data {
+				key := result.MetricId + "|" + dimensionKey(dataSet.DimensionMap)
+				if references[key] == nil {
+					references[key] = map[int64][]float64{}
+				}
+				for i, ts := range dataSet.Timestamps {
+					if i < len(dataSet.Values) && dataSet.Values[i] != nil {
+						references[key][ts+shift] = append(references[key][ts+shift], *dataSet.Values[i])
+					}
+				}
+			}
+		}
+	}
+
+	addQueryNotice(ctx, data.Notice{
+		Severity: data.NoticeSeverityInfo,
+		Text: fmt.Sprintf("The baseline is a week-over-week band (mean ± %d standard deviations of the same time in the preceding %d weeks), not the Davis baseline; it took %d extra metrics API queries",
+			baselineDeviations, weeks, weeks),
+	})
+	return references, nil
+}
+
+// addBaselineFields appends the lower and upper bound of the expected range
+// at each time of a series frame. Bounds are null where fewer than two
+// reference weeks have data.
+func (r baselineReferences) addBaselineFields(frame *data.Frame, metricId string, dimensions map[string]string, name string, labels data.Labels) {
+	timeField, _ := frame.FieldByName("time")
+	if timeField == nil {
+		return
+	}
+	history := r[metricId+"|"+dimensionKey(dimensions)]
+
+	lower := make([]*float64, timeField.Len())
+	upper := make([]*float64, timeField.Len())
+	for i := 0; i < timeField.Len(); i++ {
+		t, ok := timeField.ConcreteAt(i)
+		if !ok {
+			continue
+		}
+		values := history[t.(time.Time).UnixMilli()]
+		if len(values) < 2 {
+			continue
+		}
+		mean, deviation := meanDeviation(values)
+		lo, hi := mean-baselineDeviations*deviation, mean+baselineDeviations*deviation
+		lower[i], upper[i] = &lo, &hi
+	}
+
+	lowerField := data.NewField("lower", labels, lower)
+	lowerField.Config = &data.FieldConfig{DisplayNameFromDS: name + " (week-over-week lower)"}
+	upperField := data.NewField("upper", labels, upper)
+	upperField.Config = &data.FieldConfig{DisplayNameFromDS: name + " (week-over-week upper)"}
+	frame.Fields = append(frame.Fields, lowerField, upperField)
+}
+
+// meanDeviation returns the mean and population standard deviation of values.
+func meanDeviation(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
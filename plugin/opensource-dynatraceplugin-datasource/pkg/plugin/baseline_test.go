@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryMetricsBaseline(t *testing.T) {
+	from := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	// Value reported for a series at the start of the range, per week shifted back
+	// and null for a week without data
+	weekValues := map[int64]string{0: "50", 1: "10", 2: "20", 3: "30", 4: "null"}
+
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		queryFrom, _ := strconv.ParseInt(req.URL.Query().Get("from"), 10, 64)
+		weeks := (from.UnixMilli() - queryFrom) / week.Milliseconds()
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {"dt.entity.host": "HOST-1"}, "timestamps": [` + strconv.FormatInt(queryFrom, 10) + `], "values": [` + weekValues[weeks] + `]}
+		]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		TimeRange: backend.TimeRange{From: from, To: to},
+		JSON:      []byte(`{"useDashboardTime": true, "metricSelector": "builtin:host.cpu.usage", "resolution": "1h", "baseline": true, "baselineWeeks": 4}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	lower, _ := frame.FieldByName("lower")
+	upper, _ := frame.FieldByName("upper")
+	if lower == nil || upper == nil {
+		t.Fatalf("expected lower and upper fields, got %d fields", len(frame.Fields))
+	}
+	// Weeks 10, 20, 30, ignoring the null week: mean 20 and standard deviation ~8.165
+	lo, hi := *lower.At(0).(*float64), *upper.At(0).(*float64)
+	if lo > 3.7 || lo < 3.6 || hi < 36.3 || hi > 36.4 {
+		t.Errorf("expected range = [%v, %v], want about [3.67, 36.33]", lo, hi)
+	}
+
+	var described bool
+	for _, notice := range frame.Meta.Notices {
+		described = described || strings.Contains(notice.Text, "week-over-week band") && strings.Contains(notice.Text, "4 extra metrics API queries")
+	}
+	if !described {
+		t.Errorf("expected a notice describing the week-over-week band, got %+v", frame.Meta.Notices)
+	}
+}
+
+func TestQueryMetricsBaselineWeeksLimit(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "m", "data": [{"timestamps": [1], "values": [1]}]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"useDashboardTime": true, "metricSelector": "m", "baseline": true, "baselineWeeks": 13}`),
+	})
+	if resp.Status != backend.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.Status)
+	}
+}
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// defaultBillingResolution is used for billing queries without an explicit
+// resolution; consumption is reported hourly, so finer buckets add no detail.
+const defaultBillingResolution = "1h"
+
+// billingPreset describes a curated consumption query over builtin:billing metrics.
+type billingPreset struct {
+	// metricKeys are queried together; each key becomes its own series.
+	metricKeys []string
+	// splitBy is the dimension consumption is broken down by, if any.
+	splitBy string
+	// labelChart is the dimension used for the legend of split series.
+	labelChart string
+}
+
+// billingPresets maps the billingPreset query field to its metric selector.
+var billingPresets = map[string]billingPreset{
+	"ddu": {
+		metricKeys: []string{
+			"builtin:billing.ddu.metrics.total",
+			"builtin:billing.ddu.log.total",
+			"builtin:billing.ddu.events.total",
+			"builtin:billing.ddu.serverless.total",
+			"builtin:billing.ddu.traces.total",
+		},
+	},
+	"ddu.metrics.byEntity": {
+		metricKeys: []string{"builtin:billing.ddu.metrics.byEntity"},
+		splitBy:    "dt.entity.monitored_entity",
+		labelChart: "dt.entity.monitored_entity.name",
+	},
+	"ddu.metrics.byMetric": {
+		metricKeys: []string{"builtin:billing.ddu.metrics.byMetric"},
+		splitBy:    "metric_key",
+		labelChart: "metric_key",
+	},
+	"dem.synthetic": {
+		metricKeys: []string{"builtin:billing.synthetic.actions"},
+		splitBy:    "dt.entity.synthetic_test",
+		labelChart: "dt.entity.synthetic_test.name",
+	},
+	"dem.http": {
+		metricKeys: []string{"builtin:billing.synthetic.requests"},
+		splitBy:    "dt.entity.http_check",
+		labelChart: "dt.entity.http_check.name",
+	},
+	"dem.web": {
+		metricKeys: []string{
+			"builtin:billing.apps.web.sessionsWithoutReplayByApplication",
+			"builtin:billing.apps.web.sessionsWithReplayByApplication",
+		},
+		splitBy:    "dt.entity.application",
+		labelChart: "dt.entity.application.name",
+	},
+}
+
+// defaultBillingLimit caps the number of series returned for split presets.
+const defaultBillingLimit = 20
+
+// queryBilling runs a curated consumption query by translating the selected
+// preset into a metric selector and executing it as a metrics query.
+func (d *Datasource) queryBilling(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	selector, err := billingSelector(qm.BillingPreset, qm.BillingLimit)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	qm.MetricSelector = selector
+	if qm.LabelChart == "" {
+		qm.LabelChart = billingPresets[qm.BillingPreset].labelChart
+	}
+	if qm.Resolution == "" {
+		qm.Resolution = defaultBillingResolution
+	}
+
+	return d.queryMetrics(ctx, query, qm)
+}
+
+// billingSelector builds the metric selector for a billing preset. Split
+// presets are sorted by consumption and limited to the top series.
+func billingSelector(preset string, limit int) (string, error) {
+	p, ok := billingPresets[preset]
+	if !ok {
+		names := make([]string, 0, len(billingPresets))
+		for name := range billingPresets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown billing preset %q, expected one of: %s", preset, strings.Join(names, ", "))
+	}
+
+	if limit <= 0 {
+		limit = defaultBillingLimit
+	}
+
+	selectors := make([]string, 0, len(p.metricKeys))
+	for _, key := range p.metricKeys {
+		if p.splitBy == "" {
+			selectors = append(selectors, key+":splitBy()")
+			continue
+		}
+		selectors = append(selectors, fmt.Sprintf(`%s:splitBy("%s"):sort(value(sum,descending)):limit(%d):names`, key, p.splitBy, limit))
+	}
+
+	return strings.Join(selectors, ","), nil
+}
@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"testing"
+)
+
+func TestBillingSelector(t *testing.T) {
+	tests := []struct {
+		preset string
+		limit  int
+		want   string
+	}{
+		{
+			preset: "ddu.metrics.byMetric",
+			want:   `builtin:billing.ddu.metrics.byMetric:splitBy("metric_key"):sort(value(sum,descending)):limit(20):names`,
+		},
+		{
+			preset: "dem.http",
+			limit:  5,
+			want:   `builtin:billing.synthetic.requests:splitBy("dt.entity.http_check"):sort(value(sum,descending)):limit(5):names`,
+		},
+		{
+			preset: "ddu",
+			want:   "builtin:billing.ddu.metrics.total:splitBy(),builtin:billing.ddu.log.total:splitBy(),builtin:billing.ddu.events.total:splitBy(),builtin:billing.ddu.serverless.total:splitBy(),builtin:billing.ddu.traces.total:splitBy()",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := billingSelector(tt.preset, tt.limit)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.preset, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.preset, got, tt.want)
+		}
+	}
+
+	if _, err := billingSelector("unknown", 0); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+}
@@ -0,0 +1,156 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// bizEventsAggregations are the aggregations supported by bizevents queries.
+var bizEventsAggregations = map[string]bool{"count": true, "sum": true, "avg": true, "min": true, "max": true}
+
+// dqlFieldName matches field names that can be inserted into DQL templates.
+var dqlFieldName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// minBizEventsInterval is the smallest time bucket of bizevents series.
+const minBizEventsInterval = time.Minute
+
+// queryBizEvents aggregates Grail business events with a DQL template, e.g.
+// the count of "com.shop.order" events or the sum of their "revenue" field,
+// optionally split by a field. The result is a series per group, or a table
+// when the format is "table".
+func (d *Datasource) queryBizEvents(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	interval := query.Interval
+	if interval < minBizEventsInterval {
+		interval = minBizEventsInterval
+	}
+	dql, err := bizEventsDQL(qm, interval)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	result, err := d.executeDQL(ctx, dql, fromMs, toMs, dqlOptions{})
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace business events: %v", err))
+	}
+
+	var frames data.Frames
+	if qm.BizFormat == "table" {
+		frames = data.Frames{dqlFrame(result.Records)}
+	} else {
+		frames = bizEventsSeries(result.Records, qm.BizGroupBy)
+	}
+	for _, frame := range frames {
+		frame.Meta = &data.FrameMeta{ExecutedQueryString: dql}
+	}
+	return backend.DataResponse{Frames: frames}
+}
+
+// bizEventsDQL builds the DQL of a bizevents query.
+func bizEventsDQL(qm queryModel, interval time.Duration) (string, error) {
+	aggregation := qm.BizAggregation
+	if aggregation == "" {
+		aggregation = "count"
+	}
+	if !bizEventsAggregations[aggregation] {
+		return "", fmt.Errorf("invalid aggregation %q", aggregation)
+	}
+	if aggregation != "count" && !dqlFieldName.MatchString(qm.BizField) {
+		return "", fmt.Errorf("a valid field is required for the %s aggregation", aggregation)
+	}
+	if qm.BizGroupBy != "" && !dqlFieldName.MatchString(qm.BizGroupBy) {
+		return "", fmt.Errorf("invalid group by field %q", qm.BizGroupBy)
+	}
+	if qm.BizFormat != "" && qm.BizFormat != "table" && qm.BizFormat != "timeseries" {
+		return "", fmt.Errorf("invalid format %q: must be \"timeseries\" or \"table\"", qm.BizFormat)
+	}
+
+	var b strings.Builder
+	b.WriteString("fetch bizevents")
+	if qm.BizEventType != "" {
+		fmt.Fprintf(&b, "\n| filter event.type == %s", dqlString(qm.BizEventType))
+	}
+	if strings.TrimSpace(qm.BizFilter) != "" {
+		fmt.Fprintf(&b, "\n| filter %s", qm.BizFilter)
+	}
+
+	value := "count()"
+	if aggregation != "count" {
+		value = fmt.Sprintf("%s(%s)", aggregation, qm.BizField)
+	}
+	var by []string
+	if qm.BizFormat != "table" {
+		by = append(by, fmt.Sprintf("time = bin(timestamp, %ds)", int(interval.Seconds())))
+	}
+	if qm.BizGroupBy != "" {
+		by = append(by, qm.BizGroupBy)
+	}
+	fmt.Fprintf(&b, "\n| summarize value = %s", value)
+	if len(by) > 0 {
+		fmt.Fprintf(&b, ", by: {%s}", strings.Join(by, ", "))
+	}
+	if qm.BizFormat != "table" {
+		b.WriteString("\n| sort time asc")
+	}
+	return b.String(), nil
+}
+
+// bizEventsSeries converts binned bizevents records into a series per group.
+func bizEventsSeries(records []map[string]interface{}, groupBy string) data.Frames {
+	type series struct {
+		times  []time.Time
+		values []float64
+	}
+	groups := map[string]*series{}
+	for _, record := range records {
+		t, ok := recordTime(record, "time")
+		if !ok {
+			continue
+		}
+		value, ok := record["value"].(float64)
+		if !ok {
+			continue
+		}
+		group := ""
+		if groupBy != "" {
+			group = recordString(record, groupBy)
+		}
+		s := groups[group]
+		if s == nil {
+			s = &series{}
+			groups[group] = s
+		}
+		s.times = append(s.times, t)
+		s.values = append(s.values, value)
+	}
+
+	names := make([]string, 0, len(groups))
+	for group := range groups {
+		names = append(names, group)
+	}
+	sort.Strings(names)
+
+	frames := make(data.Frames, 0, len(groups))
+	for _, group := range names {
+		var labels data.Labels
+		if groupBy != "" {
+			labels = data.Labels{groupBy: group}
+		}
+		frames = append(frames, data.NewFrame("bizevents",
+			data.NewField("time", nil, groups[group].times),
+			data.NewField("value", labels, groups[group].values),
+		))
+	}
+	return frames
+}
@@ -0,0 +1,81 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryBizEventsSeries(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != dqlExecutePath {
+			t.Errorf("unexpected path %s", req.URL.Path)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		var execute dqlExecuteRequest
+		_ = json.Unmarshal(body, &execute)
+		for _, want := range []string{
+			`filter event.type == "com.shop.order"`,
+			"summarize value = sum(revenue), by: {time = bin(timestamp, 300s), country}",
+		} {
+			if !strings.Contains(execute.Query, want) {
+				t.Errorf("query %q does not contain %q", execute.Query, want)
+			}
+		}
+		_, _ = rw.Write([]byte(`{"state": "SUCCEEDED", "result": {"records": [
+			{"time": "2024-01-01T00:00:00Z", "country": "PT", "value": 10},
+			{"time": "2024-01-01T00:05:00Z", "country": "PT", "value": 12.5},
+			{"time": "2024-01-01T00:00:00Z", "country": "BR", "value": 7}
+		]}}`))
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeBizEvents,
+		Interval:  5 * time.Minute,
+		JSON:      []byte(`{"useDashboardTime": true, "bizEventType": "com.shop.order", "bizAggregation": "sum", "bizField": "revenue", "bizGroupBy": "country"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 2 {
+		t.Fatalf("expected a frame per country, got %d", len(resp.Frames))
+	}
+	if got := resp.Frames[1].Fields[1].Labels["country"]; got != "PT" {
+		t.Errorf("second frame country = %q, want PT", got)
+	}
+	if rows, _ := resp.Frames[1].RowLen(); rows != 2 {
+		t.Errorf("expected 2 points for PT, got %d", rows)
+	}
+}
+
+func TestBizEventsDQL(t *testing.T) {
+	dql, err := bizEventsDQL(queryModel{BizFormat: "table", BizGroupBy: "event.provider"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "fetch bizevents\n| summarize value = count(), by: {event.provider}"; dql != want {
+		t.Errorf("dql = %q, want %q", dql, want)
+	}
+
+	for _, qm := range []queryModel{
+		{BizAggregation: "median"},
+		{BizAggregation: "sum"},
+		{BizAggregation: "sum", BizField: "revenue) | delete"},
+		{BizGroupBy: "a, b"},
+		{BizFormat: "heatmap"},
+	} {
+		if _, err := bizEventsDQL(qm, time.Minute); err == nil {
+			t.Errorf("expected an error for %+v", qm)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// doRequest executes an authenticated request against the Dynatrace API and
+// returns the raw response body. Non-2xx responses are returned as errors.
+// Queries targeting the platform API version are sent to the platform.
+func (d *Datasource) doRequest(ctx context.Context, method, path string, params url.Values, body io.Reader, contentType string) ([]byte, error) {
+	if apiVersionFromContext(ctx) == apiVersionPlatform {
+		if platformPath, ok := platformApiPath(path); ok {
+			return d.doPlatformRequest(ctx, method, platformPath, params, body, contentType)
+		}
+	}
+	respBody, err := d.send(ctx, method, d.apiUrl+path, fmt.Sprintf("Api-Token %s", d.apiToken), params, body, contentType)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		apiErr.scope = endpointScope(path)
+	}
+	return respBody, err
+}
+
+// doPlatformRequest executes a request against the Dynatrace platform API
+// (e.g. Grail) using the platform token as bearer token.
+func (d *Datasource) doPlatformRequest(ctx context.Context, method, path string, params url.Values, body io.Reader, contentType string) ([]byte, error) {
+	if d.platformUrl == "" || d.platformToken == "" {
+		return nil, fmt.Errorf("platform URL and platform token must be configured to query Grail")
+	}
+	return d.send(ctx, method, d.platformUrl+path, fmt.Sprintf("Bearer %s", d.platformToken), params, body, contentType)
+}
+
+// send executes a request against fullUrl with the given Authorization
+// header. Identical concurrent GET requests, e.g. of several panels or users
+// viewing the same dashboard, are coalesced into one outbound call unless the
+// query bypasses the cache. Requests with a body are never coalesced, nor
+// are requests with different forwarded origin headers. A
+// caller served by another caller's request still counts it as an API call
+// of its query, so per-query and datasource stats match the calls made
+// without coalescing.
+func (d *Datasource) send(ctx context.Context, method, fullUrl, authorization string, params url.Values, body io.Reader, contentType string) ([]byte, error) {
+	if len(params) > 0 {
+		fullUrl = fmt.Sprintf("%s?%s", fullUrl, params.Encode())
+	}
+	if method != http.MethodGet || cacheBypassed(ctx) {
+		return d.sendWithRetries(ctx, method, fullUrl, authorization, body, contentType)
+	}
+	key := authorization + " " + fullUrl + " " + d.originKey(ctx)
+	respBody, shared, err := d.inflight.do(ctx, key, func() ([]byte, error) {
+		return d.sendWithRetries(ctx, method, fullUrl, authorization, body, contentType)
+	})
+	if shared {
+		countAPICall(ctx)
+		atomic.AddInt64(&d.stats.apiCalls, 1)
+	}
+	return respBody, err
+}
+
+// sendWithRetries executes a request, retrying rate-limited requests after
+// the Retry-After duration when Dynatrace asks for a short wait, see
+// retryRateLimited.
+func (d *Datasource) sendWithRetries(ctx context.Context, method, fullUrl, authorization string, body io.Reader, contentType string) ([]byte, error) {
+	// Buffer the body so that retries can send it again
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, fmt.Errorf("error reading request body: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		respBody, err := d.sendOnce(ctx, method, fullUrl, authorization, payload, body != nil, contentType)
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+			return respBody, err
+		}
+		if !d.retryRateLimited(ctx, apiErr, attempt) {
+			addRateLimitNotice(ctx, apiErr)
+			return nil, err
+		}
+	}
+}
+
+// sendOnce executes a single attempt of a request.
+func (d *Datasource) sendOnce(ctx context.Context, method, fullUrl, authorization string, payload []byte, hasBody bool, contentType string) ([]byte, error) {
+	log.DefaultLogger.Debug("Calling Dynatrace API", "method", method, "url", d.logURL(fullUrl))
+
+	var body io.Reader
+	if hasBody {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fullUrl, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	// Add authentication header
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Accept", "application/json")
+	if d.userAgent != "" {
+		req.Header.Set("User-Agent", d.userAgent)
+	}
+	d.setOriginHeaders(ctx, req.Header)
+	if cacheBypassed(ctx) {
+		req.Header.Set("Cache-Control", "no-cache")
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	countAPICall(ctx)
+	atomic.AddInt64(&d.stats.apiCalls, 1)
+
+	if err := d.limiter.acquire(ctx); err != nil {
+		return nil, fmt.Errorf("error waiting for a free request slot: %w", err)
+	}
+	defer d.limiter.release()
+
+	// Create HTTP client with TLS configuration
+	client, err := d.client()
+	if err != nil {
+		return nil, fmt.Errorf("error creating HTTP client: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error executing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &apiError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			apiErr.retryAfter, apiErr.hasRetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return nil, apiErr
+	}
+
+	return respBody, nil
+}
+
+// apiError is returned for non-2xx responses of the Dynatrace API.
+type apiError struct {
+	StatusCode int
+	Body       string
+
+	// followUp is set when the request carried a nextPageKey
+	followUp bool
+	// scope is the token scope required by the endpoint, if known
+	scope string
+	// retryAfter is the wait requested by the Retry-After header of a 429
+	// response, if it had one
+	retryAfter    time.Duration
+	hasRetryAfter bool
+}
+
+func (e *apiError) Error() string {
+	if message := e.authErrorMessage(); message != "" {
+		return message
+	}
+	if e.StatusCode == http.StatusTooManyRequests {
+		return e.rateLimitMessage()
+	}
+	return fmt.Sprintf("Dynatrace API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// nextPageKeyRejected reports whether a follow-up page request failed because
+// its page key expired or became invalid.
+func (e *apiError) nextPageKeyRejected() bool {
+	return e.followUp && (e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusNotFound)
+}
+
+// get performs a GET request and decodes the JSON response into out.
+func (d *Datasource) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	body, err := d.doRequest(ctx, http.MethodGet, path, params, nil, "")
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// post sends payload as a JSON body and decodes the JSON response into out,
+// which may be nil when the response body is not needed.
+func (d *Datasource) post(ctx context.Context, path string, params url.Values, payload interface{}, out interface{}) error {
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding request: %w", err)
+	}
+
+	body, err := d.doRequest(ctx, http.MethodPost, path, params, bytes.NewReader(reqBody), "application/json")
+	if err != nil {
+		return err
+	}
+	if out == nil || len(body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// postForm sends params as a form-encoded body instead of the query string
+// and decodes the JSON response into out.
+func (d *Datasource) postForm(ctx context.Context, path string, params url.Values, out interface{}) error {
+	body, err := d.doRequest(ctx, http.MethodPost, path, nil, strings.NewReader(params.Encode()), "application/x-www-form-urlencoded")
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// getAllPages follows nextPageKey on a paginated v2 endpoint and calls
+// decodePage with the body of every page once all pages were fetched.
+// As required by the API, follow-up requests carry only the nextPageKey.
+//
+// Page keys expire after a while, so if a follow-up request is rejected the
+// query is restarted once with half the page size.
+func (d *Datasource) getAllPages(ctx context.Context, path string, params url.Values, decodePage func(body []byte) error) error {
+	pages, err := d.fetchPages(ctx, path, params)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.nextPageKeyRejected() {
+		retryParams := url.Values{}
+		for key, values := range params {
+			retryParams[key] = values
+		}
+		if pageSize, convErr := strconv.Atoi(params.Get("pageSize")); convErr == nil && pageSize > 1 {
+			retryParams.Set("pageSize", strconv.Itoa(pageSize/2))
+			// Smaller pages need more of them to cover the requested rows
+			if requested, ok := pageCount(ctx); ok {
+				ctx = withPageCount(ctx, (requested*pageSize+pageSize/2-1)/(pageSize/2))
+			}
+		}
+
+		log.DefaultLogger.Warn("Page key was rejected, restarting paginated query", "path", path, "pageSize", retryParams.Get("pageSize"), "error", d.logError(err))
+		pages, err = d.fetchPages(ctx, path, retryParams)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, body := range pages {
+		if err := decodePage(body); err != nil {
+			return fmt.Errorf("error decoding response: %w", err)
+		}
+	}
+	return nil
+}
+
+// fetchPages returns the bodies of all pages of a paginated v2 endpoint, up to
+// the page limit of the datasource or the pages requested with withPageCount.
+func (d *Datasource) fetchPages(ctx context.Context, path string, params url.Values) ([][]byte, error) {
+	var pages [][]byte
+	followUp := false
+	for {
+		body, err := d.doRequest(ctx, http.MethodGet, path, params, nil, "")
+		if err != nil {
+			var apiErr *apiError
+			if errors.As(err, &apiErr) {
+				apiErr.followUp = followUp
+			}
+			return nil, err
+		}
+		pages = append(pages, body)
+
+		var page struct {
+			NextPageKey *string `json:"nextPageKey"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("error decoding response: %w", err)
+		}
+		if page.NextPageKey == nil || *page.NextPageKey == "" {
+			return pages, nil
+		}
+		if requested, ok := pageCount(ctx); ok && len(pages) >= requested {
+			return pages, nil
+		}
+
+		if len(pages) >= d.pageLimit() {
+			log.DefaultLogger.Warn("Stopping pagination at page limit", "path", path, "pages", len(pages))
+			addQueryNotice(ctx, data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     fmt.Sprintf("Results of %s were truncated after %d pages; narrow the selector or raise maxPages", path, len(pages)),
+			})
+			return pages, nil
+		}
+
+		params = url.Values{}
+		params.Set("nextPageKey", *page.NextPageKey)
+		followUp = true
+	}
+}
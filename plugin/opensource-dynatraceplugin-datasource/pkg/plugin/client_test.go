@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFetchProblemsRestartsOnExpiredPageKey(t *testing.T) {
+	expired := false
+	var pageSizes []string
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		switch {
+		case query.Get("nextPageKey") == "key-1" && !expired:
+			expired = true
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"error": {"code": 400, "message": "The nextPageKey is expired"}}`))
+		case query.Get("nextPageKey") == "key-1":
+			_, _ = rw.Write([]byte(`{"problems": [{"problemId": "P-2"}]}`))
+		default:
+			pageSizes = append(pageSizes, query.Get("pageSize"))
+			if query.Get("from") == "" {
+				t.Errorf("restarted query should carry the original parameters")
+			}
+			_, _ = rw.Write([]byte(`{"problems": [{"problemId": "P-1"}], "nextPageKey": "key-1"}`))
+		}
+	})
+
+	problems, err := ds.fetchProblems(context.Background(), "", 1000, 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 2 || problems[0].ProblemId != "P-1" || problems[1].ProblemId != "P-2" {
+		t.Errorf("expected each problem once, got %+v", problems)
+	}
+	if strings.Join(pageSizes, ",") != "500,250" {
+		t.Errorf("page sizes = %v, want the restart to halve the page size", pageSizes)
+	}
+}
+
+func TestFetchProblemsRestartsOnlyOnce(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("nextPageKey") != "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = rw.Write([]byte(`{"problems": [], "nextPageKey": "key-1"}`))
+	})
+
+	if _, err := ds.fetchProblems(context.Background(), "", 1000, 2000); err == nil {
+		t.Error("expected the error of the restarted query")
+	}
+}
+
+func TestFetchProblemsPagedRestartKeepsRequestedRows(t *testing.T) {
+	expired := false
+	pageSize := 0
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		if query.Get("nextPageKey") != "" && !expired {
+			expired = true
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"error": {"code": 400, "message": "The nextPageKey is expired"}}`))
+			return
+		}
+		if size := query.Get("pageSize"); size != "" {
+			pageSize, _ = strconv.Atoi(size)
+		}
+		problems := make([]string, pageSize)
+		for i := range problems {
+			problems[i] = `{"problemId": "P"}`
+		}
+		_, _ = rw.Write([]byte(`{"problems": [` + strings.Join(problems, ",") + `], "nextPageKey": "key"}`))
+	})
+
+	problems, err := ds.fetchProblemsPaged(context.Background(), "", "", 1000, 2000, tablePaging{limit: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) < 600 {
+		t.Errorf("expected the restarted query to cover the 600 requested rows, got %d", len(problems))
+	}
+}
@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// linkFieldReference matches the references of data links to other fields of
+// their row, e.g. ${__data.fields.problemId}.
+var linkFieldReference = regexp.MustCompile(`\$\{__data\.fields\.([^}:]+)`)
+
+// selectColumns keeps the listed fields of the table frames of a response,
+// in the listed order, e.g. ["displayId", "title", "status"] for problems.
+// Fields referenced by the data links of kept fields, such as the related
+// metric selector, are kept too but hidden. Time series frames are left
+// unchanged. Columns missing from a table are reported in a notice.
+func selectColumns(resp *backend.DataResponse, columns []string) {
+	if len(columns) == 0 || resp.Error != nil {
+		return
+	}
+	for _, frame := range resp.Frames {
+		if frame.Meta == nil || frame.Meta.PreferredVisualization != data.VisTypeTable {
+			continue
+		}
+
+		fields := make([]*data.Field, 0, len(columns))
+		var missing []string
+		for _, name := range columns {
+			if field, _ := frame.FieldByName(name); field != nil {
+				fields = append(fields, field)
+			} else {
+				missing = append(missing, name)
+			}
+		}
+		frame.Fields = append(fields, linkedFields(frame, fields)...)
+
+		if len(missing) > 0 {
+			frame.AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     fmt.Sprintf("Columns not found in %s: %s", frame.Name, strings.Join(missing, ", ")),
+			})
+		}
+	}
+}
+
+// linkedFields returns the fields of frame referenced by the data links of
+// fields and not among them, hidden from tables.
+func linkedFields(frame *data.Frame, fields []*data.Field) []*data.Field {
+	kept := map[string]bool{}
+	for _, field := range fields {
+		kept[field.Name] = true
+	}
+
+	var linked []*data.Field
+	for _, field := range fields {
+		if field.Config == nil {
+			continue
+		}
+		for _, link := range field.Config.Links {
+			for _, match := range linkFieldReference.FindAllStringSubmatch(link.URL, -1) {
+				name := match[1]
+				target, _ := frame.FieldByName(name)
+				if kept[name] || target == nil {
+					continue
+				}
+				kept[name] = true
+				if target.Config == nil {
+					target.Config = &data.FieldConfig{}
+				}
+				if target.Config.Custom == nil {
+					target.Config.Custom = map[string]interface{}{}
+				}
+				target.Config.Custom["hidden"] = true
+				linked = append(linked, target)
+			}
+		}
+	}
+	return linked
+}
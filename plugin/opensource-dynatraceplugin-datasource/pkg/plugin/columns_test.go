@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryProblemsColumns(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"problems": [{"problemId": "P-1", "displayId": "P-24", "title": "CPU saturation", "status": "OPEN"}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblems,
+		JSON:      []byte(`{"useDashboardTime": true, "columns": ["title", "displayId", "owner"]}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	// problemId is kept hidden for the problem link on displayId
+	if len(frame.Fields) != 3 || frame.Fields[0].Name != "title" || frame.Fields[1].Name != "displayId" || frame.Fields[2].Name != "problemId" {
+		t.Fatalf("unexpected fields %v", frame.Fields)
+	}
+	if frame.Fields[0].At(0) != "CPU saturation" {
+		t.Errorf("title = %v", frame.Fields[0].At(0))
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) == 0 {
+		t.Error("expected a notice about the missing owner column")
+	}
+}
+
+func TestQueryProblemsColumnsKeepLinkedFields(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"problems": [{"problemId": "P-1", "displayId": "P-24", "title": "CPU saturation",
+			"rootCauseEntity": {"entityId": {"id": "HOST-1", "type": "HOST"}, "name": "host-a"}}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblems,
+		JSON:      []byte(`{"useDashboardTime": true, "columns": ["rootCause", "title"]}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	if frame.Fields[0].Name != "rootCause" || frame.Fields[1].Name != "title" {
+		t.Fatalf("the listed columns should come first, got %v", frame.Fields)
+	}
+	for _, name := range []string{relatedMetricSelectorField, "problemId"} {
+		field, _ := frame.FieldByName(name)
+		if field == nil {
+			t.Fatalf("the %s field referenced by data links should be kept", name)
+		}
+		if field.Config == nil || field.Config.Custom["hidden"] != true {
+			t.Errorf("the %s field should be hidden", name)
+		}
+	}
+	selectors, _ := frame.FieldByName(relatedMetricSelectorField)
+	if selectors.At(0) != "builtin:host.cpu.usage:filter(eq(dt.entity.host,HOST-1))" {
+		t.Errorf("related metric selector = %v", selectors.At(0))
+	}
+	if field, _ := frame.FieldByName("displayId"); field != nil {
+		t.Error("fields no link references should be dropped")
+	}
+}
+
+func TestSelectColumnsKeepsTimeSeries(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "m", "data": [{"timestamps": [1], "values": [1]}]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"useDashboardTime": true, "metricSelector": "m", "columns": ["title"]}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames[0].Fields) != 2 {
+		t.Errorf("time series frames should keep their fields, got %d", len(resp.Frames[0].Fields))
+	}
+}
@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// maxConstantPoints bounds the points of a constant series with a resolution.
+const maxConstantPoints = 10000
+
+// queryConstant returns a flat series at qm.Constant across the query range,
+// e.g. for SLO targets or capacity thresholds. Without a resolution the
+// series has a point at the start and end of the range; with a resolution it
+// has a point per bucket so it lines up with metric series in expressions.
+func queryConstant(query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	timestamps := []int64{fromMs, toMs}
+	if qm.Resolution != "" {
+		step, err := parseResolution(qm.Resolution)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+		if estimatedDataPoints(1, fromMs, toMs, step) > maxConstantPoints {
+			return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("resolution %s yields more than %d points", qm.Resolution, maxConstantPoints))
+		}
+
+		stepMs := step.Milliseconds()
+		timestamps = nil
+		for ts := fromMs - fromMs%stepMs; ts <= toMs; ts += stepMs {
+			timestamps = append(timestamps, ts)
+		}
+	}
+
+	times := make([]time.Time, len(timestamps))
+	values := make([]float64, len(timestamps))
+	for i, ts := range timestamps {
+		times[i] = time.UnixMilli(ts)
+		values[i] = qm.Constant
+	}
+
+	name := qm.LabelChart
+	if name == "" {
+		name = "constant"
+	}
+
+	frame := data.NewFrame(name,
+		data.NewField("time", nil, times),
+		data.NewField(name, nil, values),
+	)
+	frame.Meta = &data.FrameMeta{ExecutedQueryString: fmt.Sprintf("Constant: %g", qm.Constant)}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryConstant(t *testing.T) {
+	ds := &Datasource{}
+	timeRange := backend.TimeRange{From: time.UnixMilli(90000), To: time.UnixMilli(300000)}
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeConstant,
+		TimeRange: timeRange,
+		JSON:      []byte(`{"constant": 99.9, "useDashboardTime": true, "labelChart": "SLO target"}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	frame := resp.Frames[0]
+	if frame.Fields[1].Name != "SLO target" || frame.Fields[1].Len() != 2 || frame.Fields[1].At(1) != 99.9 {
+		t.Errorf("expected a flat two-point series, got %v", frame.Fields)
+	}
+	if !frame.Fields[0].At(0).(time.Time).Equal(timeRange.From) || !frame.Fields[0].At(1).(time.Time).Equal(timeRange.To) {
+		t.Errorf("series should span the dashboard range")
+	}
+
+	resp = ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeConstant,
+		TimeRange: timeRange,
+		JSON:      []byte(`{"constant": 1, "useDashboardTime": true, "resolution": "1m"}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if times := resp.Frames[0].Fields[0]; times.Len() != 5 || times.At(0).(time.Time).UnixMilli() != 60000 {
+		t.Errorf("expected a point per aligned minute, got %d points", times.Len())
+	}
+}
@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// pluginId is the ID of this datasource plugin, referenced by generated dashboards.
+const pluginId = "opensource-dynatraceplugin-datasource"
+
+// dashboardPanel is a panel template of a generated dashboard.
+type dashboardPanel struct {
+	title  string
+	metric string
+	split  string // Dimension the series are split by, if any
+	unit   string // Grafana unit ID
+}
+
+// dashboardTemplate lists the key metrics charted for an entity type.
+type dashboardTemplate struct {
+	prefix    string
+	dimension string
+	panels    []dashboardPanel
+}
+
+// dashboardTemplates maps entity ID prefixes to the panels of their generated dashboard.
+var dashboardTemplates = []dashboardTemplate{
+	{"HOST-", "dt.entity.host", []dashboardPanel{
+		{"CPU usage", "builtin:host.cpu.usage", "", "percent"},
+		{"Memory usage", "builtin:host.mem.usage", "", "percent"},
+		{"Disk used", "builtin:host.disk.usedPct", "dt.entity.disk", "percent"},
+		{"Network traffic in", "builtin:host.net.nic.trafficIn", "dt.entity.network_interface", "bps"},
+		{"Network traffic out", "builtin:host.net.nic.trafficOut", "dt.entity.network_interface", "bps"},
+		{"Availability", "builtin:host.availability", "availability_state", "short"},
+	}},
+	{"SERVICE-", "dt.entity.service", []dashboardPanel{
+		{"Response time", "builtin:service.response.time", "", "µs"},
+		{"Requests", "builtin:service.requestCount.total", "", "short"},
+		{"Failure rate", "builtin:service.errors.total.rate", "", "percent"},
+		{"Server-side errors", "builtin:service.errors.server.count", "", "short"},
+	}},
+	{"CLOUD_APPLICATION-", "dt.entity.cloud_application", []dashboardPanel{
+		{"CPU usage", "builtin:kubernetes.workload.cpu_usage", "", "short"},
+		{"CPU throttled", "builtin:kubernetes.workload.cpu_throttled", "", "short"},
+		{"Memory working set", "builtin:kubernetes.workload.memory_working_set", "", "bytes"},
+		{"Running pods", "builtin:kubernetes.pods", "pod_phase", "short"},
+	}},
+}
+
+// Size of generated panels on the 24 column dashboard grid
+const (
+	dashboardPanelWidth  = 12
+	dashboardPanelHeight = 8
+)
+
+// handleGenerateDashboard serves GET /dashboards/generate?entityId=..., returning
+// a dashboard JSON for importing into Grafana that charts the key metrics of
+// a host, service or Kubernetes workload with this datasource, followed by
+// the problems of the entity.
+func (d *Datasource) handleGenerateDashboard(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	entityId := req.URL.Query().Get("entityId")
+	if entityId == "" {
+		writeError(rw, http.StatusBadRequest, "entityId is required")
+		return
+	}
+	var template *dashboardTemplate
+	for i := range dashboardTemplates {
+		if strings.HasPrefix(entityId, dashboardTemplates[i].prefix) {
+			template = &dashboardTemplates[i]
+			break
+		}
+	}
+	if template == nil {
+		writeError(rw, http.StatusBadRequest, "dashboards can be generated for hosts, services and Kubernetes workloads")
+		return
+	}
+
+	// Look up entities seen in the last year rather than the API's default of 3 days
+	now := time.Now()
+	entities, err := d.fetchEntitiesById(req.Context(), []string{entityId}, "", now.AddDate(-1, 0, 0).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+	entity, ok := entities[entityId]
+	if !ok {
+		writeError(rw, http.StatusNotFound, fmt.Sprintf("entity %s not found", entityId))
+		return
+	}
+	name := entity.DisplayName
+	if name == "" {
+		name = entityId
+	}
+
+	writeJSON(rw, http.StatusOK, d.generateDashboard(*template, entityId, name))
+}
+
+// generateDashboard builds the dashboard model of an entity from a template.
+func (d *Datasource) generateDashboard(template dashboardTemplate, entityId, name string) map[string]interface{} {
+	datasource := map[string]string{"type": pluginId, "uid": d.settings.UID}
+
+	var panels []interface{}
+	for i, p := range template.panels {
+		selector := fmt.Sprintf("%s:filter(eq(%q,%q))", p.metric, template.dimension, entityId)
+		if p.split != "" {
+			selector += fmt.Sprintf(":splitBy(%q)", p.split)
+		}
+		panels = append(panels, map[string]interface{}{
+			"id":         i + 1,
+			"type":       "timeseries",
+			"title":      p.title,
+			"datasource": datasource,
+			"gridPos": map[string]int{
+				"x": (i % 2) * dashboardPanelWidth,
+				"y": (i / 2) * dashboardPanelHeight,
+				"w": dashboardPanelWidth,
+				"h": dashboardPanelHeight,
+			},
+			"fieldConfig": map[string]interface{}{
+				"defaults":  map[string]interface{}{"unit": p.unit},
+				"overrides": []interface{}{},
+			},
+			"targets": []interface{}{map[string]interface{}{
+				"refId":            "A",
+				"datasource":       datasource,
+				"queryType":        queryTypeMetrics,
+				"metricSelector":   selector,
+				"useDashboardTime": true,
+			}},
+		})
+	}
+
+	rows := (len(template.panels) + 1) / 2
+	panels = append(panels, map[string]interface{}{
+		"id":         len(template.panels) + 1,
+		"type":       "table",
+		"title":      "Problems",
+		"datasource": datasource,
+		"gridPos":    map[string]int{"x": 0, "y": rows * dashboardPanelHeight, "w": 2 * dashboardPanelWidth, "h": dashboardPanelHeight},
+		"targets": []interface{}{map[string]interface{}{
+			"refId":            "A",
+			"datasource":       datasource,
+			"queryType":        queryTypeProblems,
+			"entitySelector":   fmt.Sprintf("entityId(%q)", entityId),
+			"useDashboardTime": true,
+		}},
+	})
+
+	return map[string]interface{}{
+		"title":         fmt.Sprintf("%s (Dynatrace)", name),
+		"tags":          []string{"dynatrace", "generated"},
+		"editable":      true,
+		"schemaVersion": 38,
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"panels":        panels,
+	}
+}
@@ -0,0 +1,134 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestHandleGenerateDashboard(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/entities" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+			return
+		}
+		if got := req.URL.Query().Get("entitySelector"); got != `entityId("SERVICE-1")` {
+			t.Errorf("entitySelector = %q", got)
+		}
+		_, _ = rw.Write([]byte(`{"entities": [{"entityId": "SERVICE-1", "displayName": "checkout"}]}`))
+	})
+	ds.settings.UID = "dt-uid"
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboards/generate?entityId=SERVICE-1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var dashboard struct {
+		Title  string `json:"title"`
+		Panels []struct {
+			Type    string `json:"type"`
+			Targets []struct {
+				Datasource     map[string]string `json:"datasource"`
+				QueryType      string            `json:"queryType"`
+				MetricSelector string            `json:"metricSelector"`
+				EntitySelector string            `json:"entitySelector"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &dashboard); err != nil {
+		t.Fatal(err)
+	}
+	if dashboard.Title != "checkout (Dynatrace)" {
+		t.Errorf("title = %q", dashboard.Title)
+	}
+	if len(dashboard.Panels) != 5 {
+		t.Fatalf("expected 4 metric panels and a problems table, got %d", len(dashboard.Panels))
+	}
+	first := dashboard.Panels[0].Targets[0]
+	if first.MetricSelector != `builtin:service.response.time:filter(eq("dt.entity.service","SERVICE-1"))` {
+		t.Errorf("metricSelector = %q", first.MetricSelector)
+	}
+	if first.Datasource["uid"] != "dt-uid" || first.Datasource["type"] != pluginId {
+		t.Errorf("datasource = %v", first.Datasource)
+	}
+	if last := dashboard.Panels[4]; last.Type != "table" || last.Targets[0].EntitySelector != `entityId("SERVICE-1")` {
+		t.Errorf("unexpected problems panel %+v", last)
+	}
+
+	// The problems panel scopes problems by entity, not with a problem selector
+	var raw struct {
+		Panels []struct {
+			Targets []map[string]interface{} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatal(err)
+	}
+	target := raw.Panels[4].Targets[0]
+	delete(target, "datasource")
+	want := map[string]interface{}{
+		"refId":            "A",
+		"queryType":        queryTypeProblems,
+		"entitySelector":   `entityId("SERVICE-1")`,
+		"useDashboardTime": true,
+	}
+	if !reflect.DeepEqual(target, want) {
+		t.Errorf("problems target = %v, want %v", target, want)
+	}
+}
+
+func TestGeneratedProblemsPanelQuery(t *testing.T) {
+	var params url.Values
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/api/v2/problems" {
+			params = req.URL.Query()
+		}
+		_, _ = rw.Write([]byte(`{"entities": [{"entityId": "SERVICE-1", "displayName": "checkout"}], "problems": []}`))
+	})
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboards/generate?entityId=SERVICE-1", nil))
+	var dashboard struct {
+		Panels []struct {
+			Targets []json.RawMessage `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &dashboard); err != nil {
+		t.Fatal(err)
+	}
+
+	target := dashboard.Panels[len(dashboard.Panels)-1].Targets[0]
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblems,
+		TimeRange: backend.TimeRange{From: time.UnixMilli(0), To: time.UnixMilli(3600000)},
+		JSON:      target,
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if params.Get("entitySelector") != `entityId("SERVICE-1")` || params.Get("problemSelector") != "" {
+		t.Errorf("problems request params = %v", params)
+	}
+}
+
+func TestHandleGenerateDashboardUnsupportedEntity(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s", req.URL.Path)
+	})
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboards/generate?entityId=SYNTHETIC_TEST-1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
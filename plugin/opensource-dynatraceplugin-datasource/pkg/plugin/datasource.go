@@ -6,15 +6,17 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 )
 
@@ -26,6 +28,8 @@ import (
 var (
 	_ backend.QueryDataHandler      = (*Datasource)(nil)
 	_ backend.CheckHealthHandler    = (*Datasource)(nil)
+	_ backend.CallResourceHandler   = (*Datasource)(nil)
+	_ backend.StreamHandler         = (*Datasource)(nil)
 	_ instancemgmt.InstanceDisposer = (*Datasource)(nil)
 )
 
@@ -36,6 +40,15 @@ func NewDatasource(settings backend.DataSourceInstanceSettings) (instancemgmt.In
 	if err != nil {
 		return nil, fmt.Errorf("error unmarshaling settings: %w", err)
 	}
+	if jsonData == nil {
+		jsonData = map[string]interface{}{}
+	}
+
+	// Settings may be read from environment variables instead
+	secureData, err := applyEnvOverrides(jsonData, settings.DecryptedSecureJSONData)
+	if err != nil {
+		return nil, err
+	}
 
 	apiUrl := ""
 	if url, ok := jsonData["apiUrl"].(string); ok {
@@ -47,16 +60,146 @@ func NewDatasource(settings backend.DataSourceInstanceSettings) (instancemgmt.In
 		tlsSkipVerify = skip
 	}
 
-	apiToken := settings.DecryptedSecureJSONData["apiToken"]
-	tlsCertificate := settings.DecryptedSecureJSONData["tlsCertificate"]
+	enableMetricIngest := false
+	if enabled, ok := jsonData["enableMetricIngest"].(bool); ok {
+		enableMetricIngest = enabled
+	}
+
+	enableEventIngest := false
+	if enabled, ok := jsonData["enableEventIngest"].(bool); ok {
+		enableEventIngest = enabled
+	}
+
+	// Log only hashes and lengths of queries, for regulated environments
+	disablePayloadLogging := false
+	if disabled, ok := jsonData["disablePayloadLogging"].(bool); ok {
+		disablePayloadLogging = disabled
+	}
+
+	// Appended to the User-Agent, e.g. to tell Grafana instances apart
+	userAgentSuffix, _ := jsonData["userAgentSuffix"].(string)
+
+	// Send the dashboard, panel and user of queries along with their requests
+	forwardContextHeaders := false
+	if forward, ok := jsonData["forwardContextHeaders"].(bool); ok {
+		forwardContextHeaders = forward
+	}
+
+	// Patterns removing personally identifiable data from returned frames
+	redactionRules, err := parseRedactionRules(jsonData["redactionRules"])
+	if err != nil {
+		return nil, err
+	}
+
+	// Dynatrace web UI used for deep links; defaults to the API URL, which is
+	// the environment URL for SaaS and Managed environments
+	uiUrl := strings.TrimSuffix(apiUrl, "/")
+	if url, ok := jsonData["uiUrl"].(string); ok && url != "" {
+		uiUrl = strings.TrimSuffix(url, "/")
+	}
+
+	// Maximum number of simultaneous requests to Dynatrace; 0 means unlimited
+	maxConcurrentRequests := 0
+	if max, ok := jsonData["maxConcurrentRequests"].(float64); ok {
+		maxConcurrentRequests = int(max)
+	}
+
+	// Most recent minutes excluded from metrics to allow for ingestion latency
+	var dataDelay time.Duration
+	if minutes, ok := jsonData["dataDelayMinutes"].(float64); ok && minutes > 0 {
+		dataDelay = time.Duration(minutes * float64(time.Minute))
+	}
+
+	// Ceilings of DQL request options; 0 leaves them unlimited
+	dqlMaxResultRecords := 0
+	if max, ok := jsonData["dqlMaxResultRecords"].(float64); ok {
+		dqlMaxResultRecords = int(max)
+	}
+	dqlMaxScanLimitGbytes := 0.0
+	if max, ok := jsonData["dqlMaxScanLimitGbytes"].(float64); ok {
+		dqlMaxScanLimitGbytes = max
+	}
 
-	return &Datasource{
+	// Bytes Grail may scan per hour or day; 0 leaves scans unlimited
+	var grailBudgetBytes int64
+	if gb, ok := jsonData["grailBudgetGbytes"].(float64); ok && gb > 0 {
+		grailBudgetBytes = int64(gb * 1e9)
+	}
+	budgetPeriod, _ := jsonData["grailBudgetPeriod"].(string)
+	grailPeriod, err := grailBudgetPeriod(budgetPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	// Queries slower than this are logged at warning level; 0 uses the default
+	var slowQueryThreshold time.Duration
+	if seconds, ok := jsonData["slowQueryThresholdSeconds"].(float64); ok && seconds > 0 {
+		slowQueryThreshold = time.Duration(seconds * float64(time.Second))
+	}
+
+	// Pagination safeguards; 0 uses the defaults
+	maxPages := 0
+	if max, ok := jsonData["maxPages"].(float64); ok {
+		maxPages = int(max)
+	}
+	maxResults := 0
+	if max, ok := jsonData["maxResults"].(float64); ok {
+		maxResults = int(max)
+	}
+
+	// Tokens expiring within this window are warned about; negative disables the warning
+	tokenExpiryWindow := defaultTokenExpiryWarning
+	if days, ok := jsonData["tokenExpiryWarningDays"].(float64); ok && days != 0 {
+		tokenExpiryWindow = time.Duration(days * float64(24*time.Hour))
+	}
+
+	platformUrl := ""
+	if url, ok := jsonData["platformUrl"].(string); ok {
+		platformUrl = strings.TrimSuffix(url, "/")
+	}
+
+	apiToken := secureData["apiToken"]
+	platformToken := secureData["platformToken"]
+	tlsCertificate := secureData["tlsCertificate"]
+
+	ds := &Datasource{
 		settings:       settings,
 		apiUrl:         apiUrl,
 		apiToken:       apiToken,
 		tlsSkipVerify:  tlsSkipVerify,
 		tlsCertificate: tlsCertificate,
-	}, nil
+		uiUrl:          uiUrl,
+		userAgent:      userAgent(userAgentSuffix),
+
+		forwardContextHeaders: forwardContextHeaders,
+
+		platformUrl:   platformUrl,
+		platformToken: platformToken,
+
+		enableMetricIngest: enableMetricIngest,
+		enableEventIngest:  enableEventIngest,
+
+		disablePayloadLogging: disablePayloadLogging,
+		redactionRules:        redactionRules,
+
+		limiter:   newRequestLimiter(maxConcurrentRequests),
+		dataDelay: dataDelay,
+
+		maxPages:   maxPages,
+		maxResults: maxResults,
+
+		slowQueryThreshold: slowQueryThreshold,
+		tokenExpiryWindow:  tokenExpiryWindow,
+
+		dqlMaxResultRecords:   dqlMaxResultRecords,
+		dqlMaxScanLimitGbytes: dqlMaxScanLimitGbytes,
+		grailBudget:           newGrailBudget(grailBudgetBytes, grailPeriod),
+
+		closing: make(chan struct{}),
+	}
+	ds.resourceHandler = httpadapter.New(ds.newResourceMux())
+
+	return ds, nil
 }
 
 // Datasource is a Dynatrace datasource which can respond to data queries, reports
@@ -67,13 +210,155 @@ type Datasource struct {
 	apiToken       string
 	tlsSkipVerify  bool
 	tlsCertificate string
+
+	// Base URL of the Dynatrace web UI, used for deep links
+	uiUrl string
+
+	// userAgent identifies the plugin on outbound requests
+	userAgent string
+
+	// forwardContextHeaders adds the dashboard, panel and user of a query to
+	// its requests, see setOriginHeaders
+	forwardContextHeaders bool
+
+	// Dynatrace platform (Grail) endpoint and token, used for DQL queries
+	platformUrl   string
+	platformToken string
+
+	// enableMetricIngest allows the /metrics/ingest resource route to write to Dynatrace.
+	enableMetricIngest bool
+	// enableEventIngest allows the /events resource route to write to Dynatrace.
+	enableEventIngest bool
+
+	// disablePayloadLogging replaces queries, selectors and request URLs in
+	// log lines by their hash and length, see logPayload
+	disablePayloadLogging bool
+
+	// redactionRules are applied to every string of returned frames
+	redactionRules []redactionRule
+
+	// stats counts queries and API calls for the /stats resource route
+	stats datasourceStats
+
+	// tokens caches the scopes of the API token
+	tokens tokenCache
+
+	// inflight coalesces identical concurrent API requests
+	inflight flightGroup
+
+	// tokenExpiryWindow is how long before the API token expires health
+	// checks and queries warn about it
+	tokenExpiryWindow time.Duration
+
+	// limiter bounds concurrent outbound requests across panels and streams
+	limiter *requestLimiter
+
+	// dataDelay is excluded from the end of metrics queries by default
+	dataDelay time.Duration
+
+	// maxPages caps paginated requests and maxResults the series or records
+	// of a query, see pageLimit and resultLimit
+	maxPages   int
+	maxResults int
+
+	// slowQueryThreshold is the duration above which queries are logged in
+	// detail, see slowQueryLimit
+	slowQueryThreshold time.Duration
+
+	// Ceilings of DQL request options, see limitDQLOptions
+	dqlMaxResultRecords   int
+	dqlMaxScanLimitGbytes float64
+
+	// grailBudget caps the bytes scanned by DQL queries per period
+	grailBudget *grailBudget
+
+	resourceHandler backend.CallResourceHandler
+
+	// logTails maps live tail channel paths to their logs query
+	logTails sync.Map
+
+	// dqlRuns maps DQL progress channel paths to running and recently
+	// finished queries, see startDQLRun; dqlRunsMu serializes registering and
+	// removing runs
+	dqlRuns   sync.Map
+	dqlRunsMu sync.Mutex
+
+	// metricDescriptors caches descriptors looked up by /metrics/aggregations
+	// by metric key, see validateSelectorAggregations
+	metricDescriptors sync.Map
+
+	// syntheticLocations caches synthetic locations by entity ID, see
+	// fetchSyntheticLocation
+	syntheticLocations sync.Map
+
+	// httpClient is shared by all requests of the instance so that
+	// connections are reused, see client
+	clientMu   sync.Mutex
+	httpClient *http.Client
+
+	// closing is closed by Dispose to stop background work such as log tails
+	closing   chan struct{}
+	closeOnce sync.Once
 }
 
 // Dispose here tells plugin SDK that plugin wants to clean up resources when a new instance
 // created. As soon as datasource settings change detected by SDK old datasource instance will
 // be disposed and a new one will be created using NewDatasource factory function.
 func (d *Datasource) Dispose() {
-	// Clean up datasource instance resources.
+	// Stop log tails and other background work of this instance
+	d.closeOnce.Do(func() {
+		if d.closing != nil {
+			close(d.closing)
+		}
+	})
+
+	d.clientMu.Lock()
+	if d.httpClient != nil {
+		d.httpClient.CloseIdleConnections()
+		d.httpClient = nil
+	}
+	d.clientMu.Unlock()
+
+	d.tokens.flush()
+	d.logTails.Range(func(key, _ interface{}) bool {
+		d.logTails.Delete(key)
+		return true
+	})
+	d.dqlRuns.Range(func(key, run interface{}) bool {
+		run.(*dqlRun).cancelQuery()
+		d.dqlRuns.Delete(key)
+		return true
+	})
+}
+
+// backgroundContext returns a context that is also cancelled when the
+// instance is disposed, for work that outlives a single request.
+func (d *Datasource) backgroundContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-d.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// client returns the shared HTTP client of the instance, creating it on
+// first use.
+func (d *Datasource) client() (*http.Client, error) {
+	d.clientMu.Lock()
+	defer d.clientMu.Unlock()
+
+	if d.httpClient == nil {
+		client, err := d.createHTTPClient()
+		if err != nil {
+			return nil, err
+		}
+		d.httpClient = client
+	}
+	return d.httpClient, nil
 }
 
 // QueryData handles multiple queries and returns multiple responses.
@@ -81,13 +366,26 @@ func (d *Datasource) Dispose() {
 // The QueryDataResponse contains a map of RefID to the response for each query, and each response
 // contains Frames ([]*Frame).
 func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
-	log.DefaultLogger.Info("QueryData called", "queries", len(req.Queries))
+	log.DefaultLogger.Debug("QueryData called", "queries", len(req.Queries))
 
 	// create response struct
 	response := backend.NewQueryDataResponse()
 
+	// Alert rule evaluations run in the background and yield to panel queries
+	if req.Headers["FromAlert"] == "true" {
+		ctx = withPriority(ctx, priorityBackground)
+	}
+	ctx = withRequestOrigin(ctx, queryOrigin(req))
+
 	// loop over queries and execute them individually.
+	var expressions []backend.DataQuery
 	for _, q := range req.Queries {
+		// Expressions are evaluated once the queries they reference are done
+		if q.QueryType == queryTypeExpression {
+			expressions = append(expressions, q)
+			continue
+		}
+
 		res := d.query(ctx, req.PluginContext, q)
 
 		// save the response in a hashmap
@@ -95,21 +393,252 @@ func (d *Datasource) QueryData(ctx context.Context, req *backend.QueryDataReques
 		response.Responses[q.RefID] = res
 	}
 
+	for _, q := range expressions {
+		var qm queryModel
+		if err := json.Unmarshal(q.JSON, &qm); err != nil {
+			response.Responses[q.RefID] = backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("json unmarshal: %v", err.Error()))
+			continue
+		}
+		start := time.Now()
+		res := queryExpression(qm, response.Responses)
+		d.logQuery(q, qm, res, nil, time.Since(start))
+		d.countQuery(res)
+		response.Responses[q.RefID] = res
+	}
+
 	return response, nil
 }
 
+// Query types supported by the backend. Queries without a query type are
+// treated as metrics queries so existing dashboards keep working.
+const (
+	queryTypeMetrics  = "metrics"
+	queryTypeProblems = "problems"
+	queryTypeProblem  = "problem"
+
+	queryTypeNetworkZones = "networkZones"
+	queryTypeActiveGates  = "activeGates"
+	queryTypeBilling      = "billing"
+	queryTypeHostUnits    = "hostUnits"
+	queryTypeEntityCount  = "entityCount"
+	queryTypeDeployments  = "deployments"
+	queryTypeLogs         = "logs"
+	queryTypeLogsVolume   = "logsVolume"
+	queryTypeTraces       = "traces"
+	queryTypeServiceFlow  = "serviceFlow"
+	queryTypeMetricEvents = "metricEvents"
+	queryTypeSettings     = "settings"
+	queryTypeExpression   = "expression"
+	queryTypeConstant     = "constant"
+	queryTypeAdvanced     = "advanced"
+	queryTypeDQL          = "dql"
+	queryTypeBizEvents    = "bizevents"
+	queryTypeReleases     = "releases"
+	queryTypeSecurity     = "securityProblems"
+	queryTypeAttacks      = "attacks"
+	queryTypeAvailability = "availability"
+	queryTypeSynthetic    = "synthetic"
+	queryTypeProblemCount = "problemCount"
+	queryTypeEntities     = "entities"
+	queryTypeEvents       = "events"
+	queryTypeUSQL         = "usql"
+	queryTypeSLO          = "slo"
+	queryTypeApdex        = "apdex"
+	queryTypeSessionsGeo  = "sessionsGeo"
+)
+
 // queryModel represents the query configuration from frontend
 type queryModel struct {
-	MetricSelector   string  `json:"metricSelector"` // Primary field: metric with filters/transformations
-	MetricId         string  `json:"metricId"`       // DEPRECATED: Use MetricSelector instead
-	EntitySelector   string  `json:"entitySelector"` // DEPRECATED: Use filters in MetricSelector
-	UseDashboardTime bool    `json:"useDashboardTime"`
-	CustomFrom       string  `json:"customFrom"`
-	CustomTo         string  `json:"customTo"`
-	Resolution       string  `json:"resolution"`
-	LabelChart       string  `json:"labelChart"` // Field from labels to use for chart legend
-	QueryText        string  `json:"queryText"`
-	Constant         float64 `json:"constant"`
+	MetricSelector   string `json:"metricSelector"` // Primary field: metric with filters/transformations
+	MetricId         string `json:"metricId"`       // DEPRECATED: Use MetricSelector instead
+	EntitySelector   string `json:"entitySelector"` // Entity scope; DEPRECATED for metrics: use filters in MetricSelector
+	UseDashboardTime bool   `json:"useDashboardTime"`
+	CustomFrom       string `json:"customFrom"`
+	CustomTo         string `json:"customTo"`
+	Resolution       string `json:"resolution"`
+	LabelChart       string `json:"labelChart"` // Field from labels to use for chart legend
+	RawLabels        bool   `json:"rawLabels"`  // Name fields after the metric ID and keep all dimensions as labels
+	Timezone         string `json:"timezone"`   // Dashboard timezone, e.g. "Europe/Vienna"
+	FillGaps         bool   `json:"fillGaps"`   // Insert null points for missing buckets
+
+	// Series transformation computed in the backend: "delta", "derivative"
+	// (per second) or "cumulative" (running total over the range)
+	Transform string `json:"transform"`
+
+	// Keep only series with the listed values of a dimension, or drop
+	// series with the listed values, e.g. {"dt.entity.host": ["HOST-1"]}
+	IncludeLabels map[string][]string `json:"includeLabels"`
+	ExcludeLabels map[string][]string `json:"excludeLabels"`
+
+	// Join all series on the time column into one frame: "outer" or "inner"
+	Join string `json:"join"`
+
+	// "long" returns a single frame with a row per series and timestamp,
+	// with the dimensions in dimensionOrder as the first columns; "numeric"
+	// a single value per series, see numericFrames
+	Format         string   `json:"format"`
+	DimensionOrder []string `json:"dimensionOrder"`
+
+	// Position of the time field: "timeFirst" (default) or "valueFirst"
+	FieldOrder string `json:"fieldOrder"`
+
+	// Minutes excluded from the end of the range; overrides the datasource setting
+	DataDelayMinutes int `json:"dataDelayMinutes"`
+
+	// Snap timestamps to resolution boundaries and drop the incomplete last bucket
+	AlignTimestamps   bool    `json:"alignTimestamps"`
+	DropPartialBucket bool    `json:"dropPartialBucket"`
+	QueryText         string  `json:"queryText"` // Metrics query parameters of the "advanced" query type
+	Constant          float64 `json:"constant"`
+
+	// Entity metadata attached to metric series as extra labels
+	Enrichment *entityEnrichment `json:"enrichment"`
+
+	// Attach traces from Grail as exemplars to service response time series
+	Exemplars bool `json:"exemplars"`
+
+	// Add lower and upper bounds of the expected range to each series,
+	// computed from the same time in the preceding weeks (default 4)
+	Baseline      bool `json:"baseline"`
+	BaselineWeeks int  `json:"baselineWeeks"`
+
+	// Add an "anomalous" field marking when metric events on the metric
+	// were open, and their static threshold
+	Anomalies bool `json:"anomalies"`
+
+	// Problems
+	ProblemSelector string `json:"problemSelector"`
+	ProblemId       string `json:"problemId"`
+	AlertingProfile string `json:"alertingProfile"` // Only problems the alerting profile would notify about
+
+	// Structured problem filters, combined with ProblemSelector: severity
+	// levels (e.g. "AVAILABILITY"), impact levels (e.g. "SERVICES") and
+	// "open" or "closed". The "problemCount" query type only uses severities.
+	ProblemSeverities []string `json:"problemSeverities"`
+	ProblemImpacts    []string `json:"problemImpacts"`
+	ProblemStatus     string   `json:"problemStatus"`
+
+	// Management zone selector of the "problems" query type, e.g.
+	// mzName("prod"); translated into a problem selector predicate
+	MzSelector string `json:"mzSelector"`
+
+	// Event types (e.g. "PROCESS_RESTART") and property values listed by
+	// the "events" query type, scoped by EntitySelector
+	EventTypes      []string          `json:"eventTypes"`
+	EventProperties map[string]string `json:"eventProperties"`
+
+	// Security problems, listed or aggregated as open vulnerabilities
+	// over time by risk level with the "riskLevel" aggregation
+	SecurityProblemSelector string `json:"securityProblemSelector"`
+	SecurityAggregation     string `json:"securityAggregation"`
+
+	// Browser (SYNTHETIC_TEST-...) or HTTP (HTTP_CHECK-...) monitor of the "synthetic" query type
+	SyntheticMonitorId string `json:"syntheticMonitorId"`
+	SyntheticSplitBy   string `json:"syntheticSplitBy"` // "location" adds the duration per location
+	SyntheticTimings   bool   `json:"syntheticTimings"` // Request phase timings of HTTP monitors
+	SyntheticGeo       bool   `json:"syntheticGeo"`     // Coordinates of each location
+
+	// Application attacks selector, e.g. state("EXPLOITED")
+	AttackSelector string `json:"attackSelector"`
+
+	// Billing
+	BillingPreset string `json:"billingPreset"`
+	BillingLimit  int    `json:"billingLimit"`
+
+	// Host units
+	HostUnitsGroupBy string `json:"hostUnitsGroupBy"` // "hostGroup" (default) or "managementZone"
+	HostUnitsMode    string `json:"hostUnitsMode"`    // "fullStack" (default) or "infrastructure"
+
+	// Entities
+	EntityTypes    []string `json:"entityTypes"`
+	ManagementZone string   `json:"managementZone"`
+
+	// Logs
+	LogQuery string `json:"logQuery"`
+	LogLimit int    `json:"logLimit"`
+	LogSort  string `json:"logSort"` // "asc" or "desc" by timestamp
+	Live     bool   `json:"live"`    // Tail new records over a Grafana Live channel
+
+	// Keys of JSON log bodies added as fields and labels, e.g. "http.status"
+	LogJSONFields []string `json:"logJsonFields"`
+
+	// SLO of the "slo" query type, evaluated as a single value or, with
+	// SloFormat "timeseries", per resolution slice
+	SloId     string `json:"sloId"`
+	SloFormat string `json:"sloFormat"`
+
+	// Web application (APPLICATION-...) of the "apdex" query type
+	ApplicationId string `json:"applicationId"`
+
+	// User session query of the "usql" query type and the frame shape of
+	// its result: "table", "pie" or "timeseries"; detected when empty
+	USQLQuery      string `json:"usqlQuery"`
+	USQLResultType string `json:"usqlResultType"`
+
+	// Grouping of the "sessionsGeo" query type: "country" (default) or
+	// "region", and the number of countries or regions returned
+	GeoGroupBy string `json:"geoGroupBy"`
+	GeoLimit   int    `json:"geoLimit"`
+
+	// Traces
+	TraceId      string `json:"traceId"`
+	TraceService string `json:"traceService"`
+	TraceLimit   int    `json:"traceLimit"`
+
+	// Service flow
+	ServiceId string `json:"serviceId"`
+
+	// Math over other queries, e.g. "($A / $B) * 100"
+	Expression string `json:"expression"`
+
+	// Advanced mode: full metrics query parameters given in QueryText
+	metricsParams url.Values
+
+	// Additional parameters of the metrics request, for Dynatrace API
+	// parameters not modeled by the plugin
+	ExtraParams map[string]string `json:"extraParams"`
+
+	// DQL query run on Grail by the "dql" query type and its request
+	// options, capped by the datasource ceilings
+	DQLQuery            string  `json:"dqlQuery"`
+	DQLMaxResultRecords int     `json:"maxResultRecords"`
+	DQLScanLimitGbytes  float64 `json:"scanLimitGbytes"`
+	DQLSamplingRatio    float64 `json:"samplingRatio"`
+	// ID of this run of the query, chosen by the editor to follow its
+	// progress before the query returns, see dqlProgressPath
+	DQLRunId string `json:"dqlRunId"`
+
+	// Releases selector of the "releases" query type, e.g. product("easytravel")
+	ReleasesSelector string `json:"releasesSelector"`
+
+	// Business events aggregated by the "bizevents" query type
+	BizEventType   string `json:"bizEventType"`   // e.g. "com.shop.order"
+	BizFilter      string `json:"bizFilter"`      // DQL filter condition, e.g. "amount > 100"
+	BizAggregation string `json:"bizAggregation"` // count (default), sum, avg, min or max
+	BizField       string `json:"bizField"`       // Field aggregated by sum, avg, min and max
+	BizGroupBy     string `json:"bizGroupBy"`     // Field to split series by
+	BizFormat      string `json:"bizFormat"`      // "timeseries" (default) or "table"
+
+	// Sorting and paging of the "problems", "securityProblems" and "entities"
+	// tables; the sort field is passed to the API, e.g. "startTime" or "name"
+	SortField     string `json:"sortField"`
+	SortDirection string `json:"sortDirection"` // "asc" (default) or "desc"
+	PageSize      int    `json:"pageSize"`      // Rows returned, 0 for all
+	PageOffset    int    `json:"pageOffset"`
+
+	// Fields kept in table frames, in order, e.g. ["displayId", "title", "status"]
+	Columns []string `json:"columns"`
+
+	// API version targeted by the query: "v2" (default) or "platform"
+	ApiVersion string `json:"apiVersion"`
+
+	// Cache false bypasses request coalescing, the cached metric descriptors and
+	// synthetic locations, and asks proxies not to cache, see withCacheBypass
+	Cache *bool `json:"cache"`
+
+	// Settings 2.0 objects
+	SettingsSchemaId string `json:"settingsSchemaId"`
+	SettingsScope    string `json:"settingsScope"` // Comma separated scopes, e.g. "environment"
 }
 
 // DynatraceMetricsResponse represents the response from Dynatrace Metrics V2 API
@@ -131,12 +660,11 @@ type DynatraceMetricData struct {
 	Dimensions   []interface{}     `json:"dimensions"`
 	DimensionMap map[string]string `json:"dimensionMap"`
 	Timestamps   []int64           `json:"timestamps"`
-	Values       []float64         `json:"values"`
+	// Values are nil for intervals without data
+	Values []*float64 `json:"values"`
 }
 
 func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery) backend.DataResponse {
-	var response backend.DataResponse
-
 	// Unmarshal the JSON into our queryModel.
 	var qm queryModel
 	err := json.Unmarshal(query.JSON, &qm)
@@ -145,43 +673,190 @@ func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, quer
 	}
 
 	// Log raw query JSON for debugging
-	log.DefaultLogger.Info("Raw query JSON", "json", string(query.JSON))
+	log.DefaultLogger.Debug("Raw query JSON", "json", d.logPayload(string(query.JSON)))
+
+	apiVersion, err := validateApiVersion(qm.ApiVersion)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	ctx = withApiVersion(ctx, apiVersion)
+	if qm.Cache != nil && !*qm.Cache {
+		ctx = withCacheBypass(ctx)
+	}
+
+	if len(qm.ExtraParams) > 0 && !extraParamsQueryTypes[query.QueryType] {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "extraParams is only supported by metrics, advanced and billing queries")
+	}
+
+	// Platform requests use the platform token, whose scopes can't be looked up
+	if apiVersion != apiVersionPlatform {
+		if err := d.checkQueryScopes(ctx, query.QueryType); err != nil {
+			return backend.ErrDataResponse(backend.StatusForbidden, err.Error())
+		}
+	}
+
+	start := time.Now()
+	ctx, stats := withQueryStats(ctx)
+	ctx, notices := withQueryNotices(ctx)
+	d.addTokenExpiryNotice(ctx)
+	resp := d.runQuery(ctx, pCtx, query, qm)
+	selectColumns(&resp, qm.Columns)
+	d.redactFrames(resp.Frames)
+	notices.attach(&resp)
+	d.logQuery(query, qm, resp, stats, time.Since(start))
+	d.countQuery(resp)
+	return resp
+}
+
+// runQuery dispatches a query to the handler of its query type.
+func (d *Datasource) runQuery(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	switch query.QueryType {
+	case "", queryTypeMetrics:
+		return d.queryMetrics(ctx, query, qm)
+	case queryTypeProblems:
+		return d.queryProblems(ctx, query, qm)
+	case queryTypeProblem:
+		return d.queryProblemDetails(ctx, qm)
+	case queryTypeNetworkZones:
+		return d.queryNetworkZones(ctx)
+	case queryTypeActiveGates:
+		return d.queryActiveGates(ctx)
+	case queryTypeBilling:
+		return d.queryBilling(ctx, query, qm)
+	case queryTypeHostUnits:
+		return d.queryHostUnits(ctx, query, qm)
+	case queryTypeEntityCount:
+		return d.queryEntityCount(ctx, query, qm)
+	case queryTypeDeployments:
+		return d.queryDeployments(ctx, query, qm)
+	case queryTypeLogs:
+		return d.queryLogs(ctx, pCtx, query, qm)
+	case queryTypeLogsVolume:
+		return d.queryLogsVolume(ctx, query, qm)
+	case queryTypeTraces:
+		return d.queryTraces(ctx, query, qm)
+	case queryTypeServiceFlow:
+		return d.queryServiceFlow(ctx, query, qm)
+	case queryTypeMetricEvents:
+		return d.queryMetricEvents(ctx)
+	case queryTypeSettings:
+		return d.querySettings(ctx, qm)
+	case queryTypeConstant:
+		return queryConstant(query, qm)
+	case queryTypeAdvanced:
+		return d.queryAdvanced(ctx, query, qm)
+	case queryTypeDQL:
+		return d.queryDQL(ctx, pCtx, query, qm)
+	case queryTypeBizEvents:
+		return d.queryBizEvents(ctx, query, qm)
+	case queryTypeReleases:
+		return d.queryReleases(ctx, query, qm)
+	case queryTypeSecurity:
+		return d.querySecurityProblems(ctx, query, qm)
+	case queryTypeAttacks:
+		return d.queryAttacks(ctx, query, qm)
+	case queryTypeAvailability:
+		return d.queryAvailability(ctx, query, qm)
+	case queryTypeSynthetic:
+		return d.querySynthetic(ctx, query, qm)
+	case queryTypeProblemCount:
+		return d.queryProblemCount(ctx, qm)
+	case queryTypeEntities:
+		return d.queryEntities(ctx, query, qm)
+	case queryTypeEvents:
+		return d.queryEvents(ctx, query, qm)
+	case queryTypeUSQL:
+		return d.queryUSQL(ctx, query, qm)
+	case queryTypeSLO:
+		return d.querySLO(ctx, query, qm)
+	case queryTypeApdex:
+		return d.queryApdex(ctx, query, qm)
+	case queryTypeSessionsGeo:
+		return d.querySessionsGeo(ctx, query, qm)
+	default:
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("unsupported query type: %s", query.QueryType))
+	}
+}
+
+// timeRange resolves the query time range in milliseconds, using either the
+// dashboard time range or the custom range configured on the query.
+func timeRange(qm queryModel, query backend.DataQuery) (int64, int64, error) {
+	if qm.UseDashboardTime {
+		return query.TimeRange.From.UnixMilli(), query.TimeRange.To.UnixMilli(), nil
+	}
+
+	fromMs, err := parseTimestamp(qm.CustomFrom)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid customFrom: %w", err)
+	}
+	toMs, err := parseTimestamp(qm.CustomTo)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid customTo: %w", err)
+	}
+	return fromMs, toMs, nil
+}
+
+// queryMetrics executes a metric selector against /api/v2/metrics/query and
+// converts every returned series into its own data frame.
+func (d *Datasource) queryMetrics(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	var response backend.DataResponse
 
 	// Determine which field to use (metricSelector takes precedence)
 	metricSelector := qm.MetricSelector
 	if metricSelector == "" {
 		// Fallback to legacy metricId field for backward compatibility
 		metricSelector = qm.MetricId
-		log.DefaultLogger.Info("Using legacy metricId field", "metricId", qm.MetricId)
+		log.DefaultLogger.Debug("Using legacy metricId field", "metricId", qm.MetricId)
 		// Add entitySelector as filter if provided (legacy support)
 		if qm.EntitySelector != "" {
 			metricSelector = fmt.Sprintf("%s:filter(%s)", metricSelector, qm.EntitySelector)
-			log.DefaultLogger.Info("Added entitySelector to metricSelector", "entitySelector", qm.EntitySelector)
+			log.DefaultLogger.Debug("Added entitySelector to metricSelector", "entitySelector", d.logPayload(qm.EntitySelector))
 		}
 	}
 
-	log.DefaultLogger.Info("Query model", "metricSelector", metricSelector, "useDashboardTime", qm.UseDashboardTime)
+	log.DefaultLogger.Debug("Query model", "metricSelector", d.logPayload(metricSelector), "useDashboardTime", qm.UseDashboardTime)
 
 	// Validate metric selector
 	if metricSelector == "" {
 		return backend.ErrDataResponse(backend.StatusBadRequest, "metricSelector or metricId is required")
 	}
 
+	if err := validateTransform(qm.Transform); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	if err := validateJoin(qm.Join); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	if err := validateFieldOrder(qm); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	if err := d.validateSelectorAggregations(ctx, metricSelector); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	metricsParams, err := mergeExtraParams(qm.metricsParams, qm.ExtraParams)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	qm.metricsParams = metricsParams
+
 	// Determine time range
-	var fromMs, toMs int64
-	if qm.UseDashboardTime {
-		// Use dashboard time range
-		fromMs = query.TimeRange.From.UnixMilli()
-		toMs = query.TimeRange.To.UnixMilli()
-	} else {
-		// Use custom time range
-		fromMs, err = parseTimestamp(qm.CustomFrom)
-		if err != nil {
-			return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid customFrom: %v", err))
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	// Leave out the most recent minutes, which Dynatrace may still be ingesting
+	delay := d.dataDelay
+	if qm.DataDelayMinutes > 0 {
+		delay = time.Duration(qm.DataDelayMinutes) * time.Minute
+	}
+	if delay > 0 {
+		if latest := time.Now().Add(-delay).UnixMilli(); latest < toMs {
+			toMs = latest
 		}
-		toMs, err = parseTimestamp(qm.CustomTo)
-		if err != nil {
-			return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid customTo: %v", err))
+		if toMs <= fromMs {
+			return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("time range lies entirely within the data delay of %s", delay))
 		}
 	}
 
@@ -190,22 +865,166 @@ func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, quer
 	if resolution == "" {
 		resolution = "5m"
 	}
+	if qm.Format == formatNumeric {
+		resolution = numericResolution
+	}
+
+	loc, err := dashboardLocation(qm.Timezone)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	// Daily and coarser buckets are aligned to the dashboard timezone instead
+	// of UTC, so that totals match the Dynatrace UI for the user's locale
+	var notices []data.Notice
+	var buckets [][2]time.Time
+	if step, err := parseResolution(resolution); err == nil && loc != time.UTC && step >= 24*time.Hour && step%(24*time.Hour) == 0 {
+		buckets = localBuckets(fromMs, toMs, loc, int(step/(24*time.Hour)))
+		if len(buckets) > maxLocalBuckets {
+			notices = append(notices, data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     fmt.Sprintf("Buckets are aligned to UTC because the range spans more than %d buckets of %s", maxLocalBuckets, resolution),
+			})
+			buckets = nil
+		}
+	}
 
 	// Query Dynatrace API using /api/v2/metrics/query endpoint
-	dynatraceResp, err := d.queryDynatraceAPI(ctx, metricSelector, fromMs, toMs, resolution)
+	var dynatraceResp *DynatraceMetricsResponse
+	if buckets != nil {
+		dynatraceResp, err = d.queryLocalBuckets(ctx, metricSelector, buckets, qm.metricsParams)
+	} else {
+		dynatraceResp, err = d.queryDynatraceAPI(ctx, metricSelector, fromMs, toMs, resolution, qm.metricsParams)
+	}
 	if err != nil {
 		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace API: %v", err))
 	}
 
+	// Retry at a coarser resolution if the result would exceed the data point budget
+	series := 0
+	for _, result := range dynatraceResp.Result {
+		series += len(result.Data)
+	}
+	if coarser, ok := coarserResolution(resolution, series, fromMs, toMs, metricsDataPointBudget); ok && buckets == nil {
+		log.DefaultLogger.Debug("Downgrading metrics resolution", "from", resolution, "to", coarser, "series", series)
+
+		coarserResp, err := d.queryDynatraceAPI(ctx, metricSelector, fromMs, toMs, coarser, qm.metricsParams)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace API: %v", err))
+		}
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text: fmt.Sprintf("Resolution was reduced from %s to %s because %d series at %s would exceed %d data points",
+				resolution, coarser, series, resolution, metricsDataPointBudget),
+		})
+		dynatraceResp, resolution = coarserResp, coarser
+	}
+
 	// Convert Dynatrace response to Grafana data frames
 	if len(dynatraceResp.Result) == 0 {
 		return backend.ErrDataResponse(backend.StatusNotFound, "no data returned from Dynatrace API")
 	}
 
+	if removed := filterMetricSeries(dynatraceResp, qm.IncludeLabels, qm.ExcludeLabels); removed > 0 {
+		log.DefaultLogger.Debug("Filtered series by label lists", "removed", removed)
+	}
+
+	if truncateMetricSeries(dynatraceResp, d.resultLimit()) {
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("Only the first %d series are shown; narrow the metric selector or raise maxResults", d.resultLimit()),
+		})
+	}
+
+	// Look up entity metadata to attach as extra labels
+	var entityLabels map[string]data.Labels
+	if qm.Enrichment.enabled() {
+		entityLabels, err = d.entityLabels(ctx, dynatraceResp, qm.Enrichment, fromMs, toMs)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error enriching series with entity metadata: %v", err))
+		}
+	}
+
+	// Week-over-week expected ranges from the same time in the preceding weeks
+	var references baselineReferences
+	if qm.Baseline {
+		if buckets != nil || qm.Transform != "" {
+			return backend.ErrDataResponse(backend.StatusBadRequest, "baseline cannot be combined with transforms or buckets aligned to the dashboard timezone")
+		}
+		if qm.BaselineWeeks > maxBaselineWeeks {
+			return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("baselineWeeks must be at most %d", maxBaselineWeeks))
+		}
+		references, err = d.fetchWeekOverWeekReferences(ctx, metricSelector, fromMs, toMs, qm.BaselineWeeks, resolution, qm.metricsParams)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying week-over-week baseline: %v", err))
+		}
+	}
+
+	// Anomaly windows of metric events defined on the metric
+	var anomalies *metricAnomalies
+	if qm.Anomalies {
+		anomalies, err = d.fetchMetricAnomalies(ctx, metricSelector, fromMs, toMs)
+		if err != nil {
+			log.DefaultLogger.Warn("Error fetching metric anomalies", "error", d.logError(err))
+			notices = append(notices, data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     fmt.Sprintf("Anomalies are unavailable: %v", err),
+			})
+		}
+	}
+
+	// Buckets ending after the cutoff are still being filled by Dynatrace
+	cutoffMs := toMs
+	if now := time.Now().UnixMilli(); now < cutoffMs {
+		cutoffMs = now
+	}
+	step, stepErr := parseResolution(resolution)
+
+	// Grid used to fill gaps: calendar buckets or the resolution
+	var gapStep time.Duration
+	var gapGrid []int64
+	gapTo := toMs
+	if qm.FillGaps {
+		if buckets != nil {
+			for _, bucket := range buckets {
+				if qm.DropPartialBucket && bucket[1].UnixMilli() > cutoffMs {
+					continue
+				}
+				gapGrid = append(gapGrid, bucket[0].UnixMilli())
+			}
+		} else if stepErr == nil {
+			gapStep = step
+			if qm.DropPartialBucket {
+				gapTo = cutoffMs - step.Milliseconds()
+			}
+		}
+	}
+
+	downsampled := 0
+	var frameSeries []metricSeries
 	for _, result := range dynatraceResp.Result {
 		for _, dataSet := range result.Data {
+			// Snap timestamps to bucket boundaries for stable alert evaluation
+			if buckets != nil && qm.DropPartialBucket {
+				n := len(dataSet.Timestamps)
+				if n > 0 && buckets[len(buckets)-1][1].UnixMilli() > cutoffMs && dataSet.Timestamps[n-1] == buckets[len(buckets)-1][0].UnixMilli() {
+					dataSet.Timestamps, dataSet.Values = dataSet.Timestamps[:n-1], dataSet.Values[:n-1]
+				}
+			} else if buckets == nil && stepErr == nil && (qm.AlignTimestamps || qm.DropPartialBucket) {
+				dataSet.Timestamps, dataSet.Values = alignSeries(dataSet.Timestamps, dataSet.Values, step, cutoffMs, qm.DropPartialBucket)
+			}
+
+			dataSet.Timestamps, dataSet.Values = applyTransform(qm.Transform, dataSet.Timestamps, dataSet.Values)
+
+			// Don't send the browser more points than the panel can draw;
+			// gap filled series keep their grid
+			if maxPoints := int(query.MaxDataPoints); maxPoints > 0 && len(dataSet.Values) > maxPoints && gapStep == 0 && gapGrid == nil {
+				dataSet.Timestamps, dataSet.Values = downsampleLTTB(dataSet.Timestamps, dataSet.Values, maxPoints)
+				downsampled++
+			}
+
 			// Log dimensionMap for debugging
-			log.DefaultLogger.Info("Processing data", "metricId", result.MetricId, "dimensionMap", dataSet.DimensionMap, "dimensionCount", len(dataSet.DimensionMap))
+			log.DefaultLogger.Debug("Processing data", "metricId", result.MetricId, "dimensionMap", d.logLabels(dataSet.DimensionMap), "dimensionCount", len(dataSet.DimensionMap))
 
 			// Add value field with labels from dimensionMap
 			// Note: dimensionMap can be nil or empty map, both are handled correctly by NewField
@@ -220,8 +1039,10 @@ func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, quer
 			fieldName := result.MetricId
 			fieldLabels := labels // Labels to attach to the field (keep all by default)
 
-			if len(labels) > 0 {
-				if qm.LabelChart != "" && qm.LabelChart != "" {
+			// Raw labels leave legends entirely to Grafana display name
+			// overrides and transformations
+			if len(labels) > 0 && !qm.RawLabels {
+				if qm.LabelChart != "" {
 					// User specified a labelChart field - use only that field for the name
 					if labelValue, exists := labels[qm.LabelChart]; exists {
 						// Use the specified label value for both frame and field names
@@ -229,9 +1050,9 @@ func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, quer
 						fieldName = labelValue
 						// Don't attach labels to the field to avoid duplication in legend
 						fieldLabels = nil
-						log.DefaultLogger.Info("Using labelChart field", "labelChart", qm.LabelChart, "value", labelValue)
+						log.DefaultLogger.Debug("Using labelChart field", "labelChart", qm.LabelChart, "value", d.logPayload(labelValue))
 					} else {
-						log.DefaultLogger.Warn("Label field not found in dimensionMap", "labelChart", qm.LabelChart, "availableLabels", labels)
+						log.DefaultLogger.Warn("Label field not found in dimensionMap", "labelChart", qm.LabelChart, "availableLabels", d.logLabels(labels))
 						// Fallback to default behavior: use all dimension values
 						dimensionValues := ""
 						for _, value := range labels {
@@ -275,87 +1096,162 @@ func (d *Datasource) query(ctx context.Context, pCtx backend.PluginContext, quer
 				}
 			}
 
+			if entityLabels != nil {
+				fieldLabels = enrichLabels(fieldLabels, labels, entityLabels)
+			}
+
 			// Create data frame with descriptive name
 			frame := data.NewFrame(frameName)
 
-			// Convert timestamps to time.Time
-			times := make([]time.Time, len(dataSet.Timestamps))
-			for i, ts := range dataSet.Timestamps {
-				times[i] = time.UnixMilli(ts)
-			}
-
-			// Add time field
-			frame.Fields = append(frame.Fields, data.NewField("time", nil, times))
+			log.DefaultLogger.Debug("Creating value field", "labels", d.logLabels(fieldLabels), "fieldName", d.logPayload(fieldName), "frameName", d.logPayload(frameName))
+			var valueField *data.Field
+			if gapStep > 0 || gapGrid != nil {
+				// Insert nulls for missing buckets so gaps render and series align
+				times, values := fillGaps(dataSet.Timestamps, dataSet.Values, fromMs, gapTo, gapStep, gapGrid)
+				frame.Fields = append(frame.Fields, data.NewField("time", nil, times))
+				valueField = data.NewField(fieldName, fieldLabels, values)
+			} else {
+				// Convert timestamps to time.Time
+				times := make([]time.Time, len(dataSet.Timestamps))
+				for i, ts := range dataSet.Timestamps {
+					times[i] = time.UnixMilli(ts)
+				}
 
-			log.DefaultLogger.Info("Creating value field", "labels", fieldLabels, "fieldName", fieldName, "frameName", frameName)
-			valueField := data.NewField(fieldName, fieldLabels, dataSet.Values)
+				// Add time field
+				frame.Fields = append(frame.Fields, data.NewField("time", nil, times))
+				valueField = data.NewField(fieldName, fieldLabels, dataSet.Values)
+			}
+			explorerLink := d.dataExplorerLink(metricSelector, fromMs, toMs)
+			valueField.Config = &data.FieldConfig{
+				Links: []data.DataLink{explorerLink},
+			}
 			frame.Fields = append(frame.Fields, valueField)
+			if references != nil {
+				references.addBaselineFields(frame, result.MetricId, dataSet.DimensionMap, fieldName, fieldLabels)
+			}
+			if anomalies != nil {
+				anomalies.addAnomalyFields(frame, dataSet.DimensionMap, fieldName, fieldLabels)
+			}
 
 			// Add metadata for better visualization
 			frame.Meta = &data.FrameMeta{
 				ExecutedQueryString: fmt.Sprintf("Metric: %s, Resolution: %s", result.MetricId, resolution),
+				// Let users reproduce the query in Dynatrace from the panel inspector
+				Notices: []data.Notice{{
+					Severity: data.NoticeSeverityInfo,
+					Text:     "Executed metric selector can be opened in Dynatrace Data Explorer",
+					Link:     explorerLink.URL,
+					Inspect:  data.InspectTypeMeta,
+				}},
 			}
 
 			// Add the frame to the response
 			response.Frames = append(response.Frames, frame)
+			frameSeries = append(frameSeries, metricSeries{metricId: result.MetricId, dimensions: dataSet.DimensionMap})
 		}
 	}
 
-	return response
-}
+	if downsampled > 0 {
+		notices = append(notices, data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     fmt.Sprintf("%d series were downsampled to %d points; choose a coarser resolution to avoid this", downsampled, query.MaxDataPoints),
+		})
+	}
 
-// queryDynatraceAPI queries the Dynatrace Metrics V2 API using /api/v2/metrics/query endpoint
-func (d *Datasource) queryDynatraceAPI(ctx context.Context, metricSelector string, fromMs, toMs int64, resolution string) (*DynatraceMetricsResponse, error) {
-	// Build URL for /api/v2/metrics/query endpoint with proper URL encoding
-	baseUrl := fmt.Sprintf("%s/api/v2/metrics/query", d.apiUrl)
+	// Panels that need a single table get all series in one frame
+	if qm.Join != "" && len(response.Frames) > 1 {
+		response.Frames = data.Frames{joinFrames(response.Frames, qm.Join)}
+	}
+	if qm.Format == formatLong && len(response.Frames) > 0 {
+		response.Frames = data.Frames{longFrame(response.Frames, frameSeries, qm.DimensionOrder)}
+	}
+	if qm.Format == formatNumeric {
+		response.Frames = numericFrames(response.Frames, frameSeries)
+	}
+	for _, frame := range response.Frames {
+		orderFrameFields(frame, qm.FieldOrder)
+	}
 
-	// Create URL with query parameters
-	params := url.Values{}
-	params.Add("metricSelector", metricSelector)
-	params.Add("from", fmt.Sprintf("%d", fromMs))
-	params.Add("to", fmt.Sprintf("%d", toMs))
-	params.Add("resolution", resolution)
+	if len(notices) > 0 && len(response.Frames) > 0 {
+		response.Frames[0].AppendNotices(notices...)
+	}
 
-	fullUrl := fmt.Sprintf("%s?%s", baseUrl, params.Encode())
+	// Attach representative traces as exemplars
+	if qm.Exemplars && supportsExemplars(metricSelector) && len(response.Frames) > 0 {
+		exemplars, err := d.exemplarFrame(ctx, exemplarServiceIds(dynatraceResp), fromMs, toMs, resolution)
+		if err != nil {
+			log.DefaultLogger.Warn("Error fetching exemplars", "error", d.logError(err))
+			response.Frames[0].AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     fmt.Sprintf("Exemplars are unavailable: %v", err),
+			})
+		} else {
+			response.Frames = append(response.Frames, exemplars)
+		}
+	}
 
-	log.DefaultLogger.Info("Querying Dynatrace API", "url", fullUrl)
+	return response
+}
 
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, "GET", fullUrl, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
-	}
+// maxMetricsQueryLength is the longest encoded query string sent with a GET
+// request. Common proxies reject request lines longer than 4-8 KiB.
+const maxMetricsQueryLength = 4000
+
+// queryDynatraceAPI queries the Dynatrace Metrics V2 API using /api/v2/metrics/query endpoint.
+// Selectors too long for a single request are split and the results merged.
+// Extra parameters such as mzSelector are sent as-is.
+func (d *Datasource) queryDynatraceAPI(ctx context.Context, metricSelector string, fromMs, toMs int64, resolution string, extra url.Values) (*DynatraceMetricsResponse, error) {
+	if len(metricSelector) > maxMetricSelectorLength {
+		selectors, ok := splitMetricSelector(metricSelector, maxMetricSelectorLength)
+		if !ok {
+			return nil, fmt.Errorf("metric selector is too long (%d characters) and cannot be split", len(metricSelector))
+		}
 
-	// Add authentication header
-	req.Header.Set("Authorization", fmt.Sprintf("Api-Token %s", d.apiToken))
-	req.Header.Set("Content-Type", "application/json")
+		log.DefaultLogger.Debug("Splitting long metric selector", "length", len(metricSelector), "queries", len(selectors))
 
-	// Create HTTP client with TLS configuration
-	client, err := d.createHTTPClient()
-	if err != nil {
-		return nil, fmt.Errorf("error creating HTTP client: %w", err)
+		responses := make([]*DynatraceMetricsResponse, 0, len(selectors))
+		for _, selector := range selectors {
+			resp, err := d.queryMetricsEndpoint(ctx, selector, fromMs, toMs, resolution, extra)
+			if err != nil {
+				return nil, err
+			}
+			responses = append(responses, resp)
+		}
+		return mergeMetricsResponses(responses)
 	}
 
-	// Execute request
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error executing request: %w", err)
-	}
-	defer resp.Body.Close()
+	return d.queryMetricsEndpoint(ctx, metricSelector, fromMs, toMs, resolution, extra)
+}
 
-	// Check response status
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Dynatrace API returned status %d: %s", resp.StatusCode, string(body))
+// queryMetricsEndpoint performs a single request against /api/v2/metrics/query.
+func (d *Datasource) queryMetricsEndpoint(ctx context.Context, metricSelector string, fromMs, toMs int64, resolution string, extra url.Values) (*DynatraceMetricsResponse, error) {
+	params := url.Values{}
+	params.Add("metricSelector", metricSelector)
+	params.Add("from", fmt.Sprintf("%d", fromMs))
+	params.Add("to", fmt.Sprintf("%d", toMs))
+	params.Add("resolution", resolution)
+	for key, values := range extra {
+		// The plugin's own parameters differ between the requests of a query
+		if !reservedExtraParams[key] {
+			params[key] = values
+		}
 	}
 
-	// Parse response
+	log.DefaultLogger.Debug("Querying Dynatrace API", "metricSelector", d.logPayload(metricSelector), "from", fromMs, "to", toMs, "resolution", resolution)
+
 	var dynatraceResp DynatraceMetricsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&dynatraceResp); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
+	if len(params.Encode()) > maxMetricsQueryLength {
+		// Long selectors would exceed URL length limits of proxies, so send
+		// them in the body of the POST form of the endpoint instead
+		log.DefaultLogger.Debug("Using POST for long metrics query", "length", len(params.Encode()))
+		if err := d.postForm(ctx, "/api/v2/metrics/query", params, &dynatraceResp); err != nil {
+			return nil, err
+		}
+	} else if err := d.get(ctx, "/api/v2/metrics/query", params, &dynatraceResp); err != nil {
+		return nil, err
 	}
 
-	log.DefaultLogger.Info("Dynatrace API response", "totalCount", dynatraceResp.TotalCount, "results", len(dynatraceResp.Result))
+	log.DefaultLogger.Debug("Dynatrace API response", "totalCount", dynatraceResp.TotalCount, "results", len(dynatraceResp.Result))
 
 	return &dynatraceResp, nil
 }
@@ -376,7 +1272,7 @@ func (d *Datasource) createHTTPClient() (*http.Client, error) {
 			return nil, fmt.Errorf("failed to parse TLS certificate")
 		}
 		tlsConfig.RootCAs = certPool
-		log.DefaultLogger.Info("Using custom TLS certificate")
+		log.DefaultLogger.Debug("Using custom TLS certificate")
 	}
 
 	// Create transport with TLS config
@@ -413,7 +1309,8 @@ func parseTimestamp(ts string) (int64, error) {
 // CheckHealth handles health checks sent from Grafana to the plugin.
 // The main use case for these health checks is the test button on the
 // datasource configuration page which allows users to verify that
-// a datasource is working as expected.
+// a datasource is working as expected. Every configured environment, the
+// environment API and the platform, is probed and reported in the details.
 func (d *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
 	log.DefaultLogger.Info("CheckHealth called")
 
@@ -432,44 +1329,9 @@ func (d *Datasource) CheckHealth(ctx context.Context, req *backend.CheckHealthRe
 		}, nil
 	}
 
-	// Test connection by querying the /health endpoint
-	url := fmt.Sprintf("%s/health", d.apiUrl)
-	reqHttp, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: fmt.Sprintf("Error creating health check request: %v", err),
-		}, nil
+	environments := []environmentHealth{d.probeEnvironment(ctx)}
+	if d.platformUrl != "" {
+		environments = append(environments, d.probePlatform(ctx))
 	}
-
-	// Create HTTP client with TLS configuration
-	client, err := d.createHTTPClient()
-	if err != nil {
-		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: fmt.Sprintf("Error creating HTTP client: %v", err),
-		}, nil
-	}
-
-	resp, err := client.Do(reqHttp)
-	if err != nil {
-		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: fmt.Sprintf("Error connecting to Dynatrace API: %v", err),
-		}, nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return &backend.CheckHealthResult{
-			Status:  backend.HealthStatusError,
-			Message: fmt.Sprintf("Dynatrace API health check failed (status %d): %s", resp.StatusCode, string(body)),
-		}, nil
-	}
-
-	return &backend.CheckHealthResult{
-		Status:  backend.HealthStatusOk,
-		Message: "Successfully connected to Dynatrace API",
-	}, nil
+	return healthResult(environments), nil
 }
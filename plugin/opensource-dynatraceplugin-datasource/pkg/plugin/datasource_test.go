@@ -2,7 +2,14 @@ package plugin
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 )
@@ -26,3 +33,178 @@ func TestQueryData(t *testing.T) {
 		t.Fatal("QueryData must return a response")
 	}
 }
+
+// newTestDatasource returns a datasource that talks to a test server serving handler.
+func newTestDatasource(t *testing.T, handler http.HandlerFunc) *Datasource {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	ds := &Datasource{
+		apiUrl:   server.URL,
+		apiToken: "test-token",
+	}
+
+	// Grant every scope so tests don't need to serve token lookups
+	var scopes []string
+	for _, required := range queryScopes {
+		scopes = append(scopes, required...)
+	}
+	ds.tokens.lookup = &DynatraceTokenLookup{Enabled: true, Scopes: scopes}
+	ds.tokens.expires = time.Now().Add(time.Hour)
+
+	return ds
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+// float64Ptrs returns values as a nullable series; NaN values become nulls.
+func float64Ptrs(values ...float64) []*float64 {
+	ptrs := make([]*float64, len(values))
+	for i, v := range values {
+		if !math.IsNaN(v) {
+			ptrs[i] = float64Ptr(v)
+		}
+	}
+	return ptrs
+}
+
+// formatValues formats a nullable series like fmt.Sprint formats []float64.
+func formatValues(values []*float64) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = "null"
+		if v != nil {
+			s[i] = fmt.Sprint(*v)
+		}
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+func TestQueryMetricsDataExplorerNotice(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {}, "timestamps": [1000], "values": [1.5]}
+		]}]}`))
+	})
+	ds.uiUrl = "https://abc123.live.dynatrace.com"
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"metricSelector": "builtin:host.cpu.usage", "customFrom": "1000", "customTo": "2000"}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+
+	notices := resp.Frames[0].Meta.Notices
+	if len(notices) != 1 {
+		t.Fatalf("expected a data explorer notice, got %d notices", len(notices))
+	}
+	if want := "https://abc123.live.dynatrace.com/ui/data-explorer?"; !strings.HasPrefix(notices[0].Link, want) {
+		t.Errorf("notice link = %s, want prefix %s", notices[0].Link, want)
+	}
+	if !strings.Contains(notices[0].Link, "gtf=c_1000_2000") {
+		t.Errorf("notice link should carry the query timeframe: %s", notices[0].Link)
+	}
+}
+
+func TestQueryMetricsLongSelectorUsesPost(t *testing.T) {
+	ids := make([]string, 300)
+	for i := range ids {
+		ids[i] = fmt.Sprintf(`"HOST-%016d"`, i)
+	}
+	selector := fmt.Sprintf(`builtin:host.cpu.usage:filter(in("dt.entity.host",%s))`, strings.Join(ids, ","))
+
+	for _, tt := range []struct {
+		selector string
+		method   string
+	}{
+		{"builtin:host.cpu.usage", http.MethodGet},
+		{selector, http.MethodPost},
+	} {
+		ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+			if req.Method != tt.method {
+				t.Errorf("method = %s, want %s", req.Method, tt.method)
+			}
+			if err := req.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Form.Get("metricSelector"); got != tt.selector {
+				t.Errorf("metricSelector was not sent as-is")
+			}
+			_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+				{"dimensionMap": {}, "timestamps": [1000], "values": [1.5]}
+			]}]}`))
+		})
+
+		if _, err := ds.queryDynatraceAPI(context.Background(), tt.selector, 1000, 2000, "5m", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestQueryMetricsDataDelay(t *testing.T) {
+	var to int64
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		to, _ = strconv.ParseInt(req.URL.Query().Get("to"), 10, 64)
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {}, "timestamps": [1000], "values": [1.5]}
+		]}]}`))
+	})
+	ds.dataDelay = 5 * time.Minute
+
+	now := time.Now()
+	from := now.Add(-time.Hour).UnixMilli()
+	for _, tt := range []struct {
+		json  string
+		delay time.Duration
+	}{
+		{`{"metricSelector": "builtin:host.cpu.usage", "customFrom": "%d"}`, 5 * time.Minute},
+		{`{"metricSelector": "builtin:host.cpu.usage", "customFrom": "%d", "dataDelayMinutes": 15}`, 15 * time.Minute},
+	} {
+		resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{RefID: "A", JSON: []byte(fmt.Sprintf(tt.json, from))})
+		if resp.Error != nil {
+			t.Fatal(resp.Error)
+		}
+		if latest := now.Add(-tt.delay).UnixMilli(); to > latest+1000 || to < latest-1000 {
+			t.Errorf("to = %d, want about %d for a delay of %s", to, latest, tt.delay)
+		}
+	}
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(fmt.Sprintf(`{"metricSelector": "builtin:host.cpu.usage", "customFrom": "%d"}`, now.Add(-time.Minute).UnixMilli())),
+	})
+	if resp.Status != backend.StatusBadRequest {
+		t.Errorf("ranges within the delay should be rejected, got status %d", resp.Status)
+	}
+}
+
+func TestQueryMetricsRawLabels(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {"dt.entity.host": "HOST-1", "dt.entity.host.name": "web-1"}, "timestamps": [1000], "values": [1]}
+		]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"metricSelector": "builtin:host.cpu.usage:splitBy(\"dt.entity.host\")", "useDashboardTime": true, "rawLabels": true, "labelChart": "dt.entity.host.name"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	value := frame.Fields[1]
+	if frame.Name != "builtin:host.cpu.usage" || value.Name != "builtin:host.cpu.usage" {
+		t.Errorf("frame %q and field %q should be named after the metric", frame.Name, value.Name)
+	}
+	if len(value.Labels) != 2 || value.Labels["dt.entity.host.name"] != "web-1" {
+		t.Errorf("expected the full dimension map as labels, got %v", value.Labels)
+	}
+}
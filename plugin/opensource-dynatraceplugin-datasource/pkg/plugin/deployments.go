@@ -0,0 +1,155 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// deploymentEventSelector selects deployment events from the Events V2 API.
+const deploymentEventSelector = `eventType("CUSTOM_DEPLOYMENT")`
+
+// queryDeployments returns deployment events for the selected entities as a
+// table, followed by a wide frame with one version field per entity that the
+// state timeline panel can render directly.
+func (d *Datasource) queryDeployments(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	events, err := d.fetchEvents(ctx, deploymentEventSelector, qm.EntitySelector, fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace events: %v", err))
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].StartTime < events[j].StartTime })
+
+	executed := fmt.Sprintf("Deployments: %s", qm.EntitySelector)
+
+	table := deploymentsFrame(events)
+	addFieldLinks(table, "entityId", d.entityLink(valuePlaceholder, fromMs, toMs))
+	table.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable, ExecutedQueryString: executed}
+
+	timeline := deploymentsTimelineFrame(events)
+	timeline.Meta = &data.FrameMeta{ExecutedQueryString: executed}
+
+	return backend.DataResponse{Frames: data.Frames{table, timeline}}
+}
+
+// deploymentVersion returns the deployed version, supporting both the current
+// dt.event.deployment.* properties and the legacy custom deployment properties.
+func deploymentVersion(e DynatraceEvent) string {
+	return e.property("dt.event.deployment.version", "deploymentVersion")
+}
+
+// deploymentsFrame converts deployment events into a table with one row per event.
+func deploymentsFrame(events []DynatraceEvent) *data.Frame {
+	frame := data.NewFrame("deployments",
+		data.NewField("time", nil, []time.Time{}),
+		data.NewField("timeEnd", nil, []*time.Time{}),
+		data.NewField("entity", nil, []string{}),
+		data.NewField("entityId", nil, []string{}),
+		data.NewField("deployment", nil, []string{}),
+		data.NewField("version", nil, []string{}),
+		data.NewField("source", nil, []string{}),
+		data.NewField("title", nil, []string{}),
+	)
+
+	for _, e := range events {
+		entity, entityId := deploymentEntity(e)
+		frame.AppendRow(
+			time.UnixMilli(e.StartTime),
+			problemEndTime(e.EndTime),
+			entity,
+			entityId,
+			e.property("dt.event.deployment.name", "deploymentName"),
+			deploymentVersion(e),
+			e.property("dt.event.deployment.source", "source"),
+			e.Title,
+		)
+	}
+
+	return frame
+}
+
+// deploymentsTimelineFrame builds the state timeline frame of deployment events.
+func deploymentsTimelineFrame(events []DynatraceEvent) *data.Frame {
+	changes := make([]versionChange, 0, len(events))
+	for _, e := range events {
+		entity, _ := deploymentEntity(e)
+		changes = append(changes, versionChange{entity: entity, time: e.StartTime, version: deploymentVersion(e)})
+	}
+	return versionTimelineFrame("deploymentTimeline", changes)
+}
+
+// versionChange is a version of an entity that went live at a timestamp in ms.
+type versionChange struct {
+	entity  string
+	time    int64
+	version string
+}
+
+// versionTimelineFrame builds a wide frame where each entity's field holds
+// the version live at or before each timestamp, so that state timelines
+// show how long every version was live. Values before an entity's first
+// version are null.
+func versionTimelineFrame(name string, changes []versionChange) *data.Frame {
+	var entities []string
+	versions := map[string]map[int64]string{}
+	timestampSet := map[int64]bool{}
+
+	for _, c := range changes {
+		if versions[c.entity] == nil {
+			versions[c.entity] = map[int64]string{}
+			entities = append(entities, c.entity)
+		}
+		versions[c.entity][c.time] = c.version
+		timestampSet[c.time] = true
+	}
+	sort.Strings(entities)
+
+	timestamps := make([]int64, 0, len(timestampSet))
+	for ts := range timestampSet {
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	times := make([]time.Time, len(timestamps))
+	for i, ts := range timestamps {
+		times[i] = time.UnixMilli(ts)
+	}
+
+	frame := data.NewFrame(name, data.NewField("time", nil, times))
+	for _, entity := range entities {
+		values := make([]*string, len(timestamps))
+		var current *string
+		for i, ts := range timestamps {
+			if v, ok := versions[entity][ts]; ok {
+				version := v
+				current = &version
+			}
+			values[i] = current
+		}
+		frame.Fields = append(frame.Fields, data.NewField(entity, nil, values))
+	}
+
+	return frame
+}
+
+// deploymentEntity returns the display name and ID of the entity an event was
+// reported on. Events without an entity are grouped under the environment.
+func deploymentEntity(e DynatraceEvent) (string, string) {
+	if e.EntityId == nil {
+		return "environment", ""
+	}
+	name := e.EntityId.Name
+	if name == "" {
+		name = e.EntityId.EntityId.Id
+	}
+	return name, e.EntityId.EntityId.Id
+}
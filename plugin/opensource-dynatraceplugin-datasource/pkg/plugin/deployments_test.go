@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"testing"
+)
+
+func TestDeploymentsTimelineFrame(t *testing.T) {
+	checkout := &DynatraceEntityStub{EntityId: DynatraceEntityId{Id: "SERVICE-1"}, Name: "checkout"}
+	cart := &DynatraceEntityStub{EntityId: DynatraceEntityId{Id: "SERVICE-2"}, Name: "cart"}
+
+	events := []DynatraceEvent{
+		{StartTime: 1000, EntityId: checkout, Properties: []DynatraceEventProperty{{Key: "dt.event.deployment.version", Value: "1.0"}}},
+		{StartTime: 2000, EntityId: cart, Properties: []DynatraceEventProperty{{Key: "deploymentVersion", Value: "7"}}},
+		{StartTime: 3000, EntityId: checkout, Properties: []DynatraceEventProperty{{Key: "dt.event.deployment.version", Value: "1.1"}}},
+	}
+
+	frame := deploymentsTimelineFrame(events)
+	if len(frame.Fields) != 3 {
+		t.Fatalf("expected time + 2 entity fields, got %d", len(frame.Fields))
+	}
+
+	cartField, _ := frame.FieldByName("cart")
+	if v := cartField.At(0).(*string); v != nil {
+		t.Errorf("cart before first deployment = %v, want null", *v)
+	}
+	if v := cartField.At(2).(*string); v == nil || *v != "7" {
+		t.Errorf("cart version should carry forward to 7")
+	}
+
+	checkoutField, _ := frame.FieldByName("checkout")
+	want := []string{"1.0", "1.0", "1.1"}
+	for i, w := range want {
+		if v := checkoutField.At(i).(*string); v == nil || *v != w {
+			t.Errorf("checkout[%d] = %v, want %s", i, v, w)
+		}
+	}
+
+	table := deploymentsFrame(events)
+	version, _ := table.FieldByName("version")
+	if version.At(1) != "7" {
+		t.Errorf("legacy deploymentVersion property not used: %v", version.At(1))
+	}
+}
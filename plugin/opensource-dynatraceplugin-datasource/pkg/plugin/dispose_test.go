@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestDisposeCancelsBackgroundWork(t *testing.T) {
+	settings := backend.DataSourceInstanceSettings{JSONData: json.RawMessage(`{"apiUrl": "http://localhost"}`)}
+	inst, err := NewDatasource(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := inst.(*Datasource)
+
+	ctx, cancel := ds.backgroundContext(context.Background())
+	defer cancel()
+
+	client, err := ds.client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := ds.client(); again != client {
+		t.Error("requests should share one HTTP client")
+	}
+
+	ds.Dispose()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("background context should be cancelled on Dispose")
+	}
+	if ds.httpClient != nil {
+		t.Error("Dispose should release the HTTP client")
+	}
+
+	// Disposing twice must not panic
+	ds.Dispose()
+}
@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// Grail query API endpoints
+const (
+	dqlExecutePath = "/platform/storage/query/v1/query:execute"
+	dqlPollPath    = "/platform/storage/query/v1/query:poll"
+)
+
+// dqlPollTimeoutMs is how long a single poll request waits for the query to finish.
+const dqlPollTimeoutMs = 5000
+
+// dqlExecuteRequest is the body of a Grail query:execute request
+type dqlExecuteRequest struct {
+	Query                 string  `json:"query"`
+	DefaultTimeframeStart string  `json:"defaultTimeframeStart,omitempty"`
+	DefaultTimeframeEnd   string  `json:"defaultTimeframeEnd,omitempty"`
+	MaxResultRecords      int     `json:"maxResultRecords,omitempty"`
+	ScanLimitGbytes       float64 `json:"defaultScanLimitGbytes,omitempty"`
+	SamplingRatio         float64 `json:"defaultSamplingRatio,omitempty"`
+}
+
+// dqlQueryResponse is returned by both query:execute and query:poll
+type dqlQueryResponse struct {
+	State        string     `json:"state"`
+	RequestToken string     `json:"requestToken"`
+	Progress     int        `json:"progress"`
+	Result       *dqlResult `json:"result"`
+}
+
+type dqlResult struct {
+	Records  []map[string]interface{} `json:"records"`
+	Metadata struct {
+		Grail struct {
+			ScannedBytes              int64 `json:"scannedBytes"`
+			ScannedRecords            int64 `json:"scannedRecords"`
+			ExecutionTimeMilliseconds int64 `json:"executionTimeMilliseconds"`
+		} `json:"grail"`
+	} `json:"metadata"`
+}
+
+// executeDQL runs a DQL query on Grail and polls until it completes. The
+// query time range is passed as the default timeframe. The datasource
+// ceilings are applied to the options. The Grail budget is charged with the
+// bytes the query scanned whatever its outcome, as failed and cancelled
+// queries are billed too; it uses the last scanned bytes Grail reported.
+func (d *Datasource) executeDQL(ctx context.Context, query string, fromMs, toMs int64, opts dqlOptions) (*dqlResult, error) {
+	if err := d.grailBudget.check(); err != nil {
+		return nil, err
+	}
+
+	opts, _ = d.limitDQLOptions(opts)
+	reqBody, err := json.Marshal(dqlExecuteRequest{
+		Query:                 query,
+		DefaultTimeframeStart: time.UnixMilli(fromMs).UTC().Format(time.RFC3339Nano),
+		DefaultTimeframeEnd:   time.UnixMilli(toMs).UTC().Format(time.RFC3339Nano),
+		MaxResultRecords:      opts.MaxResultRecords,
+		ScanLimitGbytes:       opts.ScanLimitGbytes,
+		SamplingRatio:         opts.SamplingRatio,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding request: %w", err)
+	}
+
+	log.DefaultLogger.Debug("Executing DQL query", "query", d.logPayload(query), "from", fromMs, "to", toMs)
+
+	body, err := d.doPlatformRequest(ctx, http.MethodPost, dqlExecutePath, nil, bytes.NewReader(reqBody), "application/json")
+	if err != nil {
+		return nil, err
+	}
+
+	var scannedBytes int64
+	defer func() { d.grailBudget.add(scannedBytes) }()
+
+	for {
+		var resp dqlQueryResponse
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, fmt.Errorf("error decoding response: %w", err)
+		}
+		reportDQLProgress(ctx, resp)
+		if resp.Result != nil {
+			scannedBytes = resp.Result.Metadata.Grail.ScannedBytes
+		}
+
+		switch resp.State {
+		case "SUCCEEDED":
+			if resp.Result == nil {
+				return &dqlResult{}, nil
+			}
+			return resp.Result, nil
+		case "RUNNING", "NOT_STARTED":
+		default:
+			return nil, fmt.Errorf("DQL query finished with state %s", resp.State)
+		}
+
+		params := url.Values{}
+		params.Set("request-token", resp.RequestToken)
+		params.Set("request-timeout-milliseconds", fmt.Sprintf("%d", dqlPollTimeoutMs))
+
+		body, err = d.doPlatformRequest(ctx, http.MethodGet, dqlPollPath, params, nil, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// dqlString quotes s as a DQL string literal.
+func dqlString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// recordString returns a record field as a string, or "" when it is absent.
+func recordString(record map[string]interface{}, key string) string {
+	v, ok := record[key]
+	if !ok || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+// recordTime parses a DQL timestamp field, which Grail returns as an RFC 3339 string.
+func recordTime(record map[string]interface{}, key string) (time.Time, bool) {
+	s := recordString(record, key)
+	if s == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// recordDuration parses a DQL duration field, returned in nanoseconds either
+// as a number or a numeric string.
+func recordDuration(record map[string]interface{}, key string) time.Duration {
+	switch v := record[key].(type) {
+	case float64:
+		return time.Duration(v)
+	case string:
+		var ns float64
+		if _, err := fmt.Sscanf(v, "%g", &ns); err == nil {
+			return time.Duration(ns)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,110 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// dqlAutocompletePath is the Grail endpoint suggesting completions of a
+// partial DQL query.
+const dqlAutocompletePath = "/platform/storage/query/v1/query:autocomplete"
+
+// dqlDataObjects are the record types that can be fetched with DQL.
+var dqlDataObjects = []string{"bizevents", "events", "logs", "spans"}
+
+// dqlFieldsSample is the number of recent records sampled to detect fields.
+const dqlFieldsSample = 200
+
+// dqlAutocompleteRequest is the body of POST /dql/autocomplete, forwarded to Grail.
+type dqlAutocompleteRequest struct {
+	Query          string `json:"query"`
+	CursorPosition *int   `json:"cursorPosition,omitempty"`
+}
+
+// handleDQLDataObjects serves GET /dql/dataObjects with the record types
+// available to fetch.
+func (d *Datasource) handleDQLDataObjects(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(rw, http.StatusOK, dqlDataObjects)
+}
+
+// handleDQLFields serves GET /dql/fields?dataObject=logs with the field
+// names found on the records of the last hour, so the DQL editor can offer
+// completions without platform credentials in the browser.
+func (d *Datasource) handleDQLFields(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	dataObject := req.URL.Query().Get("dataObject")
+	known := false
+	for _, o := range dqlDataObjects {
+		known = known || o == dataObject
+	}
+	if !known {
+		writeError(rw, http.StatusBadRequest, fmt.Sprintf("dataObject must be one of %v", dqlDataObjects))
+		return
+	}
+
+	toMs := time.Now().UnixMilli()
+	fromMs := toMs - time.Hour.Milliseconds()
+	result, err := d.executeDQL(req.Context(), fmt.Sprintf("fetch %s | limit %d", dataObject, dqlFieldsSample), fromMs, toMs, dqlOptions{MaxResultRecords: dqlFieldsSample})
+	if err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	seen := map[string]bool{}
+	fields := []string{}
+	for _, record := range result.Records {
+		for name := range record {
+			if !seen[name] {
+				seen[name] = true
+				fields = append(fields, name)
+			}
+		}
+	}
+	sort.Strings(fields)
+
+	writeJSON(rw, http.StatusOK, map[string]interface{}{"dataObject": dataObject, "fields": fields})
+}
+
+// handleDQLAutocomplete serves POST /dql/autocomplete by forwarding the
+// partial query to Grail's autocomplete endpoint and returning its
+// suggestions unchanged.
+func (d *Datasource) handleDQLAutocomplete(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var autocomplete dqlAutocompleteRequest
+	if err := json.NewDecoder(io.LimitReader(req.Body, 1<<20)).Decode(&autocomplete); err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+
+	reqBody, err := json.Marshal(autocomplete)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	body, err := d.doPlatformRequest(req.Context(), http.MethodPost, dqlAutocompletePath, nil, bytes.NewReader(reqBody), "application/json")
+	if err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = rw.Write(body)
+}
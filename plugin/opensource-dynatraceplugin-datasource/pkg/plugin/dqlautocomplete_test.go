@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleDQLFields(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		var body dqlExecuteRequest
+		_ = json.NewDecoder(req.Body).Decode(&body)
+		if body.Query != "fetch logs | limit 200" {
+			t.Errorf("query = %q", body.Query)
+		}
+		_, _ = rw.Write([]byte(`{"state": "SUCCEEDED", "result": {"records": [
+			{"timestamp": "2024-01-01T00:00:00Z", "content": "a"},
+			{"timestamp": "2024-01-01T00:00:01Z", "loglevel": "ERROR"}
+		]}}`))
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dql/fields?dataObject=logs", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Fields []string `json:"fields"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	if strings.Join(resp.Fields, ",") != "content,loglevel,timestamp" {
+		t.Errorf("fields = %v", resp.Fields)
+	}
+
+	rec = httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dql/fields?dataObject=dt.entity.host", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown data objects should be rejected, status = %d", rec.Code)
+	}
+}
+
+func TestHandleDQLAutocomplete(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != dqlAutocompletePath {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		body, _ := io.ReadAll(req.Body)
+		if string(body) != `{"query":"fetch lo","cursorPosition":8}` {
+			t.Errorf("body = %s", body)
+		}
+		_, _ = rw.Write([]byte(`{"suggestions": [{"suggestion": "logs"}]}`))
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/dql/autocomplete", strings.NewReader(`{"query": "fetch lo", "cursorPosition": 8}`)))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"logs"`) {
+		t.Errorf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+}
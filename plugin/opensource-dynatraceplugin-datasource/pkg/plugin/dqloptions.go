@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"fmt"
+)
+
+// dqlSamplingRatios are the sampling ratios accepted by Grail.
+var dqlSamplingRatios = []float64{1, 10, 100, 1000, 10000, 100000}
+
+// dqlOptions are the request options of a DQL query. Zero values leave the
+// Grail defaults in place.
+type dqlOptions struct {
+	MaxResultRecords int
+	ScanLimitGbytes  float64
+	SamplingRatio    float64
+}
+
+// validate checks the options given by a query.
+func (o dqlOptions) validate() error {
+	if o.MaxResultRecords < 0 {
+		return fmt.Errorf("maxResultRecords must not be negative")
+	}
+	if o.ScanLimitGbytes < 0 {
+		return fmt.Errorf("scanLimitGbytes must not be negative")
+	}
+	if o.SamplingRatio != 0 {
+		for _, ratio := range dqlSamplingRatios {
+			if o.SamplingRatio == ratio {
+				return nil
+			}
+		}
+		return fmt.Errorf("samplingRatio must be one of %v", dqlSamplingRatios)
+	}
+	return nil
+}
+
+// limitDQLOptions applies the ceilings configured on the datasource, so that
+// queries can't run unbounded scans. Options left unset default to the
+// ceiling. It returns a message for every option that was lowered.
+func (d *Datasource) limitDQLOptions(o dqlOptions) (dqlOptions, []string) {
+	var lowered []string
+	if max := d.dqlMaxResultRecords; max > 0 && (o.MaxResultRecords == 0 || o.MaxResultRecords > max) {
+		if o.MaxResultRecords > max {
+			lowered = append(lowered, fmt.Sprintf("maxResultRecords was lowered from %d to the datasource limit of %d", o.MaxResultRecords, max))
+		}
+		o.MaxResultRecords = max
+	}
+	if max := d.dqlMaxScanLimitGbytes; max > 0 && (o.ScanLimitGbytes == 0 || o.ScanLimitGbytes > max) {
+		if o.ScanLimitGbytes > max {
+			lowered = append(lowered, fmt.Sprintf("scanLimitGbytes was lowered from %g to the datasource limit of %g", o.ScanLimitGbytes, max))
+		}
+		o.ScanLimitGbytes = max
+	}
+	return o, lowered
+}
@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryDQLOptionsCeilings(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		var body dqlExecuteRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.MaxResultRecords != 1000 || body.ScanLimitGbytes != 50 || body.SamplingRatio != 100 {
+			t.Errorf("unexpected options %+v", body)
+		}
+		_, _ = rw.Write([]byte(`{"state": "SUCCEEDED", "result": {"records": []}}`))
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+	ds.dqlMaxResultRecords = 1000
+	ds.dqlMaxScanLimitGbytes = 50
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeDQL,
+		JSON:      []byte(`{"dqlQuery": "fetch logs", "useDashboardTime": true, "maxResultRecords": 100000, "samplingRatio": 100}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames[0].Meta.Notices) != 1 {
+		t.Errorf("expected a notice about the lowered record limit, got %+v", resp.Frames[0].Meta.Notices)
+	}
+}
+
+func TestDQLOptionsValidate(t *testing.T) {
+	if err := (dqlOptions{SamplingRatio: 100}).validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := (dqlOptions{SamplingRatio: 3}).validate(); err == nil {
+		t.Error("expected an error for an unsupported sampling ratio")
+	}
+	if err := (dqlOptions{ScanLimitGbytes: -1}).validate(); err == nil {
+		t.Error("expected an error for a negative scan limit")
+	}
+}
@@ -0,0 +1,232 @@
+package plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// dqlProgressPathPrefix is the Grafana Live path prefix of DQL progress channels.
+const dqlProgressPathPrefix = "dql/progress/"
+
+// dqlProgressInterval is how often a progress stream checks for updates.
+const dqlProgressInterval = 500 * time.Millisecond
+
+// dqlRunRetention is how long a finished run stays readable, so that clients
+// subscribing to the channel returned with the result still see its outcome.
+const dqlRunRetention = time.Minute
+
+// dqlRunIdPattern matches the run IDs the editor may choose, e.g. UUIDs.
+var dqlRunIdPattern = regexp.MustCompile(`^[A-Za-z0-9-]{8,64}$`)
+
+// dqlProgress is the state of a DQL query as published on its channel.
+type dqlProgress struct {
+	State          string
+	Progress       int
+	ScannedBytes   int64
+	ScannedRecords int64
+	Records        int
+	Done           bool
+}
+
+// dqlRun tracks a running DQL query so that its progress can be streamed and
+// the query cancelled from the editor.
+type dqlRun struct {
+	mu        sync.Mutex
+	progress  dqlProgress
+	cancel    context.CancelFunc
+	cancelled bool
+	// user is the login of the user who ran the query, the only one
+	// allowed to cancel it
+	user string
+}
+
+type dqlProgressKey struct{}
+
+// dqlProgressPath returns the channel path of a run of a DQL query. The
+// editor picks a new run ID for every run so that it can subscribe before the
+// query returns; runs without one get a random ID, announced with the result.
+// Every run thus has its own channel, even when several users run the same
+// query.
+func dqlProgressPath(runId string) (string, error) {
+	if runId == "" {
+		var nonce [16]byte
+		if _, err := rand.Read(nonce[:]); err != nil {
+			return "", fmt.Errorf("error generating DQL run ID: %w", err)
+		}
+		runId = hex.EncodeToString(nonce[:])
+	}
+	if !dqlRunIdPattern.MatchString(runId) {
+		return "", fmt.Errorf("invalid dqlRunId %q: must be 8 to 64 letters, digits or dashes", runId)
+	}
+	return dqlProgressPathPrefix + runId, nil
+}
+
+// startDQLRun registers a DQL query of user under its progress path and
+// returns a cancellable context reporting the query's progress to the run.
+// Paths of runs still registered can't be reused.
+func (d *Datasource) startDQLRun(ctx context.Context, path, user string) (context.Context, *dqlRun, error) {
+	d.dqlRunsMu.Lock()
+	defer d.dqlRunsMu.Unlock()
+	if _, ok := d.dqlRuns.Load(path); ok {
+		return nil, nil, fmt.Errorf("progress channel %s is already in use by another DQL run", path)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	run := &dqlRun{progress: dqlProgress{State: "NOT_STARTED"}, cancel: cancel, user: user}
+	d.dqlRuns.Store(path, run)
+	return context.WithValue(ctx, dqlProgressKey{}, run), run, nil
+}
+
+// endDQLRun unregisters a finished run once dqlRunRetention has passed.
+func (d *Datasource) endDQLRun(path string, run *dqlRun) {
+	time.AfterFunc(dqlRunRetention, func() { d.removeDQLRun(path, run) })
+}
+
+// removeDQLRun unregisters a run, unless another run has taken its path.
+func (d *Datasource) removeDQLRun(path string, run *dqlRun) {
+	d.dqlRunsMu.Lock()
+	defer d.dqlRunsMu.Unlock()
+	if current, ok := d.dqlRuns.Load(path); ok && current == run {
+		d.dqlRuns.Delete(path)
+	}
+}
+
+// reportDQLProgress records the state of a polled DQL query for the run
+// executed with ctx, if any, including what Grail scanned once the poll
+// response carries a result.
+func reportDQLProgress(ctx context.Context, resp dqlQueryResponse) {
+	run, ok := ctx.Value(dqlProgressKey{}).(*dqlRun)
+	if !ok {
+		return
+	}
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.progress.State = resp.State
+	run.progress.Progress = resp.Progress
+	if resp.Result != nil {
+		run.progress.ScannedBytes = resp.Result.Metadata.Grail.ScannedBytes
+		run.progress.ScannedRecords = resp.Result.Metadata.Grail.ScannedRecords
+		run.progress.Records = len(resp.Result.Records)
+	}
+}
+
+// finish marks the run as done with the outcome of the query.
+func (r *dqlRun) finish(result *dqlResult, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.progress.Done = true
+	switch {
+	case r.cancelled:
+		r.progress.State = "CANCELLED"
+	case err != nil:
+		r.progress.State = "FAILED"
+	default:
+		r.progress.State = "SUCCEEDED"
+		r.progress.Progress = 100
+		r.progress.ScannedBytes = result.Metadata.Grail.ScannedBytes
+		r.progress.ScannedRecords = result.Metadata.Grail.ScannedRecords
+		r.progress.Records = len(result.Records)
+	}
+	r.cancel()
+}
+
+// cancelQuery stops a running query.
+func (r *dqlRun) cancelQuery() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.progress.Done {
+		r.cancelled = true
+		r.cancel()
+	}
+}
+
+func (r *dqlRun) wasCancelled() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cancelled
+}
+
+func (r *dqlRun) snapshot() dqlProgress {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.progress
+}
+
+// runDQLProgressStream sends a progress frame whenever the DQL query behind
+// path changes state, until it is done or the last subscriber leaves. The
+// editor may subscribe before the query starts, so the stream waits for the
+// run to be registered.
+func (d *Datasource) runDQLProgressStream(ctx context.Context, path string, sender *backend.StreamSender) error {
+	ticker := time.NewTicker(dqlProgressInterval)
+	defer ticker.Stop()
+
+	var run *dqlRun
+
+	var last *dqlProgress
+	for {
+		if run == nil {
+			if r, ok := d.dqlRuns.Load(path); ok {
+				run = r.(*dqlRun)
+			}
+		}
+		if run != nil {
+			progress := run.snapshot()
+			if last == nil || progress != *last {
+				if err := sender.SendFrame(dqlProgressFrame(progress), data.IncludeAll); err != nil {
+					return err
+				}
+				last = &progress
+			}
+			if progress.Done {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDQLProgress handles messages sent by the editor to a progress
+// channel; {"cancel": true} from the user who ran the query cancels it.
+func (d *Datasource) publishDQLProgress(path, user string, raw json.RawMessage) backend.PublishStreamStatus {
+	var msg struct {
+		Cancel bool `json:"cancel"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil || !msg.Cancel {
+		return backend.PublishStreamStatusPermissionDenied
+	}
+	run, ok := d.dqlRuns.Load(path)
+	if !ok {
+		return backend.PublishStreamStatusNotFound
+	}
+	if run.(*dqlRun).user != user {
+		return backend.PublishStreamStatusPermissionDenied
+	}
+	run.(*dqlRun).cancelQuery()
+	return backend.PublishStreamStatusOK
+}
+
+// dqlProgressFrame is the frame sent on a DQL progress channel.
+func dqlProgressFrame(p dqlProgress) *data.Frame {
+	return data.NewFrame("progress",
+		data.NewField("time", nil, []time.Time{time.Now()}),
+		data.NewField("state", nil, []string{p.State}),
+		data.NewField("progress", nil, []int64{int64(p.Progress)}),
+		data.NewField("scannedBytes", nil, []int64{p.ScannedBytes}),
+		data.NewField("scannedRecords", nil, []int64{p.ScannedRecords}),
+		data.NewField("records", nil, []int64{int64(p.Records)}),
+		data.NewField("done", nil, []bool{p.Done}),
+	)
+}
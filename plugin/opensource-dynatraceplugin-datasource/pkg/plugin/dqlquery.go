@@ -0,0 +1,161 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+// queryDQL runs a DQL query on Grail and returns its records as a table.
+// While it runs, its progress is published on a Grafana Live channel named
+// after the run ID chosen by the editor, see dqlProgressPath; the channel is
+// also returned in the frame meta.
+func (d *Datasource) queryDQL(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	if strings.TrimSpace(qm.DQLQuery) == "" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "dqlQuery is required")
+	}
+
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	opts := dqlOptions{
+		MaxResultRecords: qm.DQLMaxResultRecords,
+		ScanLimitGbytes:  qm.DQLScanLimitGbytes,
+		SamplingRatio:    qm.DQLSamplingRatio,
+	}
+	if err := opts.validate(); err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	_, lowered := d.limitDQLOptions(opts)
+	for _, msg := range lowered {
+		addQueryNotice(ctx, data.Notice{Severity: data.NoticeSeverityWarning, Text: msg})
+	}
+
+	path, err := dqlProgressPath(qm.DQLRunId)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	var user string
+	if pCtx.User != nil {
+		user = pCtx.User.Login
+	}
+	ctx, run, err := d.startDQLRun(ctx, path, user)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	result, err := d.executeDQL(ctx, qm.DQLQuery, fromMs, toMs, opts)
+	run.finish(result, err)
+	d.endDQLRun(path, run)
+	if err != nil {
+		if run.wasCancelled() && errors.Is(err, context.Canceled) {
+			return backend.ErrDataResponse(backend.StatusBadRequest, "DQL query was cancelled")
+		}
+		if errors.Is(err, errGrailBudgetExhausted) {
+			return backend.ErrDataResponse(backend.StatusTooManyRequests, err.Error())
+		}
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error executing DQL query: %v", err))
+	}
+
+	frame := dqlFrame(result.Records)
+	frame.Meta = &data.FrameMeta{
+		ExecutedQueryString: fmt.Sprintf("DQL: %s", qm.DQLQuery),
+		Stats: []data.QueryStat{
+			{FieldConfig: data.FieldConfig{DisplayName: "Scanned bytes", Unit: "decbytes"}, Value: float64(result.Metadata.Grail.ScannedBytes)},
+			{FieldConfig: data.FieldConfig{DisplayName: "Scanned records"}, Value: float64(result.Metadata.Grail.ScannedRecords)},
+		},
+	}
+	if pCtx.DataSourceInstanceSettings != nil {
+		frame.Meta.Channel = live.Channel{
+			Scope:     live.ScopeDatasource,
+			Namespace: pCtx.DataSourceInstanceSettings.UID,
+			Path:      path,
+		}.String()
+	}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// dqlFrame converts DQL records into a table with one column per record
+// field. Column types follow the first non-null value: numbers, booleans
+// and RFC 3339 timestamps are kept typed, other values become strings.
+func dqlFrame(records []map[string]interface{}) *data.Frame {
+	var names []string
+	seen := map[string]bool{}
+	for _, record := range records {
+		for name := range record {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	frame := data.NewFrame("dql")
+	for _, name := range names {
+		var sample interface{}
+		for _, record := range records {
+			if v := record[name]; v != nil {
+				sample = v
+				break
+			}
+		}
+
+		switch sample.(type) {
+		case float64:
+			values := make([]*float64, len(records))
+			for i, record := range records {
+				if v, ok := record[name].(float64); ok {
+					values[i] = &v
+				}
+			}
+			frame.Fields = append(frame.Fields, data.NewField(name, nil, values))
+		case bool:
+			values := make([]*bool, len(records))
+			for i, record := range records {
+				if v, ok := record[name].(bool); ok {
+					values[i] = &v
+				}
+			}
+			frame.Fields = append(frame.Fields, data.NewField(name, nil, values))
+		default:
+			if _, isTime := recordTime(map[string]interface{}{name: sample}, name); isTime {
+				values := make([]*time.Time, len(records))
+				for i, record := range records {
+					if t, ok := recordTime(record, name); ok {
+						values[i] = &t
+					}
+				}
+				frame.Fields = append(frame.Fields, data.NewField(name, nil, values))
+				continue
+			}
+
+			values := make([]*string, len(records))
+			for i, record := range records {
+				switch v := record[name].(type) {
+				case nil:
+				case string:
+					values[i] = &v
+				default:
+					raw, err := json.Marshal(v)
+					if err == nil {
+						s := string(raw)
+						values[i] = &s
+					}
+				}
+			}
+			frame.Fields = append(frame.Fields, data.NewField(name, nil, values))
+		}
+	}
+	return frame
+}
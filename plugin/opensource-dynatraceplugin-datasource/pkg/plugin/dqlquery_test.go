@@ -0,0 +1,192 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryDQL(t *testing.T) {
+	polls := 0
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case dqlExecutePath:
+			_, _ = rw.Write([]byte(`{"state": "RUNNING", "requestToken": "tok", "progress": 10}`))
+		case dqlPollPath:
+			polls++
+			_, _ = rw.Write([]byte(`{"state": "SUCCEEDED", "progress": 100, "result": {
+				"records": [
+					{"timestamp": "2024-01-01T00:00:00Z", "content": "a", "count": 3, "ok": true},
+					{"timestamp": "2024-01-01T00:00:01Z", "content": null, "count": 4, "attrs": {"k": "v"}}
+				],
+				"metadata": {"grail": {"scannedBytes": 2048, "scannedRecords": 2}}
+			}}`))
+		}
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+
+	resp := ds.query(context.Background(), backend.PluginContext{
+		DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{UID: "dt"},
+	}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeDQL,
+		JSON:      []byte(`{"dqlQuery": "fetch logs", "useDashboardTime": true, "dqlRunId": "run-0001"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if polls != 1 {
+		t.Errorf("expected one poll, got %d", polls)
+	}
+
+	frame := resp.Frames[0]
+	if len(frame.Fields) != 5 {
+		t.Fatalf("expected 5 columns, got %d", len(frame.Fields))
+	}
+	if ts := frame.Fields[4].At(1).(*time.Time); ts == nil || ts.Second() != 1 {
+		t.Errorf("timestamp column should be parsed, got %v", ts)
+	}
+	if attrs := frame.Fields[0].At(1).(*string); attrs == nil || *attrs != `{"k":"v"}` {
+		t.Errorf("objects should be JSON encoded, got %v", attrs)
+	}
+	if frame.Meta.Channel != "ds/dt/dql/progress/run-0001" {
+		t.Errorf("channel = %q", frame.Meta.Channel)
+	}
+
+	// Subscribers arriving with the result still see the outcome
+	run, ok := ds.dqlRuns.Load("dql/progress/run-0001")
+	if !ok || run.(*dqlRun).snapshot().State != "SUCCEEDED" {
+		t.Error("the finished run should stay readable")
+	}
+}
+
+func TestDQLProgressPath(t *testing.T) {
+	path, err := dqlProgressPath("3f2b9c1e-7d4a-4f00-9a8e-1c2d3e4f5a6b")
+	if err != nil || path != "dql/progress/3f2b9c1e-7d4a-4f00-9a8e-1c2d3e4f5a6b" {
+		t.Errorf("path = %q, %v", path, err)
+	}
+	for _, runId := range []string{"short", "../../logs/tail/x", "run id with spaces"} {
+		if _, err := dqlProgressPath(runId); err == nil {
+			t.Errorf("expected an error for run ID %q", runId)
+		}
+	}
+
+	first, _ := dqlProgressPath("")
+	second, _ := dqlProgressPath("")
+	if first == second {
+		t.Errorf("runs without an ID should get distinct paths, got %q twice", first)
+	}
+}
+
+func TestDQLRunCancel(t *testing.T) {
+	ds := &Datasource{}
+	path := "dql/progress/run-0001"
+	ctx, run, err := ds.startDQLRun(context.Background(), path, "jane")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := ds.startDQLRun(context.Background(), path, "john"); err == nil {
+		t.Error("a path in use should not be taken over by another run")
+	}
+
+	reportDQLProgress(ctx, dqlQueryResponse{State: "RUNNING", Progress: 40})
+	if p := run.snapshot(); p.State != "RUNNING" || p.Progress != 40 {
+		t.Errorf("progress = %+v", p)
+	}
+
+	result := &dqlResult{Records: []map[string]interface{}{{}}}
+	result.Metadata.Grail.ScannedBytes, result.Metadata.Grail.ScannedRecords = 2048, 10
+	reportDQLProgress(ctx, dqlQueryResponse{State: "RUNNING", Progress: 90, Result: result})
+	if p := run.snapshot(); p.ScannedBytes != 2048 || p.ScannedRecords != 10 || p.Records != 1 {
+		t.Errorf("progress = %+v, want the scanned bytes and records of the poll response", p)
+	}
+	frame := dqlProgressFrame(run.snapshot())
+	if field, _ := frame.FieldByName("scannedRecords"); field == nil || field.At(0) != int64(10) {
+		t.Errorf("progress frame = %v, want scanned records", frame.Fields)
+	}
+
+	if status := ds.publishDQLProgress(path, "john", json.RawMessage(`{"cancel": true}`)); status != backend.PublishStreamStatusPermissionDenied {
+		t.Errorf("another user's cancel status = %v", status)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("another user should not cancel the query")
+	}
+	if status := ds.publishDQLProgress(path, "jane", json.RawMessage(`{"cancel": true}`)); status != backend.PublishStreamStatusOK {
+		t.Fatalf("publish status = %v", status)
+	}
+	if ctx.Err() == nil {
+		t.Error("cancelling should stop the query context")
+	}
+	run.finish(nil, ctx.Err())
+	if p := run.snapshot(); p.State != "CANCELLED" || !p.Done {
+		t.Errorf("progress = %+v", p)
+	}
+
+	ds.removeDQLRun(path, run)
+	if _, ok := ds.dqlRuns.Load(path); ok {
+		t.Error("the removed run should be unregistered")
+	}
+}
+
+// packetRecorder records the packets sent on a stream.
+type packetRecorder struct {
+	mu      sync.Mutex
+	packets []*backend.StreamPacket
+}
+
+func (r *packetRecorder) Send(packet *backend.StreamPacket) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.packets = append(r.packets, packet)
+	return nil
+}
+
+func TestDQLProgressStreamSubscribedBeforeQuery(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"state": "SUCCEEDED", "progress": 100, "result": {"records": [],
+			"metadata": {"grail": {"scannedBytes": 2048, "scannedRecords": 2}}}}`))
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+
+	// The editor subscribes with the run ID before sending the query
+	recorder := &packetRecorder{}
+	streamDone := make(chan error)
+	go func() {
+		streamDone <- ds.RunStream(context.Background(), &backend.RunStreamRequest{Path: "dql/progress/run-0001"}, backend.NewStreamSender(recorder))
+	}()
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeDQL,
+		JSON:      []byte(`{"dqlQuery": "fetch logs", "useDashboardTime": true, "dqlRunId": "run-0001"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	select {
+	case err := <-streamDone:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the progress stream should end once the query is done")
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.packets) == 0 {
+		t.Fatal("expected progress frames")
+	}
+	last := string(recorder.packets[len(recorder.packets)-1].Data)
+	if !strings.Contains(last, "SUCCEEDED") {
+		t.Errorf("last progress frame = %s, want the outcome", last)
+	}
+}
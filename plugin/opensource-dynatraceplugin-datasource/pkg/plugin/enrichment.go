@@ -0,0 +1,163 @@
+package plugin
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// entityEnrichment selects the entity metadata attached to metric series as
+// additional labels.
+type entityEnrichment struct {
+	HostGroup       bool     `json:"hostGroup"`
+	ManagementZones bool     `json:"managementZones"`
+	Tags            []string `json:"tags"`
+	Properties      []string `json:"properties"`
+}
+
+// enabled reports whether any enrichment has been requested.
+func (e *entityEnrichment) enabled() bool {
+	return e != nil && (e.HostGroup || e.ManagementZones || len(e.Tags) > 0 || len(e.Properties) > 0)
+}
+
+// fields returns the entities API fields needed for the requested enrichment.
+func (e *entityEnrichment) fields() string {
+	var fields []string
+	if e.HostGroup {
+		fields = append(fields, "properties.hostGroupName")
+	}
+	if e.ManagementZones {
+		fields = append(fields, "managementZones")
+	}
+	if len(e.Tags) > 0 {
+		fields = append(fields, "tags")
+	}
+	for _, p := range e.Properties {
+		fields = append(fields, "properties."+p)
+	}
+	return strings.Join(fields, ",")
+}
+
+// columns returns the label names produced by the enrichment, in the order
+// of the options.
+func (e *entityEnrichment) columns() []string {
+	var columns []string
+	if e.HostGroup {
+		columns = append(columns, "hostGroup")
+	}
+	if e.ManagementZones {
+		columns = append(columns, "managementZones")
+	}
+	for _, key := range e.Tags {
+		columns = append(columns, "tag."+key)
+	}
+	for _, p := range e.Properties {
+		columns = append(columns, p)
+	}
+	return columns
+}
+
+// labels returns the enrichment labels for a single entity. Tag labels are
+// prefixed with "tag." to keep them apart from dimension names.
+func (e *entityEnrichment) labels(entity DynatraceEntity) data.Labels {
+	labels := data.Labels{}
+	if e.HostGroup {
+		if group := entity.propertyString("hostGroupName"); group != "" {
+			labels["hostGroup"] = group
+		}
+	}
+	if e.ManagementZones && len(entity.ManagementZones) > 0 {
+		zones := make([]string, 0, len(entity.ManagementZones))
+		for _, mz := range entity.ManagementZones {
+			zones = append(zones, mz.Name)
+		}
+		sort.Strings(zones)
+		labels["managementZones"] = strings.Join(zones, ",")
+	}
+	for _, key := range e.Tags {
+		values := []string{}
+		for _, tag := range entity.Tags {
+			if tag.Key == key {
+				values = append(values, tag.Value)
+			}
+		}
+		if len(values) > 0 {
+			sort.Strings(values)
+			labels["tag."+key] = strings.Join(values, ",")
+		}
+	}
+	for _, p := range e.Properties {
+		if value := entity.propertyString(p); value != "" {
+			labels[p] = value
+		}
+	}
+	return labels
+}
+
+// seriesEntityIds returns the entity IDs referenced by a series' dimensions,
+// i.e. the values of "dt.entity.<type>" dimensions, in dimension key order.
+func seriesEntityIds(dimensionMap map[string]string) []string {
+	keys := make([]string, 0, len(dimensionMap))
+	for key := range dimensionMap {
+		if strings.HasPrefix(key, "dt.entity.") && !strings.HasSuffix(key, ".name") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	ids := make([]string, 0, len(keys))
+	for _, key := range keys {
+		ids = append(ids, dimensionMap[key])
+	}
+	return ids
+}
+
+// entityLabels fetches the entities referenced by the metrics response and
+// returns their enrichment labels keyed by entity ID.
+func (d *Datasource) entityLabels(ctx context.Context, resp *DynatraceMetricsResponse, enrichment *entityEnrichment, fromMs, toMs int64) (map[string]data.Labels, error) {
+	seen := map[string]bool{}
+	var ids []string
+	for _, result := range resp.Result {
+		for _, dataSet := range result.Data {
+			for _, id := range seriesEntityIds(dataSet.DimensionMap) {
+				if !seen[id] {
+					seen[id] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	entities, err := d.fetchEntitiesById(ctx, ids, enrichment.fields(), fromMs, toMs)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make(map[string]data.Labels, len(entities))
+	for id, entity := range entities {
+		labels[id] = enrichment.labels(entity)
+	}
+	return labels, nil
+}
+
+// enrichLabels returns a copy of fieldLabels extended with the enrichment
+// labels of every entity referenced by the series. Existing labels win.
+func enrichLabels(fieldLabels data.Labels, dimensionMap map[string]string, entityLabels map[string]data.Labels) data.Labels {
+	merged := data.Labels{}
+	for k, v := range fieldLabels {
+		merged[k] = v
+	}
+	for _, id := range seriesEntityIds(dimensionMap) {
+		for k, v := range entityLabels[id] {
+			if _, exists := merged[k]; !exists {
+				merged[k] = v
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestEntityEnrichmentLabels(t *testing.T) {
+	enrichment := &entityEnrichment{HostGroup: true, ManagementZones: true, Tags: []string{"team"}, Properties: []string{"osType"}}
+	if got, want := enrichment.fields(), "properties.hostGroupName,managementZones,tags,properties.osType"; got != want {
+		t.Errorf("fields = %s, want %s", got, want)
+	}
+
+	labels := enrichment.labels(DynatraceEntity{
+		Properties:      map[string]interface{}{"hostGroupName": "web", "osType": "LINUX"},
+		ManagementZones: []DynatraceManagementZone{{Name: "prod"}, {Name: "eu"}},
+		Tags:            []DynatraceTag{{Key: "team", Value: "payments"}, {Key: "env", Value: "prod"}},
+	})
+
+	want := map[string]string{"hostGroup": "web", "managementZones": "eu,prod", "tag.team": "payments", "osType": "LINUX"}
+	if len(labels) != len(want) {
+		t.Fatalf("labels = %v, want %v", labels, want)
+	}
+	for k, v := range want {
+		if labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, labels[k], v)
+		}
+	}
+}
+
+func TestQueryMetricsWithEnrichment(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v2/metrics/query":
+			_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+				{"dimensionMap": {"dt.entity.host": "HOST-1", "dt.entity.host.name": "web-1"}, "timestamps": [1000], "values": [42]}
+			]}]}`))
+		case "/api/v2/entities":
+			if got := req.URL.Query().Get("entitySelector"); got != `entityId("HOST-1")` {
+				t.Errorf("entitySelector = %q", got)
+			}
+			_, _ = rw.Write([]byte(`{"entities": [{"entityId": "HOST-1", "properties": {"hostGroupName": "web"}}]}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"metricSelector": "builtin:host.cpu.usage", "useDashboardTime": true, "labelChart": "dt.entity.host.name", "enrichment": {"hostGroup": true}}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	field := resp.Frames[0].Fields[1]
+	if field.Name != "web-1" {
+		t.Errorf("field name = %s, want web-1", field.Name)
+	}
+	if field.Labels["hostGroup"] != "web" {
+		t.Errorf("labels = %v, want hostGroup=web", field.Labels)
+	}
+}
@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// entityIdBatchSize bounds the number of IDs placed in a single entityId(...)
+// selector to keep request URLs short.
+const entityIdBatchSize = 100
+
+// DynatraceEntitiesResponse represents a page of the Monitored entities V2 API
+type DynatraceEntitiesResponse struct {
+	TotalCount  int               `json:"totalCount"`
+	PageSize    int               `json:"pageSize"`
+	NextPageKey *string           `json:"nextPageKey"`
+	Entities    []DynatraceEntity `json:"entities"`
+}
+
+type DynatraceEntity struct {
+	EntityId        string                    `json:"entityId"`
+	Type            string                    `json:"type"`
+	DisplayName     string                    `json:"displayName"`
+	FirstSeenTms    int64                     `json:"firstSeenTms"`
+	LastSeenTms     int64                     `json:"lastSeenTms"`
+	Properties      map[string]interface{}    `json:"properties"`
+	Tags            []DynatraceTag            `json:"tags"`
+	ManagementZones []DynatraceManagementZone `json:"managementZones"`
+
+	FromRelationships map[string][]DynatraceEntityId `json:"fromRelationships"`
+	ToRelationships   map[string][]DynatraceEntityId `json:"toRelationships"`
+}
+
+type DynatraceTag struct {
+	Context              string `json:"context"`
+	Key                  string `json:"key"`
+	Value                string `json:"value"`
+	StringRepresentation string `json:"stringRepresentation"`
+}
+
+// fetchEntities walks all pages of /api/v2/entities for an entity selector.
+// fields selects the optional entity fields to return (e.g. "tags,managementZones").
+func (d *Datasource) fetchEntities(ctx context.Context, entitySelector, fields string, fromMs, toMs int64) ([]DynatraceEntity, error) {
+	return d.fetchEntitiesPaged(ctx, entitySelector, fields, fromMs, toMs, tablePaging{})
+}
+
+// fetchEntitiesPaged fetches entities in the sort order of paging, stopping
+// once the pages covering the requested rows were fetched.
+func (d *Datasource) fetchEntitiesPaged(ctx context.Context, entitySelector, fields string, fromMs, toMs int64, paging tablePaging) ([]DynatraceEntity, error) {
+	params := url.Values{}
+	params.Set("entitySelector", entitySelector)
+	params.Set("pageSize", "500")
+	if fields != "" {
+		params.Set("fields", fields)
+	}
+	if fromMs > 0 {
+		params.Set("from", fmt.Sprintf("%d", fromMs))
+	}
+	if toMs > 0 {
+		params.Set("to", fmt.Sprintf("%d", toMs))
+	}
+	ctx = paging.apply(ctx, params, 500)
+
+	var entities []DynatraceEntity
+	err := d.getAllPages(ctx, "/api/v2/entities", params, func(body []byte) error {
+		var page DynatraceEntitiesResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		entities = append(entities, page.Entities...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// fetchEntitiesById looks up the given entity IDs in batches and returns them keyed by ID.
+func (d *Datasource) fetchEntitiesById(ctx context.Context, ids []string, fields string, fromMs, toMs int64) (map[string]DynatraceEntity, error) {
+	result := make(map[string]DynatraceEntity, len(ids))
+
+	for start := 0; start < len(ids); start += entityIdBatchSize {
+		end := start + entityIdBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		entities, err := d.fetchEntities(ctx, entityIdSelector(ids[start:end]), fields, fromMs, toMs)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entities {
+			result[e.EntityId] = e
+		}
+	}
+
+	return result, nil
+}
+
+// entityIdSelector builds an entityId("a","b",...) selector for the given IDs.
+func entityIdSelector(ids []string) string {
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = fmt.Sprintf("%q", id)
+	}
+	return fmt.Sprintf("entityId(%s)", strings.Join(quoted, ","))
+}
+
+// propertyString returns an entity property as a string, or "" when it is absent.
+func (e DynatraceEntity) propertyString(key string) string {
+	v, ok := e.Properties[key]
+	if !ok || v == nil {
+		return ""
+	}
+	switch value := v.(type) {
+	case string:
+		return value
+	case []interface{}:
+		// Multi-valued properties such as ipAddress
+		values := make([]string, len(value))
+		for i, item := range value {
+			values[i] = fmt.Sprint(item)
+		}
+		return strings.Join(values, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+// countEntities returns the number of entities matching an entity selector
+// without fetching them.
+func (d *Datasource) countEntities(ctx context.Context, entitySelector string, fromMs, toMs int64) (int64, error) {
+	params := url.Values{}
+	params.Set("entitySelector", entitySelector)
+	params.Set("pageSize", "1")
+	if fromMs > 0 {
+		params.Set("from", fmt.Sprintf("%d", fromMs))
+	}
+	if toMs > 0 {
+		params.Set("to", fmt.Sprintf("%d", toMs))
+	}
+
+	var page DynatraceEntitiesResponse
+	if err := d.get(ctx, "/api/v2/entities", params, &page); err != nil {
+		return 0, err
+	}
+	return int64(page.TotalCount), nil
+}
@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// entityBaseFields are the entities API fields of every entities table.
+const entityBaseFields = "firstSeenTms,lastSeenTms"
+
+// queryEntities lists the entities matching the entity selector as an
+// inventory table. The enrichment options add a column for the host group,
+// the management zones, each requested tag key ("tag.<key>") and each
+// requested property, e.g. "ipAddress" or "osType". Entities are sorted by
+// name unless the query sorts them by another field.
+func (d *Datasource) queryEntities(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	if qm.EntitySelector == "" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "entitySelector is required")
+	}
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	paging, err := newTablePaging(qm)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	// Pages are only stable in a fixed order, which is by name by default
+	if paging.sort == "" && paging.limit > 0 {
+		paging.sort = "+name"
+	}
+
+	fields := entityBaseFields
+	if qm.Enrichment.enabled() {
+		fields += "," + qm.Enrichment.fields()
+	}
+	entities, err := d.fetchEntitiesPaged(ctx, qm.EntitySelector, fields, fromMs, toMs, paging)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace entities: %v", err))
+	}
+	if paging.sort == "" {
+		sort.SliceStable(entities, func(i, j int) bool { return entities[i].DisplayName < entities[j].DisplayName })
+	}
+	start, end := paging.bounds(len(entities))
+	entities = entities[start:end]
+
+	frame := entitiesFrame(entities, qm.Enrichment)
+	addRelatedMetricsLink(frame, d.settings.UID, "entityId", "displayName")
+	addFieldLinks(frame, "entityId", d.entityLink(valuePlaceholder, fromMs, toMs))
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		ExecutedQueryString:    fmt.Sprintf("Entities: %s", qm.EntitySelector),
+	}
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// entitiesFrame converts entities into a table with one row per entity and
+// a column per enrichment label.
+func entitiesFrame(entities []DynatraceEntity, enrichment *entityEnrichment) *data.Frame {
+	frame := data.NewFrame("entities",
+		data.NewField("entityId", nil, []string{}),
+		data.NewField("displayName", nil, []string{}),
+		data.NewField("type", nil, []string{}),
+		data.NewField("firstSeen", nil, []*time.Time{}),
+		data.NewField("lastSeen", nil, []*time.Time{}),
+	)
+	for _, e := range entities {
+		frame.AppendRow(e.EntityId, e.DisplayName, e.Type, seenTime(e.FirstSeenTms), seenTime(e.LastSeenTms))
+	}
+
+	if !enrichment.enabled() {
+		return frame
+	}
+	labels := make([]data.Labels, len(entities))
+	for i, e := range entities {
+		labels[i] = enrichment.labels(e)
+	}
+	for _, column := range enrichment.columns() {
+		values := make([]string, len(entities))
+		for i := range entities {
+			values[i] = labels[i][column]
+		}
+		frame.Fields = append(frame.Fields, data.NewField(column, nil, values))
+	}
+	return frame
+}
@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryEntities(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got, want := req.URL.Query().Get("fields"), "firstSeenTms,lastSeenTms,managementZones,tags,properties.ipAddress"; got != want {
+			t.Errorf("fields = %s, want %s", got, want)
+		}
+		_, _ = rw.Write([]byte(`{"entities": [
+			{"entityId": "HOST-2", "displayName": "web-2", "type": "HOST", "firstSeenTms": 1000, "lastSeenTms": 2000,
+			 "properties": {"ipAddress": ["10.0.0.2", "fe80::2"]}},
+			{"entityId": "HOST-1", "displayName": "web-1", "type": "HOST", "firstSeenTms": 1000, "lastSeenTms": 2000,
+			 "managementZones": [{"name": "prod"}], "tags": [{"key": "team", "value": "payments"}],
+			 "properties": {"ipAddress": ["10.0.0.1"]}}
+		]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeEntities,
+		JSON: []byte(`{"useDashboardTime": true, "entitySelector": "type(HOST)",
+			"enrichment": {"managementZones": true, "tags": ["team"], "properties": ["ipAddress"]}}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	want := []string{"entityId", "displayName", "type", "firstSeen", "lastSeen", "managementZones", "tag.team", "ipAddress"}
+	// The related metrics link adds a selector field after the columns
+	if len(frame.Fields) != len(want)+1 {
+		t.Fatalf("got %d fields, want %d", len(frame.Fields), len(want)+1)
+	}
+	for i, name := range want {
+		if frame.Fields[i].Name != name {
+			t.Errorf("field %d = %s, want %s", i, frame.Fields[i].Name, name)
+		}
+	}
+
+	if got := frame.Fields[1].At(0); got != "web-1" {
+		t.Errorf("first row = %v, want web-1", got)
+	}
+	if got := frame.Fields[5].At(0); got != "prod" {
+		t.Errorf("managementZones = %v, want prod", got)
+	}
+	if got := frame.Fields[6].At(1); got != "" {
+		t.Errorf("tag.team of web-2 = %q, want empty", got)
+	}
+	if got := frame.Fields[7].At(1); got != "10.0.0.2,fe80::2" {
+		t.Errorf("ipAddress = %v, want 10.0.0.2,fe80::2", got)
+	}
+}
+
+func TestQueryEntitiesRequiresSelector(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s", req.URL)
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeEntities,
+		JSON:      []byte(`{"useDashboardTime": true}`),
+	})
+	if resp.Error == nil {
+		t.Fatal("expected an error without entitySelector")
+	}
+}
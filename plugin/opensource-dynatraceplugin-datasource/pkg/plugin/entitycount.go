@@ -0,0 +1,58 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// defaultCountEntityTypes are counted when the query doesn't list entity types.
+var defaultCountEntityTypes = []string{"HOST", "SERVICE", "PROCESS_GROUP_INSTANCE", "APPLICATION"}
+
+// queryEntityCount returns the number of monitored entities per entity type,
+// optionally restricted to a management zone, as a two-column table.
+func (d *Datasource) queryEntityCount(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	entityTypes := qm.EntityTypes
+	if len(entityTypes) == 0 {
+		entityTypes = defaultCountEntityTypes
+	}
+
+	frame := data.NewFrame("entityCount",
+		data.NewField("entityType", nil, []string{}),
+		data.NewField("count", nil, []int64{}),
+	)
+
+	for _, entityType := range entityTypes {
+		selector := entityCountSelector(entityType, qm.ManagementZone)
+		count, err := d.countEntities(ctx, selector, fromMs, toMs)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error counting %s entities: %v", entityType, err))
+		}
+		frame.AppendRow(entityType, count)
+	}
+
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		ExecutedQueryString:    fmt.Sprintf("Entity count: %s", strings.Join(entityTypes, ", ")),
+	}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// entityCountSelector builds the entity selector for one entity type,
+// scoped to a management zone when one is given.
+func entityCountSelector(entityType, managementZone string) string {
+	selector := fmt.Sprintf("type(%q)", entityType)
+	if managementZone != "" {
+		selector += fmt.Sprintf(",mzName(%q)", managementZone)
+	}
+	return selector
+}
@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryEntityCount(t *testing.T) {
+	counts := map[string]string{
+		`type("HOST"),mzName("prod")`:    `{"totalCount": 12}`,
+		`type("SERVICE"),mzName("prod")`: `{"totalCount": 40}`,
+	}
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		body, ok := counts[req.URL.Query().Get("entitySelector")]
+		if !ok {
+			t.Errorf("unexpected entitySelector %q", req.URL.Query().Get("entitySelector"))
+		}
+		_, _ = rw.Write([]byte(body))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeEntityCount,
+		JSON:      []byte(`{"useDashboardTime": true, "entityTypes": ["HOST", "SERVICE"], "managementZone": "prod"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	count := resp.Frames[0].Fields[1]
+	if count.At(0) != int64(12) || count.At(1) != int64(40) {
+		t.Errorf("counts = %v, %v, want 12, 40", count.At(0), count.At(1))
+	}
+}
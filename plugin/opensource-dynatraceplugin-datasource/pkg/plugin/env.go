@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// envSettings are the settings that can be read from environment variables.
+// The variable is named by the jsonData key <key>Env, e.g.
+// "apiTokenEnv": "DYNATRACE_API_TOKEN", so provisioned datasources don't
+// need to embed secrets.
+var envSettings = []struct {
+	key     string
+	secure  bool
+	boolean bool
+}{
+	{key: "apiUrl"},
+	{key: "platformUrl"},
+	{key: "tlsSkipVerify", boolean: true},
+	{key: "apiToken", secure: true},
+	{key: "platformToken", secure: true},
+	{key: "tlsCertificate", secure: true},
+}
+
+// applyEnvOverrides replaces settings referencing an environment variable
+// with its value. jsonData is updated in place; the secure settings are
+// returned as a copy. A referenced variable that is not set is an error,
+// so a missing secret mount is reported instead of silently ignored.
+func applyEnvOverrides(jsonData map[string]interface{}, secureData map[string]string) (map[string]string, error) {
+	secure := make(map[string]string, len(secureData))
+	for key, value := range secureData {
+		secure[key] = value
+	}
+
+	for _, setting := range envSettings {
+		name, ok := jsonData[setting.key+"Env"].(string)
+		if !ok || name == "" {
+			continue
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s referenced by %sEnv is not set", name, setting.key)
+		}
+
+		switch {
+		case setting.secure:
+			secure[setting.key] = value
+		case setting.boolean:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("environment variable %s referenced by %sEnv is not a boolean: %q", name, setting.key, value)
+			}
+			jsonData[setting.key] = b
+		default:
+			jsonData[setting.key] = value
+		}
+	}
+	return secure, nil
+}
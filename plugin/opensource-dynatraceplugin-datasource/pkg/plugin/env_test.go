@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestNewDatasourceEnvOverrides(t *testing.T) {
+	t.Setenv("TEST_DT_URL", "https://env.live.dynatrace.com")
+	t.Setenv("TEST_DT_TOKEN", "dt0c01.ENV")
+	t.Setenv("TEST_DT_SKIP_VERIFY", "true")
+
+	inst, err := NewDatasource(backend.DataSourceInstanceSettings{
+		JSONData: json.RawMessage(`{"apiUrl": "https://yaml.example.com", "apiUrlEnv": "TEST_DT_URL",
+			"apiTokenEnv": "TEST_DT_TOKEN", "tlsSkipVerifyEnv": "TEST_DT_SKIP_VERIFY"}`),
+		DecryptedSecureJSONData: map[string]string{"apiToken": "from-settings", "platformToken": "platform"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds := inst.(*Datasource)
+
+	if ds.apiUrl != "https://env.live.dynatrace.com" || ds.apiToken != "dt0c01.ENV" || !ds.tlsSkipVerify {
+		t.Errorf("environment should override settings: url=%s token=%s skip=%v", ds.apiUrl, ds.apiToken, ds.tlsSkipVerify)
+	}
+	if ds.platformToken != "platform" {
+		t.Errorf("settings without an environment reference should be kept, got %q", ds.platformToken)
+	}
+}
+
+func TestNewDatasourceMissingEnv(t *testing.T) {
+	_, err := NewDatasource(backend.DataSourceInstanceSettings{
+		JSONData: json.RawMessage(`{"apiTokenEnv": "TEST_DT_UNSET_VARIABLE"}`),
+	})
+	if err == nil {
+		t.Error("expected an error for an unset environment variable")
+	}
+}
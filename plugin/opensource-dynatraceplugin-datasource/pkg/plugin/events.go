@@ -0,0 +1,210 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// defaultAnnotationEventType is used for annotations that don't specify an event type.
+const defaultAnnotationEventType = "CUSTOM_ANNOTATION"
+
+// ingestableEventTypes lists the event types that may be created from Grafana annotations.
+var ingestableEventTypes = map[string]bool{
+	"CUSTOM_ANNOTATION":      true,
+	"CUSTOM_CONFIGURATION":   true,
+	"CUSTOM_DEPLOYMENT":      true,
+	"CUSTOM_INFO":            true,
+	"MARKED_FOR_TERMINATION": true,
+}
+
+// annotationEventRequest is the body accepted by the /events resource route.
+// Its shape follows Grafana's annotation model so that annotations can be
+// forwarded as-is.
+type annotationEventRequest struct {
+	EventType      string            `json:"eventType"`
+	Title          string            `json:"title"`
+	Text           string            `json:"text"`
+	Tags           []string          `json:"tags"`
+	Time           int64             `json:"time"`
+	TimeEnd        int64             `json:"timeEnd"`
+	EntitySelector string            `json:"entitySelector"`
+	Properties     map[string]string `json:"properties"`
+}
+
+// DynatraceEventIngest is the request body of the Events V2 ingest API
+type DynatraceEventIngest struct {
+	EventType      string            `json:"eventType"`
+	Title          string            `json:"title"`
+	StartTime      int64             `json:"startTime,omitempty"`
+	EndTime        int64             `json:"endTime,omitempty"`
+	EntitySelector string            `json:"entitySelector,omitempty"`
+	Properties     map[string]string `json:"properties,omitempty"`
+}
+
+// DynatraceEventIngestResults is the response of the Events V2 ingest API
+type DynatraceEventIngestResults struct {
+	ReportCount        int `json:"reportCount"`
+	EventIngestResults []struct {
+		CorrelationId string `json:"correlationId"`
+		Status        string `json:"status"`
+	} `json:"eventIngestResults"`
+}
+
+// handleEvents serves POST /events, mirroring a Grafana annotation into
+// Dynatrace through /api/v2/events/ingest. The route is disabled unless event
+// ingest is enabled in the datasource settings.
+func (d *Datasource) handleEvents(rw http.ResponseWriter, req *http.Request) {
+	if !d.enableEventIngest {
+		writeError(rw, http.StatusForbidden, "event ingest is not enabled for this datasource")
+		return
+	}
+	if req.Method != http.MethodPost {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var annotation annotationEventRequest
+	if err := json.NewDecoder(req.Body).Decode(&annotation); err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Sprintf("invalid event: %v", err))
+		return
+	}
+
+	event, err := annotationToEvent(annotation)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	log.DefaultLogger.Info("Ingesting Dynatrace event", "eventType", event.EventType, "title", d.logPayload(event.Title))
+
+	var result DynatraceEventIngestResults
+	if err := d.post(req.Context(), "/api/v2/events/ingest", nil, event, &result); err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	writeJSON(rw, http.StatusCreated, result)
+}
+
+// annotationToEvent validates an annotation and converts it into an event ingest body.
+func annotationToEvent(a annotationEventRequest) (*DynatraceEventIngest, error) {
+	eventType := strings.ToUpper(a.EventType)
+	if eventType == "" {
+		eventType = defaultAnnotationEventType
+	}
+	if !ingestableEventTypes[eventType] {
+		return nil, fmt.Errorf("unsupported event type: %s", a.EventType)
+	}
+
+	title := a.Title
+	if title == "" {
+		// Fall back to the first line of the annotation text
+		title = strings.TrimSpace(strings.SplitN(a.Text, "\n", 2)[0])
+	}
+	if title == "" {
+		return nil, fmt.Errorf("title or text is required")
+	}
+
+	properties := map[string]string{}
+	for k, v := range a.Properties {
+		properties[k] = v
+	}
+	if a.Text != "" {
+		properties["description"] = a.Text
+	}
+	if len(a.Tags) > 0 {
+		properties["tags"] = strings.Join(a.Tags, ",")
+	}
+	properties["source"] = "Grafana"
+
+	startTime := a.Time
+	if startTime == 0 {
+		startTime = time.Now().UnixMilli()
+	}
+	endTime := a.TimeEnd
+	if endTime != 0 && endTime < startTime {
+		return nil, fmt.Errorf("timeEnd must not be before time")
+	}
+
+	return &DynatraceEventIngest{
+		EventType:      eventType,
+		Title:          title,
+		StartTime:      startTime,
+		EndTime:        endTime,
+		EntitySelector: a.EntitySelector,
+		Properties:     properties,
+	}, nil
+}
+
+// DynatraceEventsResponse represents a page of the Events V2 API list endpoint
+type DynatraceEventsResponse struct {
+	TotalCount  int              `json:"totalCount"`
+	PageSize    int              `json:"pageSize"`
+	NextPageKey *string          `json:"nextPageKey"`
+	Events      []DynatraceEvent `json:"events"`
+}
+
+type DynatraceEvent struct {
+	EventId    string                   `json:"eventId"`
+	EventType  string                   `json:"eventType"`
+	Title      string                   `json:"title"`
+	Status     string                   `json:"status"`
+	StartTime  int64                    `json:"startTime"`
+	EndTime    int64                    `json:"endTime"`
+	EntityId   *DynatraceEntityStub     `json:"entityId"`
+	Properties []DynatraceEventProperty `json:"properties"`
+}
+
+type DynatraceEventProperty struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// property returns the value of the first of keys present on the event.
+func (e DynatraceEvent) property(keys ...string) string {
+	for _, key := range keys {
+		for _, p := range e.Properties {
+			if p.Key == key {
+				return p.Value
+			}
+		}
+	}
+	return ""
+}
+
+// fetchEvents walks all pages of /api/v2/events for the given selectors and time range.
+func (d *Datasource) fetchEvents(ctx context.Context, eventSelector, entitySelector string, fromMs, toMs int64) ([]DynatraceEvent, error) {
+	params := url.Values{}
+	params.Set("from", fmt.Sprintf("%d", fromMs))
+	params.Set("to", fmt.Sprintf("%d", toMs))
+	params.Set("pageSize", "1000")
+	if eventSelector != "" {
+		params.Set("eventSelector", eventSelector)
+	}
+	if entitySelector != "" {
+		params.Set("entitySelector", entitySelector)
+	}
+
+	log.DefaultLogger.Debug("Querying Dynatrace events", "eventSelector", d.logPayload(eventSelector), "entitySelector", d.logPayload(entitySelector), "from", fromMs, "to", toMs)
+
+	var events []DynatraceEvent
+	err := d.getAllPages(ctx, "/api/v2/events", params, func(body []byte) error {
+		var page DynatraceEventsResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		events = append(events, page.Events...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnnotationToEvent(t *testing.T) {
+	event, err := annotationToEvent(annotationEventRequest{
+		Text:    "Deployed v1.2.3\nfull changelog",
+		Tags:    []string{"release", "checkout"},
+		Time:    1000,
+		TimeEnd: 2000,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.EventType != defaultAnnotationEventType {
+		t.Errorf("eventType = %s, want %s", event.EventType, defaultAnnotationEventType)
+	}
+	if event.Title != "Deployed v1.2.3" {
+		t.Errorf("title = %q", event.Title)
+	}
+	if event.Properties["tags"] != "release,checkout" {
+		t.Errorf("tags property = %q", event.Properties["tags"])
+	}
+
+	if _, err := annotationToEvent(annotationEventRequest{EventType: "ERROR_EVENT", Title: "x"}); err == nil {
+		t.Error("expected unsupported event type to be rejected")
+	}
+	if _, err := annotationToEvent(annotationEventRequest{}); err == nil {
+		t.Error("expected missing title to be rejected")
+	}
+}
+
+func TestHandleEvents(t *testing.T) {
+	var ingested DynatraceEventIngest
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.URL.Path != "/api/v2/events/ingest" {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL.Path)
+		}
+		body, _ := io.ReadAll(req.Body)
+		_ = json.Unmarshal(body, &ingested)
+		rw.WriteHeader(http.StatusCreated)
+		_, _ = rw.Write([]byte(`{"reportCount": 1, "eventIngestResults": [{"correlationId": "abc", "status": "OK"}]}`))
+	})
+
+	body := `{"eventType": "custom_deployment", "title": "Release 42"}`
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected event ingest to be disabled by default, got status %d", rec.Code)
+	}
+	if ingested.Title != "" {
+		t.Fatalf("disabled event ingest sent an event: %+v", ingested)
+	}
+
+	ds.enableEventIngest = true
+	rec = httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events", bytes.NewBufferString(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if ingested.EventType != "CUSTOM_DEPLOYMENT" || ingested.Title != "Release 42" {
+		t.Errorf("unexpected ingested event: %+v", ingested)
+	}
+}
@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// queryEvents lists the events of the selected types, entities and property
+// values as a table. Its time, timeEnd and title fields let the same query
+// back annotations, e.g. for process restarts or configuration changes.
+func (d *Datasource) queryEvents(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	eventSelector, err := eventFilterSelector(qm)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	events, err := d.fetchEvents(ctx, eventSelector, qm.EntitySelector, fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace events: %v", err))
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].StartTime < events[j].StartTime })
+
+	frame := eventsFrame(events)
+	addFieldLinks(frame, "entityId", d.entityLink(valuePlaceholder, fromMs, toMs))
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		ExecutedQueryString:    fmt.Sprintf("Events: %s, entities: %s", eventSelector, qm.EntitySelector),
+	}
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// eventFilterSelector builds the event selector of the event type and
+// property filters of a query. Properties are matched in key order so the
+// selector is stable across requests.
+func eventFilterSelector(qm queryModel) (string, error) {
+	var parts []string
+	if len(qm.EventTypes) > 0 {
+		parts = append(parts, selectorPredicate("eventType", qm.EventTypes))
+	}
+	keys := make([]string, 0, len(qm.EventProperties))
+	for key := range qm.EventProperties {
+		if key == "" {
+			return "", fmt.Errorf("eventProperties keys must not be empty")
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, selectorPredicate("property."+key, []string{qm.EventProperties[key]}))
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// eventsFrame converts events into a table with one row per event.
+func eventsFrame(events []DynatraceEvent) *data.Frame {
+	frame := data.NewFrame("events",
+		data.NewField("time", nil, []time.Time{}),
+		data.NewField("timeEnd", nil, []*time.Time{}),
+		data.NewField("eventType", nil, []string{}),
+		data.NewField("title", nil, []string{}),
+		data.NewField("status", nil, []string{}),
+		data.NewField("entity", nil, []string{}),
+		data.NewField("entityId", nil, []string{}),
+	)
+	for _, e := range events {
+		entity, entityId := deploymentEntity(e)
+		frame.AppendRow(time.UnixMilli(e.StartTime), problemEndTime(e.EndTime), e.EventType, e.Title, e.Status, entity, entityId)
+	}
+	return frame
+}
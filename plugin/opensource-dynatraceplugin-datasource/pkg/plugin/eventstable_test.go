@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryEvents(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		if got, want := query.Get("eventSelector"), `eventType("PROCESS_RESTART","CUSTOM_CONFIGURATION"),property.dt.event.source("k8s"),property.team("payments")`; got != want {
+			t.Errorf("eventSelector = %q, want %q", got, want)
+		}
+		if got := query.Get("entitySelector"); got != "type(PROCESS_GROUP_INSTANCE)" {
+			t.Errorf("entitySelector = %q", got)
+		}
+		_, _ = rw.Write([]byte(`{"events": [
+			{"eventId": "2", "eventType": "CUSTOM_CONFIGURATION", "title": "Config changed", "status": "CLOSED", "startTime": 2000, "endTime": 3000},
+			{"eventId": "1", "eventType": "PROCESS_RESTART", "title": "Process restart", "status": "OPEN", "startTime": 1000, "endTime": -1,
+			 "entityId": {"entityId": {"id": "PROCESS_GROUP_INSTANCE-1", "type": "PROCESS_GROUP_INSTANCE"}, "name": "checkout"}}
+		]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeEvents,
+		JSON: []byte(`{"useDashboardTime": true, "entitySelector": "type(PROCESS_GROUP_INSTANCE)",
+			"eventTypes": ["PROCESS_RESTART", "CUSTOM_CONFIGURATION"], "eventProperties": {"team": "payments", "dt.event.source": "k8s"}}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	if frame.Rows() != 2 {
+		t.Fatalf("rows = %d, want 2", frame.Rows())
+	}
+	if got := frame.Fields[2].At(0); got != "PROCESS_RESTART" {
+		t.Errorf("first event type = %v, want PROCESS_RESTART", got)
+	}
+	if got := frame.Fields[5].At(0); got != "checkout" {
+		t.Errorf("entity = %v, want checkout", got)
+	}
+	if frame.Fields[1].At(0).(*time.Time) != nil {
+		t.Errorf("timeEnd of an open event = %v, want null", frame.Fields[1].At(0))
+	}
+}
+
+func TestEventFilterSelectorRejectsEmptyPropertyKey(t *testing.T) {
+	if _, err := eventFilterSelector(queryModel{EventProperties: map[string]string{"": "x"}}); err == nil {
+		t.Error("expected an error for an empty property key")
+	}
+}
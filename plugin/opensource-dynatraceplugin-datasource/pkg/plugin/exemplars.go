@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// exemplarMetricPrefix identifies the service response time metrics that
+// support exemplars. Their values are in microseconds, like span durations
+// once converted, so exemplars line up with the plotted series.
+const exemplarMetricPrefix = "builtin:service.response."
+
+// supportsExemplars reports whether every metric in the selector is a
+// service response time metric. The selector is split on its top level
+// commas, which separate the metrics of a multi-metric selector.
+func supportsExemplars(metricSelector string) bool {
+	metrics, ok := parseSelectorArgs(metricSelector+")", 0)
+	if !ok {
+		return false
+	}
+	for _, metric := range metrics.args {
+		if !strings.HasPrefix(selectorMetricKey.FindString(metric), exemplarMetricPrefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// exemplarFrame returns the slowest root span per service and resolution
+// bucket as an exemplar frame. The traceID field links to the traces query.
+func (d *Datasource) exemplarFrame(ctx context.Context, serviceIds []string, fromMs, toMs int64, resolution string) (*data.Frame, error) {
+	dql := "fetch spans\n| filter isNull(span.parent_id)"
+	if len(serviceIds) > 0 {
+		quoted := make([]string, len(serviceIds))
+		for i, id := range serviceIds {
+			quoted[i] = dqlString(id)
+		}
+		dql += fmt.Sprintf("\n| filter in(dt.entity.service, array(%s))", strings.Join(quoted, ", "))
+	}
+	dql += fmt.Sprintf("\n| sort duration desc\n| dedup {dt.entity.service, bin(start_time, %s)}\n| fields start_time, trace.id, duration, dt.entity.service", resolution)
+
+	result, err := d.executeDQL(ctx, dql, fromMs, toMs, dqlOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	frame := data.NewFrame("exemplar",
+		data.NewField("Time", nil, []time.Time{}),
+		data.NewField("Value", nil, []float64{}),
+		data.NewField("traceID", nil, []string{}).SetConfig(&data.FieldConfig{
+			Links: []data.DataLink{d.traceLink(fromMs, toMs)},
+		}),
+		data.NewField("dt.entity.service", nil, []string{}),
+	)
+
+	for _, r := range result.Records {
+		start, ok := recordTime(r, "start_time")
+		if !ok {
+			continue
+		}
+		durationUs := float64(recordDuration(r, "duration")) / float64(time.Microsecond)
+		frame.AppendRow(start, durationUs, recordString(r, "trace.id"), recordString(r, "dt.entity.service"))
+	}
+
+	frame.Meta = &data.FrameMeta{ExecutedQueryString: dql}
+	return frame, nil
+}
+
+// exemplarServiceIds returns the services referenced by the metrics response.
+func exemplarServiceIds(resp *DynatraceMetricsResponse) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, result := range resp.Result {
+		for _, dataSet := range result.Data {
+			if id := dataSet.DimensionMap["dt.entity.service"]; id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryMetricsWithExemplars(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v2/metrics/query":
+			_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:service.response.time", "data": [
+				{"dimensionMap": {"dt.entity.service": "SERVICE-1"}, "timestamps": [1000], "values": [1500]}
+			]}]}`))
+		case dqlExecutePath:
+			_, _ = rw.Write([]byte(`{"state": "SUCCEEDED", "result": {"records": [
+				{"start_time": "2024-01-01T00:00:00Z", "trace.id": "t1", "duration": 3000000, "dt.entity.service": "SERVICE-1"}
+			]}}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"metricSelector": "builtin:service.response.time:splitBy(\"dt.entity.service\")", "useDashboardTime": true, "exemplars": true}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 2 {
+		t.Fatalf("expected series + exemplar frames, got %d", len(resp.Frames))
+	}
+
+	exemplars := resp.Frames[1]
+	if exemplars.Name != "exemplar" {
+		t.Fatalf("frame name = %s", exemplars.Name)
+	}
+	value, _ := exemplars.FieldByName("Value")
+	if value.At(0) != 3000.0 {
+		t.Errorf("exemplar value = %v, want 3000us", value.At(0))
+	}
+	traceId, _ := exemplars.FieldByName("traceID")
+	if link := traceId.Config.Links[0].URL; !strings.Contains(link, "${__value.raw}") {
+		t.Errorf("trace link should keep the value placeholder unescaped: %s", link)
+	}
+}
+
+func TestQueryMetricsExemplarsWithoutPlatform(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:service.response.time", "data": [
+			{"dimensionMap": {}, "timestamps": [1000], "values": [1500]}
+		]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"metricSelector": "builtin:service.response.time", "useDashboardTime": true, "exemplars": true}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("missing platform settings should not fail the query: %v", resp.Error)
+	}
+	if len(resp.Frames) != 1 {
+		t.Fatalf("expected only the metric frame, got %d frames", len(resp.Frames))
+	}
+	warnings := 0
+	for _, notice := range resp.Frames[0].Meta.Notices {
+		if notice.Severity == data.NoticeSeverityWarning {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("expected a notice explaining missing exemplars")
+	}
+}
+
+func TestSupportsExemplars(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     bool
+	}{
+		{"builtin:service.response.time", true},
+		{`builtin:service.response.time:filter(in("dt.entity.service","SERVICE-1","SERVICE-2")):avg`, true},
+		{"builtin:service.response.time:avg,builtin:service.response.server:max", true},
+		{"builtin:service.response.time,builtin:host.cpu.usage", false},
+		{"builtin:host.cpu.usage,builtin:service.response.time", false},
+		{"builtin:host.cpu.usage", false},
+	}
+	for _, tt := range tests {
+		if got := supportsExemplars(tt.selector); got != tt.want {
+			t.Errorf("supportsExemplars(%q) = %v, want %v", tt.selector, got, tt.want)
+		}
+	}
+}
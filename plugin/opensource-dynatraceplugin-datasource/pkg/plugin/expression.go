@@ -0,0 +1,365 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// exprNode is a node of a parsed math expression such as ($A / $B) * 100.
+type exprNode interface {
+	eval(vars map[string][]exprSeries) (exprValue, error)
+}
+
+// exprValue is either a scalar or a set of series.
+type exprValue struct {
+	scalar   *float64
+	series   []exprSeries
+	isSeries bool
+}
+
+// exprSeries is a single time series referenced by an expression.
+type exprSeries struct {
+	name   string
+	labels data.Labels
+	points map[int64]float64
+}
+
+type exprNumber float64
+
+type exprVar string
+
+type exprNeg struct{ operand exprNode }
+
+type exprBinary struct {
+	op          byte
+	left, right exprNode
+}
+
+// queryExpression evaluates an expression query against the responses of the
+// other queries of the request, keyed by RefID.
+func queryExpression(qm queryModel, responses backend.Responses) backend.DataResponse {
+	if strings.TrimSpace(qm.Expression) == "" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "expression is required")
+	}
+
+	node, err := parseExpression(qm.Expression)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid expression: %v", err))
+	}
+
+	vars := map[string][]exprSeries{}
+	for refId, res := range responses {
+		if res.Error == nil {
+			vars[refId] = framesToSeries(res.Frames)
+		}
+	}
+
+	value, err := node.eval(vars)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	if !value.isSeries {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "expression must reference at least one query")
+	}
+
+	frames := make(data.Frames, 0, len(value.series))
+	for _, s := range value.series {
+		timestamps := make([]int64, 0, len(s.points))
+		for ts := range s.points {
+			timestamps = append(timestamps, ts)
+		}
+		sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+		times := make([]time.Time, len(timestamps))
+		values := make([]float64, len(timestamps))
+		for i, ts := range timestamps {
+			times[i] = time.UnixMilli(ts)
+			values[i] = s.points[ts]
+		}
+
+		frame := data.NewFrame(s.name,
+			data.NewField("time", nil, times),
+			data.NewField(s.name, s.labels, values),
+		)
+		frame.Meta = &data.FrameMeta{ExecutedQueryString: fmt.Sprintf("Expression: %s", qm.Expression)}
+		frames = append(frames, frame)
+	}
+
+	return backend.DataResponse{Frames: frames}
+}
+
+// framesToSeries extracts every numeric field of time series frames.
+func framesToSeries(frames data.Frames) []exprSeries {
+	var series []exprSeries
+	for _, frame := range frames {
+		var timeField *data.Field
+		for _, f := range frame.Fields {
+			if f.Type() == data.FieldTypeTime {
+				timeField = f
+				break
+			}
+		}
+		if timeField == nil {
+			continue
+		}
+
+		for _, f := range frame.Fields {
+			if !f.Type().Numeric() {
+				continue
+			}
+			s := exprSeries{name: f.Name, labels: f.Labels, points: map[int64]float64{}}
+			for i := 0; i < f.Len(); i++ {
+				v, err := f.NullableFloatAt(i)
+				if err != nil || v == nil {
+					continue
+				}
+				s.points[timeField.At(i).(time.Time).UnixMilli()] = *v
+			}
+			series = append(series, s)
+		}
+	}
+	return series
+}
+
+func (n exprNumber) eval(map[string][]exprSeries) (exprValue, error) {
+	v := float64(n)
+	return exprValue{scalar: &v}, nil
+}
+
+func (n exprVar) eval(vars map[string][]exprSeries) (exprValue, error) {
+	series, ok := vars[string(n)]
+	if !ok {
+		return exprValue{}, fmt.Errorf("query $%s has no data", string(n))
+	}
+	return exprValue{series: series, isSeries: true}, nil
+}
+
+func (n exprNeg) eval(vars map[string][]exprSeries) (exprValue, error) {
+	return exprBinary{op: '*', left: exprNumber(-1), right: n.operand}.eval(vars)
+}
+
+func (n exprBinary) eval(vars map[string][]exprSeries) (exprValue, error) {
+	left, err := n.left.eval(vars)
+	if err != nil {
+		return exprValue{}, err
+	}
+	right, err := n.right.eval(vars)
+	if err != nil {
+		return exprValue{}, err
+	}
+
+	switch {
+	case !left.isSeries && !right.isSeries:
+		v, ok := applyOp(n.op, *left.scalar, *right.scalar)
+		if !ok {
+			return exprValue{}, fmt.Errorf("division by zero")
+		}
+		return exprValue{scalar: &v}, nil
+	case !left.isSeries:
+		return exprValue{series: mapSeries(right.series, func(v float64) (float64, bool) { return applyOp(n.op, *left.scalar, v) }), isSeries: true}, nil
+	case !right.isSeries:
+		return exprValue{series: mapSeries(left.series, func(v float64) (float64, bool) { return applyOp(n.op, v, *right.scalar) }), isSeries: true}, nil
+	default:
+		return exprValue{series: joinSeries(n.op, left.series, right.series), isSeries: true}, nil
+	}
+}
+
+// applyOp applies an arithmetic operator, reporting false on division by zero.
+func applyOp(op byte, a, b float64) (float64, bool) {
+	switch op {
+	case '+':
+		return a + b, true
+	case '-':
+		return a - b, true
+	case '*':
+		return a * b, true
+	default:
+		if b == 0 {
+			return 0, false
+		}
+		return a / b, true
+	}
+}
+
+// mapSeries applies f to every point, dropping points where f fails.
+func mapSeries(series []exprSeries, f func(float64) (float64, bool)) []exprSeries {
+	out := make([]exprSeries, 0, len(series))
+	for _, s := range series {
+		mapped := exprSeries{name: s.name, labels: s.labels, points: map[int64]float64{}}
+		for ts, v := range s.points {
+			if r, ok := f(v); ok {
+				mapped.points[ts] = r
+			}
+		}
+		out = append(out, mapped)
+	}
+	return out
+}
+
+// joinSeries combines series with identical labels point by point, at the
+// timestamps present in both. A side with a single series is combined with
+// every series of the other side.
+func joinSeries(op byte, left, right []exprSeries) []exprSeries {
+	var out []exprSeries
+	combine := func(l, r exprSeries, labels data.Labels, name string) {
+		s := exprSeries{name: name, labels: labels, points: map[int64]float64{}}
+		for ts, lv := range l.points {
+			if rv, ok := r.points[ts]; ok {
+				if v, ok := applyOp(op, lv, rv); ok {
+					s.points[ts] = v
+				}
+			}
+		}
+		out = append(out, s)
+	}
+
+	switch {
+	case len(right) == 1:
+		for _, l := range left {
+			combine(l, right[0], l.labels, l.name)
+		}
+	case len(left) == 1:
+		for _, r := range right {
+			combine(left[0], r, r.labels, r.name)
+		}
+	default:
+		byLabels := map[string]exprSeries{}
+		for _, r := range right {
+			byLabels[r.labels.String()] = r
+		}
+		for _, l := range left {
+			if r, ok := byLabels[l.labels.String()]; ok {
+				combine(l, r, l.labels, l.name)
+			}
+		}
+	}
+	return out
+}
+
+// parseExpression parses an arithmetic expression over numbers and $RefID
+// variables with + - * / and parentheses.
+func parseExpression(expression string) (exprNode, error) {
+	p := &exprParser{input: expression}
+	node, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos)
+	}
+	return node, nil
+}
+
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t' || p.input[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for c := p.peek(); c == '+' || c == '-'; c = p.peek() {
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = exprBinary{op: c, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for c := p.peek(); c == '*' || c == '/'; c = p.peek() {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = exprBinary{op: c, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.peek() == '-' {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return exprNeg{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	switch c := p.peek(); {
+	case c == '(':
+		p.pos++
+		node, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis at position %d", p.pos)
+		}
+		p.pos++
+		return node, nil
+	case c == '$':
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.input) && isRefIdChar(p.input[p.pos]) {
+			p.pos++
+		}
+		if start == p.pos {
+			return nil, fmt.Errorf("missing query reference at position %d", start)
+		}
+		return exprVar(p.input[start:p.pos]), nil
+	case c >= '0' && c <= '9' || c == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (p.input[p.pos] >= '0' && p.input[p.pos] <= '9' || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+		}
+		return exprNumber(v), nil
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", c, p.pos)
+	}
+}
+
+func isRefIdChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestParseExpression(t *testing.T) {
+	for _, valid := range []string{"($A / $B) * 100", "-$A + 2.5", "$errors/$total", "1 - ($A)"} {
+		if _, err := parseExpression(valid); err != nil {
+			t.Errorf("parseExpression(%q): %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"", "$A +", "($A", "$ / 2", "$A $B", "A + 1"} {
+		if _, err := parseExpression(invalid); err == nil {
+			t.Errorf("parseExpression(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestQueryDataExpression(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		selector := req.URL.Query().Get("metricSelector")
+		switch {
+		case strings.Contains(selector, "errors"):
+			_, _ = rw.Write([]byte(`{"result": [{"metricId": "errors", "data": [
+				{"dimensionMap": {"service": "a"}, "timestamps": [1000, 2000, 3000], "values": [1, 2, 0]},
+				{"dimensionMap": {"service": "b"}, "timestamps": [1000, 2000], "values": [5, 5]}
+			]}]}`))
+		default:
+			_, _ = rw.Write([]byte(`{"result": [{"metricId": "total", "data": [
+				{"dimensionMap": {"service": "a"}, "timestamps": [1000, 2000, 3000], "values": [10, 0, 10]},
+				{"dimensionMap": {"service": "b"}, "timestamps": [2000], "values": [50]}
+			]}]}`))
+		}
+	})
+
+	resp, err := ds.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{RefID: "C", QueryType: queryTypeExpression, JSON: []byte(`{"expression": "($A / $B) * 100"}`)},
+			{RefID: "A", JSON: []byte(`{"metricSelector": "errors", "useDashboardTime": true}`)},
+			{RefID: "B", JSON: []byte(`{"metricSelector": "total", "useDashboardTime": true}`)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := resp.Responses["C"]
+	if res.Error != nil {
+		t.Fatal(res.Error)
+	}
+	if len(res.Frames) != 2 {
+		t.Fatalf("expected one series per service, got %d", len(res.Frames))
+	}
+	for _, frame := range res.Frames {
+		value := frame.Fields[1]
+		switch value.Labels["service"] {
+		case "a":
+			// The division by zero at 2000 is dropped
+			if value.Len() != 2 || value.At(0) != 10.0 || value.At(1) != 0.0 {
+				t.Errorf("unexpected series a: %v", frame.Fields)
+			}
+		case "b":
+			if value.Len() != 1 || value.At(0) != 10.0 {
+				t.Errorf("unexpected series b: %v", frame.Fields)
+			}
+		default:
+			t.Errorf("unexpected labels %v", value.Labels)
+		}
+	}
+}
+
+func TestQueryExpressionUnknownReference(t *testing.T) {
+	res := queryExpression(queryModel{Expression: "$Z * 2"}, backend.Responses{})
+	if res.Status != backend.StatusBadRequest || !strings.Contains(res.Error.Error(), "$Z") {
+		t.Errorf("expected a bad request naming the missing query, got %v", res.Error)
+	}
+}
@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+)
+
+// reservedExtraParams are set by the plugin itself and cannot be overridden
+// with extraParams.
+//
+// A deny-list is enough here because extra parameters can't reach anything
+// but the query string of a metrics request: the host, path and method are
+// fixed, values are URL-encoded, and the token is sent in the Authorization
+// header, which no query parameter replaces. What remains to protect are the
+// parameters the plugin relies on, listed here, so that time range,
+// resolution and paging stay under its control. An allow-list would defeat
+// the purpose of passing parameters the plugin doesn't model yet.
+var reservedExtraParams = map[string]bool{
+	"metricSelector": true,
+	"resolution":     true,
+	"from":           true,
+	"to":             true,
+	"nextPageKey":    true,
+	"pageSize":       true,
+	"Api-Token":      true,
+}
+
+// extraParamsQueryTypes are the query types whose metrics request carries
+// the extraParams of the query.
+var extraParamsQueryTypes = map[string]bool{
+	"":                true,
+	queryTypeMetrics:  true,
+	queryTypeAdvanced: true,
+	queryTypeBilling:  true,
+}
+
+// extraParamName matches valid names of extra query parameters.
+var extraParamName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+
+// mergeExtraParams adds the extraParams of a query to the parameters of its
+// metrics request, so that Dynatrace API parameters not modeled by the
+// plugin can be used. Reserved and malformed names are rejected, as are
+// parameters already given in advanced mode.
+func mergeExtraParams(params url.Values, extra map[string]string) (url.Values, error) {
+	if len(extra) == 0 {
+		return params, nil
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	merged := url.Values{}
+	for key, values := range params {
+		merged[key] = values
+	}
+	for _, name := range names {
+		switch {
+		case !extraParamName.MatchString(name):
+			return nil, fmt.Errorf("invalid extra parameter name %q", name)
+		case reservedExtraParams[name]:
+			return nil, fmt.Errorf("extra parameter %s is set by the plugin and cannot be overridden", name)
+		case merged.Has(name):
+			return nil, fmt.Errorf("extra parameter %s is already given in queryText", name)
+		}
+		merged.Set(name, extra[name])
+	}
+	return merged, nil
+}
@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryMetricsExtraParams(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got := req.URL.Query().Get("mzSelector"); got != `mzName("prod")` {
+			t.Errorf("mzSelector = %q", got)
+		}
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {}, "timestamps": [1000], "values": [1]}
+		]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"metricSelector": "builtin:host.cpu.usage", "useDashboardTime": true, "extraParams": {"mzSelector": "mzName(\"prod\")"}}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+}
+
+func TestMergeExtraParams(t *testing.T) {
+	base := url.Values{"entitySelector": {"type(HOST)"}}
+	tests := []struct {
+		name  string
+		extra map[string]string
+		valid bool
+	}{
+		{name: "new parameter", extra: map[string]string{"mzSelector": "mzId(1)"}, valid: true},
+		{name: "reserved", extra: map[string]string{"resolution": "1h"}},
+		{name: "malformed", extra: map[string]string{"a&b": "1"}},
+		{name: "duplicate", extra: map[string]string{"entitySelector": "type(SERVICE)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := mergeExtraParams(base, tt.extra)
+			if (err == nil) != tt.valid {
+				t.Fatalf("error = %v", err)
+			}
+			if tt.valid && merged.Get("entitySelector") != "type(HOST)" {
+				t.Errorf("existing parameters should be kept: %v", merged)
+			}
+		})
+	}
+	if len(base) != 1 {
+		t.Errorf("the base parameters should not be modified: %v", base)
+	}
+}
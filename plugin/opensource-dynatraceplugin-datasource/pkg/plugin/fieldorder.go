@@ -0,0 +1,193 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Orders of the time field relative to the other fields of a frame.
+const (
+	fieldOrderTimeFirst  = "timeFirst"
+	fieldOrderValueFirst = "valueFirst"
+)
+
+// formatLong is the metrics format with one row per series and timestamp.
+const formatLong = "long"
+
+// validateFieldOrder checks the field order and format of a metrics query.
+func validateFieldOrder(qm queryModel) error {
+	switch qm.FieldOrder {
+	case "", fieldOrderTimeFirst, fieldOrderValueFirst:
+	default:
+		return fmt.Errorf("invalid fieldOrder %q: must be %q or %q", qm.FieldOrder, fieldOrderTimeFirst, fieldOrderValueFirst)
+	}
+	switch qm.Format {
+	case "":
+	case formatLong:
+		if qm.Join != "" {
+			return fmt.Errorf("the long format cannot be combined with join")
+		}
+	case formatNumeric:
+		// These need series over time, which the numeric resolution doesn't return
+		if qm.Join != "" || qm.Exemplars || qm.Baseline || qm.Anomalies {
+			return fmt.Errorf("the numeric format cannot be combined with join, exemplars, baseline or anomalies")
+		}
+		if qm.QueryText != "" {
+			if params, err := parseAdvancedQuery(qm.QueryText); err == nil && params.Get("resolution") != "" {
+				return fmt.Errorf("the numeric format cannot be combined with a resolution, it always uses %q", numericResolution)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid format %q: must be %q or %q", qm.Format, formatLong, formatNumeric)
+	}
+	return nil
+}
+
+// orderFrameFields moves the time fields of a frame after all other fields
+// for the value first order. Time first is the order frames are built in.
+func orderFrameFields(frame *data.Frame, order string) {
+	if order != fieldOrderValueFirst {
+		return
+	}
+	var times, others []*data.Field
+	for _, f := range frame.Fields {
+		if f.Type() == data.FieldTypeTime || f.Type() == data.FieldTypeNullableTime {
+			times = append(times, f)
+		} else {
+			others = append(others, f)
+		}
+	}
+	frame.Fields = append(others, times...)
+}
+
+// metricSeries identifies the series a metrics frame was built from. Frame
+// names and labels can't be used for this, as labelChart replaces them by a
+// single dimension value.
+type metricSeries struct {
+	metricId   string
+	dimensions map[string]string
+}
+
+// longFrame converts series frames into a single long frame with a time
+// column, a metric column, a column per dimension and a value column, sorted
+// by time. series holds the metric and dimensions of each frame. The
+// dimensions listed in dimensionOrder come first in that order, the others
+// follow sorted by name. The first value field of each frame becomes the
+// value column; further fields such as the baseline bounds and anomaly
+// markers become nullable columns of their own.
+func longFrame(frames data.Frames, series []metricSeries, dimensionOrder []string) *data.Frame {
+	type row struct {
+		time   time.Time
+		metric string
+		labels data.Labels
+		value  *float64
+		extra  map[string]interface{}
+	}
+
+	var rows []row
+	dimensionSet := map[string]bool{}
+	var extraNames []string
+	extraTypes := map[string]data.FieldType{}
+	for f, frame := range frames {
+		if len(frame.Fields) < 2 {
+			continue
+		}
+		timeField, valueField := frame.Fields[0], frame.Fields[1]
+		if timeField.Type() != data.FieldTypeTime || !valueField.Type().Numeric() {
+			continue
+		}
+
+		metric := frame.Name
+		labels := data.Labels{}
+		if f < len(series) {
+			metric = series[f].metricId
+			for key, value := range series[f].dimensions {
+				labels[key] = value
+			}
+		}
+		// Keep labels added to the series, e.g. entity names
+		for key, value := range valueField.Labels {
+			if _, ok := labels[key]; !ok {
+				labels[key] = value
+			}
+		}
+		for key := range labels {
+			dimensionSet[key] = true
+		}
+
+		for _, field := range frame.Fields[2:] {
+			if _, ok := extraTypes[field.Name]; !ok {
+				extraNames = append(extraNames, field.Name)
+				extraTypes[field.Name] = field.Type().NullableType()
+			}
+		}
+
+		for i := 0; i < timeField.Len(); i++ {
+			r := row{time: timeField.At(i).(time.Time), metric: metric, labels: labels, extra: map[string]interface{}{}}
+			if v, err := valueField.NullableFloatAt(i); err == nil && v != nil {
+				value := *v
+				r.value = &value
+			}
+			for _, field := range frame.Fields[2:] {
+				if v, ok := field.ConcreteAt(i); ok {
+					r.extra[field.Name] = v
+				}
+			}
+			rows = append(rows, r)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].time.Before(rows[j].time) })
+
+	var dimensions []string
+	for _, key := range dimensionOrder {
+		if dimensionSet[key] {
+			dimensions = append(dimensions, key)
+			delete(dimensionSet, key)
+		}
+	}
+	var rest []string
+	for key := range dimensionSet {
+		rest = append(rest, key)
+	}
+	sort.Strings(rest)
+	dimensions = append(dimensions, rest...)
+
+	times := make([]time.Time, len(rows))
+	metrics := make([]string, len(rows))
+	values := make([]*float64, len(rows))
+	columns := make([][]string, len(dimensions))
+	for j := range columns {
+		columns[j] = make([]string, len(rows))
+	}
+	extraFields := make([]*data.Field, len(extraNames))
+	for j, name := range extraNames {
+		extraFields[j] = data.NewFieldFromFieldType(extraTypes[name], len(rows))
+		extraFields[j].Name = name
+	}
+	for i, r := range rows {
+		times[i], metrics[i], values[i] = r.time, r.metric, r.value
+		for j, key := range dimensions {
+			columns[j][i] = r.labels[key]
+		}
+		for j, name := range extraNames {
+			if v, ok := r.extra[name]; ok {
+				extraFields[j].SetConcrete(i, v)
+			}
+		}
+	}
+
+	frame := data.NewFrame("long", data.NewField("time", nil, times), data.NewField("metric", nil, metrics))
+	for j, key := range dimensions {
+		frame.Fields = append(frame.Fields, data.NewField(key, nil, columns[j]))
+	}
+	frame.Fields = append(frame.Fields, data.NewField("value", nil, values))
+	frame.Fields = append(frame.Fields, extraFields...)
+	frame.Meta = &data.FrameMeta{Type: data.FrameTypeTimeSeriesLong}
+	if len(frames) > 0 && frames[0].Meta != nil {
+		frame.Meta.ExecutedQueryString = frames[0].Meta.ExecutedQueryString
+	}
+	return frame
+}
@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const fieldOrderMetricsResponse = `{"result": [{"metricId": "builtin:host.disk.usedPct", "data": [
+	{"dimensionMap": {"dt.entity.host": "HOST-1", "dt.entity.disk": "DISK-1"}, "timestamps": [1000, 2000], "values": [1, 2]},
+	{"dimensionMap": {"dt.entity.host": "HOST-2", "dt.entity.disk": "DISK-2"}, "timestamps": [1000, 2000], "values": [3, 4]}
+]}]}`
+
+func TestQueryMetricsLongFormat(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(fieldOrderMetricsResponse))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"useDashboardTime": true, "metricSelector": "builtin:host.disk.usedPct", "format": "long", "dimensionOrder": ["dt.entity.host"]}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 1 {
+		t.Fatalf("expected a single long frame, got %d", len(resp.Frames))
+	}
+
+	frame := resp.Frames[0]
+	var names []string
+	for _, f := range frame.Fields {
+		names = append(names, f.Name)
+	}
+	want := []string{"time", "metric", "dt.entity.host", "dt.entity.disk", "value"}
+	if len(names) != len(want) {
+		t.Fatalf("fields = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("fields = %v, want %v", names, want)
+		}
+	}
+	if rows, _ := frame.RowLen(); rows != 4 {
+		t.Fatalf("expected 4 rows, got %d", rows)
+	}
+	// Rows are sorted by time
+	if frame.Fields[1].At(1) != "builtin:host.disk.usedPct" || frame.Fields[2].At(1) != "HOST-2" || *frame.Fields[4].At(1).(*float64) != 3 {
+		t.Errorf("second row = %v %v %v, want builtin:host.disk.usedPct HOST-2 3", frame.Fields[1].At(1), frame.Fields[2].At(1), frame.Fields[4].At(1))
+	}
+}
+
+func TestQueryMetricsLongFormatLabelChart(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"result": [
+			{"metricId": "builtin:host.cpu.usage", "data": [
+				{"dimensionMap": {"dt.entity.host": "HOST-1", "os": "linux"}, "timestamps": [1000], "values": [1]}
+			]},
+			{"metricId": "builtin:host.mem.usage", "data": [
+				{"dimensionMap": {"dt.entity.host": "HOST-1", "os": "linux"}, "timestamps": [1000], "values": [2]}
+			]}
+		]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"useDashboardTime": true, "metricSelector": "builtin:host.cpu.usage,builtin:host.mem.usage", "format": "long", "labelChart": "dt.entity.host"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	var names []string
+	for _, f := range frame.Fields {
+		names = append(names, f.Name)
+	}
+	if want := "[time metric dt.entity.host os value]"; fmt.Sprint(names) != want {
+		t.Fatalf("fields = %v, want %s", names, want)
+	}
+	// Rows of the two metrics are told apart by the metric column, and keep
+	// every dimension although labelChart names the series by one of them
+	for i, want := range []string{"builtin:host.cpu.usage", "builtin:host.mem.usage"} {
+		if frame.Fields[1].At(i) != want || frame.Fields[2].At(i) != "HOST-1" || frame.Fields[3].At(i) != "linux" {
+			t.Errorf("row %d = %v %v %v, want %s HOST-1 linux", i, frame.Fields[1].At(i), frame.Fields[2].At(i), frame.Fields[3].At(i), want)
+		}
+	}
+}
+
+func TestQueryMetricsLongFormatBaseline(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		from := req.URL.Query().Get("from")
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {"dt.entity.host": "HOST-1"}, "timestamps": [` + from + `], "values": [1]}
+		]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"metricSelector": "builtin:host.cpu.usage", "format": "long", "baseline": true, "baselineWeeks": 2, "customFrom": "1814400001", "customTo": "1814460000"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	for _, name := range []string{"lower", "upper"} {
+		field, _ := frame.FieldByName(name)
+		if field == nil {
+			t.Fatalf("expected a %s column, got %d fields", name, len(frame.Fields))
+		}
+		if field.Type() != data.FieldTypeNullableFloat64 || field.Len() != 1 || field.At(0).(*float64) == nil {
+			t.Errorf("%s = %v, want the baseline bound of the row", name, field)
+		}
+	}
+}
+
+func TestQueryMetricsValueFirst(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(fieldOrderMetricsResponse))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"useDashboardTime": true, "metricSelector": "builtin:host.disk.usedPct", "fieldOrder": "valueFirst"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	for _, frame := range resp.Frames {
+		if last := frame.Fields[len(frame.Fields)-1]; last.Name != "time" {
+			t.Errorf("last field = %q, want time", last.Name)
+		}
+	}
+}
+
+func TestValidateFieldOrder(t *testing.T) {
+	for _, qm := range []queryModel{
+		{FieldOrder: "sideways"},
+		{Format: "wide"},
+		{Format: formatLong, Join: joinOuter},
+	} {
+		if err := validateFieldOrder(qm); err == nil {
+			t.Errorf("expected an error for %+v", qm)
+		}
+	}
+}
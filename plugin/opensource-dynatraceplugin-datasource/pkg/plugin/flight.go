@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// flightGroup coalesces identical concurrent requests: while a request is in
+// flight, callers issuing the same request wait for it and share its response
+// instead of sending their own. Only GET requests are coalesced, as they have
+// no body and are identified by their URL and Authorization header.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+
+	// coalesced counts requests served by another caller's request, reported
+	// by the /stats resource route
+	coalesced int64
+}
+
+// flightCall is a request in flight.
+type flightCall struct {
+	done chan struct{}
+	body []byte
+	err  error
+}
+
+// do executes fn for key unless a call for key is already in flight, in which
+// case it waits for that call and returns its result with shared set. The
+// response body is shared and must not be modified by callers.
+func (g *flightGroup) do(ctx context.Context, key string, fn func() ([]byte, error)) (body []byte, shared bool, err error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall{}
+	}
+	if call, ok := g.calls[key]; ok {
+		g.coalesced++
+		g.mu.Unlock()
+
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+		// The leading caller may have given up; that says nothing about
+		// whether the request would succeed for this caller
+		if errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded) {
+			body, err = fn()
+			return body, false, err
+		}
+		return call.body, true, copyAPIError(call.err)
+	}
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.body, call.err = fn()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+
+	return call.body, false, copyAPIError(call.err)
+}
+
+// coalescedCount returns the number of coalesced requests.
+func (g *flightGroup) coalescedCount() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.coalesced
+}
+
+// copyAPIError copies API errors, which callers annotate, so that callers
+// sharing a response don't modify the same error.
+func copyAPIError(err error) error {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && err == error(apiErr) {
+		copied := *apiErr
+		return &copied
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdenticalRequestsCoalesced(t *testing.T) {
+	var calls int64
+	release := make(chan struct{})
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		<-release
+		rw.WriteHeader(http.StatusForbidden)
+	})
+
+	const callers = 5
+	var wg sync.WaitGroup
+	errs := make([]error, callers)
+	stats := make([]*queryStats, callers)
+	for i := 0; i < callers; i++ {
+		var ctx context.Context
+		ctx, stats[i] = withQueryStats(context.Background())
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var out map[string]interface{}
+			errs[i] = ds.get(ctx, "/api/v2/problems", nil, &out)
+		}(i)
+	}
+	// Let every caller join the request in flight before it completes
+	deadline := time.Now().Add(5 * time.Second)
+	for ds.inflight.coalescedCount() < callers-1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt64(&calls); got != 1 {
+		t.Errorf("expected a single outbound call, got %d", got)
+	}
+	for i, err := range errs {
+		if got := stats[i].calls(); got != 1 {
+			t.Errorf("caller %d: apiCalls = %d, want 1", i, got)
+		}
+		if err == nil || err.Error() != "token is missing scope problems.read (status 403); add it to the API token" {
+			t.Errorf("caller %d: error = %v", i, err)
+		}
+	}
+	if got := ds.snapshot().CoalescedRequests; got != callers-1 {
+		t.Errorf("coalescedRequests = %d, want %d", got, callers-1)
+	}
+	if got := ds.snapshot().APICalls; got != callers {
+		t.Errorf("apiCalls = %d, want %d", got, callers)
+	}
+}
+
+func TestRequestsWithBodyNotCoalesced(t *testing.T) {
+	var calls int64
+	release := make(chan struct{})
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		<-release
+		_, _ = rw.Write([]byte(`{}`))
+	})
+
+	var wg sync.WaitGroup
+	for _, title := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(title string) {
+			defer wg.Done()
+			_ = ds.post(context.Background(), "/api/v2/events/ingest", nil, map[string]string{"title": title}, nil)
+		}(title)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&calls) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt64(&calls); got != 2 {
+		t.Errorf("expected a call per request with a body, got %d", got)
+	}
+}
+
+func TestFlightGroupCanceledLeader(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() {
+		_, _, err := g.do(ctx, "key", func() ([]byte, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		done <- err
+	}()
+	<-started
+
+	result := make(chan []byte)
+	go func() {
+		body, _, _ := g.do(context.Background(), "key", func() ([]byte, error) { return []byte("own"), nil })
+		result <- body
+	}()
+	for g.coalescedCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("leader error = %v", err)
+	}
+	if body := <-result; string(body) != "own" {
+		t.Errorf("follower should send its own request after the leader gave up, got %q", body)
+	}
+}
@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// grailBucketsPath is the platform endpoint listing Grail bucket definitions.
+const grailBucketsPath = "/platform/storage/management/v1/bucket-definitions"
+
+// longRetentionDays is the retention above which a bucket is flagged, as
+// queries scanning it can be costly. It matches the default retention of
+// the built-in log buckets.
+const longRetentionDays = 35
+
+// GrailBucket is a Grail bucket definition
+type GrailBucket struct {
+	BucketName    string `json:"bucketName"`
+	Table         string `json:"table"`
+	DisplayName   string `json:"displayName"`
+	Status        string `json:"status"`
+	RetentionDays int    `json:"retentionDays"`
+
+	// LongRetention is set by the plugin for buckets kept longer than the default
+	LongRetention bool `json:"longRetention"`
+}
+
+// fetchGrailBuckets returns the Grail buckets sorted by table and name.
+func (d *Datasource) fetchGrailBuckets(ctx context.Context) ([]GrailBucket, error) {
+	body, err := d.doPlatformRequest(ctx, http.MethodGet, grailBucketsPath, nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Buckets []GrailBucket `json:"buckets"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	for i := range resp.Buckets {
+		resp.Buckets[i].LongRetention = resp.Buckets[i].RetentionDays > longRetentionDays
+	}
+	sort.Slice(resp.Buckets, func(i, j int) bool {
+		if resp.Buckets[i].Table != resp.Buckets[j].Table {
+			return resp.Buckets[i].Table < resp.Buckets[j].Table
+		}
+		return resp.Buckets[i].BucketName < resp.Buckets[j].BucketName
+	})
+	return resp.Buckets, nil
+}
+
+// handleGrailBuckets serves GET /grail/buckets with the available Grail
+// buckets and their retention, optionally filtered by ?table=logs.
+func (d *Datasource) handleGrailBuckets(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	buckets, err := d.fetchGrailBuckets(req.Context())
+	if err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	if table := req.URL.Query().Get("table"); table != "" {
+		filtered := []GrailBucket{}
+		for _, b := range buckets {
+			if b.Table == table {
+				filtered = append(filtered, b)
+			}
+		}
+		buckets = filtered
+	}
+
+	writeJSON(rw, http.StatusOK, buckets)
+}
@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleGrailBuckets(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != grailBucketsPath {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer platform-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		_, _ = rw.Write([]byte(`{"buckets": [
+			{"bucketName": "default_logs", "table": "logs", "status": "active", "retentionDays": 35},
+			{"bucketName": "audit_logs", "table": "logs", "status": "active", "retentionDays": 3657},
+			{"bucketName": "default_spans", "table": "spans", "status": "active", "retentionDays": 10}
+		]}`))
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/grail/buckets?table=logs", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var buckets []GrailBucket
+	if err := json.Unmarshal(rec.Body.Bytes(), &buckets); err != nil {
+		t.Fatal(err)
+	}
+	if len(buckets) != 2 || buckets[0].BucketName != "audit_logs" {
+		t.Fatalf("unexpected buckets %+v", buckets)
+	}
+	if !buckets[0].LongRetention || buckets[1].LongRetention {
+		t.Errorf("only buckets above the default retention should be flagged: %+v", buckets)
+	}
+}
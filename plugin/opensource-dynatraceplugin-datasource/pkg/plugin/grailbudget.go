@@ -0,0 +1,88 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// errGrailBudgetExhausted is returned for DQL queries once the scan budget
+// of the current period is used up.
+var errGrailBudgetExhausted = errors.New("Grail scan budget exhausted")
+
+// grailBudget limits the bytes Grail may scan for a datasource instance per
+// period, protecting against surprise consumption costs from dashboards.
+// Periods are fixed UTC hours or days; the budget resets when a new period
+// starts. A nil budget is unlimited.
+type grailBudget struct {
+	mu          sync.Mutex
+	limit       int64
+	period      time.Duration
+	periodStart time.Time
+	scanned     int64
+	now         func() time.Time
+}
+
+// newGrailBudget returns a budget of limit bytes per period, or nil if limit
+// is not positive.
+func newGrailBudget(limit int64, period time.Duration) *grailBudget {
+	if limit <= 0 {
+		return nil
+	}
+	return &grailBudget{limit: limit, period: period, now: time.Now}
+}
+
+// grailBudgetPeriod parses the budget period setting, a day by default.
+func grailBudgetPeriod(period string) (time.Duration, error) {
+	switch period {
+	case "", "day":
+		return 24 * time.Hour, nil
+	case "hour":
+		return time.Hour, nil
+	default:
+		return 0, fmt.Errorf("invalid grailBudgetPeriod %q: must be \"hour\" or \"day\"", period)
+	}
+}
+
+// roll starts a new period if the current one is over.
+func (b *grailBudget) roll() {
+	start := b.now().UTC().Truncate(b.period)
+	if !start.Equal(b.periodStart) {
+		b.periodStart = start
+		b.scanned = 0
+	}
+}
+
+// check returns an error if the budget of the current period is exhausted.
+func (b *grailBudget) check() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	if b.scanned < b.limit {
+		return nil
+	}
+	return fmt.Errorf("%w: %.1f GB of %.1f GB scanned this %s, DQL queries resume at %s", errGrailBudgetExhausted,
+		float64(b.scanned)/1e9, float64(b.limit)/1e9, b.periodName(), b.periodStart.Add(b.period).Format(time.RFC3339))
+}
+
+// add records bytes scanned by a query.
+func (b *grailBudget) add(bytes int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.roll()
+	b.scanned += bytes
+}
+
+func (b *grailBudget) periodName() string {
+	if b.period == time.Hour {
+		return "hour"
+	}
+	return "day"
+}
@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestGrailBudget(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 30, 0, 0, time.UTC)
+	budget := newGrailBudget(1000, time.Hour)
+	budget.now = func() time.Time { return now }
+
+	if err := budget.check(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	budget.add(600)
+	budget.add(600)
+	if err := budget.check(); !errors.Is(err, errGrailBudgetExhausted) {
+		t.Fatalf("expected the budget to be exhausted, got %v", err)
+	}
+
+	now = now.Add(30 * time.Minute)
+	if err := budget.check(); err != nil {
+		t.Errorf("budget should reset in the next hour: %v", err)
+	}
+
+	if newGrailBudget(0, time.Hour).check() != nil {
+		t.Error("a zero budget should be unlimited")
+	}
+}
+
+func TestQueryDQLRejectedOverBudget(t *testing.T) {
+	requests := 0
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		_, _ = rw.Write([]byte(`{"state": "SUCCEEDED", "result": {"records": [], "metadata": {"grail": {"scannedBytes": 2000000000}}}}`))
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+	ds.grailBudget = newGrailBudget(1e9, 24*time.Hour)
+
+	run := func() backend.DataResponse {
+		return ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+			RefID:     "A",
+			QueryType: queryTypeDQL,
+			JSON:      []byte(`{"dqlQuery": "fetch logs", "useDashboardTime": true}`),
+		})
+	}
+	if resp := run(); resp.Error != nil {
+		t.Fatalf("first query should run: %v", resp.Error)
+	}
+	resp := run()
+	if resp.Status != backend.StatusTooManyRequests || resp.Error == nil {
+		t.Fatalf("expected a budget error, got %d %v", resp.Status, resp.Error)
+	}
+	if requests != 1 {
+		t.Errorf("the second query should not reach Grail, got %d requests", requests)
+	}
+}
+
+func TestQueryDQLChargesBudgetOnEveryOutcome(t *testing.T) {
+	for _, state := range []string{"SUCCEEDED", "FAILED", "CANCELLED"} {
+		t.Run(state, func(t *testing.T) {
+			ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+				_, _ = rw.Write([]byte(`{"state": "` + state + `", "result": {"records": [], "metadata": {"grail": {"scannedBytes": 2000000000}}}}`))
+			})
+			ds.platformUrl = ds.apiUrl
+			ds.platformToken = "platform-token"
+			ds.grailBudget = newGrailBudget(1e9, 24*time.Hour)
+
+			ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+				RefID:     "A",
+				QueryType: queryTypeDQL,
+				JSON:      []byte(`{"dqlQuery": "fetch logs", "useDashboardTime": true}`),
+			})
+			if err := ds.grailBudget.check(); !errors.Is(err, errGrailBudgetExhausted) {
+				t.Errorf("the scanned bytes should be charged, got %v", err)
+			}
+		})
+	}
+}
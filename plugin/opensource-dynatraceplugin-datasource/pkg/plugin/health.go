@@ -0,0 +1,150 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// environmentHealth is the result of probing one environment in a health
+// check, reported in the health check details.
+type environmentHealth struct {
+	Name      string `json:"name"`
+	Url       string `json:"url"`
+	Reachable bool   `json:"reachable"`
+	// TokenValid is nil when the token could not be checked
+	TokenValid *bool  `json:"tokenValid"`
+	LatencyMs  int64  `json:"latencyMs"`
+	Error      string `json:"error,omitempty"`
+	Warning    string `json:"warning,omitempty"`
+}
+
+// healthy reports whether the environment can serve queries.
+func (h environmentHealth) healthy() bool {
+	return h.Reachable && (h.TokenValid == nil || *h.TokenValid) && h.Error == ""
+}
+
+// probeEnvironment checks that the environment API answers its /health
+// endpoint and that the API token is valid.
+func (d *Datasource) probeEnvironment(ctx context.Context) environmentHealth {
+	health := environmentHealth{Name: "environment", Url: d.apiUrl}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.apiUrl+"/health", nil)
+	if err != nil {
+		health.Error = fmt.Sprintf("error creating health check request: %v", err)
+		return health
+	}
+	if d.userAgent != "" {
+		req.Header.Set("User-Agent", d.userAgent)
+	}
+	client, err := d.client()
+	if err != nil {
+		health.Error = fmt.Sprintf("error creating HTTP client: %v", err)
+		return health
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	health.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		health.Error = fmt.Sprintf("error connecting to Dynatrace API: %v", err)
+		return health
+	}
+	defer resp.Body.Close()
+	health.Reachable = true
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		health.Error = fmt.Sprintf("health check failed (status %d): %s", resp.StatusCode, string(body))
+		return health
+	}
+
+	var lookup DynatraceTokenLookup
+	err = d.post(ctx, "/api/v2/apiTokens/lookup", nil, map[string]string{"token": d.apiToken}, &lookup)
+	health.TokenValid, health.Error, health.Warning = tokenValidity(err, lookup.Enabled)
+	if err == nil {
+		health.Warning = d.tokenExpiryWarning(&lookup, time.Now())
+	}
+	return health
+}
+
+// probePlatform checks that the platform answers an environment API request
+// authenticated with the platform token.
+func (d *Datasource) probePlatform(ctx context.Context) environmentHealth {
+	health := environmentHealth{Name: "platform", Url: d.platformUrl}
+
+	params := url.Values{}
+	params.Set("pageSize", "1")
+	var metrics json.RawMessage
+	start := time.Now()
+	err := d.get(withApiVersion(ctx, apiVersionPlatform), "/api/v2/metrics", params, &metrics)
+	health.LatencyMs = time.Since(start).Milliseconds()
+
+	var apiErr *apiError
+	health.Reachable = err == nil || errors.As(err, &apiErr)
+	health.TokenValid, health.Error, health.Warning = tokenValidity(err, true)
+	return health
+}
+
+// tokenValidity interprets the outcome of a request made to check a token,
+// returning its validity, an error and a warning. Only a 401 means the token
+// was rejected. A 403 means the token was accepted but lacks the scope of the
+// check, which leaves its validity unknown without failing the health check;
+// other errors leave its validity unknown as well.
+func tokenValidity(err error, enabled bool) (*bool, string, string) {
+	valid := false
+	var apiErr *apiError
+	switch {
+	case err == nil && enabled:
+		valid = true
+		return &valid, "", ""
+	case err == nil:
+		return &valid, "token is disabled", ""
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized:
+		return &valid, "token was rejected", ""
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden:
+		return nil, "", "token validity could not be checked (status 403)"
+	default:
+		return nil, err.Error(), ""
+	}
+}
+
+// healthResult aggregates the probed environments into a health check
+// result: healthy only when every environment is.
+func healthResult(environments []environmentHealth) *backend.CheckHealthResult {
+	details, _ := json.Marshal(map[string]interface{}{"environments": environments})
+
+	var failed []string
+	for _, env := range environments {
+		if !env.healthy() {
+			failed = append(failed, fmt.Sprintf("%s: %s", env.Name, env.Error))
+		}
+	}
+	if len(failed) > 0 {
+		return &backend.CheckHealthResult{
+			Status:      backend.HealthStatusError,
+			Message:     fmt.Sprintf("Dynatrace health check failed for %s", strings.Join(failed, "; ")),
+			JSONDetails: details,
+		}
+	}
+
+	message := "Successfully connected to Dynatrace API"
+	if len(environments) > 1 {
+		message = fmt.Sprintf("Successfully connected to %d Dynatrace environments", len(environments))
+	}
+	// Health checks have no warning status, so warnings extend the message
+	for _, env := range environments {
+		if env.Warning != "" {
+			message += fmt.Sprintf(". Warning: %s", env.Warning)
+		}
+	}
+	return &backend.CheckHealthResult{Status: backend.HealthStatusOk, Message: message, JSONDetails: details}
+}
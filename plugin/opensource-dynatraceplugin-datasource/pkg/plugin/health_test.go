@@ -0,0 +1,124 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestCheckHealth(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/health":
+			rw.WriteHeader(http.StatusOK)
+		case "/api/v2/apiTokens/lookup":
+			_, _ = rw.Write([]byte(`{"id": "dt0c01.ABC", "enabled": true, "scopes": ["metrics.read"]}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+
+	result, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != backend.HealthStatusOk {
+		t.Fatalf("status = %v: %s", result.Status, result.Message)
+	}
+
+	var details struct {
+		Environments []environmentHealth `json:"environments"`
+	}
+	if err := json.Unmarshal(result.JSONDetails, &details); err != nil {
+		t.Fatal(err)
+	}
+	if len(details.Environments) != 1 {
+		t.Fatalf("expected only the environment API, got %+v", details.Environments)
+	}
+	env := details.Environments[0]
+	if !env.Reachable || env.TokenValid == nil || !*env.TokenValid {
+		t.Errorf("environment = %+v, want reachable with a valid token", env)
+	}
+}
+
+func TestCheckHealthPlatform(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/health":
+			rw.WriteHeader(http.StatusOK)
+		case "/api/v2/apiTokens/lookup":
+			_, _ = rw.Write([]byte(`{"enabled": true}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+	platform := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != platformEnvironmentApiPath+"/v2/metrics" {
+			t.Errorf("unexpected platform path %s", req.URL.Path)
+		}
+		rw.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(platform.Close)
+	ds.platformUrl = platform.URL
+	ds.platformToken = "expired"
+
+	result, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != backend.HealthStatusError {
+		t.Fatalf("status = %v, want an error for the rejected platform token", result.Status)
+	}
+
+	var details struct {
+		Environments []environmentHealth `json:"environments"`
+	}
+	if err := json.Unmarshal(result.JSONDetails, &details); err != nil {
+		t.Fatal(err)
+	}
+	if len(details.Environments) != 2 {
+		t.Fatalf("expected the environment API and the platform, got %+v", details.Environments)
+	}
+	platformHealth := details.Environments[1]
+	if !platformHealth.Reachable || platformHealth.TokenValid == nil || *platformHealth.TokenValid {
+		t.Errorf("platform = %+v, want reachable with an invalid token", platformHealth)
+	}
+	if !details.Environments[0].healthy() {
+		t.Errorf("environment API = %+v, want healthy", details.Environments[0])
+	}
+}
+
+func TestCheckHealthForbiddenTokenLookup(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/health":
+			rw.WriteHeader(http.StatusOK)
+		case "/api/v2/apiTokens/lookup":
+			rw.WriteHeader(http.StatusForbidden)
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+
+	result, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != backend.HealthStatusOk {
+		t.Fatalf("status = %v: %s, want a 403 lookup not to fail the health check", result.Status, result.Message)
+	}
+
+	var details struct {
+		Environments []environmentHealth `json:"environments"`
+	}
+	if err := json.Unmarshal(result.JSONDetails, &details); err != nil {
+		t.Fatal(err)
+	}
+	if env := details.Environments[0]; env.TokenValid != nil || env.Warning == "" {
+		t.Errorf("environment = %+v, want unknown token validity with a warning", env)
+	}
+}
@@ -0,0 +1,161 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Host unit consumption metrics, reported per host in host unit hours.
+const (
+	hostUnitsFullStackMetric      = "builtin:billing.full_stack_monitoring.usage_per_host"
+	hostUnitsInfrastructureMetric = "builtin:billing.infrastructure_monitoring.usage_per_host"
+)
+
+// Supported hostUnitsGroupBy values.
+const (
+	hostUnitsByHostGroup      = "hostGroup"
+	hostUnitsByManagementZone = "managementZone"
+)
+
+// hostUnitsUngrouped is the group name used for hosts without a host group or management zone.
+const hostUnitsUngrouped = "(none)"
+
+// queryHostUnits reports host unit consumption over time, summed per host
+// group or management zone. Per-host consumption comes from the billing
+// metrics; the grouping is resolved by enriching each host from the entities API.
+func (d *Datasource) queryHostUnits(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	groupBy := qm.HostUnitsGroupBy
+	if groupBy == "" {
+		groupBy = hostUnitsByHostGroup
+	}
+	if groupBy != hostUnitsByHostGroup && groupBy != hostUnitsByManagementZone {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid hostUnitsGroupBy: %s", groupBy))
+	}
+
+	metricKey := hostUnitsFullStackMetric
+	if qm.HostUnitsMode == "infrastructure" {
+		metricKey = hostUnitsInfrastructureMetric
+	}
+
+	resolution := qm.Resolution
+	if resolution == "" {
+		resolution = defaultBillingResolution
+	}
+
+	selector := fmt.Sprintf(`%s:splitBy("dt.entity.host")`, metricKey)
+	metricsResp, err := d.queryDynatraceAPI(ctx, selector, fromMs, toMs, resolution, nil)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace API: %v", err))
+	}
+
+	var series []DynatraceMetricData
+	hostIds := map[string]bool{}
+	for _, result := range metricsResp.Result {
+		for _, dataSet := range result.Data {
+			if hostId := dataSet.DimensionMap["dt.entity.host"]; hostId != "" {
+				hostIds[hostId] = true
+				series = append(series, dataSet)
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(hostIds))
+	for id := range hostIds {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	hosts, err := d.fetchEntitiesById(ctx, ids, "properties.hostGroupName,managementZones", fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace entities: %v", err))
+	}
+
+	log.DefaultLogger.Debug("Aggregating host units", "hosts", len(ids), "groupBy", groupBy)
+
+	frames := hostUnitsFrames(series, hosts, groupBy)
+	for _, frame := range frames {
+		frame.Meta = &data.FrameMeta{
+			ExecutedQueryString: fmt.Sprintf("Metric: %s, Resolution: %s, GroupBy: %s", metricKey, resolution, groupBy),
+		}
+	}
+
+	return backend.DataResponse{Frames: frames}
+}
+
+// hostGroups returns the groups a host contributes to. A host belongs to a
+// single host group, but may be part of several management zones, in which
+// case its consumption is counted in each of them.
+func hostGroups(host DynatraceEntity, groupBy string) []string {
+	var groups []string
+	if groupBy == hostUnitsByManagementZone {
+		for _, mz := range host.ManagementZones {
+			groups = append(groups, mz.Name)
+		}
+	} else if group := host.propertyString("hostGroupName"); group != "" {
+		groups = append(groups, group)
+	}
+
+	if len(groups) == 0 {
+		groups = append(groups, hostUnitsUngrouped)
+	}
+	return groups
+}
+
+// hostUnitsFrames sums per-host series into one series per group.
+func hostUnitsFrames(series []DynatraceMetricData, hosts map[string]DynatraceEntity, groupBy string) data.Frames {
+	totals := map[string]map[int64]float64{}
+	for _, s := range series {
+		host := hosts[s.DimensionMap["dt.entity.host"]]
+		for _, group := range hostGroups(host, groupBy) {
+			if totals[group] == nil {
+				totals[group] = map[int64]float64{}
+			}
+			for i, ts := range s.Timestamps {
+				if i < len(s.Values) && s.Values[i] != nil {
+					totals[group][ts] += *s.Values[i]
+				}
+			}
+		}
+	}
+
+	groups := make([]string, 0, len(totals))
+	for group := range totals {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	frames := make(data.Frames, 0, len(groups))
+	for _, group := range groups {
+		timestamps := make([]int64, 0, len(totals[group]))
+		for ts := range totals[group] {
+			timestamps = append(timestamps, ts)
+		}
+		sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+		times := make([]time.Time, len(timestamps))
+		values := make([]float64, len(timestamps))
+		for i, ts := range timestamps {
+			times[i] = time.UnixMilli(ts)
+			values[i] = totals[group][ts]
+		}
+
+		frame := data.NewFrame(group,
+			data.NewField("time", nil, times),
+			data.NewField(group, data.Labels{groupBy: group}, values),
+		)
+		frames = append(frames, frame)
+	}
+
+	return frames
+}
@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"testing"
+)
+
+func TestHostUnitsFrames(t *testing.T) {
+	series := []DynatraceMetricData{
+		{DimensionMap: map[string]string{"dt.entity.host": "HOST-1"}, Timestamps: []int64{1000, 2000}, Values: float64Ptrs(1, 2)},
+		{DimensionMap: map[string]string{"dt.entity.host": "HOST-2"}, Timestamps: []int64{1000, 2000}, Values: float64Ptrs(4, 8)},
+		{DimensionMap: map[string]string{"dt.entity.host": "HOST-3"}, Timestamps: []int64{1000}, Values: float64Ptrs(16)},
+	}
+	hosts := map[string]DynatraceEntity{
+		"HOST-1": {
+			EntityId:        "HOST-1",
+			Properties:      map[string]interface{}{"hostGroupName": "web"},
+			ManagementZones: []DynatraceManagementZone{{Name: "prod"}, {Name: "eu"}},
+		},
+		"HOST-2": {
+			EntityId:        "HOST-2",
+			Properties:      map[string]interface{}{"hostGroupName": "web"},
+			ManagementZones: []DynatraceManagementZone{{Name: "prod"}},
+		},
+	}
+
+	frames := hostUnitsFrames(series, hosts, hostUnitsByHostGroup)
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(frames))
+	}
+	if frames[0].Name != hostUnitsUngrouped || frames[1].Name != "web" {
+		t.Fatalf("unexpected groups %s, %s", frames[0].Name, frames[1].Name)
+	}
+	web := frames[1].Fields[1]
+	if web.At(0) != 5.0 || web.At(1) != 10.0 {
+		t.Errorf("web totals = %v, %v, want 5, 10", web.At(0), web.At(1))
+	}
+
+	frames = hostUnitsFrames(series, hosts, hostUnitsByManagementZone)
+	names := []string{}
+	for _, f := range frames {
+		names = append(names, f.Name)
+	}
+	if len(frames) != 3 || names[1] != "eu" || names[2] != "prod" {
+		t.Fatalf("unexpected zones %v", names)
+	}
+	if got := frames[1].Fields[1].At(0); got != 1.0 {
+		t.Errorf("eu total = %v, want 1", got)
+	}
+	if got := frames[2].Fields[1].At(1); got != 10.0 {
+		t.Errorf("prod total = %v, want 10", got)
+	}
+}
+
+func TestEntityIdSelector(t *testing.T) {
+	got := entityIdSelector([]string{"HOST-1", "HOST-2"})
+	if want := `entityId("HOST-1","HOST-2")`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,119 @@
+package plugin
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// queryTypes lists the query types supported by the backend.
+var queryTypes = []string{
+	queryTypeMetrics, queryTypeAdvanced, queryTypeBilling, queryTypeHostUnits,
+	queryTypeProblems, queryTypeProblem, queryTypeProblemCount,
+	queryTypeLogs, queryTypeLogsVolume, queryTypeTraces,
+	queryTypeEntities, queryTypeEntityCount, queryTypeEvents, queryTypeDeployments,
+	queryTypeReleases, queryTypeServiceFlow, queryTypeAvailability,
+	queryTypeSecurity, queryTypeAttacks, queryTypeSynthetic, queryTypeApdex,
+	queryTypeUSQL, queryTypeSessionsGeo, queryTypeSLO,
+	queryTypeNetworkZones, queryTypeActiveGates, queryTypeMetricEvents, queryTypeSettings,
+	queryTypeDQL, queryTypeBizEvents,
+	queryTypeExpression, queryTypeConstant,
+}
+
+// platformQueryTypes run DQL and need the platform URL and token.
+var platformQueryTypes = map[string]bool{
+	queryTypeDQL:       true,
+	queryTypeBizEvents: true,
+	queryTypeTraces:    true,
+}
+
+// pluginInfo is the JSON body of GET /info.
+type pluginInfo struct {
+	Version      string             `json:"version"`
+	Commit       string             `json:"commit,omitempty"`
+	Features     map[string]bool    `json:"features"`
+	QueryTypes   []string           `json:"queryTypes"`
+	Capabilities pluginCapabilities `json:"capabilities"`
+}
+
+// pluginCapabilities describes what the configured environment can serve.
+type pluginCapabilities struct {
+	Platform bool `json:"platform"`
+	// TokenChecked is false when the token could not be looked up, leaving
+	// its scopes unknown
+	TokenChecked bool                           `json:"tokenChecked"`
+	TokenScopes  []string                       `json:"tokenScopes"`
+	QueryTypes   map[string]queryTypeCapability `json:"queryTypes"`
+}
+
+// queryTypeCapability tells whether a query type can be served, and if not why.
+type queryTypeCapability struct {
+	Supported bool   `json:"supported"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// handleInfo serves GET /info with the plugin version, enabled features and
+// the query types the configured environment supports, which the frontend
+// uses to hide editors that can't work.
+func (d *Datasource) handleInfo(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	info := pluginInfo{
+		Version: pluginVersion(),
+		Commit:  buildCommit,
+		Features: map[string]bool{
+			"metricIngest":          d.enableMetricIngest,
+			"eventIngest":           d.enableEventIngest,
+			"disablePayloadLogging": d.disablePayloadLogging,
+			"redaction":             len(d.redactionRules) > 0,
+			"forwardContextHeaders": d.forwardContextHeaders,
+			"requestLimit":          d.limiter != nil,
+			"grailBudget":           d.grailBudget != nil,
+		},
+		QueryTypes: queryTypes,
+	}
+
+	lookup, err := d.tokenLookup(req.Context())
+	if err != nil {
+		log.DefaultLogger.Debug("Token lookup failed, reporting capabilities without scopes", "error", err)
+		lookup = nil
+	}
+	info.Capabilities = d.capabilities(lookup)
+
+	writeJSON(rw, http.StatusOK, info)
+}
+
+// capabilities determines which query types can be served given the
+// platform configuration and the token lookup, which is nil when unknown.
+func (d *Datasource) capabilities(lookup *DynatraceTokenLookup) pluginCapabilities {
+	caps := pluginCapabilities{
+		Platform:     d.platformUrl != "" && d.platformToken != "",
+		TokenChecked: lookup != nil,
+		TokenScopes:  []string{},
+		QueryTypes:   map[string]queryTypeCapability{},
+	}
+	if lookup != nil {
+		caps.TokenScopes = append(caps.TokenScopes, lookup.Scopes...)
+		sort.Strings(caps.TokenScopes)
+	}
+
+	for _, queryType := range queryTypes {
+		capability := queryTypeCapability{Supported: true}
+		switch {
+		case platformQueryTypes[queryType] && !caps.Platform:
+			capability = queryTypeCapability{Reason: "platform URL and platform token are not configured"}
+		case lookup != nil && !lookup.Enabled && len(queryScopes[queryType]) > 0:
+			capability = queryTypeCapability{Reason: "token is disabled"}
+		case lookup != nil:
+			if err := missingScopesError(missingScopes(lookup, queryType)); err != nil {
+				capability = queryTypeCapability{Reason: err.Error()}
+			}
+		}
+		caps.QueryTypes[queryType] = capability
+	}
+	return caps
+}
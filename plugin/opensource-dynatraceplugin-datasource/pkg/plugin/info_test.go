@@ -0,0 +1,63 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleInfo(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s", req.URL.Path)
+	})
+	ds.tokens.lookup.Scopes = []string{"metrics.read", "problems.read"}
+	ds.enableMetricIngest = true
+	SetBuildInfo("1.2.3", "abc123")
+	defer SetBuildInfo("", "")
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/info", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var info pluginInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "1.2.3" || info.Commit != "abc123" {
+		t.Errorf("version = %q, commit = %q", info.Version, info.Commit)
+	}
+	if !info.Features["metricIngest"] || info.Features["redaction"] {
+		t.Errorf("features = %v", info.Features)
+	}
+	if len(info.QueryTypes) != len(queryTypes) || !info.Capabilities.TokenChecked {
+		t.Errorf("queryTypes = %v, tokenChecked = %v", info.QueryTypes, info.Capabilities.TokenChecked)
+	}
+
+	want := map[string]queryTypeCapability{
+		queryTypeMetrics:  {Supported: true},
+		queryTypeProblems: {Supported: true},
+		queryTypeLogs:     {Reason: "token missing logs.read scope"},
+		queryTypeDQL:      {Reason: "platform URL and platform token are not configured"},
+		queryTypeConstant: {Supported: true},
+	}
+	for queryType, capability := range want {
+		if got := info.Capabilities.QueryTypes[queryType]; got != capability {
+			t.Errorf("%s: capability = %+v, want %+v", queryType, got, capability)
+		}
+	}
+}
+
+func TestQueryTypesComplete(t *testing.T) {
+	listed := map[string]bool{}
+	for _, queryType := range queryTypes {
+		listed[queryType] = true
+	}
+	for queryType := range queryScopes {
+		if queryType != "" && !listed[queryType] {
+			t.Errorf("query type %s is missing from queryTypes", queryType)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package plugin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// maxMetricIngestBytes bounds the size of a single line-protocol payload
+// accepted by the /metrics/ingest resource route.
+const maxMetricIngestBytes = 1 << 20
+
+// handleMetricIngest serves POST /metrics/ingest, forwarding a line-protocol
+// payload to /api/v2/metrics/ingest with the datasource credentials. The
+// route is disabled unless metric ingest is enabled in the datasource settings.
+func (d *Datasource) handleMetricIngest(rw http.ResponseWriter, req *http.Request) {
+	if !d.enableMetricIngest {
+		writeError(rw, http.StatusForbidden, "metric ingest is not enabled for this datasource")
+		return
+	}
+	if req.Method != http.MethodPost {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(req.Body, maxMetricIngestBytes+1))
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(payload) > maxMetricIngestBytes {
+		writeError(rw, http.StatusRequestEntityTooLarge, "payload exceeds 1 MiB")
+		return
+	}
+	if len(bytes.TrimSpace(payload)) == 0 {
+		writeError(rw, http.StatusBadRequest, "payload is empty")
+		return
+	}
+
+	log.DefaultLogger.Info("Forwarding metric ingest payload", "bytes", len(payload), "lines", bytes.Count(payload, []byte("\n"))+1)
+
+	body, err := d.doRequest(req.Context(), http.MethodPost, "/api/v2/metrics/ingest", nil, bytes.NewReader(payload), "text/plain; charset=utf-8")
+	if err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusAccepted)
+	_, _ = rw.Write(body)
+}
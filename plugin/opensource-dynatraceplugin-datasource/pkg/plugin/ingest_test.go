@@ -0,0 +1,43 @@
+package plugin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleMetricIngest(t *testing.T) {
+	var received string
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/metrics/ingest" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		if ct := req.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		body, _ := io.ReadAll(req.Body)
+		received = string(body)
+		rw.WriteHeader(http.StatusAccepted)
+		_, _ = rw.Write([]byte(`{"linesOk": 1, "linesInvalid": 0}`))
+	})
+
+	payload := "grafana.kpi.orders,region=eu gauge,42"
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics/ingest", strings.NewReader(payload)))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected ingest to be disabled by default, got status %d", rec.Code)
+	}
+
+	ds.enableMetricIngest = true
+	rec = httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics/ingest", strings.NewReader(payload)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+	if received != payload {
+		t.Errorf("forwarded payload = %q, want %q", received, payload)
+	}
+}
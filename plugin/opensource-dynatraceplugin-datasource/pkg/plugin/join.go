@@ -0,0 +1,102 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Modes of joining series frames on their time column.
+const (
+	joinOuter = "outer"
+	joinInner = "inner"
+)
+
+// validateJoin checks the join mode of a query.
+func validateJoin(mode string) error {
+	switch mode {
+	case "", joinOuter, joinInner:
+		return nil
+	default:
+		return fmt.Errorf("invalid join %q: must be %q or %q", mode, joinOuter, joinInner)
+	}
+}
+
+// joinFrames merges time series frames into a single frame with one time
+// column and one nullable value field per series. An outer join keeps every
+// timestamp with nulls where a series has no value, an inner join only the
+// timestamps present in all series. Names, labels and configs of the value
+// fields and the metadata of the first frame are kept.
+func joinFrames(frames data.Frames, mode string) *data.Frame {
+	type column struct {
+		field  *data.Field
+		points map[int64]float64
+	}
+
+	var columns []column
+	counts := map[int64]int{}
+	for _, frame := range frames {
+		var timeField *data.Field
+		for _, f := range frame.Fields {
+			if f.Type() == data.FieldTypeTime {
+				timeField = f
+				break
+			}
+		}
+		if timeField == nil {
+			continue
+		}
+
+		for _, f := range frame.Fields {
+			if !f.Type().Numeric() {
+				continue
+			}
+			c := column{field: f, points: map[int64]float64{}}
+			for i := 0; i < f.Len(); i++ {
+				v, err := f.NullableFloatAt(i)
+				if err != nil || v == nil {
+					continue
+				}
+				c.points[timeField.At(i).(time.Time).UnixMilli()] = *v
+			}
+			for ts := range c.points {
+				counts[ts]++
+			}
+			columns = append(columns, c)
+		}
+	}
+
+	timestamps := make([]int64, 0, len(counts))
+	for ts, n := range counts {
+		if mode == joinInner && n < len(columns) {
+			continue
+		}
+		timestamps = append(timestamps, ts)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	times := make([]time.Time, len(timestamps))
+	for i, ts := range timestamps {
+		times[i] = time.UnixMilli(ts)
+	}
+
+	joined := data.NewFrame("joined", data.NewField("time", nil, times))
+	for _, c := range columns {
+		values := make([]*float64, len(timestamps))
+		for i, ts := range timestamps {
+			if v, ok := c.points[ts]; ok {
+				v := v
+				values[i] = &v
+			}
+		}
+		field := data.NewField(c.field.Name, c.field.Labels, values)
+		field.Config = c.field.Config
+		joined.Fields = append(joined.Fields, field)
+	}
+	if len(frames) > 0 {
+		joined.Meta = frames[0].Meta
+	}
+	return joined
+}
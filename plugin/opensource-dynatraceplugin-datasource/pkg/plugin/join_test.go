@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestJoinFrames(t *testing.T) {
+	series := func(name string, ts []int64, values []float64) *data.Frame {
+		times := make([]time.Time, len(ts))
+		for i, t := range ts {
+			times[i] = time.UnixMilli(t)
+		}
+		return data.NewFrame(name,
+			data.NewField("time", nil, times),
+			data.NewField(name, data.Labels{"host": name}, values),
+		)
+	}
+	frames := data.Frames{
+		series("a", []int64{1000, 2000, 3000}, []float64{1, 2, 3}),
+		series("b", []int64{2000, 3000, 4000}, []float64{20, 30, 40}),
+	}
+
+	outer := joinFrames(frames, joinOuter)
+	if rows, _ := outer.RowLen(); rows != 4 || len(outer.Fields) != 3 {
+		t.Fatalf("outer join: %d rows, %d fields", rows, len(outer.Fields))
+	}
+	if outer.Fields[2].At(0).(*float64) != nil {
+		t.Errorf("missing values should be null in an outer join")
+	}
+	if outer.Fields[2].Labels["host"] != "b" {
+		t.Errorf("labels should be kept, got %v", outer.Fields[2].Labels)
+	}
+
+	inner := joinFrames(frames, joinInner)
+	if rows, _ := inner.RowLen(); rows != 2 {
+		t.Fatalf("inner join should keep shared timestamps only, got %d rows", rows)
+	}
+	if got := *inner.Fields[1].At(0).(*float64); got != 2 {
+		t.Errorf("first inner value = %v, want 2", got)
+	}
+}
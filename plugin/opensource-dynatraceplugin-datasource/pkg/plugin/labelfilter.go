@@ -0,0 +1,57 @@
+package plugin
+
+// filterMetricSeries keeps the series whose dimension values are listed in
+// include for every included dimension, and drops those with a value listed
+// in exclude, e.g. include {"dt.entity.host": ["HOST-1", "HOST-2"]}. Series
+// without an included dimension are dropped. It returns the number of series
+// removed.
+func filterMetricSeries(resp *DynatraceMetricsResponse, include, exclude map[string][]string) int {
+	if len(include) == 0 && len(exclude) == 0 {
+		return 0
+	}
+	includeSets := labelValueSets(include)
+	excludeSets := labelValueSets(exclude)
+
+	removed := 0
+	for i := range resp.Result {
+		kept := resp.Result[i].Data[:0]
+		for _, dataSet := range resp.Result[i].Data {
+			if matchesLabelLists(dataSet.DimensionMap, includeSets, excludeSets) {
+				kept = append(kept, dataSet)
+			} else {
+				removed++
+			}
+		}
+		resp.Result[i].Data = kept
+	}
+	return removed
+}
+
+// labelValueSets converts dimension value lists into sets.
+func labelValueSets(lists map[string][]string) map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(lists))
+	for dimension, values := range lists {
+		set := make(map[string]bool, len(values))
+		for _, v := range values {
+			set[v] = true
+		}
+		sets[dimension] = set
+	}
+	return sets
+}
+
+// matchesLabelLists reports whether a series passes the include and exclude sets.
+func matchesLabelLists(dimensions map[string]string, include, exclude map[string]map[string]bool) bool {
+	for dimension, values := range include {
+		value, ok := dimensions[dimension]
+		if !ok || !values[value] {
+			return false
+		}
+	}
+	for dimension, values := range exclude {
+		if value, ok := dimensions[dimension]; ok && values[value] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryMetricsLabelLists(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.disk.usedPct", "data": [
+			{"dimensionMap": {"dt.entity.host": "HOST-1", "dt.entity.disk": "DISK-1"}, "timestamps": [1], "values": [1]},
+			{"dimensionMap": {"dt.entity.host": "HOST-1", "dt.entity.disk": "DISK-2"}, "timestamps": [1], "values": [2]},
+			{"dimensionMap": {"dt.entity.host": "HOST-2", "dt.entity.disk": "DISK-3"}, "timestamps": [1], "values": [3]},
+			{"dimensionMap": {"dt.entity.host": "HOST-3", "dt.entity.disk": "DISK-4"}, "timestamps": [1], "values": [4]}
+		]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON: []byte(`{"useDashboardTime": true, "metricSelector": "builtin:host.disk.usedPct", "rawLabels": true,
+			"includeLabels": {"dt.entity.host": ["HOST-1", "HOST-2"]}, "excludeLabels": {"dt.entity.disk": ["DISK-2"]}}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 2 {
+		t.Fatalf("expected 2 series, got %d", len(resp.Frames))
+	}
+	for i, disk := range []string{"DISK-1", "DISK-3"} {
+		if got := resp.Frames[i].Fields[1].Labels["dt.entity.disk"]; got != disk {
+			t.Errorf("series %d disk = %q, want %s", i, got, disk)
+		}
+	}
+}
+
+func TestMatchesLabelListsMissingDimension(t *testing.T) {
+	include := labelValueSets(map[string][]string{"dt.entity.host": {"HOST-1"}})
+	if matchesLabelLists(map[string]string{"dt.entity.service": "SERVICE-1"}, include, nil) {
+		t.Error("series without an included dimension should be dropped")
+	}
+	exclude := labelValueSets(map[string][]string{"dt.entity.host": {"HOST-1"}})
+	if !matchesLabelLists(map[string]string{"dt.entity.service": "SERVICE-1"}, nil, exclude) {
+		t.Error("series without an excluded dimension should be kept")
+	}
+}
@@ -0,0 +1,181 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Queueing parameters of the request limiter.
+const (
+	// limiterMaxWait is the longest a request waits for a free slot
+	limiterMaxWait = 30 * time.Second
+
+	// limiterQueueFactor bounds the number of waiting requests to this
+	// multiple of the concurrency limit
+	limiterQueueFactor = 10
+)
+
+// errRateLimited is returned when a request cannot be queued or waited too
+// long for a free slot.
+var errRateLimited = errors.New("rate limited: too many concurrent requests to Dynatrace, retry shortly")
+
+// requestPriority orders queued requests; interactive requests are served
+// before background ones.
+type requestPriority int
+
+const (
+	priorityInteractive requestPriority = iota
+	priorityBackground
+)
+
+type priorityKey struct{}
+
+// withPriority marks requests made with ctx as having the given priority.
+func withPriority(ctx context.Context, priority requestPriority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// priorityFromContext returns the priority of ctx, interactive by default.
+func priorityFromContext(ctx context.Context) requestPriority {
+	if p, ok := ctx.Value(priorityKey{}).(requestPriority); ok {
+		return p
+	}
+	return priorityInteractive
+}
+
+// requestLimiter bounds the number of simultaneous outbound requests of a
+// datasource instance. It is shared by all queries, resource calls and
+// streams of the instance. When all slots are taken, requests queue for a
+// bounded time, interactive requests ahead of background ones. A nil limiter
+// does not limit requests.
+type requestLimiter struct {
+	mu       sync.Mutex
+	max      int
+	active   int
+	maxQueue int
+	maxWait  time.Duration
+	queues   [2][]chan struct{} // waiters by priority
+
+	// Counters reported by the /stats resource route
+	waits    int64
+	rejected int64
+}
+
+// limiterStats is a snapshot of the state and counters of a limiter.
+type limiterStats struct {
+	active, queued  int
+	waits, rejected int64
+}
+
+// newRequestLimiter returns a limiter allowing max concurrent requests, or
+// nil if max is not positive.
+func newRequestLimiter(max int) *requestLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &requestLimiter{
+		max:      max,
+		maxQueue: max * limiterQueueFactor,
+		maxWait:  limiterMaxWait,
+	}
+}
+
+// acquire blocks until a request slot is free, ctx is done or the request
+// waited longer than the maximum wait.
+func (l *requestLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	if l.active < l.max && l.queued() == 0 {
+		l.active++
+		l.mu.Unlock()
+		return nil
+	}
+	if l.queued() >= l.maxQueue {
+		l.rejected++
+		l.mu.Unlock()
+		return errRateLimited
+	}
+	l.waits++
+	priority := priorityFromContext(ctx)
+	ready := make(chan struct{})
+	l.queues[priority] = append(l.queues[priority], ready)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.maxWait)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+		err = errRateLimited
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err == errRateLimited {
+		l.rejected++
+	}
+	if !l.dequeue(priority, ready) {
+		// The slot was handed over while giving up; pass it on
+		l.releaseLocked()
+	}
+	return err
+}
+
+// release frees a slot taken by acquire, handing it to the next waiter.
+func (l *requestLimiter) release() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.releaseLocked()
+}
+
+func (l *requestLimiter) releaseLocked() {
+	for p := range l.queues {
+		if len(l.queues[p]) > 0 {
+			next := l.queues[p][0]
+			l.queues[p] = l.queues[p][1:]
+			close(next)
+			return
+		}
+	}
+	l.active--
+}
+
+// snapshot returns the current state and counters of the limiter.
+func (l *requestLimiter) snapshot() limiterStats {
+	if l == nil {
+		return limiterStats{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return limiterStats{active: l.active, queued: l.queued(), waits: l.waits, rejected: l.rejected}
+}
+
+// queued returns the number of waiting requests.
+func (l *requestLimiter) queued() int {
+	return len(l.queues[priorityInteractive]) + len(l.queues[priorityBackground])
+}
+
+// dequeue removes a waiter, reporting false if it was already handed a slot.
+func (l *requestLimiter) dequeue(priority requestPriority, ready chan struct{}) bool {
+	queue := l.queues[priority]
+	for i, c := range queue {
+		if c == ready {
+			l.queues[priority] = append(queue[:i], queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestLimiterBoundsConcurrency(t *testing.T) {
+	var active, peak int32
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&active, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&active, -1)
+		_, _ = rw.Write([]byte(`{}`))
+	})
+	ds.limiter = newRequestLimiter(2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out map[string]interface{}
+			if err := ds.get(context.Background(), "/api/v2/activeGates", nil, &out); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", peak)
+	}
+}
+
+func TestRequestLimiterHonoursContext(t *testing.T) {
+	limiter := newRequestLimiter(1)
+	if err := limiter.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.acquire(ctx); err == nil {
+		t.Error("expected acquire to give up when the context is done")
+	}
+
+	limiter.release()
+	if err := limiter.acquire(context.Background()); err != nil {
+		t.Errorf("released slot should be reusable: %v", err)
+	}
+
+	if newRequestLimiter(0) != nil {
+		t.Error("a limit of 0 should not limit requests")
+	}
+}
+
+func TestRequestLimiterPrefersInteractiveRequests(t *testing.T) {
+	limiter := newRequestLimiter(1)
+	if err := limiter.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan requestPriority, 2)
+	acquire := func(priority requestPriority) {
+		if err := limiter.acquire(withPriority(context.Background(), priority)); err != nil {
+			t.Error(err)
+			return
+		}
+		order <- priority
+		limiter.release()
+	}
+
+	go acquire(priorityBackground)
+	waitForQueued(t, limiter, 1)
+	go acquire(priorityInteractive)
+	waitForQueued(t, limiter, 2)
+
+	limiter.release()
+	if first := <-order; first != priorityInteractive {
+		t.Errorf("expected the interactive request to be served first")
+	}
+	<-order
+}
+
+func TestRequestLimiterRejectsWhenSaturated(t *testing.T) {
+	limiter := newRequestLimiter(1)
+	limiter.maxQueue = 1
+	limiter.maxWait = 10 * time.Millisecond
+	if err := limiter.acquire(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The queued request gives up after the maximum wait
+	done := make(chan error)
+	go func() { done <- limiter.acquire(context.Background()) }()
+	waitForQueued(t, limiter, 1)
+
+	// The queue is full
+	if err := limiter.acquire(context.Background()); err != errRateLimited {
+		t.Errorf("expected overflowing request to be rate limited, got %v", err)
+	}
+	if err := <-done; err != errRateLimited {
+		t.Errorf("expected waiting request to time out, got %v", err)
+	}
+
+	limiter.release()
+	if err := limiter.acquire(context.Background()); err != nil {
+		t.Errorf("slot should be free again: %v", err)
+	}
+}
+
+// waitForQueued waits until n requests are queued on the limiter.
+func waitForQueued(t *testing.T, limiter *requestLimiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		limiter.mu.Lock()
+		queued := limiter.queued()
+		limiter.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %d queued requests", n)
+}
@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"context"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Defaults of the pagination safeguards, used when the datasource doesn't
+// configure maxPages or maxResults.
+const (
+	defaultMaxPages   = 100
+	defaultMaxResults = 10000
+)
+
+// pageLimit returns the maximum number of pages walked by a paginated request.
+func (d *Datasource) pageLimit() int {
+	if d.maxPages > 0 {
+		return d.maxPages
+	}
+	return defaultMaxPages
+}
+
+// resultLimit returns the maximum number of series or records returned by a
+// metrics or logs query.
+func (d *Datasource) resultLimit() int {
+	if d.maxResults > 0 {
+		return d.maxResults
+	}
+	return defaultMaxResults
+}
+
+// queryNotices collects notices raised while executing a query by code that
+// has no access to its frames, such as pagination.
+type queryNotices struct {
+	mu      sync.Mutex
+	notices []data.Notice
+}
+
+type queryNoticesKey struct{}
+
+// withQueryNotices returns a context collecting notices for a query.
+func withQueryNotices(ctx context.Context) (context.Context, *queryNotices) {
+	notices := &queryNotices{}
+	return context.WithValue(ctx, queryNoticesKey{}, notices), notices
+}
+
+// addQueryNotice records a notice for the query executed with ctx, if any.
+func addQueryNotice(ctx context.Context, notice data.Notice) {
+	notices, ok := ctx.Value(queryNoticesKey{}).(*queryNotices)
+	if !ok {
+		return
+	}
+	notices.mu.Lock()
+	defer notices.mu.Unlock()
+	notices.notices = append(notices.notices, notice)
+}
+
+// attach appends the collected notices to the first frame of a response.
+// Failed responses get an empty frame to carry them, so notices explaining
+// the error still reach the user.
+func (n *queryNotices) attach(resp *backend.DataResponse) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.notices) == 0 {
+		return
+	}
+	if len(resp.Frames) == 0 {
+		if resp.Error == nil {
+			return
+		}
+		resp.Frames = data.Frames{data.NewFrame("")}
+	}
+	resp.Frames[0].AppendNotices(n.notices...)
+}
+
+// truncateMetricSeries keeps at most max series across the results of a
+// metrics response, reporting whether series were dropped.
+func truncateMetricSeries(resp *DynatraceMetricsResponse, max int) bool {
+	truncated := false
+	remaining := max
+	for i := range resp.Result {
+		if len(resp.Result[i].Data) > remaining {
+			resp.Result[i].Data = resp.Result[i].Data[:remaining]
+			truncated = true
+		}
+		remaining -= len(resp.Result[i].Data)
+	}
+	return truncated
+}
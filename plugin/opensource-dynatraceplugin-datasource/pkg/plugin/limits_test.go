@@ -0,0 +1,90 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryProblemsStopsAtPageLimit(t *testing.T) {
+	requests := 0
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		_, _ = fmt.Fprintf(rw, `{"problems": [{"problemId": "P-%d", "displayId": "P-%d"}], "nextPageKey": "key-%d"}`, requests, requests, requests)
+	})
+	ds.maxPages = 3
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblems,
+		JSON:      []byte(`{"useDashboardTime": true}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if requests != 3 {
+		t.Errorf("expected 3 page requests, got %d", requests)
+	}
+	if rows, _ := resp.Frames[0].RowLen(); rows != 3 {
+		t.Errorf("expected 3 problems, got %d", rows)
+	}
+	notices := resp.Frames[0].Meta.Notices
+	if len(notices) != 1 || !strings.Contains(notices[0].Text, "truncated after 3 pages") {
+		t.Errorf("expected a truncation notice, got %+v", notices)
+	}
+}
+
+func TestQueryMetricsLimitsSeries(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {"dt.entity.host": "HOST-1"}, "dimensions": ["HOST-1"], "timestamps": [1000], "values": [1]},
+			{"dimensionMap": {"dt.entity.host": "HOST-2"}, "dimensions": ["HOST-2"], "timestamps": [1000], "values": [2]},
+			{"dimensionMap": {"dt.entity.host": "HOST-3"}, "dimensions": ["HOST-3"], "timestamps": [1000], "values": [3]}
+		]}]}`))
+	})
+	ds.maxResults = 2
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"metricSelector": "builtin:host.cpu.usage:splitBy(\"dt.entity.host\")", "useDashboardTime": true}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 2 {
+		t.Fatalf("expected 2 series, got %d", len(resp.Frames))
+	}
+	found := false
+	for _, notice := range resp.Frames[0].Meta.Notices {
+		found = found || strings.Contains(notice.Text, "first 2 series")
+	}
+	if !found {
+		t.Errorf("expected a truncation notice, got %+v", resp.Frames[0].Meta.Notices)
+	}
+}
+
+func TestQueryLogsLimitCappedByMaxResults(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got := req.URL.Query().Get("limit"); got != "50" {
+			t.Errorf("limit = %s, want 50", got)
+		}
+		_, _ = rw.Write([]byte(`{"results": []}`))
+	})
+	ds.maxResults = 50
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeLogs,
+		JSON:      []byte(`{"useDashboardTime": true, "logLimit": 5000}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames[0].Meta.Notices) != 1 {
+		t.Errorf("expected a notice about the reduced limit")
+	}
+}
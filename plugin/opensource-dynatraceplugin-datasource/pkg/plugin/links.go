@@ -0,0 +1,170 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// valuePlaceholder is substituted by Grafana with the raw value of the
+// clicked field when a data link is followed.
+const valuePlaceholder = "${__value.raw}"
+
+// exploreLink returns a Grafana Explore URL that runs query against the
+// datasource with the given UID over the from/to range. Occurrences of
+// ${__value.raw} and other ${...} variables in the query are kept unescaped
+// so Grafana can interpolate them when the link is followed.
+func exploreLink(datasourceUid string, query map[string]interface{}, from, to string) string {
+	query["refId"] = "A"
+	query["datasource"] = map[string]string{"uid": datasourceUid}
+
+	state := map[string]interface{}{
+		"datasource": datasourceUid,
+		"queries":    []interface{}{query},
+		"range":      map[string]string{"from": from, "to": to},
+	}
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return ""
+	}
+
+	escaped := url.QueryEscape(string(raw))
+	// Restore template variables so Grafana can interpolate them
+	escaped = strings.ReplaceAll(escaped, url.QueryEscape("${"), "${")
+	escaped = strings.ReplaceAll(escaped, url.QueryEscape("}"), "}")
+	return "/explore?left=" + escaped
+}
+
+// relatedMetricSelectorField is the name of the per-row field holding the
+// metric selector opened by related-metrics data links.
+const relatedMetricSelectorField = "relatedMetricSelector"
+
+// relatedMetrics maps entity ID prefixes to the dimension and key metric
+// charted when navigating from an entity to its metrics.
+var relatedMetrics = []struct {
+	prefix    string
+	dimension string
+	metric    string
+}{
+	{"HOST-", "dt.entity.host", "builtin:host.cpu.usage"},
+	{"SERVICE-", "dt.entity.service", "builtin:service.response.time"},
+	{"PROCESS_GROUP_INSTANCE-", "dt.entity.process_group_instance", "builtin:tech.generic.cpu.usage"},
+	{"APPLICATION-", "dt.entity.application", "builtin:apps.web.actionCount.category"},
+}
+
+// relatedMetricSelector returns a selector charting the key metric of an
+// entity, or "" when the entity type has no related metric. The selector is
+// written without quotes so it can be embedded in a link's JSON state.
+func relatedMetricSelector(entityId string) string {
+	for _, m := range relatedMetrics {
+		if strings.HasPrefix(entityId, m.prefix) {
+			return fmt.Sprintf("%s:filter(eq(%s,%s))", m.metric, m.dimension, entityId)
+		}
+	}
+	return ""
+}
+
+// addRelatedMetricsLink appends a relatedMetricSelector field computed from
+// the entity IDs in idField, and links linkField to an Explore metrics query
+// for that selector over the dashboard time range. The selector field only
+// feeds the link, so it is hidden in tables.
+func addRelatedMetricsLink(frame *data.Frame, datasourceUid, idField, linkField string) {
+	ids, _ := frame.FieldByName(idField)
+	target, _ := frame.FieldByName(linkField)
+	if ids == nil || target == nil {
+		return
+	}
+
+	selectors := make([]string, ids.Len())
+	for i := range selectors {
+		if id, ok := ids.ConcreteAt(i); ok {
+			selectors[i] = relatedMetricSelector(fmt.Sprint(id))
+		}
+	}
+	field := data.NewField(relatedMetricSelectorField, nil, selectors)
+	field.Config = &data.FieldConfig{Custom: map[string]interface{}{"hidden": true}}
+	frame.Fields = append(frame.Fields, field)
+
+	link := data.DataLink{
+		Title: "Show related metrics",
+		URL: exploreLink(datasourceUid, map[string]interface{}{
+			"queryType":        queryTypeMetrics,
+			"metricSelector":   "${__data.fields." + relatedMetricSelectorField + "}",
+			"useDashboardTime": true,
+		}, "${__from}", "${__to}"),
+	}
+
+	if target.Config == nil {
+		target.Config = &data.FieldConfig{}
+	}
+	target.Config.Links = append(target.Config.Links, link)
+}
+
+// dynatraceTimeframe formats a custom time range as understood by the gtf
+// parameter of Dynatrace UI URLs.
+func dynatraceTimeframe(fromMs, toMs int64) string {
+	return fmt.Sprintf("c_%d_%d", fromMs, toMs)
+}
+
+// dataExplorerLink opens metricSelector over the given range in the Dynatrace
+// Data Explorer.
+func (d *Datasource) dataExplorerLink(metricSelector string, fromMs, toMs int64) data.DataLink {
+	params := url.Values{}
+	params.Set("gtf", dynatraceTimeframe(fromMs, toMs))
+	params.Set("gf", "all")
+	params.Set("metricSelector", metricSelector)
+
+	return data.DataLink{
+		Title:       "Open in Dynatrace Data Explorer",
+		TargetBlank: true,
+		URL:         fmt.Sprintf("%s/ui/data-explorer?%s", d.uiUrl, params.Encode()),
+	}
+}
+
+// problemLink opens the Dynatrace problem detail page of problemId, which may
+// be a Grafana variable such as ${__data.fields.problemId}.
+func (d *Datasource) problemLink(problemId string, fromMs, toMs int64) data.DataLink {
+	return data.DataLink{
+		Title:       "Open problem in Dynatrace",
+		TargetBlank: true,
+		URL:         fmt.Sprintf("%s/#problems/problemdetails;gtf=%s;pid=%s", d.uiUrl, dynatraceTimeframe(fromMs, toMs), problemId),
+	}
+}
+
+// entityLink opens the Dynatrace entity page of entityId, which may be a
+// Grafana variable such as ${__value.raw}.
+func (d *Datasource) entityLink(entityId string, fromMs, toMs int64) data.DataLink {
+	return data.DataLink{
+		Title:       "Open entity in Dynatrace",
+		TargetBlank: true,
+		URL:         fmt.Sprintf("%s/ui/entity/%s?gtf=%s", d.uiUrl, entityId, dynatraceTimeframe(fromMs, toMs)),
+	}
+}
+
+// traceLink opens the trace whose ID is the field value in the trace view of
+// Explore, searching the given time range.
+func (d *Datasource) traceLink(fromMs, toMs int64) data.DataLink {
+	return data.DataLink{
+		Title: "Open trace",
+		URL: exploreLink(d.settings.UID, map[string]interface{}{
+			"queryType": queryTypeTraces,
+			"traceId":   valuePlaceholder,
+		}, strconv.FormatInt(fromMs, 10), strconv.FormatInt(toMs, 10)),
+	}
+}
+
+// addFieldLinks appends links to the named field of frame, if present.
+func addFieldLinks(frame *data.Frame, fieldName string, links ...data.DataLink) {
+	field, _ := frame.FieldByName(fieldName)
+	if field == nil {
+		return
+	}
+	if field.Config == nil {
+		field.Config = &data.FieldConfig{}
+	}
+	field.Config.Links = append(field.Config.Links, links...)
+}
@@ -0,0 +1,99 @@
+package plugin
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestRelatedMetricSelector(t *testing.T) {
+	tests := map[string]string{
+		"HOST-ABC":                 "builtin:host.cpu.usage:filter(eq(dt.entity.host,HOST-ABC))",
+		"SERVICE-123":              "builtin:service.response.time:filter(eq(dt.entity.service,SERVICE-123))",
+		"PROCESS_GROUP_INSTANCE-1": "builtin:tech.generic.cpu.usage:filter(eq(dt.entity.process_group_instance,PROCESS_GROUP_INSTANCE-1))",
+		"CUSTOM_DEVICE-1":          "",
+		"":                         "",
+	}
+	for id, want := range tests {
+		if got := relatedMetricSelector(id); got != want {
+			t.Errorf("relatedMetricSelector(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestAddRelatedMetricsLink(t *testing.T) {
+	frame := data.NewFrame("evidence",
+		data.NewField("entity", nil, []string{"host-a", "svc"}),
+		data.NewField("entityId", nil, []string{"HOST-1", "SERVICE-1"}),
+	)
+
+	addRelatedMetricsLink(frame, "dt-uid", "entityId", "entity")
+
+	selectors, _ := frame.FieldByName(relatedMetricSelectorField)
+	if selectors == nil || selectors.At(1) != "builtin:service.response.time:filter(eq(dt.entity.service,SERVICE-1))" {
+		t.Fatalf("unexpected selectors field")
+	}
+	if selectors.Config == nil || selectors.Config.Custom["hidden"] != true {
+		t.Errorf("the selectors field should be hidden")
+	}
+
+	links := frame.Fields[0].Config.Links
+	if len(links) != 1 {
+		t.Fatalf("expected one link, got %d", len(links))
+	}
+	link := links[0].URL
+	if !strings.HasPrefix(link, "/explore?left=") {
+		t.Fatalf("unexpected link %s", link)
+	}
+	for _, variable := range []string{"${__data.fields.relatedMetricSelector}", "${__from}", "${__to}"} {
+		if !strings.Contains(link, variable) {
+			t.Errorf("link should contain %s unescaped: %s", variable, link)
+		}
+	}
+	state, err := url.QueryUnescape(strings.TrimPrefix(link, "/explore?left="))
+	if err != nil || !strings.Contains(state, `"uid":"dt-uid"`) {
+		t.Errorf("unexpected explore state %s", state)
+	}
+}
+
+func TestDynatraceLinks(t *testing.T) {
+	ds := &Datasource{uiUrl: "https://abc123.live.dynatrace.com"}
+
+	link := ds.dataExplorerLink(`builtin:host.cpu.usage:filter(eq("dt.entity.host","HOST-1"))`, 1000, 2000)
+	u, err := url.Parse(link.URL)
+	if err != nil {
+		t.Fatalf("invalid link %s: %v", link.URL, err)
+	}
+	if u.Path != "/ui/data-explorer" || u.Query().Get("gtf") != "c_1000_2000" {
+		t.Errorf("unexpected data explorer link %s", link.URL)
+	}
+	if got := u.Query().Get("metricSelector"); got != `builtin:host.cpu.usage:filter(eq("dt.entity.host","HOST-1"))` {
+		t.Errorf("unexpected metric selector %s", got)
+	}
+
+	if got, want := ds.problemLink("P-1", 1000, 2000).URL, "https://abc123.live.dynatrace.com/#problems/problemdetails;gtf=c_1000_2000;pid=P-1"; got != want {
+		t.Errorf("problem link = %s, want %s", got, want)
+	}
+	if got, want := ds.entityLink(valuePlaceholder, 1000, 2000).URL, "https://abc123.live.dynatrace.com/ui/entity/${__value.raw}?gtf=c_1000_2000"; got != want {
+		t.Errorf("entity link = %s, want %s", got, want)
+	}
+}
+
+func TestUiUrlDefaultsToApiUrl(t *testing.T) {
+	tests := map[string]string{
+		`{"apiUrl":"https://abc123.live.dynatrace.com/"}`:                                  "https://abc123.live.dynatrace.com",
+		`{"apiUrl":"https://abc123.live.dynatrace.com","uiUrl":"https://ui.example.com/"}`: "https://ui.example.com",
+	}
+	for jsonData, want := range tests {
+		instance, err := NewDatasource(backend.DataSourceInstanceSettings{JSONData: []byte(jsonData)})
+		if err != nil {
+			t.Fatalf("NewDatasource: %v", err)
+		}
+		if got := instance.(*Datasource).uiUrl; got != want {
+			t.Errorf("uiUrl for %s = %s, want %s", jsonData, got, want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of the /logs/context resource route.
+const (
+	defaultLogContextLines = 10
+	maxLogContextLines     = 500
+	logContextWindow       = time.Hour
+)
+
+// logContextLabel matches label names usable in a log context query.
+var logContextLabel = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9._-]*$`)
+
+// logContextResponse is the body of GET /logs/context. Both lists are
+// ordered oldest first.
+type logContextResponse struct {
+	Before []DynatraceLogRecord `json:"before"`
+	After  []DynatraceLogRecord `json:"after"`
+}
+
+// handleLogContext serves GET /logs/context, returning the log lines written
+// around a record by the same source for Explore's "show context", e.g.
+//
+//	/logs/context?timestamp=1700000000000&before=20&after=20&log.source=/var/log/app.log&host.name=web-1
+//
+// Parameters other than timestamp, before and after are labels the
+// surrounding lines must match. Lines are searched within an hour of the
+// record; the record itself is not included.
+func (d *Datasource) handleLogContext(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	params := req.URL.Query()
+	timestamp, err := strconv.ParseInt(params.Get("timestamp"), 10, 64)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, "timestamp in milliseconds is required")
+		return
+	}
+	before, err := logContextLines(params.Get("before"))
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Sprintf("invalid before: %v", err))
+		return
+	}
+	after, err := logContextLines(params.Get("after"))
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, fmt.Sprintf("invalid after: %v", err))
+		return
+	}
+	params.Del("timestamp")
+	params.Del("before")
+	params.Del("after")
+
+	logQuery, err := logContextQuery(params)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	window := logContextWindow.Milliseconds()
+	resp := logContextResponse{Before: []DynatraceLogRecord{}, After: []DynatraceLogRecord{}}
+	if before > 0 {
+		records, err := d.searchLogs(req.Context(), logQuery, timestamp-window, timestamp, before, "-timestamp")
+		if err != nil {
+			writeError(rw, http.StatusBadGateway, err.Error())
+			return
+		}
+		resp.Before = records.Results
+		sortLogRecords(resp.Before)
+	}
+	if after > 0 {
+		records, err := d.searchLogs(req.Context(), logQuery, timestamp+1, timestamp+window, after, "timestamp")
+		if err != nil {
+			writeError(rw, http.StatusBadGateway, err.Error())
+			return
+		}
+		resp.After = records.Results
+		sortLogRecords(resp.After)
+	}
+
+	d.writeRedactedJSON(rw, http.StatusOK, resp)
+}
+
+// logContextLines parses the number of context lines requested on one side.
+func logContextLines(value string) (int, error) {
+	if value == "" {
+		return defaultLogContextLines, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > maxLogContextLines {
+		return 0, fmt.Errorf("must be a number between 0 and %d", maxLogContextLines)
+	}
+	return n, nil
+}
+
+// logContextQuery builds a logs search query matching all given labels.
+func logContextQuery(labels map[string][]string) (string, error) {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		if !logContextLabel.MatchString(key) {
+			return "", fmt.Errorf("invalid label %q", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	conditions := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(labels[key][0])
+		conditions = append(conditions, fmt.Sprintf(`%s="%s"`, key, value))
+	}
+	return strings.Join(conditions, " AND "), nil
+}
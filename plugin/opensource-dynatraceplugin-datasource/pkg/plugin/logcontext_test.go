@@ -0,0 +1,57 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleLogContext(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		if got := query.Get("query"); got != `host.name="web-1" AND log.source="/var/log/app.log"` {
+			t.Errorf("query = %s", got)
+		}
+		switch query.Get("sort") {
+		case "-timestamp":
+			if query.Get("to") != "1700000000000" || query.Get("limit") != "2" {
+				t.Errorf("unexpected before search %v", query)
+			}
+			_, _ = rw.Write([]byte(`{"results": [{"timestamp": 1699999999000, "content": "b2"}, {"timestamp": 1699999998000, "content": "b1"}]}`))
+		case "timestamp":
+			if query.Get("from") != "1700000000001" {
+				t.Errorf("after search should exclude the record, from = %s", query.Get("from"))
+			}
+			_, _ = rw.Write([]byte(`{"results": [{"timestamp": 1700000001000, "content": "a1"}]}`))
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet,
+		"/logs/context?timestamp=1700000000000&before=2&log.source=/var/log/app.log&host.name=web-1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp logContextResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Before) != 2 || resp.Before[0].Content != "b1" {
+		t.Errorf("before lines should be oldest first: %+v", resp.Before)
+	}
+	if len(resp.After) != 1 {
+		t.Errorf("expected 1 after line, got %d", len(resp.After))
+	}
+}
+
+func TestHandleLogContextRequiresTimestamp(t *testing.T) {
+	ds := &Datasource{}
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/logs/context?host.name=web-1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Limits of the /logs/fields resource route.
+const (
+	logFieldsSample    = 1000
+	logFieldsTopValues = 10
+)
+
+// logField is an attribute found on log records with its most frequent values.
+type logField struct {
+	Name   string          `json:"name"`
+	Count  int             `json:"count"`
+	Values []logFieldValue `json:"values"`
+}
+
+type logFieldValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// handleLogFields serves GET /logs/fields?query=&from=&to= with the
+// attributes of the log records matching a logs query and their top values,
+// for field facets and label filters. from and to are in milliseconds and
+// default to the last hour. Attributes are detected on the most recent
+// records only.
+func (d *Datasource) handleLogFields(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	params := req.URL.Query()
+	toMs := time.Now().UnixMilli()
+	fromMs := toMs - time.Hour.Milliseconds()
+	if v := params.Get("from"); v != "" {
+		from, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(rw, http.StatusBadRequest, "from must be a timestamp in milliseconds")
+			return
+		}
+		fromMs = from
+	}
+	if v := params.Get("to"); v != "" {
+		to, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(rw, http.StatusBadRequest, "to must be a timestamp in milliseconds")
+			return
+		}
+		toMs = to
+	}
+
+	logsResp, err := d.searchLogs(req.Context(), params.Get("query"), fromMs, toMs, logFieldsSample, "-timestamp")
+	if err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	d.writeRedactedJSON(rw, http.StatusOK, detectLogFields(logsResp.Results))
+}
+
+// detectLogFields counts the attributes of log records and their values,
+// most frequent first.
+func detectLogFields(records []DynatraceLogRecord) []logField {
+	counts := map[string]map[string]int{}
+	add := func(name, value string) {
+		if counts[name] == nil {
+			counts[name] = map[string]int{}
+		}
+		counts[name][value]++
+	}
+	for _, r := range records {
+		for name, values := range r.AdditionalColumns {
+			for _, value := range values {
+				add(name, value)
+			}
+		}
+		if r.Status != "" {
+			add("status", r.Status)
+		}
+		if r.EventType != "" {
+			add("event.type", r.EventType)
+		}
+	}
+
+	fields := make([]logField, 0, len(counts))
+	for name, values := range counts {
+		field := logField{Name: name, Values: make([]logFieldValue, 0, len(values))}
+		for value, n := range values {
+			field.Count += n
+			field.Values = append(field.Values, logFieldValue{Value: value, Count: n})
+		}
+		sort.Slice(field.Values, func(i, j int) bool {
+			if field.Values[i].Count != field.Values[j].Count {
+				return field.Values[i].Count > field.Values[j].Count
+			}
+			return field.Values[i].Value < field.Values[j].Value
+		})
+		if len(field.Values) > logFieldsTopValues {
+			field.Values = field.Values[:logFieldsTopValues]
+		}
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].Count != fields[j].Count {
+			return fields[i].Count > fields[j].Count
+		}
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
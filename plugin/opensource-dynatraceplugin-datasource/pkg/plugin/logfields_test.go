@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleLogFields(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got := req.URL.Query().Get("query"); got != `status="ERROR"` {
+			t.Errorf("query = %q", got)
+		}
+		if got := req.URL.Query().Get("from"); got != "1000" {
+			t.Errorf("from = %q", got)
+		}
+		_, _ = rw.Write([]byte(`{"results": [
+			{"timestamp": 3, "status": "ERROR", "additionalColumns": {"host.name": ["web-1"], "log.source": ["/var/log/app.log"]}},
+			{"timestamp": 2, "status": "ERROR", "additionalColumns": {"host.name": ["web-2"]}},
+			{"timestamp": 1, "status": "ERROR", "additionalColumns": {"host.name": ["web-1"]}}
+		]}`))
+	})
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, `/logs/fields?query=status%3D%22ERROR%22&from=1000&to=2000`, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var fields []logField
+	if err := json.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 3 || fields[0].Name != "host.name" || fields[2].Name != "log.source" {
+		t.Fatalf("unexpected fields %+v", fields)
+	}
+	if top := fields[0].Values[0]; top.Value != "web-1" || top.Count != 2 {
+		t.Errorf("top host value = %+v, want web-1 x2", top)
+	}
+}
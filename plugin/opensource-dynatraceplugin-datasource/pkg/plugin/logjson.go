@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// logColumnNames are the fixed columns of log frames and the record fields
+// they are filled from. JSON keys with these names are prefixed with "json."
+// so that they don't shadow them.
+var logColumnNames = map[string]bool{
+	"timestamp": true,
+	"body":      true,
+	"content":   true,
+	"severity":  true,
+	"status":    true,
+	"labels":    true,
+	"entityId":  true,
+}
+
+// logJSONFieldName returns the field and label name of a JSON key.
+func logJSONFieldName(key string) string {
+	if logColumnNames[key] {
+		return "json." + key
+	}
+	return key
+}
+
+// logJSONValues returns the values of keys in a JSON log body. Keys may
+// address nested objects with dots, e.g. "http.status". Bodies that are not
+// JSON objects and keys that are missing yield no values. Strings are
+// returned as-is and other values in their JSON form, with numbers as written
+// rather than in exponent form.
+func logJSONValues(content string, keys []string) map[string]string {
+	content = strings.TrimSpace(content)
+	if len(keys) == 0 || !strings.HasPrefix(content, "{") {
+		return nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(content))
+	decoder.UseNumber()
+	var body map[string]interface{}
+	if err := decoder.Decode(&body); err != nil {
+		return nil
+	}
+
+	values := map[string]string{}
+	for _, key := range keys {
+		if value, ok := jsonPath(body, key); ok {
+			values[key] = jsonValueString(value)
+		}
+	}
+	return values
+}
+
+// jsonPath looks up a key in a decoded JSON object. A key present verbatim
+// wins over a nested lookup of its dot-separated parts.
+func jsonPath(object map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := object[key]; ok {
+		return value, true
+	}
+	parts := strings.SplitN(key, ".", 2)
+	if len(parts) < 2 {
+		return nil, false
+	}
+	nested, ok := object[parts[0]].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return jsonPath(nested, parts[1])
+}
+
+// jsonValueString formats a decoded JSON value as a field value.
+func jsonValueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(raw)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestLogJSONValues(t *testing.T) {
+	content := `{"level": "warn", "http": {"status": 503, "path": "/cart"}, "user.id": "u-1", "tags": ["a", "b"], "bytes": 12345678901, "ratio": 0.5}`
+	values := logJSONValues(content, []string{"level", "http.status", "user.id", "tags", "bytes", "ratio", "missing"})
+
+	want := map[string]string{"level": "warn", "http.status": "503", "user.id": "u-1", "tags": `["a","b"]`, "bytes": "12345678901", "ratio": "0.5"}
+	if len(values) != len(want) {
+		t.Fatalf("values = %v, want %v", values, want)
+	}
+	for k, v := range want {
+		if values[k] != v {
+			t.Errorf("%s = %q, want %q", k, values[k], v)
+		}
+	}
+
+	if values := logJSONValues("GET /cart 503", []string{"level"}); values != nil {
+		t.Errorf("values of a plain text body = %v, want none", values)
+	}
+}
+
+func TestLogsFrameJSONFields(t *testing.T) {
+	frame := logsFrame([]DynatraceLogRecord{
+		{Timestamp: 1000, Content: `{"order": "o-1", "level": "info"}`, AdditionalColumns: map[string][]string{"level": {"INFO"}}},
+		{Timestamp: 2000, Content: "plain text"},
+	}, []string{"order", "level"})
+
+	order, _ := frame.FieldByName("order")
+	if order == nil {
+		t.Fatal("expected an order field")
+	}
+	if got := order.At(0).(*string); got == nil || *got != "o-1" {
+		t.Errorf("order = %v, want o-1", got)
+	}
+	if got := order.At(1).(*string); got != nil {
+		t.Errorf("order of a plain text record = %v, want null", *got)
+	}
+
+	var labels map[string]string
+	if err := json.Unmarshal(frame.Fields[3].At(0).(json.RawMessage), &labels); err != nil {
+		t.Fatal(err)
+	}
+	if labels["order"] != "o-1" {
+		t.Errorf("labels = %v, want order=o-1", labels)
+	}
+	if labels["level"] != "INFO" {
+		t.Errorf("level label = %q, the log attribute should be kept", labels["level"])
+	}
+}
+
+func TestLogsFrameJSONFieldsCollidingWithColumns(t *testing.T) {
+	frame := logsFrame([]DynatraceLogRecord{
+		{Timestamp: 1000, Content: `{"timestamp": "yesterday", "status": "ok"}`, Status: "INFO"},
+	}, []string{"timestamp", "status"})
+
+	if got := frame.Fields[0].At(0); got != time.UnixMilli(1000) {
+		t.Errorf("timestamp = %v, the record timestamp should be kept", got)
+	}
+	for key, want := range map[string]string{"json.timestamp": "yesterday", "json.status": "ok"} {
+		field, _ := frame.FieldByName(key)
+		if field == nil {
+			t.Fatalf("expected a %s field", key)
+		}
+		if got := field.At(0).(*string); got == nil || *got != want {
+			t.Errorf("%s = %v, want %s", key, got, want)
+		}
+	}
+	if len(frame.Fields) != 7 {
+		t.Errorf("expected 5 columns and 2 JSON fields, got %d", len(frame.Fields))
+	}
+}
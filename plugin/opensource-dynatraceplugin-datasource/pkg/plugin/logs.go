@@ -0,0 +1,252 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/live"
+)
+
+// defaultLogLimit is the number of log records returned when the query doesn't set a limit.
+const defaultLogLimit = 1000
+
+// DynatraceLogsResponse represents the response of /api/v2/logs/search
+type DynatraceLogsResponse struct {
+	NextSliceKey *string              `json:"nextSliceKey"`
+	SliceSize    int                  `json:"sliceSize"`
+	Warnings     string               `json:"warnings"`
+	Results      []DynatraceLogRecord `json:"results"`
+}
+
+type DynatraceLogRecord struct {
+	Timestamp         int64               `json:"timestamp"`
+	Content           string              `json:"content"`
+	Status            string              `json:"status"`
+	EventType         string              `json:"eventType"`
+	AdditionalColumns map[string][]string `json:"additionalColumns"`
+}
+
+// queryLogs searches log records with the Logs V2 API and returns them as a
+// logs frame. By default the most recent records are returned oldest first;
+// logSort "asc" returns the oldest records and "desc" the most recent ones
+// newest first. Live queries also carry a Grafana Live channel that tails new
+// records, see RunStream.
+func (d *Datasource) queryLogs(ctx context.Context, pCtx backend.PluginContext, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	if qm.LogLimit > d.resultLimit() {
+		addQueryNotice(ctx, data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("Log limit %d was reduced to maxResults %d", qm.LogLimit, d.resultLimit()),
+		})
+		qm.LogLimit = d.resultLimit()
+	}
+
+	var logsResp *DynatraceLogsResponse
+	switch qm.LogSort {
+	case "":
+		logsResp, err = d.fetchLogs(ctx, qm.LogQuery, fromMs, toMs, qm.LogLimit)
+	case "asc":
+		// The limit keeps the oldest records instead
+		logsResp, err = d.searchLogs(ctx, qm.LogQuery, fromMs, toMs, qm.LogLimit, "timestamp")
+	case "desc":
+		logsResp, err = d.searchLogs(ctx, qm.LogQuery, fromMs, toMs, qm.LogLimit, "-timestamp")
+	default:
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid logSort %q, expected asc or desc", qm.LogSort))
+	}
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace logs: %v", err))
+	}
+
+	frame := logsFrame(logsResp.Results, qm.LogJSONFields)
+	addRelatedMetricsLink(frame, d.settings.UID, "entityId", "entityId")
+	addFieldLinks(frame, "entityId", d.entityLink(valuePlaceholder, fromMs, toMs))
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeLogs,
+		ExecutedQueryString:    fmt.Sprintf("Logs: %s", qm.LogQuery),
+	}
+	if logsResp.Warnings != "" {
+		frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityWarning, Text: logsResp.Warnings})
+	}
+
+	if qm.Live && pCtx.DataSourceInstanceSettings != nil {
+		path := d.registerLogTail(query.RefID, qm)
+		frame.Meta.Channel = live.Channel{
+			Scope:     live.ScopeDatasource,
+			Namespace: pCtx.DataSourceInstanceSettings.UID,
+			Path:      path,
+		}.String()
+	}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// fetchLogs runs a Logs V2 search and returns the records in ascending timestamp order.
+func (d *Datasource) fetchLogs(ctx context.Context, logQuery string, fromMs, toMs int64, limit int) (*DynatraceLogsResponse, error) {
+	// The newest records are requested so that the limit keeps the most recent
+	// ones; present them oldest first.
+	logsResp, err := d.searchLogs(ctx, logQuery, fromMs, toMs, limit, "-timestamp")
+	if err != nil {
+		return nil, err
+	}
+	sortLogRecords(logsResp.Results)
+
+	return logsResp, nil
+}
+
+// searchLogs runs a Logs V2 search returning the records in the given sort
+// order, "timestamp" or "-timestamp".
+func (d *Datasource) searchLogs(ctx context.Context, logQuery string, fromMs, toMs int64, limit int, sortOrder string) (*DynatraceLogsResponse, error) {
+	if limit <= 0 {
+		limit = defaultLogLimit
+	}
+
+	params := url.Values{}
+	params.Set("from", fmt.Sprintf("%d", fromMs))
+	params.Set("to", fmt.Sprintf("%d", toMs))
+	params.Set("limit", fmt.Sprintf("%d", limit))
+	params.Set("sort", sortOrder)
+	if logQuery != "" {
+		params.Set("query", logQuery)
+	}
+
+	log.DefaultLogger.Debug("Querying Dynatrace logs", "query", d.logPayload(logQuery), "from", fromMs, "to", toMs, "limit", limit, "sort", sortOrder)
+
+	var logsResp DynatraceLogsResponse
+	if err := d.get(ctx, "/api/v2/logs/search", params, &logsResp); err != nil {
+		return nil, err
+	}
+	return &logsResp, nil
+}
+
+// sortLogRecords orders log records oldest first.
+func sortLogRecords(records []DynatraceLogRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp < records[j].Timestamp
+	})
+}
+
+// logsFrame converts log records into a frame following Grafana's logs data
+// frame conventions: timestamp, body, severity and a labels JSON field. The
+// jsonKeys of JSON log bodies are added as fields and labels, see
+// logJSONFieldName; records whose body isn't JSON have null values.
+func logsFrame(records []DynatraceLogRecord, jsonKeys []string) *data.Frame {
+	frame := data.NewFrame("logs",
+		data.NewField("timestamp", nil, []time.Time{}),
+		data.NewField("body", nil, []string{}),
+		data.NewField("severity", nil, []string{}),
+		data.NewField("labels", nil, []json.RawMessage{}),
+		data.NewField("entityId", nil, []string{}),
+	)
+
+	for _, key := range jsonKeys {
+		frame.Fields = append(frame.Fields, data.NewField(logJSONFieldName(key), nil, []*string{}))
+	}
+
+	for _, r := range records {
+		values := logJSONValues(r.Content, jsonKeys)
+		labels := make(map[string]string, len(values))
+		for key, value := range values {
+			labels[logJSONFieldName(key)] = value
+		}
+		row := []interface{}{time.UnixMilli(r.Timestamp), r.Content, logLevel(r.level()), logLabels(r, labels), logEntityId(r)}
+		for _, key := range jsonKeys {
+			var value *string
+			if v, ok := values[key]; ok {
+				value = &v
+			}
+			row = append(row, value)
+		}
+		frame.AppendRow(row...)
+	}
+
+	return frame
+}
+
+// level returns the level a record was logged at: its status, or the
+// loglevel attribute for records without one.
+func (r DynatraceLogRecord) level() string {
+	if r.Status != "" && r.Status != "NONE" {
+		return r.Status
+	}
+	if values := r.AdditionalColumns["loglevel"]; len(values) > 0 {
+		return values[0]
+	}
+	return r.Status
+}
+
+// logLevel maps the level of a Dynatrace log record to one of Grafana's log
+// levels, so that Explore colors lines and filters them by level.
+func logLevel(level string) string {
+	switch strings.ToUpper(level) {
+	case "EMERGENCY", "ALERT", "CRITICAL", "FATAL", "SEVERE":
+		return "critical"
+	case "ERROR", "ERR":
+		return "error"
+	case "WARN", "WARNING":
+		return "warning"
+	case "INFO", "INFORMATIONAL", "NOTICE":
+		return "info"
+	case "DEBUG", "FINE":
+		return "debug"
+	case "TRACE", "FINER", "FINEST":
+		return "trace"
+	default:
+		return "unknown"
+	}
+}
+
+// logEntityIdColumns are the entity columns of a log record, most specific first.
+var logEntityIdColumns = []string{"dt.entity.process_group_instance", "dt.entity.service", "dt.entity.host"}
+
+// logEntityId returns the most specific entity a log record was collected from.
+func logEntityId(r DynatraceLogRecord) string {
+	for _, column := range logEntityIdColumns {
+		if values := r.AdditionalColumns[column]; len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// logLabels flattens the additional columns of a record into a labels object.
+// Multi-valued columns are joined with commas. Extra labels don't replace
+// columns of the same name.
+func logLabels(r DynatraceLogRecord, extra map[string]string) json.RawMessage {
+	labels := data.Labels{}
+	for key, values := range r.AdditionalColumns {
+		if len(values) == 0 {
+			continue
+		}
+		value := values[0]
+		for _, v := range values[1:] {
+			value += "," + v
+		}
+		labels[key] = value
+	}
+	if r.EventType != "" {
+		labels["event.type"] = r.EventType
+	}
+	for key, value := range extra {
+		if _, ok := labels[key]; !ok {
+			labels[key] = value
+		}
+	}
+
+	raw, err := json.Marshal(labels)
+	if err != nil {
+		return json.RawMessage("{}")
+	}
+	return raw
+}
@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryLogsSort(t *testing.T) {
+	tests := []struct {
+		logSort  string
+		wantSort string
+		wantLast int64
+	}{
+		{logSort: "", wantSort: "-timestamp", wantLast: 2000},
+		{logSort: "asc", wantSort: "timestamp", wantLast: 2000},
+		{logSort: "desc", wantSort: "-timestamp", wantLast: 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.logSort, func(t *testing.T) {
+			ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+				sortOrder := req.URL.Query().Get("sort")
+				if sortOrder != tt.wantSort {
+					t.Errorf("sort = %q, want %q", sortOrder, tt.wantSort)
+				}
+				if sortOrder == "timestamp" {
+					_, _ = rw.Write([]byte(`{"results": [{"timestamp": 1000, "content": "a"}, {"timestamp": 2000, "content": "b"}]}`))
+					return
+				}
+				_, _ = rw.Write([]byte(`{"results": [{"timestamp": 2000, "content": "b"}, {"timestamp": 1000, "content": "a"}]}`))
+			})
+
+			resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+				RefID:     "A",
+				QueryType: queryTypeLogs,
+				JSON:      []byte(`{"useDashboardTime": true, "logQuery": "status=\"ERROR\"", "logSort": "` + tt.logSort + `"}`),
+			})
+			if resp.Error != nil {
+				t.Fatalf("unexpected error: %v", resp.Error)
+			}
+			timestamps := resp.Frames[0].Fields[0]
+			if got := timestamps.At(timestamps.Len() - 1).(time.Time).UnixMilli(); got != tt.wantLast {
+				t.Errorf("last timestamp = %d, want %d", got, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestQueryLogsInvalidSort(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s", req.URL)
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeLogs,
+		JSON:      []byte(`{"useDashboardTime": true, "logSort": "newest"}`),
+	})
+	if resp.Error == nil {
+		t.Fatal("expected an error for an invalid logSort")
+	}
+}
+
+func TestLogsFrameSeverity(t *testing.T) {
+	frame := logsFrame([]DynatraceLogRecord{
+		{Timestamp: 1000, Status: "ERROR"},
+		{Timestamp: 2000, Status: "WARN"},
+		{Timestamp: 3000, Status: "NONE", AdditionalColumns: map[string][]string{"loglevel": {"debug"}}},
+		{Timestamp: 4000, AdditionalColumns: map[string][]string{"loglevel": {"FATAL"}}},
+		{Timestamp: 5000, Status: "NONE"},
+	}, nil)
+
+	want := []string{"error", "warning", "debug", "critical", "unknown"}
+	severity := frame.Fields[2]
+	for i, level := range want {
+		if got := severity.At(i); got != level {
+			t.Errorf("severity %d = %v, want %s", i, got, level)
+		}
+	}
+}
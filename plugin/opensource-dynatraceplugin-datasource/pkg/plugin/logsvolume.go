@@ -0,0 +1,153 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// logsVolumeBuckets is the number of histogram buckets when the query has
+// no interval.
+const logsVolumeBuckets = 100
+
+// The logs aggregate API splits the range into at most 1440 time buckets of
+// at least a minute.
+const (
+	maxLogsAggregateBuckets  = 1440
+	minLogsAggregateBucketMs = int64(time.Minute / time.Millisecond)
+)
+
+// DynatraceLogsAggregateResponse represents the response of
+// /api/v2/logs/aggregate. With time buckets, aggregationResult maps the start
+// of each bucket, in milliseconds, to the record counts per grouped field and
+// value, e.g. {"60000": {"status": {"ERROR": 2}}}.
+type DynatraceLogsAggregateResponse struct {
+	AggregationResult map[string]map[string]map[string]int64 `json:"aggregationResult"`
+}
+
+// queryLogsVolume counts the log records matched by a logs query per time
+// bucket and level with the logs aggregate API. It backs the supplementary
+// logs volume histogram that Explore shows above log results. The bucket
+// width is the query's resolution, or its interval if it has none.
+func (d *Datasource) queryLogsVolume(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	interval := query.Interval.Milliseconds()
+	if qm.Resolution != "" {
+		resolution, err := parseResolution(qm.Resolution)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+		interval = resolution.Milliseconds()
+	}
+	if interval <= 0 {
+		interval = (toMs - fromMs) / logsVolumeBuckets
+	}
+	buckets := logsVolumeBucketCount(fromMs, toMs, interval)
+
+	params := url.Values{}
+	params.Set("from", strconv.FormatInt(fromMs, 10))
+	params.Set("to", strconv.FormatInt(toMs, 10))
+	params.Set("timeBuckets", strconv.Itoa(buckets))
+	params.Set("groupBy", "status")
+	if qm.LogQuery != "" {
+		params.Set("query", qm.LogQuery)
+	}
+
+	log.DefaultLogger.Debug("Aggregating Dynatrace logs", "query", d.logPayload(qm.LogQuery), "from", fromMs, "to", toMs, "timeBuckets", buckets)
+
+	var aggregateResp DynatraceLogsAggregateResponse
+	if err := d.get(ctx, "/api/v2/logs/aggregate", params, &aggregateResp); err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace logs: %v", err))
+	}
+
+	frames, err := logsVolumeFrames(aggregateResp, fromMs, toMs, buckets)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+	}
+	for _, frame := range frames {
+		frame.Meta = &data.FrameMeta{
+			ExecutedQueryString: fmt.Sprintf("Logs volume: %s", qm.LogQuery),
+			// Tells Explore the histogram spans the whole range
+			Custom: map[string]interface{}{
+				"logsVolumeType": "FullRange",
+				"absoluteRange":  map[string]int64{"from": fromMs, "to": toMs},
+			},
+		}
+	}
+
+	return backend.DataResponse{Frames: frames}
+}
+
+// logsVolumeBucketCount returns the number of time buckets of the given width
+// covering the range, within the limits of the logs aggregate API.
+func logsVolumeBucketCount(fromMs, toMs, interval int64) int {
+	if interval < minLogsAggregateBucketMs {
+		interval = minLogsAggregateBucketMs
+	}
+	buckets := (toMs - fromMs + interval - 1) / interval
+	if buckets > maxLogsAggregateBuckets {
+		buckets = maxLogsAggregateBuckets
+	}
+	if buckets < 1 {
+		buckets = 1
+	}
+	return int(buckets)
+}
+
+// logsVolumeFrames returns one count series per log level, with a zero
+// filled value for each of the buckets the range was split into.
+func logsVolumeFrames(resp DynatraceLogsAggregateResponse, fromMs, toMs int64, buckets int) (data.Frames, error) {
+	width := (toMs - fromMs) / int64(buckets)
+	if width <= 0 {
+		width = 1
+	}
+
+	counts := map[string][]int64{}
+	for key, groups := range resp.AggregationResult {
+		bucketMs, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid logs aggregate time bucket %q", key)
+		}
+		i := (bucketMs - fromMs) / width
+		if i < 0 || i >= int64(buckets) {
+			continue
+		}
+		for status, count := range groups["status"] {
+			level := logLevel(status)
+			if counts[level] == nil {
+				counts[level] = make([]int64, buckets)
+			}
+			counts[level][i] += count
+		}
+	}
+
+	levels := make([]string, 0, len(counts))
+	for level := range counts {
+		levels = append(levels, level)
+	}
+	sort.Strings(levels)
+
+	times := make([]time.Time, buckets)
+	for i := range times {
+		times[i] = time.UnixMilli(fromMs + int64(i)*width)
+	}
+
+	frames := make(data.Frames, 0, len(levels))
+	for _, level := range levels {
+		count := data.NewField("count", data.Labels{"level": level}, counts[level])
+		count.Config = &data.FieldConfig{DisplayNameFromDS: level}
+		frames = append(frames, data.NewFrame("logs volume", data.NewField("time", nil, times), count))
+	}
+	return frames, nil
+}
@@ -0,0 +1,74 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryLogsVolume(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/logs/aggregate" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		params := req.URL.Query()
+		if params.Get("timeBuckets") != "3" || params.Get("groupBy") != "status" || params.Get("query") != `status="ERROR"` {
+			t.Errorf("unexpected params %v", params)
+		}
+		_, _ = rw.Write([]byte(`{"aggregationResult": {
+			"0": {"status": {"ERROR": 2}},
+			"60000": {"status": {"INFO": 1}},
+			"120000": {"status": {"NONE": 1, "ERROR": 3}}
+		}}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeLogsVolume,
+		Interval:  time.Minute,
+		TimeRange: backend.TimeRange{From: time.UnixMilli(0), To: time.UnixMilli(180000)},
+		JSON:      []byte(`{"useDashboardTime": true, "logQuery": "status=\"ERROR\""}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 3 {
+		t.Fatalf("expected a series per level, got %d", len(resp.Frames))
+	}
+
+	errors := resp.Frames[0].Fields[1]
+	if errors.Labels["level"] != "error" {
+		t.Fatalf("first level = %v, want error", errors.Labels)
+	}
+	if errors.Len() != 3 || errors.At(0) != int64(2) || errors.At(1) != int64(0) || errors.At(2) != int64(3) {
+		t.Errorf("unexpected error counts %v %v %v", errors.At(0), errors.At(1), errors.At(2))
+	}
+	if resp.Frames[0].Meta.Custom.(map[string]interface{})["logsVolumeType"] != "FullRange" {
+		t.Errorf("frames should be marked as full range logs volume")
+	}
+}
+
+func TestLogsVolumeBucketCount(t *testing.T) {
+	hour := int64(time.Hour / time.Millisecond)
+	tests := []struct {
+		name     string
+		interval int64
+		toMs     int64
+		want     int
+	}{
+		{name: "interval", interval: 5 * 60000, toMs: hour, want: 12},
+		{name: "below a minute", interval: 1000, toMs: hour, want: 60},
+		{name: "capped", interval: 60000, toMs: 7 * 24 * hour, want: maxLogsAggregateBuckets},
+		{name: "short range", interval: 60000, toMs: 1000, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logsVolumeBucketCount(0, tt.toMs, tt.interval); got != tt.want {
+				t.Errorf("logsVolumeBucketCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
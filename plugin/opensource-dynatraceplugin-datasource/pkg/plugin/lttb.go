@@ -0,0 +1,77 @@
+package plugin
+
+import "math"
+
+// downsampleLTTB reduces a series to threshold points with the
+// Largest-Triangle-Three-Buckets algorithm, which keeps the visual shape of
+// the series: the first and last points are kept and from each bucket in
+// between the point forming the largest triangle with its neighbours is
+// selected. Null points are only selected from buckets without values, so
+// gaps stay visible. Series with at most threshold points are returned unchanged.
+func downsampleLTTB(timestamps []int64, values []*float64, threshold int) ([]int64, []*float64) {
+	n := len(values)
+	if threshold >= n || threshold < 3 {
+		return timestamps, values
+	}
+
+	outTs := make([]int64, 0, threshold)
+	outValues := make([]*float64, 0, threshold)
+	outTs = append(outTs, timestamps[0])
+	outValues = append(outValues, values[0])
+
+	bucketSize := float64(n-2) / float64(threshold-2)
+	// The last selected point with a value, the first point of the triangle
+	var ax, ay float64
+	if values[0] != nil {
+		ax, ay = float64(timestamps[0]), *values[0]
+	}
+	for i := 0; i < threshold-2; i++ {
+		// Average of the next bucket, the third point of the triangle
+		nextStart := int(float64(i+1)*bucketSize) + 1
+		nextEnd := int(float64(i+2)*bucketSize) + 1
+		if nextEnd > n {
+			nextEnd = n
+		}
+		var avgX, avgY, count float64
+		for j := nextStart; j < nextEnd; j++ {
+			if values[j] != nil {
+				avgX += float64(timestamps[j])
+				avgY += *values[j]
+				count++
+			}
+		}
+		if count > 0 {
+			avgX /= count
+			avgY /= count
+		} else {
+			avgX, avgY = float64(timestamps[nextEnd-1]), ay
+		}
+
+		// Point of the current bucket forming the largest triangle with the
+		// previously selected point and the next bucket's average
+		start := int(float64(i)*bucketSize) + 1
+		end := int(float64(i+1)*bucketSize) + 1
+		maxArea := -1.0
+		next := start
+		for j := start; j < end; j++ {
+			if values[j] == nil {
+				continue
+			}
+			area := math.Abs((ax-avgX)*(*values[j]-ay) - (ax-float64(timestamps[j]))*(avgY-ay))
+			if area > maxArea {
+				maxArea = area
+				next = j
+			}
+		}
+
+		outTs = append(outTs, timestamps[next])
+		outValues = append(outValues, values[next])
+		if values[next] != nil {
+			ax, ay = float64(timestamps[next]), *values[next]
+		}
+	}
+
+	outTs = append(outTs, timestamps[n-1])
+	outValues = append(outValues, values[n-1])
+	return outTs, outValues
+}
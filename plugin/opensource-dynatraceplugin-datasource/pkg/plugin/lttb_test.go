@@ -0,0 +1,60 @@
+package plugin
+
+import "testing"
+
+func TestDownsampleLTTB(t *testing.T) {
+	n := 1000
+	timestamps := make([]int64, n)
+	values := make([]*float64, n)
+	for i := range values {
+		timestamps[i] = int64(i) * 60000
+		values[i] = float64Ptr(0)
+	}
+	values[500] = float64Ptr(100) // a spike must survive downsampling
+
+	ts, out := downsampleLTTB(timestamps, values, 50)
+	if len(ts) != 50 || len(out) != 50 {
+		t.Fatalf("expected 50 points, got %d", len(ts))
+	}
+	if ts[0] != timestamps[0] || ts[49] != timestamps[n-1] {
+		t.Errorf("first and last points should be kept")
+	}
+	spike := false
+	for i := range out {
+		spike = spike || *out[i] == 100
+		if i > 0 && ts[i] <= ts[i-1] {
+			t.Fatalf("timestamps should stay ascending at %d", i)
+		}
+	}
+	if !spike {
+		t.Error("the spike should be kept")
+	}
+
+	if ts, _ := downsampleLTTB(timestamps[:10], values[:10], 50); len(ts) != 10 {
+		t.Errorf("short series should be unchanged, got %d points", len(ts))
+	}
+}
+
+func TestDownsampleLTTBKeepsGaps(t *testing.T) {
+	n := 1000
+	timestamps := make([]int64, n)
+	values := make([]*float64, n)
+	for i := range values {
+		timestamps[i] = int64(i) * 60000
+		// No data in the middle of the range
+		if i < 400 || i >= 600 {
+			values[i] = float64Ptr(float64(i % 7))
+		}
+	}
+
+	_, out := downsampleLTTB(timestamps, values, 50)
+	nulls := 0
+	for _, v := range out {
+		if v == nil {
+			nulls++
+		}
+	}
+	if nulls == 0 || nulls == len(out) {
+		t.Errorf("expected the gap to stay visible, got %s", formatValues(out))
+	}
+}
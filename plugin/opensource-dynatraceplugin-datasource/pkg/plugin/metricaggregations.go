@@ -0,0 +1,175 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DynatraceMetricDescriptor is the part of /api/v2/metrics/{metricKey} that
+// describes how a metric can be aggregated.
+type DynatraceMetricDescriptor struct {
+	MetricId           string   `json:"metricId"`
+	AggregationTypes   []string `json:"aggregationTypes"`
+	Transformations    []string `json:"transformations"`
+	DefaultAggregation struct {
+		Type      string  `json:"type"`
+		Parameter float64 `json:"parameter,omitempty"`
+	} `json:"defaultAggregation"`
+}
+
+// metricAggregations is returned by the /metrics/aggregations resource route.
+type metricAggregations struct {
+	MetricKey          string   `json:"metricKey"`
+	AggregationTypes   []string `json:"aggregationTypes"`
+	Transformations    []string `json:"transformations"`
+	DefaultAggregation string   `json:"defaultAggregation"`
+}
+
+// aggregationTransformations maps the aggregation transformations of the
+// metric selector language to the aggregation type they require.
+var aggregationTransformations = map[string]string{
+	"auto":       "auto",
+	"avg":        "avg",
+	"count":      "count",
+	"max":        "max",
+	"min":        "min",
+	"sum":        "sum",
+	"value":      "value",
+	"percentile": "percentile",
+	"median":     "percentile",
+}
+
+// selectorMetricKey matches the metric key a selector starts with, e.g.
+// "builtin:host.cpu.usage" in "builtin:host.cpu.usage:splitBy():avg".
+var selectorMetricKey = regexp.MustCompile(`^[A-Za-z0-9_-]+(:[A-Za-z0-9_-]+)?(\.[A-Za-z0-9_-]+)+`)
+
+// handleMetricAggregations serves GET /metrics/aggregations?metricKey=...,
+// returning the aggregation types, transformations and default aggregation
+// of a metric so the query editor can disable invalid choices. Descriptors
+// are cached for validating metric queries.
+func (d *Datasource) handleMetricAggregations(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	metricKey := req.URL.Query().Get("metricKey")
+	if metricKey == "" {
+		writeError(rw, http.StatusBadRequest, "metricKey is required")
+		return
+	}
+
+	descriptor, err := d.fetchMetricDescriptor(req.Context(), metricKey)
+	if err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, metricAggregations{
+		MetricKey:          metricKey,
+		AggregationTypes:   descriptor.AggregationTypes,
+		Transformations:    descriptor.Transformations,
+		DefaultAggregation: descriptor.DefaultAggregation.Type,
+	})
+}
+
+// fetchMetricDescriptor looks up a metric descriptor and caches it.
+func (d *Datasource) fetchMetricDescriptor(ctx context.Context, metricKey string) (*DynatraceMetricDescriptor, error) {
+	var descriptor DynatraceMetricDescriptor
+	if err := d.get(ctx, "/api/v2/metrics/"+url.PathEscape(metricKey), nil, &descriptor); err != nil {
+		return nil, err
+	}
+	d.metricDescriptors.Store(metricKey, &descriptor)
+	return &descriptor, nil
+}
+
+// validateSelectorAggregations checks the aggregation transformations applied
+// to the metric a selector starts with against its cached descriptor.
+// Selectors of metrics whose descriptor was not looked up yet, selectors that
+// are not a metric key followed by a chain of transformations and queries
+// bypassing the cache are left for the API to validate.
+func (d *Datasource) validateSelectorAggregations(ctx context.Context, selector string) error {
+	metricKey := selectorMetricKey.FindString(selector)
+	if metricKey == "" || cacheBypassed(ctx) {
+		return nil
+	}
+	cached, ok := d.metricDescriptors.Load(metricKey)
+	if !ok {
+		return nil
+	}
+	descriptor := cached.(*DynatraceMetricDescriptor)
+
+	names, ok := transformationNames(selector[len(metricKey):])
+	if !ok {
+		return nil
+	}
+	for _, name := range names {
+		required, isAggregation := aggregationTransformations[name]
+		if !isAggregation {
+			continue
+		}
+		allowed := false
+		for _, t := range descriptor.AggregationTypes {
+			if t == required {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("aggregation %q is not supported by metric %s; allowed: %s", name, metricKey, strings.Join(descriptor.AggregationTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// transformationNames returns the names of a chain of transformations such
+// as ":splitBy(\"dt.entity.host\"):avg". It reports false when chain is
+// anything else, e.g. part of a metric expression.
+func transformationNames(chain string) ([]string, bool) {
+	var names []string
+	for chain != "" {
+		if chain[0] != ':' {
+			return nil, false
+		}
+		chain = chain[1:]
+		end := 0
+		for end < len(chain) && (chain[end] >= 'a' && chain[end] <= 'z' || chain[end] >= 'A' && chain[end] <= 'Z') {
+			end++
+		}
+		if end == 0 {
+			return nil, false
+		}
+		names = append(names, chain[:end])
+		chain = chain[end:]
+
+		if chain == "" || chain[0] != '(' {
+			continue
+		}
+		depth, quoted := 0, false
+		i := 0
+		for ; i < len(chain); i++ {
+			switch c := chain[i]; {
+			case c == '\\' && quoted:
+				i++
+			case c == '"':
+				quoted = !quoted
+			case c == '(' && !quoted:
+				depth++
+			case c == ')' && !quoted:
+				depth--
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		if depth != 0 {
+			return nil, false
+		}
+		chain = chain[i+1:]
+	}
+	return names, true
+}
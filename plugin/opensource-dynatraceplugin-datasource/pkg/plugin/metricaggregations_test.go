@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestHandleMetricAggregations(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/metrics/builtin:host.cpu.usage" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+			return
+		}
+		_, _ = rw.Write([]byte(`{"metricId": "builtin:host.cpu.usage", "aggregationTypes": ["auto", "avg", "max", "min"],
+			"transformations": ["filter", "splitBy", "fold"], "defaultAggregation": {"type": "avg"}}`))
+	})
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/aggregations?metricKey=builtin:host.cpu.usage", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got metricAggregations
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.DefaultAggregation != "avg" || strings.Join(got.AggregationTypes, ",") != "auto,avg,max,min" {
+		t.Errorf("unexpected aggregations %+v", got)
+	}
+
+	// The cached descriptor now rejects unsupported aggregations before querying
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"useDashboardTime": true, "metricSelector": "builtin:host.cpu.usage:splitBy(\"dt.entity.host\"):sum"}`),
+	})
+	if resp.Error == nil || !strings.Contains(resp.Error.Error(), `aggregation "sum" is not supported`) {
+		t.Errorf("expected the sum aggregation to be rejected, got %v", resp.Error)
+	}
+}
+
+func TestHandleMetricAggregationsRequiresKey(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s", req.URL.Path)
+	})
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/aggregations", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestValidateSelectorAggregations(t *testing.T) {
+	ds := &Datasource{}
+	descriptor := &DynatraceMetricDescriptor{AggregationTypes: []string{"auto", "avg", "percentile"}}
+	ds.metricDescriptors.Store("builtin:service.response.time", descriptor)
+
+	tests := []struct {
+		selector string
+		valid    bool
+	}{
+		{"builtin:service.response.time", true},
+		{"builtin:service.response.time:avg", true},
+		{"builtin:service.response.time:median", true},
+		{`builtin:service.response.time:filter(eq("dt.entity.service","a:max")):percentile(90)`, true},
+		{"builtin:service.response.time:splitBy():max", false},
+		{"builtin:service.response.time:count", false},
+		// Expressions and unknown metrics are left to the API
+		{"builtin:service.response.time:max / 2", true},
+		{"builtin:host.cpu.usage:sum", true},
+	}
+	for _, tt := range tests {
+		err := ds.validateSelectorAggregations(context.Background(), tt.selector)
+		if (err == nil) != tt.valid {
+			t.Errorf("validateSelectorAggregations(%q) = %v, want valid %v", tt.selector, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// metricEventsSchemaId is the settings schema of metric events (custom alerts).
+const metricEventsSchemaId = "builtin:anomaly-detection.metric-events"
+
+// DynatraceMetricEvent is the value of a metric event settings object
+type DynatraceMetricEvent struct {
+	Enabled         bool   `json:"enabled"`
+	Summary         string `json:"summary"`
+	QueryDefinition struct {
+		Type           string `json:"type"` // METRIC_KEY or METRIC_SELECTOR
+		MetricKey      string `json:"metricKey"`
+		MetricSelector string `json:"metricSelector"`
+		Aggregation    string `json:"aggregation"`
+	} `json:"queryDefinition"`
+	ModelProperties struct {
+		Type              string   `json:"type"` // STATIC_THRESHOLD, AUTO_ADAPTIVE_THRESHOLD or SEASONAL_BASELINE
+		Threshold         *float64 `json:"threshold"`
+		AlertCondition    string   `json:"alertCondition"`
+		AlertOnNoData     bool     `json:"alertOnNoData"`
+		Samples           int64    `json:"samples"`
+		ViolatingSamples  int64    `json:"violatingSamples"`
+		DealertingSamples int64    `json:"dealertingSamples"`
+	} `json:"modelProperties"`
+	EventTemplate struct {
+		Title     string `json:"title"`
+		EventType string `json:"eventType"`
+	} `json:"eventTemplate"`
+}
+
+// metricEvent is a metric event as returned by the /metricEvents resource route.
+type metricEvent struct {
+	ObjectId          string   `json:"objectId"`
+	Enabled           bool     `json:"enabled"`
+	Summary           string   `json:"summary"`
+	MetricSelector    string   `json:"metricSelector"`
+	Aggregation       string   `json:"aggregation,omitempty"`
+	Model             string   `json:"model"`
+	AlertCondition    string   `json:"alertCondition"`
+	Threshold         *float64 `json:"threshold"`
+	Samples           int64    `json:"samples"`
+	ViolatingSamples  int64    `json:"violatingSamples"`
+	DealertingSamples int64    `json:"dealertingSamples"`
+	AlertOnNoData     bool     `json:"alertOnNoData"`
+	EventType         string   `json:"eventType"`
+	Title             string   `json:"title"`
+}
+
+// queryMetricEvents lists the configured metric events as a table.
+func (d *Datasource) queryMetricEvents(ctx context.Context) backend.DataResponse {
+	events, err := d.fetchMetricEvents(ctx)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace metric events: %v", err))
+	}
+
+	frame := metricEventsFrame(events)
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		ExecutedQueryString:    "Metric events",
+	}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// handleMetricEvents serves GET /metricEvents.
+func (d *Datasource) handleMetricEvents(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	events, err := d.fetchMetricEvents(req.Context())
+	if err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, events)
+}
+
+// fetchMetricEvents reads all metric event settings objects.
+func (d *Datasource) fetchMetricEvents(ctx context.Context) ([]metricEvent, error) {
+	objects, err := d.fetchSettingsObjects(ctx, metricEventsSchemaId, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]metricEvent, 0, len(objects))
+	for _, o := range objects {
+		var value DynatraceMetricEvent
+		if err := json.Unmarshal(o.Value, &value); err != nil {
+			return nil, fmt.Errorf("error decoding metric event %s: %w", o.ObjectId, err)
+		}
+
+		// Metric key definitions are shown as the equivalent selector
+		selector := value.QueryDefinition.MetricSelector
+		if value.QueryDefinition.Type == "METRIC_KEY" {
+			selector = value.QueryDefinition.MetricKey
+		}
+
+		events = append(events, metricEvent{
+			ObjectId:          o.ObjectId,
+			Enabled:           value.Enabled,
+			Summary:           value.Summary,
+			MetricSelector:    selector,
+			Aggregation:       value.QueryDefinition.Aggregation,
+			Model:             value.ModelProperties.Type,
+			AlertCondition:    value.ModelProperties.AlertCondition,
+			Threshold:         value.ModelProperties.Threshold,
+			Samples:           value.ModelProperties.Samples,
+			ViolatingSamples:  value.ModelProperties.ViolatingSamples,
+			DealertingSamples: value.ModelProperties.DealertingSamples,
+			AlertOnNoData:     value.ModelProperties.AlertOnNoData,
+			EventType:         value.EventTemplate.EventType,
+			Title:             value.EventTemplate.Title,
+		})
+	}
+	return events, nil
+}
+
+// metricEventsFrame converts metric events into a table frame with one row per event.
+func metricEventsFrame(events []metricEvent) *data.Frame {
+	frame := data.NewFrame("metricEvents",
+		data.NewField("objectId", nil, []string{}),
+		data.NewField("enabled", nil, []bool{}),
+		data.NewField("summary", nil, []string{}),
+		data.NewField("metricSelector", nil, []string{}),
+		data.NewField("aggregation", nil, []string{}),
+		data.NewField("model", nil, []string{}),
+		data.NewField("alertCondition", nil, []string{}),
+		data.NewField("threshold", nil, []*float64{}),
+		data.NewField("violatingSamples", nil, []int64{}),
+		data.NewField("samples", nil, []int64{}),
+		data.NewField("dealertingSamples", nil, []int64{}),
+		data.NewField("alertOnNoData", nil, []bool{}),
+		data.NewField("eventType", nil, []string{}),
+		data.NewField("title", nil, []string{}),
+	)
+
+	for _, e := range events {
+		frame.AppendRow(
+			e.ObjectId,
+			e.Enabled,
+			e.Summary,
+			e.MetricSelector,
+			e.Aggregation,
+			e.Model,
+			e.AlertCondition,
+			e.Threshold,
+			e.ViolatingSamples,
+			e.Samples,
+			e.DealertingSamples,
+			e.AlertOnNoData,
+			e.EventType,
+			e.Title,
+		)
+	}
+
+	return frame
+}
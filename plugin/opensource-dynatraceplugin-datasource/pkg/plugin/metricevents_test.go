@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const metricEventsResponse = `{"items": [
+	{"objectId": "obj-1", "value": {"enabled": true, "summary": "CPU high",
+		"queryDefinition": {"type": "METRIC_KEY", "metricKey": "builtin:host.cpu.usage", "aggregation": "AVG"},
+		"modelProperties": {"type": "STATIC_THRESHOLD", "threshold": 90, "alertCondition": "ABOVE", "samples": 5, "violatingSamples": 3, "dealertingSamples": 5},
+		"eventTemplate": {"title": "CPU above 90%", "eventType": "RESOURCE"}}},
+	{"objectId": "obj-2", "value": {"enabled": false, "summary": "Errors",
+		"queryDefinition": {"type": "METRIC_SELECTOR", "metricSelector": "builtin:service.errors.total.rate"},
+		"modelProperties": {"type": "AUTO_ADAPTIVE_THRESHOLD", "alertCondition": "ABOVE"},
+		"eventTemplate": {"title": "Error rate", "eventType": "ERROR"}}}
+]}`
+
+func TestQueryMetricEvents(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got := req.URL.Query().Get("schemaIds"); got != metricEventsSchemaId {
+			t.Errorf("schemaIds = %q", got)
+		}
+		_, _ = rw.Write([]byte(metricEventsResponse))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeMetricEvents,
+		JSON:      []byte(`{}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	if rows, _ := frame.RowLen(); rows != 2 {
+		t.Fatalf("expected 2 rows, got %d", rows)
+	}
+	selector, _ := frame.FieldByName("metricSelector")
+	if selector.At(0) != "builtin:host.cpu.usage" || selector.At(1) != "builtin:service.errors.total.rate" {
+		t.Errorf("unexpected selectors %v, %v", selector.At(0), selector.At(1))
+	}
+	threshold, _ := frame.FieldByName("threshold")
+	if v := threshold.At(0).(*float64); v == nil || *v != 90 {
+		t.Errorf("unexpected static threshold")
+	}
+	if threshold.At(1).(*float64) != nil {
+		t.Errorf("adaptive thresholds should have no static threshold")
+	}
+}
+
+func TestHandleMetricEvents(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(metricEventsResponse))
+	})
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metricEvents", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var events []metricEvent
+	if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Title != "CPU above 90%" || events[1].Enabled {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
@@ -0,0 +1,104 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// DynatraceNetworkZonesResponse represents the response of /api/v2/networkZones
+type DynatraceNetworkZonesResponse struct {
+	NetworkZones []DynatraceNetworkZone `json:"networkZones"`
+}
+
+type DynatraceNetworkZone struct {
+	Id                           string   `json:"id"`
+	Description                  string   `json:"description"`
+	AlternativeZones             []string `json:"alternativeZones"`
+	NumOfConfiguredActiveGates   int64    `json:"numOfConfiguredActiveGates"`
+	NumOfConfiguredOneAgents     int64    `json:"numOfConfiguredOneAgents"`
+	NumOfOneAgentsUsing          int64    `json:"numOfOneAgentsUsing"`
+	NumOfOneAgentsFromOtherZones int64    `json:"numOfOneAgentsFromOtherZones"`
+}
+
+// DynatraceNetworkZoneSettings represents the response of /api/v2/networkZoneSettings
+type DynatraceNetworkZoneSettings struct {
+	NetworkZonesEnabled bool `json:"networkZonesEnabled"`
+}
+
+// queryNetworkZones returns the configured network zones and their runtime
+// usage as a table.
+func (d *Datasource) queryNetworkZones(ctx context.Context) backend.DataResponse {
+	log.DefaultLogger.Debug("Querying Dynatrace network zones")
+
+	var settings DynatraceNetworkZoneSettings
+	if err := d.get(ctx, "/api/v2/networkZoneSettings", nil, &settings); err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace network zone settings: %v", err))
+	}
+
+	var zones DynatraceNetworkZonesResponse
+	if err := d.get(ctx, "/api/v2/networkZones", nil, &zones); err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace network zones: %v", err))
+	}
+
+	frame := networkZonesFrame(zones.NetworkZones)
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		ExecutedQueryString:    "Network zones",
+	}
+	if !settings.NetworkZonesEnabled {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     "Network zones are disabled for this environment; configured zones are not in effect.",
+		})
+	}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// networkZonesFrame converts network zones into a table frame with one row per zone.
+func networkZonesFrame(zones []DynatraceNetworkZone) *data.Frame {
+	frame := data.NewFrame("networkZones",
+		data.NewField("zone", nil, []string{}),
+		data.NewField("description", nil, []string{}),
+		data.NewField("alternativeZones", nil, []string{}),
+		data.NewField("configuredActiveGates", nil, []int64{}),
+		data.NewField("configuredOneAgents", nil, []int64{}),
+		data.NewField("oneAgentsUsing", nil, []int64{}),
+		data.NewField("oneAgentsFromOtherZones", nil, []int64{}),
+		data.NewField("state", nil, []string{}),
+	)
+
+	for _, z := range zones {
+		frame.AppendRow(
+			z.Id,
+			z.Description,
+			strings.Join(z.AlternativeZones, ", "),
+			z.NumOfConfiguredActiveGates,
+			z.NumOfConfiguredOneAgents,
+			z.NumOfOneAgentsUsing,
+			z.NumOfOneAgentsFromOtherZones,
+			networkZoneState(z),
+		)
+	}
+
+	return frame
+}
+
+// networkZoneState summarizes the runtime state of a zone: a zone without
+// ActiveGates can't serve its OneAgents, and a zone whose OneAgents are being
+// served elsewhere is running on its alternatives.
+func networkZoneState(z DynatraceNetworkZone) string {
+	switch {
+	case z.NumOfConfiguredActiveGates == 0:
+		return "NO_ACTIVEGATES"
+	case z.NumOfOneAgentsUsing < z.NumOfConfiguredOneAgents:
+		return "FAILOVER"
+	default:
+		return "OK"
+	}
+}
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryNetworkZones(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v2/networkZoneSettings":
+			_, _ = rw.Write([]byte(`{"networkZonesEnabled": true}`))
+		case "/api/v2/networkZones":
+			_, _ = rw.Write([]byte(`{"networkZones": [
+				{"id": "eu-west", "numOfConfiguredActiveGates": 2, "numOfConfiguredOneAgents": 10, "numOfOneAgentsUsing": 10},
+				{"id": "us-east", "alternativeZones": ["eu-west"], "numOfConfiguredActiveGates": 1, "numOfConfiguredOneAgents": 5, "numOfOneAgentsUsing": 3},
+				{"id": "default", "numOfConfiguredActiveGates": 0}
+			]}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeNetworkZones,
+		JSON:      []byte(`{}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	if rows, _ := frame.RowLen(); rows != 3 {
+		t.Fatalf("expected 3 rows, got %d", rows)
+	}
+
+	state, _ := frame.FieldByName("state")
+	for i, want := range []string{"OK", "FAILOVER", "NO_ACTIVEGATES"} {
+		if got := state.At(i); got != want {
+			t.Errorf("row %d state = %v, want %s", i, got, want)
+		}
+	}
+	if len(frame.Meta.Notices) != 0 {
+		t.Errorf("expected no notices when network zones are enabled")
+	}
+}
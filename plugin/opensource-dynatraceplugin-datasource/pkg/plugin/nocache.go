@@ -0,0 +1,25 @@
+package plugin
+
+import "context"
+
+type cacheBypassKey struct{}
+
+// withCacheBypass makes queries made with ctx skip the plugin's shortcuts:
+//   - GET requests are sent even when an identical request is in flight,
+//     instead of sharing its response
+//   - selectors are not validated against cached metric descriptors
+//   - synthetic locations are looked up instead of read from the cache
+//   - requests carry a Cache-Control: no-cache header, for proxies between
+//     the plugin and Dynatrace
+//
+// The plugin keeps no cache of API responses, so nothing else changes.
+// Queries opt in with cache: false to verify the very latest data.
+func withCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+// cacheBypassed reports whether ctx was made with withCacheBypass.
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return bypass
+}
@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryCacheBypass(t *testing.T) {
+	var calls int64
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		if req.Header.Get("Cache-Control") != "no-cache" {
+			t.Errorf("Cache-Control = %q, want no-cache", req.Header.Get("Cache-Control"))
+		}
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {}, "timestamps": [1000], "values": [1.5]}
+		]}]}`))
+	})
+	// The cached descriptor rejects avg; a query bypassing the cache leaves
+	// the aggregation for the API to validate
+	ds.metricDescriptors.Store("builtin:host.cpu.usage", &DynatraceMetricDescriptor{
+		MetricId:         "builtin:host.cpu.usage",
+		AggregationTypes: []string{"max"},
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		JSON:      []byte(`{"metricSelector": "builtin:host.cpu.usage:avg", "cache": false}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if atomic.LoadInt64(&calls) != 1 {
+		t.Errorf("expected the query to reach the API, got %d calls", calls)
+	}
+}
+
+func TestSyntheticLocationCacheBypass(t *testing.T) {
+	var calls int64
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		_, _ = rw.Write([]byte(`{"entityId": "SYNTHETIC_LOCATION-1", "name": "Vienna", "latitude": 48.2, "longitude": 16.4}`))
+	})
+
+	for _, ctx := range []context.Context{context.Background(), context.Background(), withCacheBypass(context.Background())} {
+		if _, err := ds.fetchSyntheticLocation(ctx, "SYNTHETIC_LOCATION-1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := atomic.LoadInt64(&calls); got != 2 {
+		t.Errorf("expected a cached lookup and a bypassing one, got %d calls", got)
+	}
+}
@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// formatNumeric is the metrics format with a single value per series, for
+// server-side expressions, recorded queries and alerting.
+const formatNumeric = "numeric"
+
+// numericResolution makes Dynatrace aggregate each series into a single data
+// point over the whole time range.
+const numericResolution = "Inf"
+
+// numericFrameTypeVersion is the version of the data plane contract the
+// numeric frames conform to.
+var numericFrameTypeVersion = data.FrameTypeVersion{0, 1}
+
+// numericFrames converts series frames into data plane numeric multi frames:
+// a frame per series holding a single labeled value field and no time field.
+// The value field is labeled with every dimension of the series and its
+// metric, given in series, so that values stay distinguishable when labelChart
+// strips the series labels or several metrics share dimensions. The last
+// non-null value of each series is used, which with the numeric resolution is
+// its only value. Only the first value field of each frame is used. Queries
+// without series return a single empty typed frame so that consumers can tell
+// no data from an error.
+func numericFrames(frames data.Frames, series []metricSeries) data.Frames {
+	var numeric data.Frames
+	for f, frame := range frames {
+		if len(frame.Fields) < 2 {
+			continue
+		}
+		timeField, valueField := frame.Fields[0], frame.Fields[1]
+		if timeField.Type() != data.FieldTypeTime || !valueField.Type().Numeric() {
+			continue
+		}
+
+		var value *float64
+		for i := valueField.Len() - 1; i >= 0 && value == nil; i-- {
+			if v, err := valueField.NullableFloatAt(i); err == nil && v != nil {
+				last := *v
+				value = &last
+			}
+		}
+
+		labels := data.Labels{}
+		for key, value := range valueField.Labels {
+			labels[key] = value
+		}
+		if f < len(series) {
+			for key, value := range series[f].dimensions {
+				labels[key] = value
+			}
+			labels["metric"] = series[f].metricId
+		}
+
+		field := data.NewField(valueField.Name, labels, []*float64{value})
+		field.Config = valueField.Config
+		numericFrame := data.NewFrame(frame.Name, field)
+		numericFrame.Meta = numericMeta(frame.Meta)
+		numeric = append(numeric, numericFrame)
+	}
+
+	if len(numeric) == 0 {
+		empty := data.NewFrame("")
+		var meta *data.FrameMeta
+		if len(frames) > 0 {
+			meta = frames[0].Meta
+		}
+		empty.Meta = numericMeta(meta)
+		return data.Frames{empty}
+	}
+	return numeric
+}
+
+// numericMeta returns the metadata of a numeric frame, keeping the executed
+// query and notices of the series frame it was made from.
+func numericMeta(source *data.FrameMeta) *data.FrameMeta {
+	meta := &data.FrameMeta{Type: data.FrameTypeNumericMulti, TypeVersion: numericFrameTypeVersion}
+	if source != nil {
+		meta.ExecutedQueryString = source.ExecutedQueryString
+		meta.Notices = source.Notices
+	}
+	return meta
+}
@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestQueryMetricsNumericFormat(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got := req.URL.Query().Get("resolution"); got != numericResolution {
+			t.Errorf("resolution = %q, want %q", got, numericResolution)
+		}
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage:splitBy(\"dt.entity.host\")", "data": [
+			{"dimensionMap": {"dt.entity.host": "HOST-1"}, "timestamps": [1000], "values": [12.5]},
+			{"dimensionMap": {"dt.entity.host": "HOST-2"}, "timestamps": [1000], "values": [80]},
+			{"dimensionMap": {"dt.entity.host": "HOST-3"}, "timestamps": [1000], "values": [null]}
+		]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		JSON:      []byte(`{"metricSelector": "builtin:host.cpu.usage:splitBy(\"dt.entity.host\")", "format": "numeric", "resolution": "1m"}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if len(resp.Frames) != 3 {
+		t.Fatalf("expected a frame per series, got %d", len(resp.Frames))
+	}
+	for i, want := range []*float64{float64Ptr(12.5), float64Ptr(80), nil} {
+		frame := resp.Frames[i]
+		if frame.Meta == nil || frame.Meta.Type != data.FrameTypeNumericMulti || frame.Meta.TypeVersion != numericFrameTypeVersion {
+			t.Errorf("frame %d meta = %+v", i, frame.Meta)
+		}
+		if len(frame.Fields) != 1 || frame.Fields[0].Len() != 1 {
+			t.Fatalf("frame %d should hold a single value field with one value, got %v", i, frame.Fields)
+		}
+		got := frame.Fields[0].At(0).(*float64)
+		if (got == nil) != (want == nil) || got != nil && *got != *want {
+			t.Errorf("frame %d value = %v, want %v", i, got, want)
+		}
+		if frame.Fields[0].Labels["dt.entity.host"] == "" {
+			t.Errorf("frame %d lost its labels: %v", i, frame.Fields[0].Labels)
+		}
+	}
+}
+
+func TestNumericFramesLastValue(t *testing.T) {
+	series := data.NewFrame("series",
+		data.NewField("time", nil, []time.Time{time.UnixMilli(1000), time.UnixMilli(2000), time.UnixMilli(3000)}),
+		data.NewField("value", data.Labels{"host": "a"}, []*float64{float64Ptr(1), float64Ptr(2), nil}),
+	)
+	frames := numericFrames(data.Frames{series}, nil)
+	if got := frames[0].Fields[0].At(0).(*float64); got == nil || *got != 2 {
+		t.Errorf("value = %v, want the last non-null value 2", got)
+	}
+
+	empty := numericFrames(nil, nil)
+	if len(empty) != 1 || len(empty[0].Fields) != 0 || empty[0].Meta.Type != data.FrameTypeNumericMulti {
+		t.Errorf("empty result = %+v", empty)
+	}
+}
+
+func TestNumericFormatValidation(t *testing.T) {
+	for _, qm := range []queryModel{
+		{Format: formatNumeric, Join: "outer"},
+		{Format: formatNumeric, Exemplars: true},
+		{Format: formatNumeric, Baseline: true},
+		{Format: formatNumeric, Anomalies: true},
+		{Format: formatNumeric, QueryText: "metricSelector=builtin:host.cpu.usage&resolution=1h"},
+	} {
+		if err := validateFieldOrder(qm); err == nil {
+			t.Errorf("expected an error for %+v", qm)
+		}
+	}
+	if err := validateFieldOrder(queryModel{Format: formatNumeric, QueryText: "metricSelector=builtin:host.cpu.usage"}); err != nil {
+		t.Errorf("advanced query without resolution: %v", err)
+	}
+}
+
+func TestQueryMetricsNumericFormatLabelChart(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"result": [
+			{"metricId": "builtin:host.cpu.usage", "data": [
+				{"dimensionMap": {"dt.entity.host": "HOST-1", "os": "linux"}, "timestamps": [1000], "values": [1]},
+				{"dimensionMap": {"dt.entity.host": "HOST-1", "os": "windows"}, "timestamps": [1000], "values": [2]}
+			]},
+			{"metricId": "builtin:host.mem.usage", "data": [
+				{"dimensionMap": {"dt.entity.host": "HOST-1", "os": "linux"}, "timestamps": [1000], "values": [3]}
+			]}
+		]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		JSON:      []byte(`{"metricSelector": "builtin:host.cpu.usage,builtin:host.mem.usage", "format": "numeric", "labelChart": "dt.entity.host"}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if len(resp.Frames) != 3 {
+		t.Fatalf("expected a frame per series, got %d", len(resp.Frames))
+	}
+
+	seen := map[string]bool{}
+	for i, frame := range resp.Frames {
+		labels := frame.Fields[0].Labels
+		if labels["dt.entity.host"] != "HOST-1" || labels["os"] == "" || labels["metric"] == "" {
+			t.Errorf("frame %d labels = %v, want every dimension and the metric", i, labels)
+		}
+		if seen[labels.String()] {
+			t.Errorf("frame %d has the same labels as another frame: %v", i, labels)
+		}
+		seen[labels.String()] = true
+	}
+}
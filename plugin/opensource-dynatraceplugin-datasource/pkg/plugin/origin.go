@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// Headers carrying the Grafana origin of a request to Dynatrace.
+const (
+	headerDashboardUid = "X-Grafana-Dashboard-Uid"
+	headerPanelId      = "X-Grafana-Panel-Id"
+	headerUser         = "X-Grafana-User"
+)
+
+// requestOrigin identifies the dashboard, panel and user a query came from.
+type requestOrigin struct {
+	dashboardUid string
+	panelId      string
+	user         string
+}
+
+type requestOriginKey struct{}
+
+// queryOrigin returns the origin of a query request. Grafana forwards the
+// dashboard UID and panel ID headers sent by panels; alert rules and Explore
+// queries have neither.
+func queryOrigin(req *backend.QueryDataRequest) requestOrigin {
+	origin := requestOrigin{
+		dashboardUid: req.GetHTTPHeader("X-Dashboard-Uid"),
+		panelId:      req.GetHTTPHeader("X-Panel-Id"),
+	}
+	if user := req.PluginContext.User; user != nil {
+		origin.user = user.Login
+	}
+	return origin
+}
+
+// withRequestOrigin makes requests made with ctx carry the origin headers.
+func withRequestOrigin(ctx context.Context, origin requestOrigin) context.Context {
+	return context.WithValue(ctx, requestOriginKey{}, origin)
+}
+
+// setOriginHeaders adds the origin headers of ctx to req when the datasource
+// forwards them, so that API usage can be traced back to a dashboard.
+func (d *Datasource) setOriginHeaders(ctx context.Context, header http.Header) {
+	if !d.forwardContextHeaders {
+		return
+	}
+	origin, _ := ctx.Value(requestOriginKey{}).(requestOrigin)
+	for name, value := range map[string]string{
+		headerDashboardUid: origin.dashboardUid,
+		headerPanelId:      origin.panelId,
+		headerUser:         origin.user,
+	} {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+}
+
+// originKey identifies the forwarded origin of ctx in the key of coalesced
+// requests, so that only requests sent with the same origin headers share a
+// response. It is empty when the datasource doesn't forward them.
+func (d *Datasource) originKey(ctx context.Context) string {
+	if !d.forwardContextHeaders {
+		return ""
+	}
+	origin, _ := ctx.Value(requestOriginKey{}).(requestOrigin)
+	return fmt.Sprintf("%q %q %q", origin.dashboardUid, origin.panelId, origin.user)
+}
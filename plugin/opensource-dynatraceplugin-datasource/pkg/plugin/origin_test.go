@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryDataForwardsOrigin(t *testing.T) {
+	for _, forward := range []bool{false, true} {
+		var got http.Header
+		ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+			got = req.Header.Clone()
+			_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+				{"dimensionMap": {}, "timestamps": [1000], "values": [1.5]}
+			]}]}`))
+		})
+		ds.forwardContextHeaders = forward
+
+		req := &backend.QueryDataRequest{
+			PluginContext: backend.PluginContext{User: &backend.User{Login: "jane"}},
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+				JSON:      []byte(`{"metricSelector": "builtin:host.cpu.usage"}`),
+			}},
+		}
+		req.SetHTTPHeader("X-Dashboard-Uid", "dash-1")
+		req.SetHTTPHeader("X-Panel-Id", "4")
+		if _, err := ds.QueryData(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]string{headerDashboardUid: "dash-1", headerPanelId: "4", headerUser: "jane"}
+		for name, value := range want {
+			if !forward {
+				value = ""
+			}
+			if got.Get(name) != value {
+				t.Errorf("forward %v: %s = %q, want %q", forward, name, got.Get(name), value)
+			}
+		}
+	}
+}
+
+func TestRequestsFromDifferentOriginsNotCoalesced(t *testing.T) {
+	var mu sync.Mutex
+	panels := map[string]bool{}
+	var calls int64
+	release := make(chan struct{})
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(&calls, 1)
+		mu.Lock()
+		panels[req.Header.Get(headerPanelId)] = true
+		mu.Unlock()
+		<-release
+		_, _ = rw.Write([]byte(`{}`))
+	})
+	ds.forwardContextHeaders = true
+
+	var wg sync.WaitGroup
+	for _, panelId := range []string{"1", "2"} {
+		ctx := withRequestOrigin(context.Background(), requestOrigin{dashboardUid: "dash-1", panelId: panelId})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var out map[string]interface{}
+			_ = ds.get(ctx, "/api/v2/problems", nil, &out)
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt64(&calls) < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if !panels["1"] || !panels["2"] {
+		t.Errorf("expected a request carrying each panel's origin, got %v", panels)
+	}
+}
@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// logPayload returns a user-entered query, selector or URL for a log line.
+// With disablePayloadLogging only its hash and length are logged, so that
+// log lines can still be correlated without revealing entity names or filter
+// values.
+func (d *Datasource) logPayload(payload string) string {
+	if !d.disablePayloadLogging || payload == "" {
+		return payload
+	}
+	return fmt.Sprintf("[redacted hash=%s length=%d]", selectorHash(payload), len(payload))
+}
+
+// logURL returns a request URL for a log line, redacting its query string,
+// which carries the selectors, when payload logging is disabled.
+func (d *Datasource) logURL(rawUrl string) string {
+	if !d.disablePayloadLogging {
+		return rawUrl
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return d.logPayload(rawUrl)
+	}
+	query := u.RawQuery
+	u.RawQuery = ""
+	if query == "" {
+		return u.String()
+	}
+	return u.String() + "?" + d.logPayload(query)
+}
+
+// logLabels returns a series' dimension labels for a log line. With payload
+// logging disabled only the label keys are logged, as the values are entity
+// names, hosts and filter values.
+func (d *Datasource) logLabels(labels map[string]string) interface{} {
+	if !d.disablePayloadLogging {
+		return labels
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// logError returns an error for a log line. Dynatrace error bodies echo the
+// offending selector, so they are treated as payload.
+func (d *Datasource) logError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return d.logPayload(err.Error())
+}
@@ -0,0 +1,108 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+func TestLogPayload(t *testing.T) {
+	ds := &Datasource{}
+	selector := `builtin:host.cpu.usage:filter(eq("dt.entity.host.name","payroll-db"))`
+	if got := ds.logPayload(selector); got != selector {
+		t.Errorf("payload logging enabled: got %q", got)
+	}
+
+	ds.disablePayloadLogging = true
+	got := ds.logPayload(selector)
+	if strings.Contains(got, "payroll") {
+		t.Errorf("payload was logged: %q", got)
+	}
+	if want := "[redacted hash=" + selectorHash(selector) + " length=69]"; got != want {
+		t.Errorf("logPayload = %q, want %q", got, want)
+	}
+	if got := ds.logPayload(""); got != "" {
+		t.Errorf("empty payloads need no redaction, got %q", got)
+	}
+}
+
+func TestLogURL(t *testing.T) {
+	ds := &Datasource{disablePayloadLogging: true}
+	got := ds.logURL("https://abc123.live.dynatrace.com/api/v2/metrics/query?metricSelector=payroll")
+	if !strings.HasPrefix(got, "https://abc123.live.dynatrace.com/api/v2/metrics/query?[redacted hash=") || strings.Contains(got, "payroll") {
+		t.Errorf("logURL = %q", got)
+	}
+	if got := ds.logURL("https://abc123.live.dynatrace.com/health"); got != "https://abc123.live.dynatrace.com/health" {
+		t.Errorf("URLs without query string are kept, got %q", got)
+	}
+}
+
+// logRecorder is a log.Logger keeping every line it is given.
+type logRecorder struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *logRecorder) record(level, msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprint(level, " ", msg, " ", args))
+}
+
+func (l *logRecorder) Debug(msg string, args ...interface{}) { l.record("debug", msg, args...) }
+func (l *logRecorder) Info(msg string, args ...interface{})  { l.record("info", msg, args...) }
+func (l *logRecorder) Warn(msg string, args ...interface{})  { l.record("warn", msg, args...) }
+func (l *logRecorder) Error(msg string, args ...interface{}) { l.record("error", msg, args...) }
+func (l *logRecorder) With(args ...interface{}) log.Logger   { return l }
+func (l *logRecorder) Level() log.Level                      { return log.Debug }
+
+func recordLogs(t *testing.T) *logRecorder {
+	t.Helper()
+	recorder := &logRecorder{}
+	previous := log.DefaultLogger
+	log.DefaultLogger = recorder
+	t.Cleanup(func() { log.DefaultLogger = previous })
+	return recorder
+}
+
+func TestQueryLogsWithoutPayload(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if strings.Contains(req.URL.Query().Get("metricSelector"), "payroll-missing") {
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"error": {"code": 400, "message": "Metric selector payroll-missing is invalid"}}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {"dt.entity.host.name": "payroll-db"}, "timestamps": [60000], "values": [42]}
+		]}]}`))
+	})
+	ds.disablePayloadLogging = true
+	logs := recordLogs(t)
+
+	ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"metricSelector": "builtin:host.cpu.usage", "labelChart": "dt.entity.host"}`),
+	})
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "B",
+		JSON:  []byte(`{"metricSelector": "payroll-missing"}`),
+	})
+	if resp.Error == nil {
+		t.Fatal("expected the invalid selector to fail")
+	}
+
+	if len(logs.lines) == 0 {
+		t.Fatal("no log lines were recorded")
+	}
+	for _, line := range logs.lines {
+		if strings.Contains(line, "payroll") {
+			t.Errorf("payload was logged: %s", line)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// openProblemsWindow is the timeframe queried for open problems. Open
+// problems overlap the present, so a short window finds all of them while
+// keeping the request cheap.
+const openProblemsWindow = 5 * time.Minute
+
+// queryProblemCount returns the number of currently open problems matching
+// the severity and management zone filters as a single value for stat
+// panels. Only the total count is requested, not the problems themselves.
+func (d *Datasource) queryProblemCount(ctx context.Context, qm queryModel) backend.DataResponse {
+	selector := openProblemsSelector(qm.ProblemSeverities, qm.ManagementZone, qm.ProblemSelector)
+
+	now := time.Now()
+	count, err := d.countProblems(ctx, selector, now.Add(-openProblemsWindow).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error counting Dynatrace problems: %v", err))
+	}
+
+	field := data.NewField("count", nil, []int64{count})
+	field.Config = &data.FieldConfig{DisplayNameFromDS: "Open problems"}
+	frame := data.NewFrame("problemCount", field)
+	frame.Meta = &data.FrameMeta{ExecutedQueryString: fmt.Sprintf("Open problems: %s", selector)}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// openProblemsSelector builds the problem selector of open problems with
+// the given severities in a management zone, narrowed by selector.
+func openProblemsSelector(severities []string, managementZone, selector string) string {
+	parts := []string{`status("open")`}
+	if len(severities) > 0 {
+		parts = append(parts, selectorPredicate("severityLevel", severities))
+	}
+	if managementZone != "" {
+		parts = append(parts, fmt.Sprintf("managementZones(%q)", managementZone))
+	}
+	if selector != "" {
+		parts = append(parts, selector)
+	}
+	return strings.Join(parts, ",")
+}
+
+// countProblems returns the number of problems matching a problem selector
+// without fetching them.
+func (d *Datasource) countProblems(ctx context.Context, problemSelector string, fromMs, toMs int64) (int64, error) {
+	params := url.Values{}
+	params.Set("problemSelector", problemSelector)
+	params.Set("pageSize", "1")
+	params.Set("from", fmt.Sprintf("%d", fromMs))
+	params.Set("to", fmt.Sprintf("%d", toMs))
+
+	var page DynatraceProblemsResponse
+	if err := d.get(ctx, "/api/v2/problems", params, &page); err != nil {
+		return 0, err
+	}
+	return int64(page.TotalCount), nil
+}
@@ -0,0 +1,38 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryProblemCount(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/problems" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+			return
+		}
+		query := req.URL.Query()
+		if got := query.Get("problemSelector"); got != `status("open"),severityLevel("AVAILABILITY","ERROR"),managementZones("prod")` {
+			t.Errorf("problemSelector = %q", got)
+		}
+		if query.Get("pageSize") != "1" {
+			t.Errorf("pageSize = %q, want only the count", query.Get("pageSize"))
+		}
+		_, _ = rw.Write([]byte(`{"totalCount": 7, "problems": [{"problemId": "P-1"}], "nextPageKey": "key"}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblemCount,
+		JSON:      []byte(`{"problemSeverities": ["AVAILABILITY", "ERROR"], "managementZone": "prod"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if got := resp.Frames[0].Fields[0].At(0); got != int64(7) {
+		t.Errorf("count = %v, want 7", got)
+	}
+}
@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Values accepted by the severityLevel, impactLevel and status problem
+// selector predicates.
+var (
+	problemSeverityLevels = []string{"AVAILABILITY", "ERROR", "PERFORMANCE", "RESOURCE_CONTENTION", "CUSTOM_ALERT", "MONITORING_UNAVAILABLE", "INFO"}
+	problemImpactLevels   = []string{"APPLICATION", "SERVICES", "INFRASTRUCTURE", "ENVIRONMENT"}
+	problemStatuses       = []string{"open", "closed"}
+)
+
+// problemFilterSelector combines the structured problem filters of a query
+// with its free-form problem selector. The free-form selector comes last so
+// that it can narrow the filters further.
+func problemFilterSelector(qm queryModel) (string, error) {
+	var parts []string
+	if len(qm.ProblemSeverities) > 0 {
+		if err := validateProblemFilter("problemSeverities", qm.ProblemSeverities, problemSeverityLevels); err != nil {
+			return "", err
+		}
+		parts = append(parts, selectorPredicate("severityLevel", qm.ProblemSeverities))
+	}
+	if len(qm.ProblemImpacts) > 0 {
+		if err := validateProblemFilter("problemImpacts", qm.ProblemImpacts, problemImpactLevels); err != nil {
+			return "", err
+		}
+		parts = append(parts, selectorPredicate("impactLevel", qm.ProblemImpacts))
+	}
+	if qm.ProblemStatus != "" {
+		if err := validateProblemFilter("problemStatus", []string{qm.ProblemStatus}, problemStatuses); err != nil {
+			return "", err
+		}
+		parts = append(parts, selectorPredicate("status", []string{qm.ProblemStatus}))
+	}
+	if qm.MzSelector != "" {
+		predicate, err := mzProblemPredicate(qm.MzSelector)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, predicate)
+	}
+	if qm.ProblemSelector != "" {
+		parts = append(parts, qm.ProblemSelector)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// mzSelectorPattern matches the mzId(...) and mzName(...) forms of a
+// management zone selector.
+var mzSelectorPattern = regexp.MustCompile(`^mz(Id|Name)\((.+)\)$`)
+
+// mzProblemPredicate translates a management zone selector into the
+// equivalent problem selector predicate. The problems API has no mzSelector
+// parameter, but filters by zone ID or name in the problem selector.
+func mzProblemPredicate(mzSelector string) (string, error) {
+	m := mzSelectorPattern.FindStringSubmatch(strings.TrimSpace(mzSelector))
+	if m == nil {
+		return "", fmt.Errorf("invalid mzSelector %q, expected mzId(...) or mzName(...)", mzSelector)
+	}
+	value := strings.Trim(m[2], `"`)
+	if m[1] == "Id" {
+		return selectorPredicate("managementZoneIds", []string{value}), nil
+	}
+	return selectorPredicate("managementZones", []string{value}), nil
+}
+
+// validateProblemFilter checks that every value of a filter is one the
+// problems API knows.
+func validateProblemFilter(name string, values, allowed []string) error {
+	known := make(map[string]bool, len(allowed))
+	for _, value := range allowed {
+		known[value] = true
+	}
+	for _, value := range values {
+		if !known[value] {
+			return fmt.Errorf("invalid %s value %q, expected one of %s", name, value, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// selectorPredicate formats a selector predicate matching any of values,
+// e.g. severityLevel("ERROR","AVAILABILITY").
+func selectorPredicate(name string, values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = fmt.Sprintf("%q", value)
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(quoted, ","))
+}
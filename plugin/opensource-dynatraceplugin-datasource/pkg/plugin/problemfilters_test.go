@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestProblemFilterSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		qm      queryModel
+		want    string
+		wantErr bool
+	}{
+		{name: "none", qm: queryModel{}, want: ""},
+		{name: "selector only", qm: queryModel{ProblemSelector: `text("disk")`}, want: `text("disk")`},
+		{
+			name: "all filters",
+			qm: queryModel{
+				ProblemSeverities: []string{"AVAILABILITY", "ERROR"},
+				ProblemImpacts:    []string{"SERVICES"},
+				ProblemStatus:     "closed",
+				ProblemSelector:   `text("disk")`,
+			},
+			want: `severityLevel("AVAILABILITY","ERROR"),impactLevel("SERVICES"),status("closed"),text("disk")`,
+		},
+		{name: "management zone name", qm: queryModel{MzSelector: `mzName("prod")`}, want: `managementZones("prod")`},
+		{name: "management zone id", qm: queryModel{MzSelector: "mzId(123)"}, want: `managementZoneIds("123")`},
+		{name: "invalid management zone", qm: queryModel{MzSelector: `prod`}, wantErr: true},
+		{name: "unknown severity", qm: queryModel{ProblemSeverities: []string{"error"}}, wantErr: true},
+		{name: "unknown impact", qm: queryModel{ProblemImpacts: []string{"HOSTS"}}, wantErr: true},
+		{name: "unknown status", qm: queryModel{ProblemStatus: "OPEN"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := problemFilterSelector(tt.qm)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("selector = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryProblemsWithFilters(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got, want := req.URL.Query().Get("problemSelector"), `impactLevel("INFRASTRUCTURE"),status("open")`; got != want {
+			t.Errorf("problemSelector = %q, want %q", got, want)
+		}
+		_, _ = rw.Write([]byte(`{"totalCount": 1, "problems": [{"problemId": "P-1", "displayId": "P-1", "title": "Disk full"}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblems,
+		JSON:      []byte(`{"useDashboardTime": true, "problemImpacts": ["INFRASTRUCTURE"], "problemStatus": "open"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if got := resp.Frames[0].Rows(); got != 1 {
+		t.Errorf("rows = %d, want 1", got)
+	}
+}
+
+func TestQueryProblemsScopedToEntities(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		if got, want := query.Get("entitySelector"), `type(SERVICE),tag("checkout")`; got != want {
+			t.Errorf("entitySelector = %q, want %q", got, want)
+		}
+		if got, want := query.Get("problemSelector"), `managementZones("prod")`; got != want {
+			t.Errorf("problemSelector = %q, want %q", got, want)
+		}
+		_, _ = rw.Write([]byte(`{"totalCount": 0, "problems": []}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblems,
+		JSON:      []byte(`{"useDashboardTime": true, "entitySelector": "type(SERVICE),tag(\"checkout\")", "mzSelector": "mzName(\"prod\")"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if got, want := resp.Frames[0].Meta.ExecutedQueryString, `Problems: managementZones("prod"), entities: type(SERVICE),tag("checkout")`; got != want {
+		t.Errorf("executed query = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,362 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// problemDetailFields are the optional fields requested when fetching a single
+// problem, so that the detail view can show evidence and impact.
+const problemDetailFields = "evidenceDetails,impactAnalysis"
+
+// DynatraceProblemsResponse represents a page of the Problems V2 API list endpoint
+type DynatraceProblemsResponse struct {
+	TotalCount  int                `json:"totalCount"`
+	PageSize    int                `json:"pageSize"`
+	NextPageKey *string            `json:"nextPageKey"`
+	Problems    []DynatraceProblem `json:"problems"`
+}
+
+type DynatraceProblem struct {
+	ProblemId        string                    `json:"problemId"`
+	DisplayId        string                    `json:"displayId"`
+	Title            string                    `json:"title"`
+	ImpactLevel      string                    `json:"impactLevel"`
+	SeverityLevel    string                    `json:"severityLevel"`
+	Status           string                    `json:"status"`
+	StartTime        int64                     `json:"startTime"`
+	EndTime          int64                     `json:"endTime"`
+	RootCauseEntity  *DynatraceEntityStub      `json:"rootCauseEntity"`
+	AffectedEntities []DynatraceEntityStub     `json:"affectedEntities"`
+	ImpactedEntities []DynatraceEntityStub     `json:"impactedEntities"`
+	ManagementZones  []DynatraceManagementZone `json:"managementZones"`
+	EntityTags       []DynatraceTag            `json:"entityTags"`
+	EvidenceDetails  *DynatraceEvidenceDetails `json:"evidenceDetails"`
+	ImpactAnalysis   *DynatraceImpactAnalysis  `json:"impactAnalysis"`
+}
+
+type DynatraceEntityStub struct {
+	EntityId DynatraceEntityId `json:"entityId"`
+	Name     string            `json:"name"`
+}
+
+type DynatraceEntityId struct {
+	Id   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type DynatraceManagementZone struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type DynatraceEvidenceDetails struct {
+	TotalCount int                 `json:"totalCount"`
+	Details    []DynatraceEvidence `json:"details"`
+}
+
+type DynatraceEvidence struct {
+	EvidenceType      string              `json:"evidenceType"`
+	DisplayName       string              `json:"displayName"`
+	Entity            DynatraceEntityStub `json:"entity"`
+	RootCauseRelevant bool                `json:"rootCauseRelevant"`
+	StartTime         int64               `json:"startTime"`
+}
+
+type DynatraceImpactAnalysis struct {
+	Impacts []DynatraceImpact `json:"impacts"`
+}
+
+type DynatraceImpact struct {
+	ImpactType             string              `json:"impactType"`
+	ImpactedEntity         DynatraceEntityStub `json:"impactedEntity"`
+	EstimatedAffectedUsers int64               `json:"estimatedAffectedUsers"`
+}
+
+// queryProblems lists the problems overlapping the query time range as a table.
+func (d *Datasource) queryProblems(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	paging, err := newTablePaging(qm)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	problemSelector, err := problemFilterSelector(qm)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+	var profile *DynatraceAlertingProfile
+	if qm.AlertingProfile != "" {
+		profile, err = d.fetchAlertingProfile(ctx, qm.AlertingProfile)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace alerting profile: %v", err))
+		}
+		problemSelector = profile.problemSelector(problemSelector)
+	}
+
+	// Profiles are matched after fetching, so only a page of the matching
+	// problems can be cut out of the full result
+	fetchPaging := paging
+	if profile != nil {
+		fetchPaging = tablePaging{sort: paging.sort}
+	}
+	problems, err := d.fetchProblemsPaged(ctx, problemSelector, qm.EntitySelector, fromMs, toMs, fetchPaging)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace problems: %v", err))
+	}
+
+	if profile != nil {
+		now := time.Now()
+		matching := problems[:0]
+		for _, p := range problems {
+			if profile.matches(p, now) {
+				matching = append(matching, p)
+			}
+		}
+		problems = matching
+	}
+	start, end := paging.bounds(len(problems))
+	problems = problems[start:end]
+
+	frame := problemsFrame(problems)
+	addRelatedMetricsLink(frame, d.settings.UID, "rootCauseEntityId", "rootCause")
+	addFieldLinks(frame, "displayId", d.problemLink("${__data.fields.problemId}", fromMs, toMs))
+	addFieldLinks(frame, "title", d.problemLink("${__data.fields.problemId}", fromMs, toMs))
+	addFieldLinks(frame, "rootCauseEntityId", d.entityLink(valuePlaceholder, fromMs, toMs))
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		ExecutedQueryString:    problemsQueryString(problemSelector, qm.EntitySelector),
+	}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// queryProblemDetails fetches a single problem by ID and returns its summary,
+// evidence and impacted entities as separate table frames.
+func (d *Datasource) queryProblemDetails(ctx context.Context, qm queryModel) backend.DataResponse {
+	if qm.ProblemId == "" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "problemId is required")
+	}
+
+	problem, err := d.fetchProblem(ctx, qm.ProblemId)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace problem: %v", err))
+	}
+
+	frames := problemDetailFrames(problem)
+	addRelatedMetricsLink(frames[0], d.settings.UID, "rootCauseEntityId", "rootCause")
+	addRelatedMetricsLink(frames[1], d.settings.UID, "entityId", "entity")
+	addRelatedMetricsLink(frames[2], d.settings.UID, "entityId", "name")
+
+	// Open the problem and its entities over the lifetime of the problem
+	fromMs, toMs := problem.StartTime, problem.EndTime
+	if toMs <= 0 {
+		toMs = time.Now().UnixMilli()
+	}
+	addFieldLinks(frames[0], "displayId", d.problemLink(problem.ProblemId, fromMs, toMs))
+	addFieldLinks(frames[0], "rootCauseEntityId", d.entityLink(valuePlaceholder, fromMs, toMs))
+	addFieldLinks(frames[1], "entityId", d.entityLink(valuePlaceholder, fromMs, toMs))
+	addFieldLinks(frames[2], "entityId", d.entityLink(valuePlaceholder, fromMs, toMs))
+
+	return backend.DataResponse{Frames: frames}
+}
+
+// fetchProblems walks all pages of /api/v2/problems for the given time range.
+func (d *Datasource) fetchProblems(ctx context.Context, problemSelector string, fromMs, toMs int64) ([]DynatraceProblem, error) {
+	return d.fetchProblemsPaged(ctx, problemSelector, "", fromMs, toMs, tablePaging{})
+}
+
+// fetchProblemsPaged fetches problems in the sort order of paging, stopping
+// once the pages covering the requested rows were fetched. A non-empty
+// entitySelector keeps only problems affecting the selected entities.
+func (d *Datasource) fetchProblemsPaged(ctx context.Context, problemSelector, entitySelector string, fromMs, toMs int64, paging tablePaging) ([]DynatraceProblem, error) {
+	params := url.Values{}
+	params.Set("from", fmt.Sprintf("%d", fromMs))
+	params.Set("to", fmt.Sprintf("%d", toMs))
+	params.Set("pageSize", "500")
+	if problemSelector != "" {
+		params.Set("problemSelector", problemSelector)
+	}
+	if entitySelector != "" {
+		params.Set("entitySelector", entitySelector)
+	}
+	ctx = paging.apply(ctx, params, 500)
+
+	log.DefaultLogger.Debug("Querying Dynatrace problems", "problemSelector", d.logPayload(problemSelector), "entitySelector", d.logPayload(entitySelector), "from", fromMs, "to", toMs)
+
+	var problems []DynatraceProblem
+	err := d.getAllPages(ctx, "/api/v2/problems", params, func(body []byte) error {
+		var page DynatraceProblemsResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		problems = append(problems, page.Problems...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// fetchProblem returns a single problem including evidence and impact analysis.
+func (d *Datasource) fetchProblem(ctx context.Context, problemId string) (*DynatraceProblem, error) {
+	params := url.Values{}
+	params.Set("fields", problemDetailFields)
+
+	var problem DynatraceProblem
+	if err := d.get(ctx, "/api/v2/problems/"+url.PathEscape(problemId), params, &problem); err != nil {
+		return nil, err
+	}
+	return &problem, nil
+}
+
+// problemsFrame converts a list of problems into a table frame with one row per problem.
+func problemsFrame(problems []DynatraceProblem) *data.Frame {
+	frame := data.NewFrame("problems",
+		data.NewField("problemId", nil, []string{}),
+		data.NewField("displayId", nil, []string{}),
+		data.NewField("title", nil, []string{}),
+		data.NewField("status", nil, []string{}),
+		data.NewField("severityLevel", nil, []string{}),
+		data.NewField("impactLevel", nil, []string{}),
+		data.NewField("rootCause", nil, []string{}),
+		data.NewField("rootCauseEntityId", nil, []string{}),
+		data.NewField("affectedEntities", nil, []string{}),
+		data.NewField("managementZones", nil, []string{}),
+		data.NewField("startTime", nil, []time.Time{}),
+		data.NewField("endTime", nil, []*time.Time{}),
+	)
+
+	for _, p := range problems {
+		rootCause, rootCauseId := "", ""
+		if p.RootCauseEntity != nil {
+			rootCause = p.RootCauseEntity.Name
+			rootCauseId = p.RootCauseEntity.EntityId.Id
+		}
+
+		zones := make([]string, 0, len(p.ManagementZones))
+		for _, mz := range p.ManagementZones {
+			zones = append(zones, mz.Name)
+		}
+
+		frame.AppendRow(
+			p.ProblemId,
+			p.DisplayId,
+			p.Title,
+			p.Status,
+			p.SeverityLevel,
+			p.ImpactLevel,
+			rootCause,
+			rootCauseId,
+			entityNames(p.AffectedEntities),
+			strings.Join(zones, ", "),
+			time.UnixMilli(p.StartTime),
+			problemEndTime(p.EndTime),
+		)
+	}
+
+	return frame
+}
+
+// problemDetailFrames builds the drill-down frames for a single problem.
+func problemDetailFrames(p *DynatraceProblem) data.Frames {
+	rootCause, rootCauseId := "", ""
+	if p.RootCauseEntity != nil {
+		rootCause = p.RootCauseEntity.Name
+		rootCauseId = p.RootCauseEntity.EntityId.Id
+	}
+
+	summary := data.NewFrame("problem",
+		data.NewField("problemId", nil, []string{p.ProblemId}),
+		data.NewField("displayId", nil, []string{p.DisplayId}),
+		data.NewField("title", nil, []string{p.Title}),
+		data.NewField("status", nil, []string{p.Status}),
+		data.NewField("severityLevel", nil, []string{p.SeverityLevel}),
+		data.NewField("impactLevel", nil, []string{p.ImpactLevel}),
+		data.NewField("rootCause", nil, []string{rootCause}),
+		data.NewField("rootCauseEntityId", nil, []string{rootCauseId}),
+		data.NewField("startTime", nil, []time.Time{time.UnixMilli(p.StartTime)}),
+		data.NewField("endTime", nil, []*time.Time{problemEndTime(p.EndTime)}),
+	)
+
+	evidence := data.NewFrame("evidence",
+		data.NewField("evidenceType", nil, []string{}),
+		data.NewField("displayName", nil, []string{}),
+		data.NewField("entity", nil, []string{}),
+		data.NewField("entityId", nil, []string{}),
+		data.NewField("rootCauseRelevant", nil, []bool{}),
+		data.NewField("startTime", nil, []time.Time{}),
+	)
+	if p.EvidenceDetails != nil {
+		for _, e := range p.EvidenceDetails.Details {
+			evidence.AppendRow(e.EvidenceType, e.DisplayName, e.Entity.Name, e.Entity.EntityId.Id, e.RootCauseRelevant, time.UnixMilli(e.StartTime))
+		}
+	}
+
+	impacted := data.NewFrame("impactedEntities",
+		data.NewField("entityId", nil, []string{}),
+		data.NewField("entityType", nil, []string{}),
+		data.NewField("name", nil, []string{}),
+		data.NewField("impactType", nil, []string{}),
+		data.NewField("estimatedAffectedUsers", nil, []int64{}),
+	)
+	impactTypes := map[string]DynatraceImpact{}
+	if p.ImpactAnalysis != nil {
+		for _, impact := range p.ImpactAnalysis.Impacts {
+			impactTypes[impact.ImpactedEntity.EntityId.Id] = impact
+		}
+	}
+	for _, e := range p.ImpactedEntities {
+		impact := impactTypes[e.EntityId.Id]
+		impacted.AppendRow(e.EntityId.Id, e.EntityId.Type, e.Name, impact.ImpactType, impact.EstimatedAffectedUsers)
+	}
+
+	for _, frame := range []*data.Frame{summary, evidence, impacted} {
+		frame.Meta = &data.FrameMeta{
+			PreferredVisualization: data.VisTypeTable,
+			ExecutedQueryString:    fmt.Sprintf("Problem: %s", p.ProblemId),
+		}
+	}
+
+	return data.Frames{summary, evidence, impacted}
+}
+
+// problemEndTime converts the API end time to a nullable time. Open problems
+// report an end time of -1.
+func problemEndTime(endTime int64) *time.Time {
+	if endTime <= 0 {
+		return nil
+	}
+	t := time.UnixMilli(endTime)
+	return &t
+}
+
+// entityNames joins the display names of the given entities.
+func entityNames(entities []DynatraceEntityStub) string {
+	names := make([]string, 0, len(entities))
+	for _, e := range entities {
+		names = append(names, e.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// problemsQueryString describes the selectors of a problems query.
+func problemsQueryString(problemSelector, entitySelector string) string {
+	if entitySelector == "" {
+		return fmt.Sprintf("Problems: %s", problemSelector)
+	}
+	return fmt.Sprintf("Problems: %s, entities: %s", problemSelector, entitySelector)
+}
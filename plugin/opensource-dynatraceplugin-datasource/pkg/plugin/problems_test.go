@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryProblemDetails(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/problems/P-123" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		if got := req.URL.Query().Get("fields"); got != problemDetailFields {
+			t.Errorf("fields = %q, want %q", got, problemDetailFields)
+		}
+		if got := req.Header.Get("Authorization"); got != "Api-Token test-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		_, _ = rw.Write([]byte(`{
+			"problemId": "P-123", "displayId": "P-1", "title": "High CPU", "status": "OPEN",
+			"startTime": 1700000000000, "endTime": -1,
+			"rootCauseEntity": {"entityId": {"id": "HOST-1", "type": "HOST"}, "name": "host-a"},
+			"impactedEntities": [{"entityId": {"id": "SERVICE-1", "type": "SERVICE"}, "name": "checkout"}],
+			"evidenceDetails": {"totalCount": 1, "details": [
+				{"evidenceType": "EVENT", "displayName": "CPU saturation", "entity": {"entityId": {"id": "HOST-1", "type": "HOST"}, "name": "host-a"}, "rootCauseRelevant": true, "startTime": 1700000000000}
+			]},
+			"impactAnalysis": {"impacts": [
+				{"impactType": "SERVICE", "impactedEntity": {"entityId": {"id": "SERVICE-1", "type": "SERVICE"}, "name": "checkout"}, "estimatedAffectedUsers": 42}
+			]}
+		}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblem,
+		JSON:      []byte(`{"problemId": "P-123"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(resp.Frames))
+	}
+
+	summary := resp.Frames[0]
+	if got := summary.Fields[6].At(0); got != "host-a" {
+		t.Errorf("rootCause = %v, want host-a", got)
+	}
+	if got := summary.Fields[9].At(0).(*time.Time); got != nil {
+		t.Errorf("open problem should have no end time, got %v", got)
+	}
+
+	if rows, _ := resp.Frames[1].RowLen(); rows != 1 {
+		t.Errorf("expected 1 evidence row, got %d", rows)
+	}
+
+	impacted := resp.Frames[2]
+	if got := impacted.Fields[4].At(0); got != int64(42) {
+		t.Errorf("estimatedAffectedUsers = %v, want 42", got)
+	}
+}
+
+func TestQueryProblemDetailsRequiresId(t *testing.T) {
+	ds := Datasource{}
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblem,
+		JSON:      []byte(`{}`),
+	})
+	if resp.Error == nil {
+		t.Fatal("expected an error when problemId is missing")
+	}
+}
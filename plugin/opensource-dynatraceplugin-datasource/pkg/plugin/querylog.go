@@ -0,0 +1,148 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// queryStats counts the Dynatrace API calls made for a single query.
+type queryStats struct {
+	apiCalls int64
+}
+
+type queryStatsKey struct{}
+
+// withQueryStats returns a context counting the API calls of a query.
+func withQueryStats(ctx context.Context) (context.Context, *queryStats) {
+	stats := &queryStats{}
+	return context.WithValue(ctx, queryStatsKey{}, stats), stats
+}
+
+// countAPICall records an API call for the query executed with ctx, if any.
+func countAPICall(ctx context.Context) {
+	if stats, ok := ctx.Value(queryStatsKey{}).(*queryStats); ok {
+		atomic.AddInt64(&stats.apiCalls, 1)
+	}
+}
+
+// calls returns the number of API calls made so far.
+func (s *queryStats) calls() int64 {
+	if s == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&s.apiCalls)
+}
+
+// defaultSlowQueryThreshold is used when the datasource doesn't configure
+// slowQueryThresholdSeconds.
+const defaultSlowQueryThreshold = 5 * time.Second
+
+// slowQueryLimit returns the duration above which queries are logged in detail.
+func (d *Datasource) slowQueryLimit() time.Duration {
+	if d.slowQueryThreshold > 0 {
+		return d.slowQueryThreshold
+	}
+	return defaultSlowQueryThreshold
+}
+
+// logQuery writes the summary line of an executed query. The selector is
+// logged as a hash so that log lines can be grouped by query without
+// leaking entity names or filter values. Slow queries are logged at warning
+// level with the sanitized query so that operators can find them, unless
+// payload logging is disabled.
+func (d *Datasource) logQuery(query backend.DataQuery, qm queryModel, resp backend.DataResponse, stats *queryStats, elapsed time.Duration) {
+	status := resp.Status
+	if status == 0 {
+		status = backend.StatusOK
+	}
+
+	queryType := query.QueryType
+	if queryType == "" {
+		queryType = queryTypeMetrics
+	}
+
+	args := []interface{}{
+		"refId", query.RefID,
+		"queryType", queryType,
+		"selectorHash", selectorHash(qm.selector()),
+		"status", int(status),
+		"dataPoints", dataPoints(resp.Frames),
+		"apiCalls", stats.calls(),
+		"duration", elapsed.Milliseconds(),
+	}
+	if resp.Error != nil {
+		args = append(args, "error", d.logError(resp.Error))
+	}
+
+	if elapsed > d.slowQueryLimit() {
+		args = append(args,
+			"threshold", d.slowQueryLimit().Milliseconds(),
+			"from", query.TimeRange.From.UnixMilli(),
+			"to", query.TimeRange.To.UnixMilli(),
+			"query", d.logPayload(sanitizeQueryJSON(query.JSON)),
+		)
+		log.DefaultLogger.Warn("Slow query executed", args...)
+		return
+	}
+	log.DefaultLogger.Info("Query executed", args...)
+}
+
+// tokenPattern matches Dynatrace API and platform tokens.
+var tokenPattern = regexp.MustCompile(`dt0[a-z][0-9]{2}\.[A-Za-z0-9._-]+`)
+
+// sanitizeQueryJSON returns the query JSON without Grafana's datasource
+// reference and with anything looking like a Dynatrace token masked.
+func sanitizeQueryJSON(raw json.RawMessage) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return tokenPattern.ReplaceAllString(string(raw), "***")
+	}
+	delete(fields, "datasource")
+
+	sanitized, err := json.Marshal(fields)
+	if err != nil {
+		return ""
+	}
+	return tokenPattern.ReplaceAllString(string(sanitized), "***")
+}
+
+// selector returns the main selector of a query, whatever its query type.
+func (qm queryModel) selector() string {
+	for _, s := range []string{qm.MetricSelector, qm.MetricId, qm.ProblemSelector, qm.ProblemId, qm.LogQuery, qm.QueryText, qm.Expression, qm.DQLQuery, qm.USQLQuery, qm.SloId, qm.SettingsSchemaId, qm.EntitySelector} {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// selectorHash returns a short stable hash of a selector, or "" if empty.
+func selectorHash(selector string) string {
+	if selector == "" {
+		return ""
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(selector))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// dataPoints returns the number of rows across frames.
+func dataPoints(frames data.Frames) int {
+	total := 0
+	for _, frame := range frames {
+		rows, err := frame.RowLen()
+		if err == nil {
+			total += rows
+		}
+	}
+	return total
+}
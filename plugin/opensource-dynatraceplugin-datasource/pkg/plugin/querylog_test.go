@@ -0,0 +1,45 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestQueryStatsCountsAPICalls(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"problems": []}`))
+	})
+
+	ctx, stats := withQueryStats(context.Background())
+	if _, err := ds.fetchProblems(ctx, "", 1000, 2000); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ds.fetchProblems(ctx, "", 1000, 2000); err != nil {
+		t.Fatal(err)
+	}
+	if got := stats.calls(); got != 2 {
+		t.Errorf("apiCalls = %d, want 2", got)
+	}
+}
+
+func TestSelectorHash(t *testing.T) {
+	if selectorHash("") != "" {
+		t.Error("empty selectors should have no hash")
+	}
+	a := selectorHash(`builtin:host.cpu.usage:filter(eq("dt.entity.host","HOST-1"))`)
+	if a != selectorHash(`builtin:host.cpu.usage:filter(eq("dt.entity.host","HOST-1"))`) {
+		t.Error("hash should be stable")
+	}
+	if a == selectorHash("builtin:host.cpu.usage") || len(a) != 16 {
+		t.Errorf("unexpected hash %q", a)
+	}
+}
+
+func TestSanitizeQueryJSON(t *testing.T) {
+	got := sanitizeQueryJSON([]byte(`{"datasource": {"uid": "abc"}, "metricSelector": "builtin:host.cpu.usage", "queryText": "token=dt0c01.ABCDEF.GHIJKL"}`))
+	want := `{"metricSelector":"builtin:host.cpu.usage","queryText":"token=***"}`
+	if got != want {
+		t.Errorf("sanitizeQueryJSON = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Retry parameters of rate-limited (429) requests.
+const (
+	// rateLimitRetries is how often a rate-limited request is retried
+	rateLimitRetries = 2
+
+	// rateLimitMaxWait is the longest Retry-After a request waits for;
+	// longer waits fail the request right away
+	rateLimitMaxWait = 10 * time.Second
+
+	// rateLimitDefaultWait is waited when a 429 response has no Retry-After
+	rateLimitDefaultWait = time.Second
+)
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP
+// date, reporting false if the header is missing or invalid.
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait.Round(time.Second), true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// retryRateLimited waits before the next attempt of a rate-limited request,
+// reporting false when retries are exhausted, the requested wait is too long
+// or ctx is done.
+func (d *Datasource) retryRateLimited(ctx context.Context, apiErr *apiError, attempt int) bool {
+	if attempt >= rateLimitRetries {
+		return false
+	}
+	wait := rateLimitDefaultWait
+	if apiErr.hasRetryAfter {
+		wait = apiErr.retryAfter
+	}
+	if wait > rateLimitMaxWait {
+		return false
+	}
+
+	log.DefaultLogger.Debug("Retrying rate-limited request", "attempt", attempt+1, "wait", wait)
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// rateLimitMessage describes a 429 response including when to retry.
+func (e *apiError) rateLimitMessage() string {
+	if !e.hasRetryAfter {
+		return "Dynatrace API rate limit exceeded (status 429); retry shortly"
+	}
+	return fmt.Sprintf("Dynatrace API rate limit exceeded (status 429); retry after %s", e.retryAfter)
+}
+
+// addRateLimitNotice tells the user that a failed query was rate limited,
+// which is temporary, and when to expect recovery.
+func addRateLimitNotice(ctx context.Context, apiErr *apiError) {
+	text := "Dynatrace is rate limiting requests; this is temporary, data should be available again shortly"
+	if apiErr.hasRetryAfter {
+		text = fmt.Sprintf("Dynatrace is rate limiting requests; this is temporary, data should be available again in about %s", apiErr.retryAfter)
+	}
+	addQueryNotice(ctx, data.Notice{Severity: data.NoticeSeverityWarning, Text: text})
+}
@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"30", 30 * time.Second, true},
+		{"-1", 0, false},
+		{"Fri, 01 Mar 2024 12:01:00 GMT", time.Minute, true},
+		{"Fri, 01 Mar 2024 11:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.header, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRateLimitedRequestRetried(t *testing.T) {
+	calls := 0
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		if calls == 1 {
+			rw.Header().Set("Retry-After", "0")
+			rw.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {}, "timestamps": [1000], "values": [1.5]}
+		]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		JSON:      []byte(`{"metricSelector": "builtin:host.cpu.usage"}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if calls != 2 {
+		t.Errorf("expected one retry, got %d calls", calls)
+	}
+}
+
+func TestRateLimitedQuerySurfacesRetryAfter(t *testing.T) {
+	calls := 0
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		rw.Header().Set("Retry-After", "120")
+		rw.WriteHeader(http.StatusTooManyRequests)
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		JSON:      []byte(`{"metricSelector": "builtin:host.cpu.usage"}`),
+	})
+	if calls != 1 {
+		t.Errorf("waits longer than %s should not be retried, got %d calls", rateLimitMaxWait, calls)
+	}
+	if resp.Error == nil || !strings.Contains(resp.Error.Error(), "retry after 2m0s") {
+		t.Errorf("error = %v, want the Retry-After duration", resp.Error)
+	}
+	if len(resp.Frames) != 1 || resp.Frames[0].Meta == nil || len(resp.Frames[0].Meta.Notices) != 1 {
+		t.Fatalf("expected a rate limit notice, got %+v", resp.Frames)
+	}
+	if text := resp.Frames[0].Meta.Notices[0].Text; !strings.Contains(text, "temporary") || !strings.Contains(text, "2m0s") {
+		t.Errorf("notice = %q", text)
+	}
+}
@@ -0,0 +1,157 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// defaultRedactionReplacement replaces matches of rules without a replacement.
+const defaultRedactionReplacement = "[REDACTED]"
+
+// redactionRule replaces matches of a pattern in returned data, e.g. e-mail
+// addresses in log bodies or user names in dimension values.
+type redactionRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// parseRedactionRules parses the redactionRules setting, a list of objects
+// with a pattern and an optional replacement.
+func parseRedactionRules(raw interface{}) ([]redactionRule, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("redactionRules must be a list of rules")
+	}
+
+	var rules []redactionRule
+	for i, item := range list {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("redaction rule %d must be an object with a pattern", i+1)
+		}
+		pattern, _ := fields["pattern"].(string)
+		if pattern == "" {
+			return nil, fmt.Errorf("redaction rule %d has no pattern", i+1)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of redaction rule %d: %w", i+1, err)
+		}
+		replacement := defaultRedactionReplacement
+		if r, ok := fields["replacement"].(string); ok {
+			replacement = r
+		}
+		rules = append(rules, redactionRule{pattern: re, replacement: replacement})
+	}
+	return rules, nil
+}
+
+// redact applies the redaction rules to a string.
+func (d *Datasource) redact(s string) string {
+	for _, rule := range d.redactionRules {
+		s = rule.pattern.ReplaceAllString(s, rule.replacement)
+	}
+	return s
+}
+
+// redactFrames applies the redaction rules to every string returned in
+// frames: frame and display names, labels and the values of string and JSON
+// fields, which hold log bodies, dimension values and USQL or DQL results.
+func (d *Datasource) redactFrames(frames data.Frames) {
+	if len(d.redactionRules) == 0 {
+		return
+	}
+	for _, frame := range frames {
+		frame.Name = d.redact(frame.Name)
+		for _, field := range frame.Fields {
+			for key, value := range field.Labels {
+				field.Labels[key] = d.redact(value)
+			}
+			if field.Config != nil {
+				field.Config.DisplayNameFromDS = d.redact(field.Config.DisplayNameFromDS)
+			}
+			d.redactField(field)
+		}
+	}
+}
+
+// redactField redacts the values of string and JSON fields.
+func (d *Datasource) redactField(field *data.Field) {
+	for i := 0; i < field.Len(); i++ {
+		switch v := field.At(i).(type) {
+		case string:
+			field.Set(i, d.redact(v))
+		case *string:
+			if v != nil {
+				redacted := d.redact(*v)
+				field.Set(i, &redacted)
+			}
+		case json.RawMessage:
+			field.Set(i, d.redactJSON(v))
+		case *json.RawMessage:
+			if v != nil {
+				redacted := d.redactJSON(*v)
+				field.Set(i, &redacted)
+			}
+		}
+	}
+}
+
+// redactJSON redacts the string values of a JSON document, leaving its
+// structure and numbers intact.
+func (d *Datasource) redactJSON(raw json.RawMessage) json.RawMessage {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// Keep large integers such as IDs and timestamps exact
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return raw
+	}
+	redacted, err := json.Marshal(d.redactValue(value))
+	if err != nil {
+		return raw
+	}
+	return redacted
+}
+
+// redactValue redacts the strings of a decoded JSON value.
+func (d *Datasource) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return d.redact(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = d.redactValue(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = d.redactValue(v[key])
+		}
+	}
+	return value
+}
+
+// writeRedactedJSON writes v as a JSON response like writeJSON, with the
+// redaction rules applied to its strings. Resource routes returning log
+// records, tags or problem details use it, as their responses are shown in
+// the same panels as query results.
+func (d *Datasource) writeRedactedJSON(rw http.ResponseWriter, status int, v interface{}) {
+	if len(d.redactionRules) == 0 {
+		writeJSON(rw, status, v)
+		return
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(rw, status, d.redactJSON(raw))
+}
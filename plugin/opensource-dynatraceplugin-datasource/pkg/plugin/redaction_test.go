@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func TestParseRedactionRules(t *testing.T) {
+	var raw interface{}
+	_ = json.Unmarshal([]byte(`[{"pattern": "\\d{4}-\\d{4}"}, {"pattern": "secret", "replacement": "***"}]`), &raw)
+	rules, err := parseRedactionRules(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules[0].replacement != defaultRedactionReplacement || rules[1].replacement != "***" {
+		t.Errorf("rules = %+v", rules)
+	}
+
+	for _, invalid := range []string{`{"pattern": "x"}`, `[{"replacement": "x"}]`, `[{"pattern": "("}]`} {
+		_ = json.Unmarshal([]byte(invalid), &raw)
+		if _, err := parseRedactionRules(raw); err == nil {
+			t.Errorf("expected an error for %s", invalid)
+		}
+	}
+}
+
+func TestRedactFrames(t *testing.T) {
+	ds := &Datasource{redactionRules: []redactionRule{{pattern: regexp.MustCompile(`[\w.]+@example\.com`), replacement: "[email]"}}}
+
+	user := "jane@example.com"
+	series := data.NewField("value", data.Labels{"user": user}, []float64{1})
+	series.Config = &data.FieldConfig{DisplayNameFromDS: user}
+	frame := data.NewFrame("sessions of "+user,
+		series,
+		data.NewField("body", nil, []string{"login by " + user}),
+		data.NewField("optional", nil, []*string{&user, nil}),
+		data.NewField("labels", nil, []json.RawMessage{json.RawMessage(`{"user": "jane@example.com", "count": 2, "id": 12345678901234567890}`)}),
+	)
+	ds.redactFrames(data.Frames{frame})
+
+	if frame.Name != "sessions of [email]" || series.Labels["user"] != "[email]" || series.Config.DisplayNameFromDS != "[email]" {
+		t.Errorf("names and labels not redacted: %q %v %q", frame.Name, series.Labels, series.Config.DisplayNameFromDS)
+	}
+	if got := frame.Fields[1].At(0).(string); got != "login by [email]" {
+		t.Errorf("body = %q", got)
+	}
+	if got := frame.Fields[2].At(0).(*string); *got != "[email]" || user != "jane@example.com" {
+		t.Errorf("optional = %q, source modified: %q", *got, user)
+	}
+	if got := string(frame.Fields[3].At(0).(json.RawMessage)); got != `{"count":2,"id":12345678901234567890,"user":"[email]"}` {
+		t.Errorf("labels = %s", got)
+	}
+}
+
+func TestQueryRedactsLogBodies(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"results": [{"timestamp": 1000, "content": "payment by card 4111-1111-1111-1111", "status": "INFO"}]}`))
+	})
+	ds.redactionRules = []redactionRule{{pattern: regexp.MustCompile(`\d{4}(-\d{4}){3}`), replacement: defaultRedactionReplacement}}
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeLogs,
+		TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		JSON:      []byte(`{"logQuery": "payment"}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	body, _ := resp.Frames[0].FieldByName("body")
+	if got := body.At(0).(string); strings.Contains(got, "4111") || !strings.Contains(got, "[REDACTED]") {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestResourcesRedactResponses(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v2/logs/search":
+			_, _ = rw.Write([]byte(`{"results": [{"timestamp": 1700000000000, "content": "login by jane@example.com", "status": "INFO", "additionalColumns": {"user.email": ["jane@example.com"]}}]}`))
+		case "/api/v2/entities":
+			_, _ = rw.Write([]byte(`{"entities": [{"entityId": "HOST-1", "tags": [{"key": "owner", "value": "jane@example.com"}]}]}`))
+		case "/api/v2/problems/P-1":
+			_, _ = rw.Write([]byte(`{"problemId": "P-1", "title": "Failures for jane@example.com"}`))
+		default:
+			_, _ = rw.Write([]byte(`{}`))
+		}
+	})
+	ds.redactionRules = []redactionRule{{pattern: regexp.MustCompile(`[\w.]+@example\.com`), replacement: "[email]"}}
+
+	for _, path := range []string{
+		"/logs/context?timestamp=1700000000001&before=1&host.name=web-1",
+		"/logs/fields?query=login",
+		"/tags/owner/values?entitySelector=type(HOST)",
+		"/problems/P-1",
+	} {
+		rec := httptest.NewRecorder()
+		ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d, body = %s", path, rec.Code, rec.Body.String())
+			continue
+		}
+		if body := rec.Body.String(); strings.Contains(body, "jane@example.com") || !strings.Contains(body, "[email]") {
+			t.Errorf("%s: response not redacted: %s", path, body)
+		}
+	}
+}
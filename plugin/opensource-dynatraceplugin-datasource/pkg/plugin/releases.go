@@ -0,0 +1,158 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// DynatraceReleasesResponse represents a page of /api/v2/releases
+type DynatraceReleasesResponse struct {
+	TotalCount  int                `json:"totalCount"`
+	NextPageKey *string            `json:"nextPageKey"`
+	Releases    []DynatraceRelease `json:"releases"`
+}
+
+type DynatraceRelease struct {
+	Name                              string `json:"name"`
+	Product                           string `json:"product"`
+	Stage                             string `json:"stage"`
+	Version                           string `json:"version"`
+	ReleaseEntityId                   string `json:"releaseEntityId"`
+	Running                           bool   `json:"running"`
+	ProblemCount                      int64  `json:"problemCount"`
+	SecurityVulnerabilitiesCount      int64  `json:"securityVulnerabilitiesCount"`
+	AffectedByProblems                bool   `json:"affectedByProblems"`
+	AffectedBySecurityVulnerabilities bool   `json:"affectedBySecurityVulnerabilities"`
+}
+
+// queryReleases returns the versions deployed per service as a table,
+// followed by a state timeline frame. The releases API carries no
+// timestamps, so each release is placed at the first and last time its
+// release entity was seen.
+func (d *Datasource) queryReleases(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	releases, err := d.fetchReleases(ctx, qm.ReleasesSelector, fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace releases: %v", err))
+	}
+
+	ids := make([]string, 0, len(releases))
+	for _, r := range releases {
+		if r.ReleaseEntityId != "" {
+			ids = append(ids, r.ReleaseEntityId)
+		}
+	}
+	entities, err := d.fetchEntitiesById(ctx, ids, "firstSeenTms,lastSeenTms", fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace release entities: %v", err))
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		return entities[releases[i].ReleaseEntityId].FirstSeenTms < entities[releases[j].ReleaseEntityId].FirstSeenTms
+	})
+
+	executed := fmt.Sprintf("Releases: %s", qm.ReleasesSelector)
+
+	table := releasesFrame(releases, entities)
+	addFieldLinks(table, "releaseEntityId", d.entityLink(valuePlaceholder, fromMs, toMs))
+	table.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable, ExecutedQueryString: executed}
+
+	timeline := releasesTimelineFrame(releases, entities)
+	timeline.Meta = &data.FrameMeta{ExecutedQueryString: executed}
+
+	return backend.DataResponse{Frames: data.Frames{table, timeline}}
+}
+
+// fetchReleases walks all pages of /api/v2/releases for a releases selector.
+func (d *Datasource) fetchReleases(ctx context.Context, releasesSelector string, fromMs, toMs int64) ([]DynatraceRelease, error) {
+	params := url.Values{}
+	params.Set("from", fmt.Sprintf("%d", fromMs))
+	params.Set("to", fmt.Sprintf("%d", toMs))
+	params.Set("pageSize", "1000")
+	if releasesSelector != "" {
+		params.Set("releasesSelector", releasesSelector)
+	}
+
+	var releases []DynatraceRelease
+	err := d.getAllPages(ctx, "/api/v2/releases", params, func(body []byte) error {
+		var page DynatraceReleasesResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		releases = append(releases, page.Releases...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// releasesFrame converts releases into a table with one row per release.
+// Timestamps are null when the release entity was not found.
+func releasesFrame(releases []DynatraceRelease, entities map[string]DynatraceEntity) *data.Frame {
+	frame := data.NewFrame("releases",
+		data.NewField("firstSeen", nil, []*time.Time{}),
+		data.NewField("lastSeen", nil, []*time.Time{}),
+		data.NewField("service", nil, []string{}),
+		data.NewField("product", nil, []string{}),
+		data.NewField("stage", nil, []string{}),
+		data.NewField("version", nil, []string{}),
+		data.NewField("running", nil, []bool{}),
+		data.NewField("problems", nil, []int64{}),
+		data.NewField("vulnerabilities", nil, []int64{}),
+		data.NewField("releaseEntityId", nil, []string{}),
+	)
+
+	for _, r := range releases {
+		entity := entities[r.ReleaseEntityId]
+		frame.AppendRow(
+			seenTime(entity.FirstSeenTms),
+			seenTime(entity.LastSeenTms),
+			r.Name,
+			r.Product,
+			r.Stage,
+			r.Version,
+			r.Running,
+			r.ProblemCount,
+			r.SecurityVulnerabilitiesCount,
+			r.ReleaseEntityId,
+		)
+	}
+
+	return frame
+}
+
+// releasesTimelineFrame builds the state timeline frame of releases, with one
+// field per service holding the version first seen at or before each timestamp.
+func releasesTimelineFrame(releases []DynatraceRelease, entities map[string]DynatraceEntity) *data.Frame {
+	changes := make([]versionChange, 0, len(releases))
+	for _, r := range releases {
+		entity, ok := entities[r.ReleaseEntityId]
+		if !ok || entity.FirstSeenTms == 0 {
+			continue
+		}
+		changes = append(changes, versionChange{entity: r.Name, time: entity.FirstSeenTms, version: r.Version})
+	}
+	return versionTimelineFrame("releaseTimeline", changes)
+}
+
+// seenTime converts an entity first/last seen timestamp, returning nil when it is unknown.
+func seenTime(ms int64) *time.Time {
+	if ms == 0 {
+		return nil
+	}
+	t := time.UnixMilli(ms)
+	return &t
+}
@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryReleases(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v2/releases":
+			if got := req.URL.Query().Get("releasesSelector"); got != `product("shop")` {
+				t.Errorf("releasesSelector = %q", got)
+			}
+			_, _ = rw.Write([]byte(`{"releases": [
+				{"name": "cart", "product": "shop", "stage": "prod", "version": "1.1", "releaseEntityId": "PROCESS_GROUP_INSTANCE-2", "running": true},
+				{"name": "cart", "product": "shop", "stage": "prod", "version": "1.0", "releaseEntityId": "PROCESS_GROUP_INSTANCE-1", "problemCount": 2},
+				{"name": "checkout", "product": "shop", "version": "3.0", "releaseEntityId": "PROCESS_GROUP_INSTANCE-3", "running": true}
+			]}`))
+		case "/api/v2/entities":
+			_, _ = rw.Write([]byte(`{"entities": [
+				{"entityId": "PROCESS_GROUP_INSTANCE-1", "firstSeenTms": 1000, "lastSeenTms": 3000},
+				{"entityId": "PROCESS_GROUP_INSTANCE-2", "firstSeenTms": 3000, "lastSeenTms": 5000},
+				{"entityId": "PROCESS_GROUP_INSTANCE-3", "firstSeenTms": 2000, "lastSeenTms": 5000}
+			]}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeReleases,
+		JSON:      []byte(`{"useDashboardTime": true, "releasesSelector": "product(\"shop\")"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 2 {
+		t.Fatalf("expected a table and a timeline frame, got %d", len(resp.Frames))
+	}
+
+	table := resp.Frames[0]
+	if rows, _ := table.RowLen(); rows != 3 {
+		t.Fatalf("expected 3 releases, got %d", rows)
+	}
+	if got := table.Fields[5].At(0); got != "1.0" {
+		t.Errorf("first release = %v, want the earliest version 1.0", got)
+	}
+
+	timeline := resp.Frames[1]
+	if rows, _ := timeline.RowLen(); rows != 3 {
+		t.Fatalf("expected 3 timestamps, got %d", rows)
+	}
+	cart, _ := timeline.FieldByName("cart")
+	if v := cart.At(1).(*string); v == nil || *v != "1.0" {
+		t.Errorf("cart at 2000 = %v, want 1.0 still live", v)
+	}
+	if v := cart.At(2).(*string); v == nil || *v != "1.1" {
+		t.Errorf("cart at 3000 = %v, want 1.1", v)
+	}
+}
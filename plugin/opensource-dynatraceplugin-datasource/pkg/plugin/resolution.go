@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsDataPointBudget is the largest number of data points a single
+// metrics query may return before it is retried at a coarser resolution.
+const metricsDataPointBudget = 500000
+
+// resolutionSteps are the resolutions tried, from fine to coarse, when a query
+// has to be downgraded.
+var resolutionSteps = []string{"1m", "2m", "5m", "10m", "15m", "30m", "1h", "2h", "6h", "12h", "1d", "1w"}
+
+// parseResolution parses a Dynatrace resolution such as "5m", "1h", "1d" or
+// "1w". "Inf" and other non-duration resolutions return an error.
+func parseResolution(resolution string) (time.Duration, error) {
+	if len(resolution) < 2 {
+		return 0, fmt.Errorf("invalid resolution: %q", resolution)
+	}
+
+	unit := resolution[len(resolution)-1:]
+	n, err := strconv.Atoi(resolution[:len(resolution)-1])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid resolution: %q", resolution)
+	}
+
+	switch strings.ToLower(unit) {
+	case "s":
+		return time.Duration(n) * time.Second, nil
+	case "m":
+		return time.Duration(n) * time.Minute, nil
+	case "h":
+		return time.Duration(n) * time.Hour, nil
+	case "d":
+		return time.Duration(n) * 24 * time.Hour, nil
+	case "w":
+		return time.Duration(n) * 7 * 24 * time.Hour, nil
+	default:
+		return 0, fmt.Errorf("invalid resolution: %q", resolution)
+	}
+}
+
+// estimatedDataPoints estimates the data points of series series over the range.
+func estimatedDataPoints(series int, fromMs, toMs int64, resolution time.Duration) int64 {
+	buckets := (time.Duration(toMs-fromMs)*time.Millisecond + resolution - 1) / resolution
+	return int64(series) * int64(buckets)
+}
+
+// coarserResolution returns the finest resolution step coarser than current
+// that keeps series within budget data points over the range. It returns
+// false if current already fits or no step is coarse enough.
+func coarserResolution(current string, series int, fromMs, toMs int64, budget int64) (string, bool) {
+	currentDuration, err := parseResolution(current)
+	if err != nil || estimatedDataPoints(series, fromMs, toMs, currentDuration) <= budget {
+		return "", false
+	}
+
+	for _, step := range resolutionSteps {
+		stepDuration, _ := parseResolution(step)
+		if stepDuration <= currentDuration {
+			continue
+		}
+		if estimatedDataPoints(series, fromMs, toMs, stepDuration) <= budget {
+			return step, true
+		}
+	}
+	return "", false
+}
+
+// fillGaps returns the series on a regular grid with step spacing between
+// fromMs and toMs, anchored at the series' own timestamps so that existing
+// points stay in place. Missing buckets are filled with nulls, as are the null
+// values of the series. If grid is non-nil it is used instead of a regular
+// grid, e.g. for calendar buckets.
+func fillGaps(timestamps []int64, values []*float64, fromMs, toMs int64, step time.Duration, grid []int64) ([]time.Time, []*float64) {
+	if grid == nil && len(timestamps) > 0 && step > 0 {
+		stepMs := step.Milliseconds()
+		start := timestamps[0] - (timestamps[0]-fromMs)/stepMs*stepMs
+		for ts := start; ts <= toMs; ts += stepMs {
+			grid = append(grid, ts)
+		}
+	}
+
+	byTime := make(map[int64]*float64, len(timestamps))
+	for i, ts := range timestamps {
+		byTime[ts] = values[i]
+	}
+
+	// Keep points that are not on the grid rather than dropping data
+	all := append([]int64{}, grid...)
+	onGrid := make(map[int64]bool, len(grid))
+	for _, ts := range grid {
+		onGrid[ts] = true
+	}
+	for _, ts := range timestamps {
+		if !onGrid[ts] {
+			all = append(all, ts)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+
+	times := make([]time.Time, len(all))
+	filled := make([]*float64, len(all))
+	for i, ts := range all {
+		times[i] = time.UnixMilli(ts)
+		filled[i] = byTime[ts]
+	}
+	return times, filled
+}
+
+// alignSeries snaps timestamps down to multiples of step and, if dropPartial
+// is set, drops trailing points whose bucket ends after cutoffMs. Dynatrace
+// reports the last, still filling interval of a range; dropping it keeps
+// "last value" evaluations from flapping as the bucket fills up.
+func alignSeries(timestamps []int64, values []*float64, step time.Duration, cutoffMs int64, dropPartial bool) ([]int64, []*float64) {
+	stepMs := step.Milliseconds()
+	if stepMs <= 0 {
+		return timestamps, values
+	}
+
+	aligned := make([]int64, 0, len(timestamps))
+	kept := make([]*float64, 0, len(values))
+	for i, ts := range timestamps {
+		ts -= ts % stepMs
+		if dropPartial && ts+stepMs > cutoffMs {
+			continue
+		}
+		aligned = append(aligned, ts)
+		kept = append(kept, values[i])
+	}
+	return aligned, kept
+}
@@ -0,0 +1,176 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestParseResolution(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30s": 30 * time.Second,
+		"5m":  5 * time.Minute,
+		"2h":  2 * time.Hour,
+		"1d":  24 * time.Hour,
+		"1w":  7 * 24 * time.Hour,
+	}
+	for resolution, want := range tests {
+		got, err := parseResolution(resolution)
+		if err != nil || got != want {
+			t.Errorf("parseResolution(%q) = %v, %v, want %v", resolution, got, err, want)
+		}
+	}
+	for _, invalid := range []string{"", "Inf", "m", "0m", "5x"} {
+		if _, err := parseResolution(invalid); err == nil {
+			t.Errorf("parseResolution(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestCoarserResolution(t *testing.T) {
+	week := int64(7 * 24 * time.Hour / time.Millisecond)
+
+	// 100 series over a week at 1m are ~1M points
+	if got, ok := coarserResolution("1m", 100, 0, week, 500000); !ok || got != "5m" {
+		t.Errorf("coarserResolution = %s, %v, want 5m", got, ok)
+	}
+	if _, ok := coarserResolution("1h", 100, 0, week, 500000); ok {
+		t.Error("queries within the budget should not be downgraded")
+	}
+	if _, ok := coarserResolution("Inf", 100000, 0, week, 10); ok {
+		t.Error("non-duration resolutions cannot be downgraded")
+	}
+}
+
+func TestQueryMetricsDowngradesResolution(t *testing.T) {
+	var resolutions []string
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		resolution := req.URL.Query().Get("resolution")
+		resolutions = append(resolutions, resolution)
+
+		series := make([]string, 1000)
+		for i := range series {
+			series[i] = fmt.Sprintf(`{"dimensionMap": {"dt.entity.host": "HOST-%d"}, "timestamps": [1000], "values": [1]}`, i)
+		}
+		_, _ = rw.Write([]byte(fmt.Sprintf(`{"resolution": %q, "result": [{"metricId": "builtin:host.cpu.usage", "data": [%s]}]}`,
+			resolution, strings.Join(series, ","))))
+	})
+
+	day := int64(24 * time.Hour / time.Millisecond)
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(fmt.Sprintf(`{"metricSelector": "builtin:host.cpu.usage", "resolution": "1m", "customFrom": "1", "customTo": "%d"}`, day+1)),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+
+	// 1000 series over a day: 1m and 2m exceed the budget, 5m fits
+	if strings.Join(resolutions, ",") != "1m,5m" {
+		t.Errorf("resolutions = %v, want a retry at 5m", resolutions)
+	}
+	found := false
+	for _, notice := range resp.Frames[0].Meta.Notices {
+		if strings.Contains(notice.Text, "from 1m to 5m") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected a notice explaining the downgrade, got %+v", resp.Frames[0].Meta.Notices)
+	}
+}
+
+func TestFillGaps(t *testing.T) {
+	minute := int64(time.Minute / time.Millisecond)
+	times, values := fillGaps([]int64{2 * minute, 3 * minute, 4 * minute}, float64Ptrs(1, math.NaN(), 2), 30000, 5*minute, time.Minute, nil)
+
+	// Anchored at the first point, from 1m (the first grid point after from)
+	// to 5m; the null at 3m stays null
+	if len(times) != 5 {
+		t.Fatalf("expected 5 points, got %d", len(times))
+	}
+	if times[0].UnixMilli() != minute || times[4].UnixMilli() != 5*minute {
+		t.Errorf("unexpected grid %v .. %v", times[0], times[4])
+	}
+	for i, want := range []interface{}{nil, 1.0, nil, 2.0, nil} {
+		got := values[i]
+		if (want == nil) != (got == nil) || (got != nil && *got != want) {
+			t.Errorf("value %d = %v, want %v", i, got, want)
+		}
+	}
+
+	// Explicit grids are used as-is, off-grid points are kept
+	times, values = fillGaps([]int64{5}, float64Ptrs(3), 0, 100, 0, []int64{0, 50})
+	if len(times) != 3 || values[0] != nil || *values[1] != 3 || values[2] != nil {
+		t.Errorf("unexpected explicit grid fill %v %v", times, values)
+	}
+}
+
+func TestAlignSeries(t *testing.T) {
+	minute := int64(time.Minute / time.Millisecond)
+	timestamps := []int64{minute + 500, 2*minute + 10, 3*minute + 999}
+	values := float64Ptrs(1, 2, 3)
+
+	aligned, kept := alignSeries(timestamps, values, time.Minute, 10*minute, false)
+	if fmt.Sprint(aligned) != fmt.Sprint([]int64{minute, 2 * minute, 3 * minute}) || len(kept) != 3 {
+		t.Errorf("aligned = %v", aligned)
+	}
+
+	// The bucket starting at 3m ends after the cutoff at 3m30s
+	aligned, kept = alignSeries(timestamps, values, time.Minute, 3*minute+30000, true)
+	if len(aligned) != 2 || *kept[1] != 2 {
+		t.Errorf("expected the partial bucket to be dropped, got %v %v", aligned, kept)
+	}
+}
+
+func TestQueryMetricsDropsPartialBucket(t *testing.T) {
+	minute := int64(time.Minute / time.Millisecond)
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(fmt.Sprintf(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {}, "timestamps": [%d, %d, %d], "values": [1, 2, 3]}
+		]}]}`, 5*minute, 10*minute, 15*minute)))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(fmt.Sprintf(`{"metricSelector": "builtin:host.cpu.usage", "dropPartialBucket": true, "customFrom": "%d", "customTo": "%d"}`, 5*minute, 17*minute)),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if n := resp.Frames[0].Fields[0].Len(); n != 2 {
+		t.Errorf("expected the 15m bucket ending after the range to be dropped, got %d points", n)
+	}
+}
+
+func TestQueryMetricsKeepsNulls(t *testing.T) {
+	minute := int64(time.Minute / time.Millisecond)
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(fmt.Sprintf(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {}, "timestamps": [%d, %d, %d], "values": [1, null, 3]}
+		]}]}`, 5*minute, 6*minute, 7*minute)))
+	})
+
+	for _, fillGaps := range []bool{false, true} {
+		resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+			RefID: "A",
+			JSON:  []byte(fmt.Sprintf(`{"metricSelector": "builtin:host.cpu.usage", "resolution": "1m", "fillGaps": %t, "customFrom": "%d", "customTo": "%d"}`, fillGaps, 5*minute, 7*minute)),
+		})
+		if resp.Error != nil {
+			t.Fatal(resp.Error)
+		}
+		values := resp.Frames[0].Fields[1]
+		if values.Len() != 3 {
+			t.Fatalf("fillGaps=%t: expected 3 points, got %d", fillGaps, values.Len())
+		}
+		if v, _ := values.NullableFloatAt(1); v != nil {
+			t.Errorf("fillGaps=%t: null value became %v", fillGaps, *v)
+		}
+	}
+}
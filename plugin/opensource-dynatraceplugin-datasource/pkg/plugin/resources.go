@@ -0,0 +1,80 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+)
+
+// CallResource handles resource calls sent from Grafana to the plugin, e.g.
+// GET /api/datasources/uid/<uid>/resources/problems/<id>.
+func (d *Datasource) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	if d.resourceHandler == nil {
+		d.resourceHandler = httpadapter.New(d.newResourceMux())
+	}
+	return d.resourceHandler.CallResource(ctx, req, sender)
+}
+
+// newResourceMux registers the resource routes served by the datasource.
+func (d *Datasource) newResourceMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/problems/", d.handleProblem)
+	mux.HandleFunc("/events", d.handleEvents)
+	mux.HandleFunc("/metrics/ingest", d.handleMetricIngest)
+	mux.HandleFunc("/metrics/aggregations", d.handleMetricAggregations)
+	mux.HandleFunc("/tags/", d.handleTagValues)
+	mux.HandleFunc("/alertingProfiles", d.handleAlertingProfiles)
+	mux.HandleFunc("/metricEvents", d.handleMetricEvents)
+	mux.HandleFunc("/settings/objects", d.handleSettingsObjects)
+	mux.HandleFunc("/logs/context", d.handleLogContext)
+	mux.HandleFunc("/logs/fields", d.handleLogFields)
+	mux.HandleFunc("/dql/dataObjects", d.handleDQLDataObjects)
+	mux.HandleFunc("/dql/fields", d.handleDQLFields)
+	mux.HandleFunc("/dql/autocomplete", d.handleDQLAutocomplete)
+	mux.HandleFunc("/grail/buckets", d.handleGrailBuckets)
+	mux.HandleFunc("/dashboards/generate", d.handleGenerateDashboard)
+	mux.HandleFunc("/stats", d.handleStats)
+	mux.HandleFunc("/info", d.handleInfo)
+	return mux
+}
+
+// handleProblem serves GET /problems/{problemId} with evidence and impact analysis.
+func (d *Datasource) handleProblem(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	problemId := strings.TrimPrefix(req.URL.Path, "/problems/")
+	if problemId == "" || strings.Contains(problemId, "/") {
+		writeError(rw, http.StatusBadRequest, "problem ID is required")
+		return
+	}
+
+	problem, err := d.fetchProblem(req.Context(), problemId)
+	if err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	d.writeRedactedJSON(rw, http.StatusOK, problem)
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.DefaultLogger.Error("Error writing resource response", "error", err)
+	}
+}
+
+// writeError writes a JSON error body of the form {"error": "..."}.
+func writeError(rw http.ResponseWriter, status int, message string) {
+	writeJSON(rw, status, map[string]string{"error": message})
+}
@@ -0,0 +1,216 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// securityRiskLevels are the Davis risk levels, most severe first.
+var securityRiskLevels = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "NONE"}
+
+// securityTrendBuckets is the number of buckets of the risk level series when
+// the query carries no interval.
+const securityTrendBuckets = 100
+
+// DynatraceSecurityProblemsResponse represents a page of /api/v2/securityProblems
+type DynatraceSecurityProblemsResponse struct {
+	TotalCount       int                        `json:"totalCount"`
+	NextPageKey      *string                    `json:"nextPageKey"`
+	SecurityProblems []DynatraceSecurityProblem `json:"securityProblems"`
+}
+
+type DynatraceSecurityProblem struct {
+	SecurityProblemId     string `json:"securityProblemId"`
+	DisplayId             string `json:"displayId"`
+	Status                string `json:"status"`
+	Muted                 bool   `json:"muted"`
+	Title                 string `json:"title"`
+	PackageName           string `json:"packageName"`
+	Technology            string `json:"technology"`
+	VulnerabilityType     string `json:"vulnerabilityType"`
+	FirstSeenTimestamp    int64  `json:"firstSeenTimestamp"`
+	LastUpdatedTimestamp  int64  `json:"lastUpdatedTimestamp"`
+	LastResolvedTimestamp int64  `json:"lastResolvedTimestamp"`
+	RiskAssessment        struct {
+		RiskLevel string  `json:"riskLevel"`
+		RiskScore float64 `json:"riskScore"`
+	} `json:"riskAssessment"`
+}
+
+// resolvedAt returns when a resolved security problem was resolved, or 0 when
+// it is still open.
+func (p DynatraceSecurityProblem) resolvedAt() int64 {
+	if p.Status != "RESOLVED" {
+		return 0
+	}
+	if p.LastResolvedTimestamp > 0 {
+		return p.LastResolvedTimestamp
+	}
+	return p.LastUpdatedTimestamp
+}
+
+// querySecurityProblems lists the security problems seen in the query time
+// range as a table. With the "riskLevel" aggregation it instead returns the
+// number of open, unmuted vulnerabilities over time per risk level.
+func (d *Datasource) querySecurityProblems(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	if qm.SecurityAggregation != "" && qm.SecurityAggregation != "riskLevel" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid security aggregation %q: must be \"riskLevel\"", qm.SecurityAggregation))
+	}
+
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	// Risk trends count all problems, so only tables are paged
+	var paging tablePaging
+	if qm.SecurityAggregation == "" {
+		paging, err = newTablePaging(qm)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+	}
+
+	problems, err := d.fetchSecurityProblems(ctx, qm.SecurityProblemSelector, fromMs, toMs, paging)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace security problems: %v", err))
+	}
+
+	executed := fmt.Sprintf("Security problems: %s", qm.SecurityProblemSelector)
+
+	if qm.SecurityAggregation == "riskLevel" {
+		interval := query.Interval.Milliseconds()
+		if interval <= 0 {
+			interval = (toMs - fromMs) / securityTrendBuckets
+		}
+		if interval <= 0 {
+			interval = 1
+		}
+
+		frames := securityRiskFrames(problems, fromMs, toMs, interval)
+		for _, frame := range frames {
+			frame.Meta = &data.FrameMeta{ExecutedQueryString: executed}
+		}
+		return backend.DataResponse{Frames: frames}
+	}
+
+	start, end := paging.bounds(len(problems))
+	frame := securityProblemsFrame(problems[start:end])
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable, ExecutedQueryString: executed}
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// fetchSecurityProblems walks the pages of /api/v2/securityProblems for the
+// given time range, in the sort order of paging and up to its requested rows.
+func (d *Datasource) fetchSecurityProblems(ctx context.Context, selector string, fromMs, toMs int64, paging tablePaging) ([]DynatraceSecurityProblem, error) {
+	params := url.Values{}
+	params.Set("from", fmt.Sprintf("%d", fromMs))
+	params.Set("to", fmt.Sprintf("%d", toMs))
+	params.Set("pageSize", "500")
+	params.Set("fields", "+riskAssessment")
+	if selector != "" {
+		params.Set("securityProblemSelector", selector)
+	}
+	ctx = paging.apply(ctx, params, 500)
+
+	log.DefaultLogger.Debug("Querying Dynatrace security problems", "securityProblemSelector", d.logPayload(selector), "from", fromMs, "to", toMs)
+
+	var problems []DynatraceSecurityProblem
+	err := d.getAllPages(ctx, "/api/v2/securityProblems", params, func(body []byte) error {
+		var page DynatraceSecurityProblemsResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		problems = append(problems, page.SecurityProblems...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// securityProblemsFrame converts security problems into a table with one row per problem.
+func securityProblemsFrame(problems []DynatraceSecurityProblem) *data.Frame {
+	frame := data.NewFrame("securityProblems",
+		data.NewField("firstSeen", nil, []time.Time{}),
+		data.NewField("resolved", nil, []*time.Time{}),
+		data.NewField("displayId", nil, []string{}),
+		data.NewField("title", nil, []string{}),
+		data.NewField("status", nil, []string{}),
+		data.NewField("muted", nil, []bool{}),
+		data.NewField("riskLevel", nil, []string{}),
+		data.NewField("riskScore", nil, []float64{}),
+		data.NewField("package", nil, []string{}),
+		data.NewField("technology", nil, []string{}),
+		data.NewField("securityProblemId", nil, []string{}),
+	)
+
+	for _, p := range problems {
+		frame.AppendRow(
+			time.UnixMilli(p.FirstSeenTimestamp),
+			problemEndTime(p.resolvedAt()),
+			p.DisplayId,
+			p.Title,
+			p.Status,
+			p.Muted,
+			p.RiskAssessment.RiskLevel,
+			p.RiskAssessment.RiskScore,
+			p.PackageName,
+			p.Technology,
+			p.SecurityProblemId,
+		)
+	}
+
+	return frame
+}
+
+// securityRiskFrames returns one series per risk level counting the
+// vulnerabilities open at every interval between from and to. A problem is
+// open from its first seen timestamp until it is resolved; muted problems
+// are not counted.
+func securityRiskFrames(problems []DynatraceSecurityProblem, fromMs, toMs, interval int64) data.Frames {
+	start := fromMs - fromMs%interval
+	buckets := int((toMs-start)/interval) + 1
+
+	times := make([]time.Time, buckets)
+	for i := range times {
+		times[i] = time.UnixMilli(start + int64(i)*interval)
+	}
+
+	counts := map[string][]int64{}
+	for _, level := range securityRiskLevels {
+		counts[level] = make([]int64, buckets)
+	}
+	for _, p := range problems {
+		if p.Muted {
+			continue
+		}
+		level := p.RiskAssessment.RiskLevel
+		if counts[level] == nil {
+			level = "NONE"
+		}
+		resolved := p.resolvedAt()
+		for i := range times {
+			ts := start + int64(i)*interval
+			if p.FirstSeenTimestamp <= ts && (resolved == 0 || resolved > ts) {
+				counts[level][i]++
+			}
+		}
+	}
+
+	frames := make(data.Frames, 0, len(securityRiskLevels))
+	for _, level := range securityRiskLevels {
+		count := data.NewField("open", data.Labels{"riskLevel": level}, counts[level])
+		count.Config = &data.FieldConfig{DisplayNameFromDS: level}
+		frames = append(frames, data.NewFrame("vulnerabilities", data.NewField("time", nil, times), count))
+	}
+	return frames
+}
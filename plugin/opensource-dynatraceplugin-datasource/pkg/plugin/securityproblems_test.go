@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestSecurityRiskFrames(t *testing.T) {
+	problems := []DynatraceSecurityProblem{
+		{Status: "OPEN", FirstSeenTimestamp: 0},
+		{Status: "RESOLVED", FirstSeenTimestamp: 1000, LastResolvedTimestamp: 3000},
+		{Status: "OPEN", FirstSeenTimestamp: 2000, Muted: true},
+		{Status: "RESOLVED", FirstSeenTimestamp: 0, LastUpdatedTimestamp: 1500},
+	}
+	problems[0].RiskAssessment.RiskLevel = "HIGH"
+	problems[1].RiskAssessment.RiskLevel = "HIGH"
+	problems[2].RiskAssessment.RiskLevel = "CRITICAL"
+	problems[3].RiskAssessment.RiskLevel = "LOW"
+
+	frames := securityRiskFrames(problems, 0, 4000, 1000)
+	if len(frames) != len(securityRiskLevels) {
+		t.Fatalf("expected a series per risk level, got %d", len(frames))
+	}
+
+	want := map[string][]int64{
+		"CRITICAL": {0, 0, 0, 0, 0},
+		"HIGH":     {1, 2, 2, 1, 1},
+		"LOW":      {1, 1, 0, 0, 0},
+	}
+	for _, frame := range frames {
+		level := frame.Fields[1].Labels["riskLevel"]
+		expected, ok := want[level]
+		if !ok {
+			continue
+		}
+		for i, count := range expected {
+			if got := frame.Fields[1].At(i).(int64); got != count {
+				t.Errorf("%s at bucket %d = %d, want %d", level, i, got, count)
+			}
+		}
+	}
+}
+
+func TestQuerySecurityProblemsTable(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/securityProblems" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+			return
+		}
+		if got := req.URL.Query().Get("securityProblemSelector"); got != `status("OPEN")` {
+			t.Errorf("securityProblemSelector = %q", got)
+		}
+		_, _ = rw.Write([]byte(`{"securityProblems": [
+			{"securityProblemId": "1", "displayId": "S-1", "status": "OPEN", "title": "Log4Shell", "firstSeenTimestamp": 1000, "riskAssessment": {"riskLevel": "CRITICAL", "riskScore": 10}}
+		]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSecurity,
+		TimeRange: backend.TimeRange{From: time.UnixMilli(0), To: time.UnixMilli(5000)},
+		JSON:      []byte(`{"useDashboardTime": true, "securityProblemSelector": "status(\"OPEN\")"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	frame := resp.Frames[0]
+	if rows, _ := frame.RowLen(); rows != 1 {
+		t.Fatalf("expected 1 row, got %d", rows)
+	}
+	if level, _ := frame.FieldByName("riskLevel"); level.At(0) != "CRITICAL" {
+		t.Errorf("riskLevel = %v", level.At(0))
+	}
+}
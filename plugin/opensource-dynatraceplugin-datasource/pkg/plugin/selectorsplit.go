@@ -0,0 +1,240 @@
+package plugin
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// maxMetricSelectorLength is the longest metric selector sent in a single
+// request. Longer selectors are split on their largest in(...) filter, which
+// is what multi-value variables usually expand into.
+const maxMetricSelectorLength = 32000
+
+// selectorArgs is the argument list of a function call inside a selector.
+type selectorArgs struct {
+	start, end int // offsets of the arguments, excluding the parentheses
+	args       []string
+}
+
+// splitMetricSelector splits the largest in(...) filter of selector into
+// several selectors that each stay below maxLength where possible. It returns
+// false when the selector has no in(...) filter with more than one value, or
+// when it aggregates across the filtered dimension, in which case the results
+// of the split selectors can't be combined.
+func splitMetricSelector(selector string, maxLength int) ([]string, bool) {
+	var largest *selectorArgs
+	for i := 0; i+3 <= len(selector); i++ {
+		if selector[i:i+3] != "in(" || (i > 0 && isSelectorIdentChar(selector[i-1])) {
+			continue
+		}
+		if args, ok := parseSelectorArgs(selector, i+3); ok && len(args.args) > 2 {
+			if largest == nil || len(args.args) > len(largest.args) {
+				largest = args
+			}
+		}
+	}
+	if largest == nil || aggregatesAcross(selector, strings.Trim(largest.args[0], `"`)) {
+		return nil, false
+	}
+
+	prefix := selector[:largest.start] + largest.args[0]
+	suffix := selector[largest.end:]
+	budget := maxLength - len(prefix) - len(suffix)
+
+	var selectors []string
+	var values []string
+	length := 0
+	for _, value := range largest.args[1:] {
+		// Every value needs a separating comma
+		if len(values) > 0 && length+len(value)+1 > budget {
+			selectors = append(selectors, prefix+","+strings.Join(values, ",")+suffix)
+			values, length = nil, 0
+		}
+		values = append(values, value)
+		length += len(value) + 1
+	}
+	selectors = append(selectors, prefix+","+strings.Join(values, ",")+suffix)
+
+	return selectors, len(selectors) > 1
+}
+
+// parseSelectorArgs parses the comma separated arguments starting at offset
+// start up to the matching closing parenthesis. Quoted arguments may contain
+// commas and parentheses; ~ escapes the next character inside quotes.
+func parseSelectorArgs(selector string, start int) (*selectorArgs, bool) {
+	var args []string
+	depth := 0
+	quoted := false
+	argStart := start
+	for i := start; i < len(selector); i++ {
+		c := selector[i]
+		switch {
+		case quoted && c == '~':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case c == ')':
+			args = append(args, strings.TrimSpace(selector[argStart:i]))
+			return &selectorArgs{start: start, end: i, args: args}, true
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(selector[argStart:i]))
+			argStart = i + 1
+		}
+	}
+	return nil, false
+}
+
+// aggregatingTransformations combine series, so splitting the selector would
+// return partial aggregates instead of one aggregate over all values.
+var aggregatingTransformations = map[string]bool{
+	"merge": true,
+	"fold":  true,
+}
+
+// aggregatesAcross reports whether selector applies a transformation that
+// combines series of different values of dimension: :merge, :fold, or a
+// :splitBy that doesn't keep the dimension.
+func aggregatesAcross(selector, dimension string) bool {
+	quoted := false
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		switch {
+		case quoted && c == '~':
+			i++
+			continue
+		case c == '"':
+			quoted = !quoted
+			continue
+		case quoted || c != ':':
+			continue
+		}
+
+		end := i + 1
+		for end < len(selector) && isSelectorIdentChar(selector[end]) && selector[end] != ':' {
+			end++
+		}
+		name := selector[i+1 : end]
+		if aggregatingTransformations[name] {
+			return true
+		}
+		if name != "splitBy" || end >= len(selector) || selector[end] != '(' {
+			continue
+		}
+		args, ok := parseSelectorArgs(selector, end+1)
+		if !ok {
+			return true
+		}
+		kept := false
+		for _, arg := range args.args {
+			if strings.Trim(arg, `"`) == dimension {
+				kept = true
+			}
+		}
+		if !kept {
+			return true
+		}
+	}
+	return false
+}
+
+func isSelectorIdentChar(c byte) bool {
+	return c == '_' || c == '.' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// mergeMetricsResponses combines the responses of split or incremental
+// queries into one series per metric and dimension set, in the order they
+// were first returned. Points returned by several responses are kept once, so
+// overlapping windows are not counted twice; responses that disagree on the
+// value of a point are an error, as neither can be trusted.
+func mergeMetricsResponses(responses []*DynatraceMetricsResponse) (*DynatraceMetricsResponse, error) {
+	merged := &DynatraceMetricsResponse{}
+	results := map[string]int{}
+	series := map[string]int{}
+	for _, resp := range responses {
+		if merged.Resolution == "" {
+			merged.Resolution = resp.Resolution
+		}
+		for _, result := range resp.Result {
+			r, ok := results[result.MetricId]
+			if !ok {
+				r = len(merged.Result)
+				results[result.MetricId] = r
+				merged.Result = append(merged.Result, DynatraceMetricResult{
+					MetricId:            result.MetricId,
+					DataPointCountRatio: result.DataPointCountRatio,
+					DimensionCountRatio: result.DimensionCountRatio,
+				})
+			}
+
+			for _, dataSet := range result.Data {
+				key := result.MetricId + "|" + dimensionKey(dataSet.DimensionMap)
+				i, ok := series[key]
+				if !ok {
+					i = len(merged.Result[r].Data)
+					series[key] = i
+					merged.Result[r].Data = append(merged.Result[r].Data, DynatraceMetricData{
+						Dimensions:   dataSet.Dimensions,
+						DimensionMap: dataSet.DimensionMap,
+					})
+				}
+				target := &merged.Result[r].Data[i]
+				target.Timestamps = append(target.Timestamps, dataSet.Timestamps...)
+				target.Values = append(target.Values, dataSet.Values...)
+			}
+		}
+	}
+
+	for r := range merged.Result {
+		for i := range merged.Result[r].Data {
+			dataSet := &merged.Result[r].Data[i]
+			var err error
+			dataSet.Timestamps, dataSet.Values, err = dedupeTimestamps(dataSet.Timestamps, dataSet.Values)
+			if err != nil {
+				return nil, fmt.Errorf("cannot merge results of %s %s: %w", merged.Result[r].MetricId, dimensionKey(dataSet.DimensionMap), err)
+			}
+		}
+		merged.TotalCount += len(merged.Result[r].Data)
+	}
+	return merged, nil
+}
+
+// dedupeTimestamps sorts points by timestamp, keeping a single point per
+// timestamp. A value replaces a null, e.g. of an interval that was still empty
+// when an earlier window was queried. It fails when points with the same
+// timestamp have different values.
+func dedupeTimestamps(timestamps []int64, values []*float64) ([]int64, []*float64, error) {
+	latest := make(map[int64]*float64, len(timestamps))
+	for i, ts := range timestamps {
+		previous, ok := latest[ts]
+		if ok && previous != nil && values[i] != nil && !sameValue(*previous, *values[i]) {
+			return nil, nil, fmt.Errorf("conflicting values %v and %v at %d", *previous, *values[i], ts)
+		}
+		if !ok || previous == nil {
+			latest[ts] = values[i]
+		}
+	}
+
+	unique := make([]int64, 0, len(latest))
+	for ts := range latest {
+		unique = append(unique, ts)
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i] < unique[j] })
+
+	deduped := make([]*float64, len(unique))
+	for i, ts := range unique {
+		deduped[i] = latest[ts]
+	}
+	return unique, deduped, nil
+}
+
+// sameValue compares data point values, treating NaN values as equal.
+func sameValue(a, b float64) bool {
+	return a == b || math.IsNaN(a) && math.IsNaN(b)
+}
@@ -0,0 +1,163 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSplitMetricSelector(t *testing.T) {
+	selector := `builtin:host.cpu.usage:filter(and(in("dt.entity.host","HOST-1","HOST-2","HOST-3"),eq("os","li~"nux,(x)"))):splitBy("dt.entity.host")`
+
+	selectors, ok := splitMetricSelector(selector, 125)
+	if !ok {
+		t.Fatal("expected selector to be split")
+	}
+	want := []string{
+		`builtin:host.cpu.usage:filter(and(in("dt.entity.host","HOST-1","HOST-2"),eq("os","li~"nux,(x)"))):splitBy("dt.entity.host")`,
+		`builtin:host.cpu.usage:filter(and(in("dt.entity.host","HOST-3"),eq("os","li~"nux,(x)"))):splitBy("dt.entity.host")`,
+	}
+	if len(selectors) != len(want) {
+		t.Fatalf("selectors = %v", selectors)
+	}
+	for i := range want {
+		if selectors[i] != want[i] {
+			t.Errorf("selector %d = %s, want %s", i, selectors[i], want[i])
+		}
+	}
+
+	if _, ok := splitMetricSelector(`builtin:host.cpu.usage:filter(eq("os","linux"))`, 10); ok {
+		t.Error("selectors without in(...) cannot be split")
+	}
+	if _, ok := splitMetricSelector(`builtin:host.cpu.usage:filter(in("dt.entity.host","HOST-1"))`, 10); ok {
+		t.Error("in(...) with a single value cannot be split")
+	}
+
+	for _, aggregating := range []string{
+		`builtin:host.cpu.usage:filter(in("dt.entity.host","HOST-1","HOST-2","HOST-3")):merge("dt.entity.host")`,
+		`builtin:host.cpu.usage:filter(in("dt.entity.host","HOST-1","HOST-2","HOST-3")):splitBy():fold(avg)`,
+		`builtin:host.cpu.usage:filter(in("dt.entity.host","HOST-1","HOST-2","HOST-3")):splitBy()`,
+		`builtin:host.cpu.usage:filter(in("dt.entity.host","HOST-1","HOST-2","HOST-3")):splitBy("os")`,
+	} {
+		if _, ok := splitMetricSelector(aggregating, 80); ok {
+			t.Errorf("selector aggregating across the filtered dimension was split: %s", aggregating)
+		}
+	}
+	if _, ok := splitMetricSelector(`builtin:host.cpu.usage:filter(in("dt.entity.host","HOST-1","HOST-2","HOST-3")):splitBy("os","dt.entity.host"):avg`, 105); !ok {
+		t.Error("selector keeping the filtered dimension should be split")
+	}
+}
+
+func TestQueryMetricsSplitsLongSelector(t *testing.T) {
+	ids := make([]string, 2000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf(`"HOST-%016d"`, i)
+	}
+	selector := fmt.Sprintf(`builtin:host.cpu.usage:filter(in("dt.entity.host",%s))`, strings.Join(ids, ","))
+
+	requests := 0
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		if err := req.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(req.Form.Get("metricSelector")); got > maxMetricSelectorLength {
+			t.Errorf("selector of %d characters was not split", got)
+		}
+		_, _ = rw.Write([]byte(fmt.Sprintf(`{"totalCount": 1, "result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {"dt.entity.host": "HOST-%d"}, "timestamps": [1000], "values": [1.5]}
+		]}]}`, requests)))
+	})
+
+	resp, err := ds.queryDynatraceAPI(context.Background(), selector, 1000, 2000, "5m", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests < 2 {
+		t.Fatalf("expected several requests, got %d", requests)
+	}
+	if len(resp.Result) != 1 || len(resp.Result[0].Data) != requests || resp.TotalCount != requests {
+		t.Errorf("expected series of all requests to be merged, got %+v", resp)
+	}
+}
+
+func TestMergeMetricsResponsesDeduplicatesTimestamps(t *testing.T) {
+	page := func(values string) *DynatraceMetricsResponse {
+		var resp DynatraceMetricsResponse
+		body := fmt.Sprintf(`{"result": [{"metricId": "m", "data": [{"dimensionMap": {"host": "a"}, %s}]}]}`, values)
+		if err := json.Unmarshal([]byte(body), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return &resp
+	}
+
+	merged, err := mergeMetricsResponses([]*DynatraceMetricsResponse{
+		page(`"timestamps": [2000, 1000, 3000], "values": [2, 1, 3]`),
+		page(`"timestamps": [3000, 4000], "values": [3, 4]`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(merged.Result) != 1 || len(merged.Result[0].Data) != 1 || merged.TotalCount != 1 {
+		t.Fatalf("expected a single merged series, got %+v", merged)
+	}
+	series := merged.Result[0].Data[0]
+	if fmt.Sprint(series.Timestamps) != "[1000 2000 3000 4000]" || formatValues(series.Values) != "[1 2 3 4]" {
+		t.Errorf("merged series = %v %s, want sorted unique timestamps", series.Timestamps, formatValues(series.Values))
+	}
+
+	if _, err := mergeMetricsResponses([]*DynatraceMetricsResponse{
+		page(`"timestamps": [2000, 3000], "values": [2, 3]`),
+		page(`"timestamps": [3000, 4000], "values": [30, 4]`),
+	}); err == nil || !strings.Contains(err.Error(), "conflicting values 3 and 30 at 3000") {
+		t.Errorf("expected an error for conflicting values, got %v", err)
+	}
+
+	// A later window fills in an interval that was still empty
+	merged, err = mergeMetricsResponses([]*DynatraceMetricsResponse{
+		page(`"timestamps": [2000, 3000], "values": [2, null]`),
+		page(`"timestamps": [3000, 4000], "values": [3, null]`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := formatValues(merged.Result[0].Data[0].Values); got != "[2 3 null]" {
+		t.Errorf("merged values = %s, want the null replaced", got)
+	}
+}
+
+func TestQueryMetricsSplitSelectorMergesSharedSeries(t *testing.T) {
+	ids := make([]string, 2000)
+	for i := range ids {
+		ids[i] = fmt.Sprintf(`"HOST-%016d"`, i)
+	}
+	selector := fmt.Sprintf(`builtin:host.cpu.usage:filter(in("dt.entity.host",%s)):splitBy("os","dt.entity.host")`, strings.Join(ids, ","))
+
+	requests := 0
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		// Every request returns the same series, e.g. hosts reported under
+		// an entity that matches several filter values
+		_, _ = rw.Write([]byte(`{"totalCount": 1, "result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {"os": "linux", "dt.entity.host": "HOST-1"}, "timestamps": [1000, 2000], "values": [1.5, 2.5]}
+		]}]}`))
+	})
+
+	resp, err := ds.queryDynatraceAPI(context.Background(), selector, 1000, 3000, "1s", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests < 2 {
+		t.Fatalf("expected several requests, got %d", requests)
+	}
+	if len(resp.Result) != 1 || len(resp.Result[0].Data) != 1 || resp.TotalCount != 1 {
+		t.Fatalf("expected the series returned by every request to be merged, got %+v", resp)
+	}
+	if series := resp.Result[0].Data[0]; fmt.Sprint(series.Timestamps) != "[1000 2000]" || formatValues(series.Values) != "[1.5 2.5]" {
+		t.Errorf("merged series = %v %s, want each point once", series.Timestamps, formatValues(series.Values))
+	}
+}
@@ -0,0 +1,174 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Service metrics used as node and edge statistics. Response time is
+// reported in microseconds.
+const (
+	serviceRequestCountMetric = "builtin:service.requestCount.total"
+	serviceResponseTimeMetric = "builtin:service.response.time"
+)
+
+// serviceStats holds the request count and average response time (ms) of a service.
+type serviceStats struct {
+	requests       float64
+	responseTimeMs float64
+}
+
+// queryServiceFlow builds a node graph around a service: its callers and the
+// services it calls, taken from the entity topology, with request counts and
+// response times from service metrics. Edges carry the statistics of the
+// called service.
+func (d *Datasource) queryServiceFlow(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	if qm.ServiceId == "" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "serviceId is required")
+	}
+
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	services, err := d.fetchEntities(ctx, entityIdSelector([]string{qm.ServiceId}), "fromRelationships.calls,toRelationships.calls", fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace entities: %v", err))
+	}
+	if len(services) == 0 {
+		return backend.ErrDataResponse(backend.StatusNotFound, fmt.Sprintf("service %s not found", qm.ServiceId))
+	}
+	service := services[0]
+
+	var edges [][2]string
+	ids := []string{service.EntityId}
+	for _, caller := range service.ToRelationships["calls"] {
+		edges = append(edges, [2]string{caller.Id, service.EntityId})
+		ids = append(ids, caller.Id)
+	}
+	for _, callee := range service.FromRelationships["calls"] {
+		edges = append(edges, [2]string{service.EntityId, callee.Id})
+		ids = append(ids, callee.Id)
+	}
+	ids = uniqueStrings(ids)
+
+	entities, err := d.fetchEntitiesById(ctx, ids, "", fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace entities: %v", err))
+	}
+
+	stats, err := d.serviceStats(ctx, ids, fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace API: %v", err))
+	}
+
+	nodes, edgesFrame := serviceFlowFrames(service.EntityId, ids, edges, entities, stats)
+	return backend.DataResponse{Frames: data.Frames{nodes, edgesFrame}}
+}
+
+// metricSelectorString quotes s as a metric selector string literal, in which
+// quotes and tildes are escaped with a tilde.
+func metricSelectorString(s string) string {
+	s = strings.ReplaceAll(s, "~", "~~")
+	s = strings.ReplaceAll(s, `"`, `~"`)
+	return `"` + s + `"`
+}
+
+// serviceStats fetches the total request count and average response time of
+// each service over the whole time range.
+func (d *Datasource) serviceStats(ctx context.Context, ids []string, fromMs, toMs int64) (map[string]serviceStats, error) {
+	filter := fmt.Sprintf(`:filter(in("dt.entity.service",entitySelector(%s))):splitBy("dt.entity.service")`,
+		metricSelectorString("type(SERVICE),"+entityIdSelector(ids)))
+	selector := fmt.Sprintf("%s%s:fold(sum),%s%s:fold(avg)", serviceRequestCountMetric, filter, serviceResponseTimeMetric, filter)
+
+	resp, err := d.queryDynatraceAPI(ctx, selector, fromMs, toMs, "Inf", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]serviceStats{}
+	for _, result := range resp.Result {
+		for _, dataSet := range result.Data {
+			id := dataSet.DimensionMap["dt.entity.service"]
+			if id == "" || len(dataSet.Values) == 0 || dataSet.Values[0] == nil {
+				continue
+			}
+			s := stats[id]
+			switch {
+			case strings.HasPrefix(result.MetricId, serviceRequestCountMetric):
+				s.requests = *dataSet.Values[0]
+			case strings.HasPrefix(result.MetricId, serviceResponseTimeMetric):
+				s.responseTimeMs = *dataSet.Values[0] / 1000
+			}
+			stats[id] = s
+		}
+	}
+	return stats, nil
+}
+
+// serviceFlowFrames builds the nodes and edges frames consumed by Grafana's node graph panel.
+func serviceFlowFrames(rootId string, ids []string, edges [][2]string, entities map[string]DynatraceEntity, stats map[string]serviceStats) (*data.Frame, *data.Frame) {
+	nodes := data.NewFrame("nodes",
+		data.NewField("id", nil, []string{}),
+		data.NewField("title", nil, []string{}),
+		data.NewField("subTitle", nil, []string{}),
+		data.NewField("mainStat", nil, []float64{}).SetConfig(&data.FieldConfig{DisplayName: "Requests"}),
+		data.NewField("secondaryStat", nil, []float64{}).SetConfig(&data.FieldConfig{DisplayName: "Response time", Unit: "ms"}),
+	)
+	for _, id := range ids {
+		title := entities[id].DisplayName
+		if title == "" {
+			title = id
+		}
+		subTitle := ""
+		if id == rootId {
+			subTitle = "selected service"
+		}
+		nodes.AppendRow(id, title, subTitle, stats[id].requests, stats[id].responseTimeMs)
+	}
+
+	edgesFrame := data.NewFrame("edges",
+		data.NewField("id", nil, []string{}),
+		data.NewField("source", nil, []string{}),
+		data.NewField("target", nil, []string{}),
+		data.NewField("mainStat", nil, []float64{}).SetConfig(&data.FieldConfig{DisplayName: "Requests"}),
+		data.NewField("secondaryStat", nil, []float64{}).SetConfig(&data.FieldConfig{DisplayName: "Response time", Unit: "ms"}),
+	)
+	for _, e := range edges {
+		target := stats[e[1]]
+		edgesFrame.AppendRow(e[0]+"->"+e[1], e[0], e[1], target.requests, target.responseTimeMs)
+	}
+
+	for _, frame := range []*data.Frame{nodes, edgesFrame} {
+		frame.Meta = &data.FrameMeta{
+			PreferredVisualization: data.VisTypeNodeGraph,
+			ExecutedQueryString:    fmt.Sprintf("Service flow: %s", rootId),
+		}
+	}
+
+	return nodes, edgesFrame
+}
+
+// uniqueStrings returns values without duplicates, keeping the first
+// occurrence of each and sorting the remainder after the first element.
+func uniqueStrings(values []string) []string {
+	if len(values) == 0 {
+		return values
+	}
+	seen := map[string]bool{values[0]: true}
+	var rest []string
+	for _, v := range values[1:] {
+		if !seen[v] {
+			seen[v] = true
+			rest = append(rest, v)
+		}
+	}
+	sort.Strings(rest)
+	return append([]string{values[0]}, rest...)
+}
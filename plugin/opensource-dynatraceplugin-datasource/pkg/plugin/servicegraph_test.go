@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryServiceFlow(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v2/entities":
+			if req.URL.Query().Get("fields") != "" {
+				_, _ = rw.Write([]byte(`{"entities": [{"entityId": "SERVICE-B",
+					"toRelationships": {"calls": [{"id": "SERVICE-A", "type": "SERVICE"}]},
+					"fromRelationships": {"calls": [{"id": "SERVICE-C", "type": "SERVICE"}]}}]}`))
+				return
+			}
+			_, _ = rw.Write([]byte(`{"entities": [
+				{"entityId": "SERVICE-A", "displayName": "frontend"},
+				{"entityId": "SERVICE-B", "displayName": "checkout"},
+				{"entityId": "SERVICE-C", "displayName": "payments"}
+			]}`))
+		case "/api/v2/metrics/query":
+			filter := `:filter(in("dt.entity.service",entitySelector("type(SERVICE),entityId(~"SERVICE-B~",~"SERVICE-A~",~"SERVICE-C~")"))):splitBy("dt.entity.service")`
+			want := "builtin:service.requestCount.total" + filter + ":fold(sum),builtin:service.response.time" + filter + ":fold(avg)"
+			if got := req.URL.Query().Get("metricSelector"); got != want {
+				t.Errorf("metricSelector = %s, want %s", got, want)
+			}
+			_, _ = rw.Write([]byte(`{"result": [
+				{"metricId": "builtin:service.requestCount.total:filter(x):fold(sum)", "data": [
+					{"dimensionMap": {"dt.entity.service": "SERVICE-B"}, "values": [100]},
+					{"dimensionMap": {"dt.entity.service": "SERVICE-C"}, "values": [40]}
+				]},
+				{"metricId": "builtin:service.response.time:filter(x):fold(avg)", "data": [
+					{"dimensionMap": {"dt.entity.service": "SERVICE-C"}, "values": [2500]}
+				]}
+			]}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeServiceFlow,
+		JSON:      []byte(`{"useDashboardTime": true, "serviceId": "SERVICE-B"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	nodes, edges := resp.Frames[0], resp.Frames[1]
+	if rows, _ := nodes.RowLen(); rows != 3 {
+		t.Fatalf("expected 3 nodes, got %d", rows)
+	}
+	if title, _ := nodes.FieldByName("title"); title.At(0) != "checkout" {
+		t.Errorf("root node title = %v", title.At(0))
+	}
+
+	if rows, _ := edges.RowLen(); rows != 2 {
+		t.Fatalf("expected 2 edges, got %d", rows)
+	}
+	id, _ := edges.FieldByName("id")
+	mainStat, _ := edges.FieldByName("mainStat")
+	secondaryStat, _ := edges.FieldByName("secondaryStat")
+	if id.At(1) != "SERVICE-B->SERVICE-C" || mainStat.At(1) != 40.0 || secondaryStat.At(1) != 2.5 {
+		t.Errorf("unexpected outgoing edge %v %v %v", id.At(1), mainStat.At(1), secondaryStat.At(1))
+	}
+}
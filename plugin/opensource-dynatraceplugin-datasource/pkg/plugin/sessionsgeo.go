@@ -0,0 +1,110 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Countries or regions returned by the "sessionsGeo" query type. USQL returns
+// only 50 rows without a LIMIT and accepts at most 5000.
+const (
+	defaultGeoLimit = 1000
+	maxGeoLimit     = 5000
+)
+
+// querySessionsGeo counts user sessions and user actions per country, or
+// per region with geoGroupBy "region", for the geomap panel. Countries
+// carry their ISO 3166-1 alpha-2 code, which the geomap panel looks up.
+// The geoLimit countries or regions with the most sessions are returned.
+func (d *Datasource) querySessionsGeo(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	groupBy := "country"
+	switch qm.GeoGroupBy {
+	case "", "country":
+	case "region":
+		groupBy = "country, region"
+	default:
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid geoGroupBy %q, expected country or region", qm.GeoGroupBy))
+	}
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	limit := qm.GeoLimit
+	if limit <= 0 {
+		limit = defaultGeoLimit
+	}
+	if limit > maxGeoLimit {
+		limit = maxGeoLimit
+	}
+
+	usqlQuery := fmt.Sprintf("SELECT %s, COUNT(*) AS sessions, SUM(userActionCount) AS userActions FROM usersession GROUP BY %s ORDER BY COUNT(*) DESC LIMIT %d", groupBy, groupBy, limit)
+	result, err := d.fetchUSQL(ctx, usqlQuery, fromMs, toMs, limit)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace user sessions: %v", err))
+	}
+
+	frame := sessionsGeoFrame(result, qm.GeoGroupBy == "region")
+	frame.Meta = &data.FrameMeta{ExecutedQueryString: usqlQuery}
+	if len(result.Values) >= limit {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("Only the %d %s with the most sessions are shown; raise geoLimit (at most %d) to see more", limit, geoGroupNoun(qm.GeoGroupBy), maxGeoLimit),
+		})
+	}
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// geoGroupNoun names the rows of a sessions by geography query.
+func geoGroupNoun(groupBy string) string {
+	if groupBy == "region" {
+		return "regions"
+	}
+	return "countries"
+}
+
+// sessionsGeoFrame converts the rows of a sessions by geography query into a
+// frame with the country code next to the country name.
+func sessionsGeoFrame(result *DynatraceUSQLResult, withRegion bool) *data.Frame {
+	countries := []string{}
+	codes := []string{}
+	regions := []string{}
+	sessions := []float64{}
+	actions := []float64{}
+	for _, row := range result.Values {
+		values := row
+		var region string
+		if withRegion && len(values) == 4 {
+			region, _ = values[1].(string)
+			values = append(values[:1:1], values[2:]...)
+		}
+		if len(values) != 3 {
+			continue
+		}
+		country, _ := values[0].(string)
+		count, _ := values[1].(float64)
+		actionCount, _ := values[2].(float64)
+
+		countries = append(countries, country)
+		codes = append(codes, countryCode(country))
+		regions = append(regions, region)
+		sessions = append(sessions, count)
+		actions = append(actions, actionCount)
+	}
+
+	frame := data.NewFrame("sessions",
+		data.NewField("country", nil, countries),
+		data.NewField("countryCode", nil, codes),
+	)
+	if withRegion {
+		frame.Fields = append(frame.Fields, data.NewField("region", nil, regions))
+	}
+	frame.Fields = append(frame.Fields,
+		data.NewField("sessions", nil, sessions),
+		data.NewField("userActions", nil, actions),
+	)
+	return frame
+}
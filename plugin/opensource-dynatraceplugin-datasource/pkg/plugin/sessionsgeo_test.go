@@ -0,0 +1,82 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQuerySessionsGeo(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got := req.URL.Query().Get("query"); !strings.Contains(got, "GROUP BY country, region ORDER BY COUNT(*) DESC LIMIT 3") {
+			t.Errorf("query = %q, want grouping by region limited to 3 rows", got)
+		}
+		if got := req.URL.Query().Get("pageSize"); got != "3" {
+			t.Errorf("pageSize = %q, want 3", got)
+		}
+		_, _ = rw.Write([]byte(`{"columnNames": ["country", "region", "sessions", "userActions"], "values": [
+			["Austria", "Upper Austria", 120, 940],
+			["United States", "Oregon", 80, 310],
+			["Atlantis", "", 1, 2]
+		]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSessionsGeo,
+		JSON:      []byte(`{"useDashboardTime": true, "geoGroupBy": "region", "geoLimit": 3}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	want := []string{"country", "countryCode", "region", "sessions", "userActions"}
+	for i, name := range want {
+		if frame.Fields[i].Name != name {
+			t.Errorf("field %d = %s, want %s", i, frame.Fields[i].Name, name)
+		}
+	}
+	codes := frame.Fields[1]
+	if codes.At(0) != "AT" || codes.At(1) != "US" || codes.At(2) != "" {
+		t.Errorf("country codes = %v, %v, %v", codes.At(0), codes.At(1), codes.At(2))
+	}
+	if got := frame.Fields[3].At(1); got != 80.0 {
+		t.Errorf("sessions = %v, want 80", got)
+	}
+	if frame.Meta == nil || len(frame.Meta.Notices) != 1 || !strings.Contains(frame.Meta.Notices[0].Text, "Only the 3 regions") {
+		t.Errorf("expected a notice that the limit was reached, got %+v", frame.Meta)
+	}
+}
+
+func TestQuerySessionsGeoLimit(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got := req.URL.Query().Get("query"); !strings.HasSuffix(got, "GROUP BY country ORDER BY COUNT(*) DESC LIMIT 5000") {
+			t.Errorf("query = %q, want the limit capped at 5000", got)
+		}
+		_, _ = rw.Write([]byte(`{"columnNames": ["country", "sessions", "userActions"], "values": [["Austria", 120, 940]]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSessionsGeo,
+		JSON:      []byte(`{"useDashboardTime": true, "geoLimit": 100000}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if notices := resp.Frames[0].Meta.Notices; len(notices) != 0 {
+		t.Errorf("unexpected notices %+v", notices)
+	}
+}
+
+func TestCountryCode(t *testing.T) {
+	for name, want := range map[string]string{"Germany": "DE", " united kingdom ": "GB", "Czechia": "CZ", "Nowhere": ""} {
+		if got := countryCode(name); got != want {
+			t.Errorf("countryCode(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
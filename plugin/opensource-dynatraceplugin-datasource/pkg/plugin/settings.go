@@ -0,0 +1,180 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// DynatraceSettingsObjectsResponse represents a page of /api/v2/settings/objects
+type DynatraceSettingsObjectsResponse struct {
+	TotalCount  int                       `json:"totalCount"`
+	PageSize    int                       `json:"pageSize"`
+	NextPageKey *string                   `json:"nextPageKey"`
+	Items       []DynatraceSettingsObject `json:"items"`
+}
+
+type DynatraceSettingsObject struct {
+	ObjectId      string          `json:"objectId"`
+	SchemaId      string          `json:"schemaId"`
+	SchemaVersion string          `json:"schemaVersion"`
+	Scope         string          `json:"scope"`
+	Summary       string          `json:"summary"`
+	Value         json.RawMessage `json:"value"`
+}
+
+// settingsObjectFields are requested so objects can be told apart across schemas and scopes.
+const settingsObjectFields = "objectId,schemaId,schemaVersion,scope,summary,value"
+
+// fetchSettingsObjects walks all pages of /api/v2/settings/objects for a
+// schema, optionally restricted to the given scopes.
+func (d *Datasource) fetchSettingsObjects(ctx context.Context, schemaId string, scopes []string) ([]DynatraceSettingsObject, error) {
+	params := url.Values{}
+	params.Set("schemaIds", schemaId)
+	params.Set("fields", settingsObjectFields)
+	params.Set("pageSize", "500")
+	if len(scopes) > 0 {
+		params.Set("scopes", strings.Join(scopes, ","))
+	}
+
+	log.DefaultLogger.Debug("Querying Dynatrace settings objects", "schemaId", schemaId, "scopes", scopes)
+
+	var objects []DynatraceSettingsObject
+	err := d.getAllPages(ctx, "/api/v2/settings/objects", params, func(body []byte) error {
+		var page DynatraceSettingsObjectsResponse
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		objects = append(objects, page.Items...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// querySettings returns the settings objects of a schema as a table with one
+// column per top-level property of the object values.
+func (d *Datasource) querySettings(ctx context.Context, qm queryModel) backend.DataResponse {
+	if qm.SettingsSchemaId == "" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "settingsSchemaId is required")
+	}
+
+	objects, err := d.fetchSettingsObjects(ctx, qm.SettingsSchemaId, settingsScopes(qm.SettingsScope))
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace settings: %v", err))
+	}
+
+	frame, err := settingsFrame(objects)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, err.Error())
+	}
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		ExecutedQueryString:    fmt.Sprintf("Settings: %s %s", qm.SettingsSchemaId, qm.SettingsScope),
+	}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// handleSettingsObjects serves GET /settings/objects?schemaId=...&scope=...,
+// returning the raw settings objects.
+func (d *Datasource) handleSettingsObjects(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	schemaId := req.URL.Query().Get("schemaId")
+	if schemaId == "" {
+		writeError(rw, http.StatusBadRequest, "schemaId is required")
+		return
+	}
+
+	objects, err := d.fetchSettingsObjects(req.Context(), schemaId, settingsScopes(req.URL.Query().Get("scope")))
+	if err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+	if objects == nil {
+		objects = []DynatraceSettingsObject{}
+	}
+
+	writeJSON(rw, http.StatusOK, objects)
+}
+
+// settingsScopes splits a comma separated list of scopes, e.g. "environment,HOST-1".
+func settingsScopes(scope string) []string {
+	var scopes []string
+	for _, s := range strings.Split(scope, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// settingsFrame flattens settings objects into a table. Top-level string
+// properties are shown as-is, any other property as its JSON representation.
+func settingsFrame(objects []DynatraceSettingsObject) (*data.Frame, error) {
+	values := make([]map[string]json.RawMessage, len(objects))
+	keySet := map[string]bool{}
+	for i, o := range objects {
+		if err := json.Unmarshal(o.Value, &values[i]); err != nil {
+			return nil, fmt.Errorf("error decoding settings object %s: %w", o.ObjectId, err)
+		}
+		for key := range values[i] {
+			keySet[key] = true
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	objectIds := make([]string, len(objects))
+	scopes := make([]string, len(objects))
+	summaries := make([]string, len(objects))
+	columns := make([][]*string, len(keys))
+	for k := range keys {
+		columns[k] = make([]*string, len(objects))
+	}
+
+	for i, o := range objects {
+		objectIds[i] = o.ObjectId
+		scopes[i] = o.Scope
+		summaries[i] = o.Summary
+		for k, key := range keys {
+			raw, ok := values[i][key]
+			if !ok {
+				continue
+			}
+			value := string(raw)
+			var s string
+			if json.Unmarshal(raw, &s) == nil {
+				value = s
+			}
+			columns[k][i] = &value
+		}
+	}
+
+	frame := data.NewFrame("settings",
+		data.NewField("objectId", nil, objectIds),
+		data.NewField("scope", nil, scopes),
+		data.NewField("summary", nil, summaries),
+	)
+	for k, key := range keys {
+		frame.Fields = append(frame.Fields, data.NewField(key, nil, columns[k]))
+	}
+	return frame, nil
+}
@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQuerySettings(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/settings/objects" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		if got := req.URL.Query().Get("scopes"); got != "HOST-1,HOST-2" {
+			t.Errorf("scopes = %q", got)
+		}
+		_, _ = rw.Write([]byte(`{"items": [
+			{"objectId": "obj-1", "scope": "HOST-1", "value": {"updateMode": "AUTOMATIC", "maintenanceWindows": [{"name": "weekend"}]}},
+			{"objectId": "obj-2", "scope": "HOST-2", "value": {"updateMode": "MANUAL", "targetVersion": "1.281"}}
+		]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSettings,
+		JSON:      []byte(`{"settingsSchemaId": "builtin:deployment.oneagent.updates", "settingsScope": "HOST-1, HOST-2"}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	mode, _ := frame.FieldByName("updateMode")
+	if mode == nil || *mode.At(1).(*string) != "MANUAL" {
+		t.Fatalf("expected string properties as-is")
+	}
+	windows, _ := frame.FieldByName("maintenanceWindows")
+	if got := *windows.At(0).(*string); got != `[{"name": "weekend"}]` {
+		t.Errorf("expected nested properties as JSON, got %s", got)
+	}
+	version, _ := frame.FieldByName("targetVersion")
+	if version.At(0).(*string) != nil {
+		t.Errorf("missing properties should be null")
+	}
+}
+
+func TestQuerySettingsRequiresSchema(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s", req.URL.Path)
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSettings,
+		JSON:      []byte(`{}`),
+	})
+	if resp.Status != backend.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.Status, backend.StatusBadRequest)
+	}
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/settings/objects", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected missing schemaId to be rejected, got %d", rec.Code)
+	}
+}
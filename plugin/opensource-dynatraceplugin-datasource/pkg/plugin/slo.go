@@ -0,0 +1,140 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// DynatraceSLO is a service-level objective evaluated by /api/v2/slo/{id}
+type DynatraceSLO struct {
+	Id                  string  `json:"id"`
+	Name                string  `json:"name"`
+	Status              string  `json:"status"`
+	Target              float64 `json:"target"`
+	Warning             float64 `json:"warning"`
+	EvaluatedPercentage float64 `json:"evaluatedPercentage"`
+	ErrorBudget         float64 `json:"errorBudget"`
+	MetricExpression    string  `json:"metricExpression"`
+	Error               string  `json:"error"`
+}
+
+// querySLO evaluates an SLO over the query time range. By default it
+// returns the attainment, status and error budget as a single row for stat
+// and gauge panels. With sloFormat "timeseries" the SLO's metric expression
+// is queried instead, giving the attainment of every resolution slice.
+func (d *Datasource) querySLO(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	if qm.SloId == "" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "sloId is required")
+	}
+	if qm.SloFormat != "" && qm.SloFormat != "timeseries" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid sloFormat %q, expected timeseries", qm.SloFormat))
+	}
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	slo, err := d.fetchSLO(ctx, qm.SloId, fromMs, toMs)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace SLO: %v", err))
+	}
+
+	if qm.SloFormat != "timeseries" {
+		frame := sloFrame(slo)
+		frame.Meta = &data.FrameMeta{ExecutedQueryString: fmt.Sprintf("SLO: %s", slo.Name)}
+		if slo.Error != "" && slo.Error != "NONE" {
+			frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityWarning, Text: fmt.Sprintf("SLO evaluation error: %s", slo.Error)})
+		}
+		return backend.DataResponse{Frames: data.Frames{frame}}
+	}
+
+	if slo.MetricExpression == "" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("SLO %s has no metric expression to evaluate over time", slo.Name))
+	}
+	resolution := qm.Resolution
+	if resolution == "" {
+		resolution = "5m"
+	}
+	resp, err := d.queryDynatraceAPI(ctx, slo.MetricExpression, fromMs, toMs, resolution, nil)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace API: %v", err))
+	}
+	frames := sloSeriesFrames(slo, resp)
+	for _, frame := range frames {
+		frame.Meta = &data.FrameMeta{ExecutedQueryString: slo.MetricExpression}
+	}
+	return backend.DataResponse{Frames: frames}
+}
+
+// fetchSLO evaluates an SLO over the given time range.
+func (d *Datasource) fetchSLO(ctx context.Context, id string, fromMs, toMs int64) (*DynatraceSLO, error) {
+	params := url.Values{}
+	params.Set("from", fmt.Sprintf("%d", fromMs))
+	params.Set("to", fmt.Sprintf("%d", toMs))
+	// Evaluate over from and to instead of the SLO's own timeframe
+	params.Set("timeFrame", "GTF")
+
+	log.DefaultLogger.Debug("Querying Dynatrace SLO", "id", id, "from", fromMs, "to", toMs)
+
+	var slo DynatraceSLO
+	if err := d.get(ctx, "/api/v2/slo/"+url.PathEscape(id), params, &slo); err != nil {
+		return nil, err
+	}
+	return &slo, nil
+}
+
+// sloFrame converts an SLO evaluation into a single row frame.
+func sloFrame(slo *DynatraceSLO) *data.Frame {
+	attainment := data.NewField("evaluatedPercentage", nil, []float64{slo.EvaluatedPercentage})
+	attainment.Config = &data.FieldConfig{DisplayNameFromDS: slo.Name, Unit: "percent", Thresholds: sloThresholds(slo)}
+	errorBudget := data.NewField("errorBudget", nil, []float64{slo.ErrorBudget})
+	errorBudget.Config = &data.FieldConfig{Unit: "percent"}
+	return data.NewFrame("slo",
+		attainment,
+		errorBudget,
+		data.NewField("status", nil, []string{slo.Status}),
+		data.NewField("target", nil, []float64{slo.Target}),
+		data.NewField("warning", nil, []float64{slo.Warning}),
+		data.NewField("name", nil, []string{slo.Name}),
+	)
+}
+
+// sloSeriesFrames converts the series of an SLO metric expression into
+// attainment frames, one per series.
+func sloSeriesFrames(slo *DynatraceSLO, resp *DynatraceMetricsResponse) data.Frames {
+	var frames data.Frames
+	for _, result := range resp.Result {
+		for _, dataSet := range result.Data {
+			times := make([]time.Time, len(dataSet.Timestamps))
+			for i, ts := range dataSet.Timestamps {
+				times[i] = time.UnixMilli(ts)
+			}
+			values := data.NewField("evaluatedPercentage", data.Labels(dataSet.DimensionMap), dataSet.Values)
+			values.Config = &data.FieldConfig{DisplayNameFromDS: slo.Name, Unit: "percent", Thresholds: sloThresholds(slo)}
+			frames = append(frames, data.NewFrame("slo", data.NewField("time", nil, times), values))
+		}
+	}
+	return frames
+}
+
+// sloThresholds colors attainment like Dynatrace does: red below the
+// target, orange between the target and the warning value, green above.
+// SLOs without a warning value above the target are only red and green.
+func sloThresholds(slo *DynatraceSLO) *data.ThresholdsConfig {
+	steps := []data.Threshold{
+		data.NewThreshold(math.Inf(-1), "red", ""),
+		data.NewThreshold(slo.Target, "green", ""),
+	}
+	if slo.Warning > slo.Target {
+		steps[1].Color = "orange"
+		steps = append(steps, data.NewThreshold(slo.Warning, "green", ""))
+	}
+	return &data.ThresholdsConfig{Mode: data.ThresholdsModeAbsolute, Steps: steps}
+}
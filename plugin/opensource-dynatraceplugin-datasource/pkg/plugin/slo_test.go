@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const testSLO = `{"id": "slo-1", "name": "Checkout availability", "status": "WARNING", "target": 99.5, "warning": 99.8,
+	"evaluatedPercentage": 99.6, "errorBudget": 0.1, "error": "NONE",
+	"metricExpression": "(100)*(builtin:service.errors.server.successCount:splitBy())/(builtin:service.requestCount.server:splitBy())"}`
+
+func TestQuerySLO(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/slo/slo-1" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		if got := req.URL.Query().Get("timeFrame"); got != "GTF" {
+			t.Errorf("timeFrame = %q, want GTF", got)
+		}
+		_, _ = rw.Write([]byte(testSLO))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSLO,
+		JSON:      []byte(`{"useDashboardTime": true, "sloId": "slo-1"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	if frame.Rows() != 1 {
+		t.Fatalf("rows = %d, want 1", frame.Rows())
+	}
+	if got := frame.Fields[0].At(0); got != 99.6 {
+		t.Errorf("evaluatedPercentage = %v, want 99.6", got)
+	}
+	if frame.Fields[0].Config.DisplayNameFromDS != "Checkout availability" {
+		t.Errorf("display name = %q", frame.Fields[0].Config.DisplayNameFromDS)
+	}
+
+	steps := frame.Fields[0].Config.Thresholds.Steps
+	if len(steps) != 3 {
+		t.Fatalf("expected red, orange and green steps, got %+v", steps)
+	}
+	if steps[1].Value != 99.5 || steps[1].Color != "orange" || steps[2].Value != 99.8 || steps[2].Color != "green" {
+		t.Errorf("steps = %+v, want orange from the target and green from the warning value", steps)
+	}
+}
+
+func TestSLOThresholdsWithoutWarning(t *testing.T) {
+	steps := sloThresholds(&DynatraceSLO{Target: 95}).Steps
+	if len(steps) != 2 || steps[1].Value != 95 || steps[1].Color != "green" {
+		t.Errorf("steps = %+v, want red below and green from the target", steps)
+	}
+}
+
+func TestQuerySLOTimeSeries(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v2/slo/slo-1":
+			_, _ = rw.Write([]byte(testSLO))
+		case "/api/v2/metrics/query":
+			if got := req.URL.Query().Get("metricSelector"); got != "(100)*(builtin:service.errors.server.successCount:splitBy())/(builtin:service.requestCount.server:splitBy())" {
+				t.Errorf("metricSelector = %q", got)
+			}
+			if got := req.URL.Query().Get("resolution"); got != "1h" {
+				t.Errorf("resolution = %q, want 1h", got)
+			}
+			_, _ = rw.Write([]byte(`{"result": [{"metricId": "(100)*...", "data": [
+				{"dimensionMap": {}, "timestamps": [1000, 2000], "values": [99.9, 98.5]}
+			]}]}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSLO,
+		JSON:      []byte(`{"useDashboardTime": true, "sloId": "slo-1", "sloFormat": "timeseries", "resolution": "1h"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	values := resp.Frames[0].Fields[1]
+	if v, _ := values.FloatAt(1); values.Len() != 2 || v != 98.5 {
+		t.Errorf("attainment = %v, want two slices ending at 98.5", values)
+	}
+	if values.Config.Unit != "percent" {
+		t.Errorf("unit = %q, want percent", values.Config.Unit)
+	}
+}
+
+func TestQuerySLORequiresId(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s", req.URL)
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSLO,
+		JSON:      []byte(`{"useDashboardTime": true}`),
+	})
+	if resp.Error == nil {
+		t.Fatal("expected an error without sloId")
+	}
+}
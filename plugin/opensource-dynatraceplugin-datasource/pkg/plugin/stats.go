@@ -0,0 +1,65 @@
+package plugin
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// datasourceStats holds the counters of a datasource instance reported by
+// the /stats resource route. They reset when Grafana recreates the instance,
+// e.g. after the datasource settings are saved.
+type datasourceStats struct {
+	queries       int64
+	queryErrors   int64
+	apiCalls      int64
+	activeStreams int64
+}
+
+// statsSnapshot is the JSON body of GET /stats.
+type statsSnapshot struct {
+	Queries           int64 `json:"queries"`
+	QueryErrors       int64 `json:"queryErrors"`
+	APICalls          int64 `json:"apiCalls"`
+	ActiveStreams     int64 `json:"activeStreams"`
+	ActiveRequests    int   `json:"activeRequests"`
+	QueuedRequests    int   `json:"queuedRequests"`
+	RateLimitWaits    int64 `json:"rateLimitWaits"`
+	RateLimited       int64 `json:"rateLimited"`
+	CoalescedRequests int64 `json:"coalescedRequests"`
+}
+
+// snapshot returns the current counters of the datasource.
+func (d *Datasource) snapshot() statsSnapshot {
+	limiter := d.limiter.snapshot()
+	return statsSnapshot{
+		Queries:           atomic.LoadInt64(&d.stats.queries),
+		QueryErrors:       atomic.LoadInt64(&d.stats.queryErrors),
+		APICalls:          atomic.LoadInt64(&d.stats.apiCalls),
+		ActiveStreams:     atomic.LoadInt64(&d.stats.activeStreams),
+		ActiveRequests:    limiter.active,
+		QueuedRequests:    limiter.queued,
+		RateLimitWaits:    limiter.waits,
+		RateLimited:       limiter.rejected,
+		CoalescedRequests: d.inflight.coalescedCount(),
+	}
+}
+
+// countQuery records an executed query and whether it failed.
+func (d *Datasource) countQuery(resp backend.DataResponse) {
+	atomic.AddInt64(&d.stats.queries, 1)
+	if resp.Error != nil {
+		atomic.AddInt64(&d.stats.queryErrors, 1)
+	}
+}
+
+// handleStats serves GET /stats with a snapshot of the instance counters.
+func (d *Datasource) handleStats(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(rw, http.StatusOK, d.snapshot())
+}
@@ -0,0 +1,44 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestHandleStats(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("problemSelector") != "" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = rw.Write([]byte(`{"problems": []}`))
+	})
+
+	for _, selector := range []string{``, `status("open")`} {
+		ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+			RefID:     "A",
+			QueryType: queryTypeProblems,
+			JSON:      []byte(`{"problemSelector": ` + strconv.Quote(selector) + `}`),
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	var stats statsSnapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Queries != 2 || stats.QueryErrors != 1 || stats.APICalls != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
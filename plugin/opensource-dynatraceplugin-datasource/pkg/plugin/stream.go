@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// logTailPathPrefix is the Grafana Live path prefix of log tailing channels.
+const logTailPathPrefix = "logs/tail/"
+
+// logTailInterval is how often a live tail polls for new log records.
+const logTailInterval = 5 * time.Second
+
+// logTailLookback is how far back the first poll of a live tail reaches.
+const logTailLookback = time.Minute
+
+// registerLogTail remembers the logs query behind a live tail channel and
+// returns the channel path. The path is derived from the query so that
+// panels showing the same query share one stream. The query is forgotten
+// when the stream stops; the next query of a panel registers it again.
+func (d *Datasource) registerLogTail(refId string, qm queryModel) string {
+	sum := sha256.Sum256([]byte(refId + "\x00" + qm.LogQuery))
+	path := logTailPathPrefix + hex.EncodeToString(sum[:8])
+	d.logTails.Store(path, qm)
+	return path
+}
+
+// logTailQuery returns the logs query for a tail channel, preferring the query
+// registered by QueryData and falling back to the query sent with the request.
+func (d *Datasource) logTailQuery(path string, raw json.RawMessage) (queryModel, bool) {
+	if qm, ok := d.logTails.Load(path); ok {
+		return qm.(queryModel), true
+	}
+	var qm queryModel
+	if len(raw) > 0 && json.Unmarshal(raw, &qm) == nil {
+		return qm, true
+	}
+	return queryModel{}, false
+}
+
+// SubscribeStream is called when a client wants to connect to a stream.
+func (d *Datasource) SubscribeStream(_ context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	// DQL progress channels may be subscribed before their query starts
+	if strings.HasPrefix(req.Path, dqlProgressPathPrefix) {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+	}
+	if !strings.HasPrefix(req.Path, logTailPathPrefix) {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+	if _, ok := d.logTailQuery(req.Path, req.Data); !ok {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+// PublishStream is called when a client sends a message to the stream. Log
+// tails are read-only; DQL progress channels accept cancellation requests.
+func (d *Datasource) PublishStream(_ context.Context, req *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	if strings.HasPrefix(req.Path, dqlProgressPathPrefix) {
+		var user string
+		if req.PluginContext.User != nil {
+			user = req.PluginContext.User.Login
+		}
+		return &backend.PublishStreamResponse{Status: d.publishDQLProgress(req.Path, user, req.Data)}, nil
+	}
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream is called once for every channel with subscribers and runs until
+// the last subscriber leaves. For log tails it polls the Logs API with a
+// moving cursor and pushes only records not sent before. DQL progress
+// channels report the state of their query until it is done.
+func (d *Datasource) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	if strings.HasPrefix(req.Path, dqlProgressPathPrefix) {
+		ctx, cancel := d.backgroundContext(ctx)
+		defer cancel()
+		return d.runDQLProgressStream(ctx, req.Path, sender)
+	}
+
+	qm, ok := d.logTailQuery(req.Path, req.Data)
+	if !ok {
+		return fmt.Errorf("unknown stream: %s", req.Path)
+	}
+	defer d.logTails.Delete(req.Path)
+
+	log.DefaultLogger.Info("Starting log tail", "path", req.Path, "query", d.logPayload(qm.LogQuery))
+
+	// Tails stop when the datasource instance is disposed
+	ctx, cancel := d.backgroundContext(ctx)
+	defer cancel()
+
+	atomic.AddInt64(&d.stats.activeStreams, 1)
+	defer atomic.AddInt64(&d.stats.activeStreams, -1)
+
+	// Polling yields to interactive queries when requests are limited
+	ctx = withPriority(ctx, priorityBackground)
+
+	cursor := newLogCursor(time.Now().Add(-logTailLookback).UnixMilli())
+	ticker := time.NewTicker(logTailInterval)
+	defer ticker.Stop()
+
+	for {
+		logsResp, err := d.fetchLogs(ctx, qm.LogQuery, cursor.from, time.Now().UnixMilli(), qm.LogLimit)
+		if err != nil {
+			log.DefaultLogger.Warn("Error polling logs for live tail", "path", req.Path, "error", d.logError(err))
+		} else if records := cursor.advance(logsResp.Results); len(records) > 0 {
+			frame := logsFrame(records, qm.LogJSONFields)
+			d.redactFrames(data.Frames{frame})
+			if err := sender.SendFrame(frame, data.IncludeAll); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.DefaultLogger.Info("Stopping log tail", "path", req.Path)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// logCursor tracks the position of a live tail. Polls restart at the newest
+// timestamp seen, so records sharing that timestamp are remembered to avoid
+// sending them twice.
+type logCursor struct {
+	from   int64
+	seenAt map[string]bool
+}
+
+func newLogCursor(from int64) *logCursor {
+	return &logCursor{from: from, seenAt: map[string]bool{}}
+}
+
+// advance returns the records that are new since the last poll and moves the
+// cursor to the newest timestamp. records must be in ascending order.
+func (c *logCursor) advance(records []DynatraceLogRecord) []DynatraceLogRecord {
+	var fresh []DynatraceLogRecord
+	for _, r := range records {
+		if r.Timestamp < c.from {
+			continue
+		}
+		key := r.Status + "\x00" + r.Content
+		if r.Timestamp == c.from && c.seenAt[key] {
+			continue
+		}
+		if r.Timestamp > c.from {
+			c.from = r.Timestamp
+			c.seenAt = map[string]bool{}
+		}
+		c.seenAt[key] = true
+		fresh = append(fresh, r)
+	}
+	return fresh
+}
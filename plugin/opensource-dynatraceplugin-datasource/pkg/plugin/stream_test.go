@@ -0,0 +1,71 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestLogCursorAdvance(t *testing.T) {
+	cursor := newLogCursor(1000)
+
+	fresh := cursor.advance([]DynatraceLogRecord{
+		{Timestamp: 900, Content: "too old"},
+		{Timestamp: 1000, Content: "a"},
+		{Timestamp: 2000, Content: "b"},
+		{Timestamp: 2000, Content: "c"},
+	})
+	if len(fresh) != 3 {
+		t.Fatalf("expected 3 new records, got %d", len(fresh))
+	}
+
+	// The next poll starts at the newest timestamp and returns it again
+	fresh = cursor.advance([]DynatraceLogRecord{
+		{Timestamp: 2000, Content: "b"},
+		{Timestamp: 2000, Content: "c"},
+		{Timestamp: 2000, Content: "d"},
+		{Timestamp: 3000, Content: "e"},
+	})
+	if len(fresh) != 2 || fresh[0].Content != "d" || fresh[1].Content != "e" {
+		t.Fatalf("unexpected records %+v", fresh)
+	}
+	if cursor.from != 3000 {
+		t.Errorf("cursor = %d, want 3000", cursor.from)
+	}
+}
+
+func TestSubscribeStream(t *testing.T) {
+	ds := &Datasource{}
+	path := ds.registerLogTail("A", queryModel{LogQuery: "status=\"ERROR\""})
+
+	resp, err := ds.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != backend.SubscribeStreamStatusOK {
+		t.Errorf("status = %v, want OK", resp.Status)
+	}
+
+	resp, _ = ds.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{Path: "unknown"})
+	if resp.Status != backend.SubscribeStreamStatusNotFound {
+		t.Errorf("status = %v, want NotFound", resp.Status)
+	}
+}
+
+func TestRunStreamForgetsLogTail(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"results": []}`))
+	})
+	path := ds.registerLogTail("A", queryModel{LogQuery: "status=\"ERROR\""})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ds.RunStream(ctx, &backend.RunStreamRequest{Path: path}, backend.NewStreamSender(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ds.logTails.Load(path); ok {
+		t.Error("the log tail should be forgotten once its stream stopped")
+	}
+}
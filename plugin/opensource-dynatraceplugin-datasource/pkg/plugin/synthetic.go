@@ -0,0 +1,192 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// syntheticMonitor describes the per-location availability and duration
+// metrics of a synthetic monitor type.
+type syntheticMonitor struct {
+	prefix    string
+	dimension string
+	metric    string
+	duration  string
+}
+
+// syntheticMonitors maps monitor ID prefixes to their per-location metrics.
+var syntheticMonitors = []syntheticMonitor{
+	{"SYNTHETIC_TEST-", "dt.entity.synthetic_test", "builtin:synthetic.browser.availability.location.total", "builtin:synthetic.browser.totalDuration.geo"},
+	{"HTTP_CHECK-", "dt.entity.http_check", "builtin:synthetic.http.availability.location.total", "builtin:synthetic.http.duration.geo"},
+}
+
+// syntheticExecutionResolution is the resolution of the execution status
+// frames. Monitors run at most once a minute per location, so every non-null
+// bucket holds a single execution.
+const syntheticExecutionResolution = "1m"
+
+// syntheticNoExecution replaces null buckets in which a monitor did not run,
+// which the metrics API would otherwise report like failed executions.
+const syntheticNoExecution = -1
+
+// querySynthetic returns the availability of a browser or HTTP monitor per
+// location, followed by a status frame per location with the pass/fail
+// result of every execution for the status history panel. With
+// syntheticSplitBy "location" the duration per location follows the
+// availability instead, to compare performance across locations, and with
+// syntheticGeo the coordinates of each location, see syntheticGeoFrames.
+func (d *Datasource) querySynthetic(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	var monitor *syntheticMonitor
+	for i := range syntheticMonitors {
+		if strings.HasPrefix(qm.SyntheticMonitorId, syntheticMonitors[i].prefix) {
+			monitor = &syntheticMonitors[i]
+			break
+		}
+	}
+	if monitor == nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "syntheticMonitorId must be a browser (SYNTHETIC_TEST-) or HTTP (HTTP_CHECK-) monitor")
+	}
+	if qm.SyntheticSplitBy != "" && qm.SyntheticSplitBy != "location" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid syntheticSplitBy %q, expected location", qm.SyntheticSplitBy))
+	}
+
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	resolution := qm.Resolution
+	if resolution == "" {
+		resolution = "5m"
+	}
+
+	if qm.SyntheticTimings {
+		return d.querySyntheticTimings(ctx, qm, fromMs, toMs, resolution)
+	}
+
+	selector := syntheticLocationSelector(monitor.metric, monitor.dimension, qm.SyntheticMonitorId)
+	availability, err := d.queryDynatraceAPI(ctx, selector, fromMs, toMs, resolution, nil)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace API: %v", err))
+	}
+
+	if qm.SyntheticGeo {
+		return d.syntheticGeoResponse(ctx, availability, selector)
+	}
+
+	if qm.SyntheticSplitBy == "location" {
+		durationSelector := syntheticLocationSelector(monitor.duration, monitor.dimension, qm.SyntheticMonitorId)
+		durations, err := d.queryDynatraceAPI(ctx, durationSelector, fromMs, toMs, resolution, nil)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace API: %v", err))
+		}
+		frames := syntheticAvailabilityFrames(availability)
+		for _, frame := range frames {
+			frame.Meta = &data.FrameMeta{ExecutedQueryString: selector}
+		}
+		for _, frame := range syntheticDurationFrames(durations) {
+			frame.Meta = &data.FrameMeta{ExecutedQueryString: durationSelector}
+			frames = append(frames, frame)
+		}
+		return backend.DataResponse{Frames: frames}
+	}
+	executionSelector := fmt.Sprintf("%s:default(%d)", selector, syntheticNoExecution)
+	executions, err := d.queryDynatraceAPI(ctx, executionSelector, fromMs, toMs, syntheticExecutionResolution, nil)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace API: %v", err))
+	}
+
+	frames := syntheticAvailabilityFrames(availability)
+	frames = append(frames, syntheticStatusFrames(executions)...)
+	for _, frame := range frames {
+		frame.Meta = &data.FrameMeta{ExecutedQueryString: selector}
+	}
+	return backend.DataResponse{Frames: frames}
+}
+
+// syntheticLocationSelector selects a metric of a monitor split by location.
+func syntheticLocationSelector(metric, dimension, monitorId string) string {
+	return fmt.Sprintf(`%s:filter(eq(%q,%q)):splitBy("dt.entity.synthetic_location"):names`, metric, dimension, monitorId)
+}
+
+// syntheticLocation returns the display name of the location of a series.
+func syntheticLocation(dataSet DynatraceMetricData) string {
+	if name := dataSet.DimensionMap["dt.entity.synthetic_location.name"]; name != "" {
+		return name
+	}
+	return dataSet.DimensionMap["dt.entity.synthetic_location"]
+}
+
+// syntheticSeries returns the series of a response sorted by location.
+func syntheticSeries(resp *DynatraceMetricsResponse) []DynatraceMetricData {
+	var series []DynatraceMetricData
+	for _, result := range resp.Result {
+		series = append(series, result.Data...)
+	}
+	sort.SliceStable(series, func(i, j int) bool { return syntheticLocation(series[i]) < syntheticLocation(series[j]) })
+	return series
+}
+
+// syntheticAvailabilityFrames converts availability series into a percentage frame per location.
+func syntheticAvailabilityFrames(resp *DynatraceMetricsResponse) data.Frames {
+	var frames data.Frames
+	for _, dataSet := range syntheticSeries(resp) {
+		times := make([]time.Time, len(dataSet.Timestamps))
+		for i, ts := range dataSet.Timestamps {
+			times[i] = time.UnixMilli(ts)
+		}
+		location := syntheticLocation(dataSet)
+		values := data.NewField("availability", data.Labels{"location": location}, dataSet.Values)
+		values.Config = &data.FieldConfig{DisplayNameFromDS: location, Unit: "percent"}
+		frames = append(frames, data.NewFrame("availability", data.NewField("time", nil, times), values))
+	}
+	return frames
+}
+
+// syntheticDurationFrames converts duration series into a frame per location.
+func syntheticDurationFrames(resp *DynatraceMetricsResponse) data.Frames {
+	var frames data.Frames
+	for _, dataSet := range syntheticSeries(resp) {
+		times := make([]time.Time, len(dataSet.Timestamps))
+		for i, ts := range dataSet.Timestamps {
+			times[i] = time.UnixMilli(ts)
+		}
+		location := syntheticLocation(dataSet)
+		values := data.NewField("duration", data.Labels{"location": location}, dataSet.Values)
+		values.Config = &data.FieldConfig{DisplayNameFromDS: location, Unit: "ms"}
+		frames = append(frames, data.NewFrame("duration", data.NewField("time", nil, times), values))
+	}
+	return frames
+}
+
+// syntheticStatusFrames converts per-minute availability into a frame per
+// location with one "pass" or "fail" row per execution.
+func syntheticStatusFrames(resp *DynatraceMetricsResponse) data.Frames {
+	var frames data.Frames
+	for _, dataSet := range syntheticSeries(resp) {
+		times := []time.Time{}
+		statuses := []string{}
+		for i, ts := range dataSet.Timestamps {
+			if i >= len(dataSet.Values) || dataSet.Values[i] == nil || *dataSet.Values[i] == syntheticNoExecution {
+				continue
+			}
+			status := "pass"
+			if *dataSet.Values[i] < 100 {
+				status = "fail"
+			}
+			times = append(times, time.UnixMilli(ts))
+			statuses = append(statuses, status)
+		}
+		location := syntheticLocation(dataSet)
+		status := data.NewField("status", data.Labels{"location": location}, statuses)
+		status.Config = &data.FieldConfig{DisplayNameFromDS: location}
+		frames = append(frames, data.NewFrame("executions", data.NewField("time", nil, times), status))
+	}
+	return frames
+}
@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQuerySynthetic(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		selector := req.URL.Query().Get("metricSelector")
+		if !strings.HasPrefix(selector, `builtin:synthetic.http.availability.location.total:filter(eq("dt.entity.http_check","HTTP_CHECK-1"))`) {
+			t.Errorf("unexpected selector %q", selector)
+		}
+		if req.URL.Query().Get("resolution") == syntheticExecutionResolution {
+			if !strings.HasSuffix(selector, ":default(-1)") {
+				t.Errorf("execution selector %q should default nulls", selector)
+			}
+			_, _ = rw.Write([]byte(`{"result": [{"data": [
+				{"dimensionMap": {"dt.entity.synthetic_location": "SYNTHETIC_LOCATION-1", "dt.entity.synthetic_location.name": "Lisbon"},
+				 "timestamps": [60000, 120000, 180000], "values": [100, -1, 0]}
+			]}]}`))
+			return
+		}
+		_, _ = rw.Write([]byte(`{"result": [{"data": [
+			{"dimensionMap": {"dt.entity.synthetic_location": "SYNTHETIC_LOCATION-1", "dt.entity.synthetic_location.name": "Lisbon"},
+			 "timestamps": [300000], "values": [50]}
+		]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSynthetic,
+		JSON:      []byte(`{"useDashboardTime": true, "syntheticMonitorId": "HTTP_CHECK-1"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 2 {
+		t.Fatalf("expected an availability and a status frame, got %d", len(resp.Frames))
+	}
+	if got, _ := resp.Frames[0].Fields[1].FloatAt(0); got != 50.0 {
+		t.Errorf("availability = %v, want 50", got)
+	}
+
+	status := resp.Frames[1].Fields[1]
+	if status.Labels["location"] != "Lisbon" {
+		t.Errorf("location = %q", status.Labels["location"])
+	}
+	if status.Len() != 2 || status.At(0) != "pass" || status.At(1) != "fail" {
+		t.Errorf("expected a pass and a failed execution, got %d rows", status.Len())
+	}
+}
+
+func TestQuerySyntheticRequiresMonitor(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s", req.URL.Path)
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSynthetic,
+		JSON:      []byte(`{"useDashboardTime": true, "syntheticMonitorId": "HOST-1"}`),
+	})
+	if resp.Status != backend.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.Status)
+	}
+}
+
+func TestQuerySyntheticSplitByLocation(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		selector := req.URL.Query().Get("metricSelector")
+		if req.URL.Query().Get("resolution") == syntheticExecutionResolution {
+			t.Errorf("unexpected execution status request %q", selector)
+		}
+		value := 100
+		if strings.HasPrefix(selector, "builtin:synthetic.http.duration.geo:") {
+			value = 230
+		}
+		_, _ = rw.Write([]byte(fmt.Sprintf(`{"result": [{"data": [
+			{"dimensionMap": {"dt.entity.synthetic_location.name": "Lisbon"}, "timestamps": [300000], "values": [%d]},
+			{"dimensionMap": {"dt.entity.synthetic_location.name": "Frankfurt"}, "timestamps": [300000], "values": [%d]}
+		]}]}`, value, value)))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSynthetic,
+		JSON:      []byte(`{"useDashboardTime": true, "syntheticMonitorId": "HTTP_CHECK-1", "syntheticSplitBy": "location"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 4 {
+		t.Fatalf("expected availability and duration frames for 2 locations, got %d", len(resp.Frames))
+	}
+
+	duration := resp.Frames[2].Fields[1]
+	if got, _ := duration.FloatAt(0); duration.Name != "duration" || duration.Labels["location"] != "Frankfurt" || got != 230.0 {
+		t.Errorf("first duration series = %s %v %v", duration.Name, duration.Labels, got)
+	}
+}
+
+func TestQuerySyntheticGeo(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/v2/metrics/query":
+			_, _ = rw.Write([]byte(`{"result": [{"data": [
+				{"dimensionMap": {"dt.entity.synthetic_location": "SYNTHETIC_LOCATION-1", "dt.entity.synthetic_location.name": "Lisbon"},
+				 "timestamps": [300000, 600000, 900000], "values": [100, null, 50]},
+				{"dimensionMap": {"dt.entity.synthetic_location": "SYNTHETIC_LOCATION-2", "dt.entity.synthetic_location.name": "Sydney"},
+				 "timestamps": [300000], "values": [100]}
+			]}]}`))
+		case "/api/v2/synthetic/locations/SYNTHETIC_LOCATION-1":
+			_, _ = rw.Write([]byte(`{"entityId": "SYNTHETIC_LOCATION-1", "city": "Lisbon", "countryCode": "PT", "latitude": 38.72, "longitude": -9.14}`))
+		default:
+			http.NotFound(rw, req)
+		}
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSynthetic,
+		JSON:      []byte(`{"useDashboardTime": true, "syntheticMonitorId": "HTTP_CHECK-1", "syntheticGeo": true}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 3 {
+		t.Fatalf("expected 2 availability frames and a locations table, got %d", len(resp.Frames))
+	}
+
+	if labels := resp.Frames[0].Fields[1].Labels; labels["latitude"] != "38.72" || labels["longitude"] != "-9.14" {
+		t.Errorf("labels = %v, want the coordinates of Lisbon", labels)
+	}
+
+	table := resp.Frames[2]
+	if got := *table.Fields[4].At(0).(*float64); got != 38.72 {
+		t.Errorf("latitude = %v, want 38.72", got)
+	}
+	// The bucket without executions doesn't count as 0% availability
+	if got := *table.Fields[6].At(0).(*float64); got != 75 {
+		t.Errorf("mean availability = %v, want 75", got)
+	}
+	if table.Fields[4].At(1).(*float64) != nil {
+		t.Error("expected no coordinates for a location that can't be looked up")
+	}
+	if len(table.Meta.Notices) != 1 {
+		t.Errorf("expected a notice about the failed lookup, got %+v", table.Meta.Notices)
+	}
+}
+
+func TestMeanValue(t *testing.T) {
+	if got := meanValue(float64Ptrs(100, math.NaN(), 50)); got == nil || *got != 75 {
+		t.Errorf("mean = %v, want 75 ignoring the null bucket", got)
+	}
+	if got := meanValue(float64Ptrs(math.NaN(), math.NaN())); got != nil {
+		t.Errorf("mean of null buckets = %v, want nil", *got)
+	}
+}
@@ -0,0 +1,113 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// DynatraceSyntheticLocation is a synthetic location of /api/v2/synthetic/locations/{id}
+type DynatraceSyntheticLocation struct {
+	EntityId    string  `json:"entityId"`
+	Name        string  `json:"name"`
+	City        string  `json:"city"`
+	CountryCode string  `json:"countryCode"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+}
+
+// fetchSyntheticLocation looks up a synthetic location. Locations don't
+// move, so they are cached for the lifetime of the instance unless a query
+// bypasses the cache.
+func (d *Datasource) fetchSyntheticLocation(ctx context.Context, id string) (*DynatraceSyntheticLocation, error) {
+	if cached, ok := d.syntheticLocations.Load(id); ok && !cacheBypassed(ctx) {
+		return cached.(*DynatraceSyntheticLocation), nil
+	}
+	var location DynatraceSyntheticLocation
+	if err := d.get(ctx, "/api/v2/synthetic/locations/"+url.PathEscape(id), nil, &location); err != nil {
+		return nil, err
+	}
+	d.syntheticLocations.Store(id, &location)
+	return &location, nil
+}
+
+// syntheticGeoFrames returns the availability frames of a monitor with the
+// coordinates of their location as labels, followed by a table with a row
+// per location and its mean availability for the geomap panel. Locations
+// that can't be looked up, e.g. without the syntheticLocations.read scope,
+// are left without coordinates.
+func (d *Datasource) syntheticGeoFrames(ctx context.Context, availability *DynatraceMetricsResponse) data.Frames {
+	series := syntheticSeries(availability)
+	frames := syntheticAvailabilityFrames(availability)
+
+	table := data.NewFrame("locations",
+		data.NewField("location", nil, []string{}),
+		data.NewField("locationId", nil, []string{}),
+		data.NewField("city", nil, []string{}),
+		data.NewField("countryCode", nil, []string{}),
+		data.NewField("latitude", nil, []*float64{}),
+		data.NewField("longitude", nil, []*float64{}),
+		data.NewField("availability", nil, []*float64{}),
+	)
+	table.Fields[6].Config = &data.FieldConfig{Unit: "percent"}
+
+	failed := 0
+	for i, dataSet := range series {
+		id := dataSet.DimensionMap["dt.entity.synthetic_location"]
+		var city, country string
+		var latitude, longitude *float64
+		if location, err := d.fetchSyntheticLocation(ctx, id); err != nil {
+			log.DefaultLogger.Warn("Error looking up synthetic location", "id", id, "error", d.logError(err))
+			failed++
+		} else {
+			city, country = location.City, location.CountryCode
+			latitude, longitude = &location.Latitude, &location.Longitude
+			labels := frames[i].Fields[1].Labels
+			labels["latitude"] = strconv.FormatFloat(location.Latitude, 'f', -1, 64)
+			labels["longitude"] = strconv.FormatFloat(location.Longitude, 'f', -1, 64)
+		}
+		table.AppendRow(syntheticLocation(dataSet), id, city, country, latitude, longitude, meanValue(dataSet.Values))
+	}
+	if failed > 0 {
+		table.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityWarning,
+			Text:     fmt.Sprintf("Coordinates of %d synthetic locations could not be looked up", failed),
+		})
+	}
+	return append(frames, table)
+}
+
+// meanValue returns the mean of the non-null, non-NaN values, or nil without any.
+func meanValue(values []*float64) *float64 {
+	sum, n := 0.0, 0
+	for _, v := range values {
+		if v != nil && !math.IsNaN(*v) {
+			sum += *v
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	mean := sum / float64(n)
+	return &mean
+}
+
+// syntheticGeoResponse is the response of a synthetic query with
+// syntheticGeo set.
+func (d *Datasource) syntheticGeoResponse(ctx context.Context, availability *DynatraceMetricsResponse, selector string) backend.DataResponse {
+	frames := d.syntheticGeoFrames(ctx, availability)
+	for _, frame := range frames {
+		if frame.Meta == nil {
+			frame.Meta = &data.FrameMeta{}
+		}
+		frame.Meta.ExecutedQueryString = selector
+	}
+	return backend.DataResponse{Frames: frames}
+}
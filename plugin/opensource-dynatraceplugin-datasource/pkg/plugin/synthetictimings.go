@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// httpTimingPhase is a phase of the requests of an HTTP monitor and the
+// per-request metric timing it.
+type httpTimingPhase struct {
+	field  string
+	metric string
+}
+
+// httpTimingPhases are the phases of a request in the order they happen.
+var httpTimingPhases = []httpTimingPhase{
+	{"dns", "builtin:synthetic.http.request.dnsLookupTime"},
+	{"connect", "builtin:synthetic.http.request.tcpConnectTime"},
+	{"tls", "builtin:synthetic.http.request.tlsHandshakeTime"},
+	{"response", "builtin:synthetic.http.request.responseTime"},
+}
+
+// querySyntheticTimings returns the DNS, connect, TLS and response time of
+// every request of an HTTP monitor as a frame per request step with a field
+// per phase, which stacked bars render as a waterfall breakdown.
+func (d *Datasource) querySyntheticTimings(ctx context.Context, qm queryModel, fromMs, toMs int64, resolution string) backend.DataResponse {
+	if !strings.HasPrefix(qm.SyntheticMonitorId, "HTTP_CHECK-") {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "syntheticTimings is only available for HTTP (HTTP_CHECK-) monitors")
+	}
+
+	selectors := make([]string, len(httpTimingPhases))
+	for i, phase := range httpTimingPhases {
+		selectors[i] = fmt.Sprintf(`%s:filter(eq("dt.entity.http_check",%q)):splitBy("dt.entity.http_check_step"):names`, phase.metric, qm.SyntheticMonitorId)
+	}
+	selector := strings.Join(selectors, ",")
+
+	resp, err := d.queryDynatraceAPI(ctx, selector, fromMs, toMs, resolution, nil)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace API: %v", err))
+	}
+
+	frames := httpTimingFrames(resp)
+	for _, frame := range frames {
+		frame.Meta = &data.FrameMeta{ExecutedQueryString: selector}
+	}
+	return backend.DataResponse{Frames: frames}
+}
+
+// httpTimingFrames joins the phase series of each request step on their
+// timestamps, with nulls where a phase has no value.
+func httpTimingFrames(resp *DynatraceMetricsResponse) data.Frames {
+	type stepTimings struct {
+		name   string
+		phases map[int]map[int64]float64
+	}
+	steps := map[string]*stepTimings{}
+	for _, result := range resp.Result {
+		phase := -1
+		for i, p := range httpTimingPhases {
+			if strings.HasPrefix(result.MetricId, p.metric) {
+				phase = i
+			}
+		}
+		if phase < 0 {
+			continue
+		}
+		for _, dataSet := range result.Data {
+			id := dataSet.DimensionMap["dt.entity.http_check_step"]
+			step := steps[id]
+			if step == nil {
+				name := dataSet.DimensionMap["dt.entity.http_check_step.name"]
+				if name == "" {
+					name = id
+				}
+				step = &stepTimings{name: name, phases: map[int]map[int64]float64{}}
+				steps[id] = step
+			}
+			if step.phases[phase] == nil {
+				step.phases[phase] = map[int64]float64{}
+			}
+			for i, ts := range dataSet.Timestamps {
+				if i < len(dataSet.Values) && dataSet.Values[i] != nil {
+					step.phases[phase][ts] = *dataSet.Values[i]
+				}
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(steps))
+	for id := range steps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var frames data.Frames
+	for _, id := range ids {
+		step := steps[id]
+		timestamps := map[int64]bool{}
+		for _, values := range step.phases {
+			for ts := range values {
+				timestamps[ts] = true
+			}
+		}
+		sorted := make([]int64, 0, len(timestamps))
+		for ts := range timestamps {
+			sorted = append(sorted, ts)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		times := make([]time.Time, len(sorted))
+		for i, ts := range sorted {
+			times[i] = time.UnixMilli(ts)
+		}
+		frame := data.NewFrame("timings", data.NewField("time", nil, times))
+		for i, phase := range httpTimingPhases {
+			values := make([]*float64, len(sorted))
+			for j, ts := range sorted {
+				if v, ok := step.phases[i][ts]; ok {
+					values[j] = &v
+				}
+			}
+			field := data.NewField(phase.field, data.Labels{"step": step.name}, values)
+			field.Config = &data.FieldConfig{DisplayNameFromDS: fmt.Sprintf("%s %s", step.name, phase.field), Unit: "ms"}
+			frame.Fields = append(frame.Fields, field)
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
@@ -0,0 +1,76 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQuerySyntheticTimings(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		selector := req.URL.Query().Get("metricSelector")
+		if strings.Count(selector, `filter(eq("dt.entity.http_check","HTTP_CHECK-1"))`) != len(httpTimingPhases) {
+			t.Errorf("selector %q should select every phase of the monitor", selector)
+		}
+		_, _ = rw.Write([]byte(`{"result": [
+			{"metricId": "builtin:synthetic.http.request.dnsLookupTime:filter(...)", "data": [
+				{"dimensionMap": {"dt.entity.http_check_step": "HTTP_CHECK_STEP-1", "dt.entity.http_check_step.name": "Login"},
+				 "timestamps": [60000, 120000], "values": [4, 5]}
+			]},
+			{"metricId": "builtin:synthetic.http.request.responseTime:filter(...)", "data": [
+				{"dimensionMap": {"dt.entity.http_check_step": "HTTP_CHECK_STEP-1", "dt.entity.http_check_step.name": "Login"},
+				 "timestamps": [120000], "values": [180]}
+			]}
+		]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSynthetic,
+		JSON:      []byte(`{"useDashboardTime": true, "syntheticMonitorId": "HTTP_CHECK-1", "syntheticTimings": true}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if len(resp.Frames) != 1 {
+		t.Fatalf("expected a frame per step, got %d", len(resp.Frames))
+	}
+
+	frame := resp.Frames[0]
+	want := []string{"time", "dns", "connect", "tls", "response"}
+	for i, name := range want {
+		if frame.Fields[i].Name != name {
+			t.Errorf("field %d = %s, want %s", i, frame.Fields[i].Name, name)
+		}
+	}
+	if frame.Rows() != 2 {
+		t.Fatalf("rows = %d, want 2", frame.Rows())
+	}
+	if got := frame.Fields[4].At(0).(*float64); got != nil {
+		t.Errorf("response time without data = %v, want null", *got)
+	}
+	if got := frame.Fields[4].At(1).(*float64); got == nil || *got != 180 {
+		t.Errorf("response time = %v, want 180", got)
+	}
+	if frame.Fields[1].Labels["step"] != "Login" {
+		t.Errorf("labels = %v, want step=Login", frame.Fields[1].Labels)
+	}
+}
+
+func TestQuerySyntheticTimingsRequiresHTTPMonitor(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s", req.URL)
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeSynthetic,
+		JSON:      []byte(`{"useDashboardTime": true, "syntheticMonitorId": "SYNTHETIC_TEST-1", "syntheticTimings": true}`),
+	})
+	if resp.Error == nil {
+		t.Fatal("expected an error for a browser monitor")
+	}
+}
@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// sortFieldPattern matches the field names accepted by the sort parameter
+// of the problems and security problems APIs, e.g. "riskAssessment.riskScore".
+var sortFieldPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z.]*$`)
+
+// tablePaging is the sort order and page of rows requested by a table query.
+type tablePaging struct {
+	sort   string // API sort parameter, e.g. "-startTime"
+	offset int
+	limit  int // 0 returns all rows
+}
+
+// newTablePaging validates the sorting and paging options of a query.
+func newTablePaging(qm queryModel) (tablePaging, error) {
+	var p tablePaging
+	if qm.SortField != "" {
+		if !sortFieldPattern.MatchString(qm.SortField) {
+			return p, fmt.Errorf("invalid sortField %q", qm.SortField)
+		}
+		switch qm.SortDirection {
+		case "", "asc":
+			p.sort = "+" + qm.SortField
+		case "desc":
+			p.sort = "-" + qm.SortField
+		default:
+			return p, fmt.Errorf("invalid sortDirection %q: must be \"asc\" or \"desc\"", qm.SortDirection)
+		}
+	}
+	if qm.PageOffset < 0 || qm.PageSize < 0 {
+		return p, fmt.Errorf("pageOffset and pageSize must not be negative")
+	}
+	p.offset, p.limit = qm.PageOffset, qm.PageSize
+	return p, nil
+}
+
+// apply sets the sort order and page size of a paginated request and returns
+// a context that stops pagination once the requested rows were fetched.
+func (p tablePaging) apply(ctx context.Context, params url.Values, maxPageSize int) context.Context {
+	if p.sort != "" {
+		params.Set("sort", p.sort)
+	}
+	if p.limit == 0 {
+		return ctx
+	}
+	rows := p.offset + p.limit
+	pageSize := maxPageSize
+	if rows < pageSize {
+		pageSize = rows
+	}
+	params.Set("pageSize", strconv.Itoa(pageSize))
+	return withPageCount(ctx, (rows+pageSize-1)/pageSize)
+}
+
+// bounds returns the range of the requested rows among n fetched rows.
+func (p tablePaging) bounds(n int) (int, int) {
+	start := p.offset
+	if start > n {
+		start = n
+	}
+	end := n
+	if p.limit > 0 && start+p.limit < end {
+		end = start + p.limit
+	}
+	return start, end
+}
+
+type pageCountKey struct{}
+
+// withPageCount returns a context limiting paginated requests to pages pages.
+func withPageCount(ctx context.Context, pages int) context.Context {
+	return context.WithValue(ctx, pageCountKey{}, pages)
+}
+
+// pageCount returns the number of pages requested with withPageCount, if any.
+func pageCount(ctx context.Context) (int, bool) {
+	pages, ok := ctx.Value(pageCountKey{}).(int)
+	return pages, ok
+}
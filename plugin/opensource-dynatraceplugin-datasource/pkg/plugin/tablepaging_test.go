@@ -0,0 +1,84 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryProblemsSortAndPage(t *testing.T) {
+	requests := 0
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		query := req.URL.Query()
+		if query.Get("nextPageKey") == "" {
+			if query.Get("sort") != "-startTime" || query.Get("pageSize") != "3" {
+				t.Errorf("sort = %q, pageSize = %q", query.Get("sort"), query.Get("pageSize"))
+			}
+		}
+		_, _ = rw.Write([]byte(`{"problems": [{"problemId": "P-1"}, {"problemId": "P-2"}, {"problemId": "P-3"}], "nextPageKey": "key"}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeProblems,
+		JSON:      []byte(`{"useDashboardTime": true, "sortField": "startTime", "sortDirection": "desc", "pageOffset": 1, "pageSize": 2}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if requests != 1 {
+		t.Errorf("expected pagination to stop after the requested rows, got %d requests", requests)
+	}
+	ids, _ := resp.Frames[0].FieldByName("problemId")
+	if ids.Len() != 2 || ids.At(0) != "P-2" || ids.At(1) != "P-3" {
+		t.Errorf("expected problems P-2 and P-3, got %d rows", ids.Len())
+	}
+}
+
+func TestQueryEntitiesPage(t *testing.T) {
+	requests := 0
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		query := req.URL.Query()
+		if query.Get("sort") != "+name" || query.Get("pageSize") != "2" {
+			t.Errorf("sort = %q, pageSize = %q", query.Get("sort"), query.Get("pageSize"))
+		}
+		_, _ = rw.Write([]byte(`{"entities": [{"entityId": "HOST-1"}, {"entityId": "HOST-2"}], "nextPageKey": "key"}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeEntities,
+		JSON:      []byte(`{"useDashboardTime": true, "entitySelector": "type(HOST)", "pageOffset": 1, "pageSize": 1}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if requests != 1 {
+		t.Errorf("expected pagination to stop after the requested rows, got %d requests", requests)
+	}
+	ids, _ := resp.Frames[0].FieldByName("entityId")
+	if ids.Len() != 1 || ids.At(0) != "HOST-2" {
+		t.Errorf("expected entity HOST-2, got %d rows", ids.Len())
+	}
+}
+
+func TestNewTablePaging(t *testing.T) {
+	for _, qm := range []queryModel{
+		{SortField: "startTime; drop"},
+		{SortField: "startTime", SortDirection: "up"},
+		{PageOffset: -1},
+	} {
+		if _, err := newTablePaging(qm); err == nil {
+			t.Errorf("expected an error for %+v", qm)
+		}
+	}
+
+	p := tablePaging{offset: 5, limit: 10}
+	if start, end := p.bounds(3); start != 3 || end != 3 {
+		t.Errorf("bounds beyond the rows = %d, %d", start, end)
+	}
+}
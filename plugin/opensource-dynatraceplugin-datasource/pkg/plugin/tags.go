@@ -0,0 +1,59 @@
+package plugin
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// handleTagValues serves GET /tags/{key}/values?entitySelector=..., returning
+// the distinct values of a tag key across the selected entities. Tags without
+// a value are skipped.
+func (d *Datasource) handleTagValues(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	rest := strings.TrimPrefix(req.URL.Path, "/tags/")
+	if !strings.HasSuffix(rest, "/values") {
+		writeError(rw, http.StatusNotFound, "not found")
+		return
+	}
+	key := strings.TrimSuffix(rest, "/values")
+	if key == "" {
+		writeError(rw, http.StatusBadRequest, "tag key is required")
+		return
+	}
+
+	entitySelector := req.URL.Query().Get("entitySelector")
+	if entitySelector == "" {
+		writeError(rw, http.StatusBadRequest, "entitySelector is required")
+		return
+	}
+
+	entities, err := d.fetchEntities(req.Context(), entitySelector, "tags", 0, 0)
+	if err != nil {
+		writeError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	d.writeRedactedJSON(rw, http.StatusOK, tagValues(entities, key))
+}
+
+// tagValues returns the sorted, distinct values of tag key across entities.
+func tagValues(entities []DynatraceEntity, key string) []string {
+	seen := map[string]bool{}
+	values := []string{}
+	for _, e := range entities {
+		for _, tag := range e.Tags {
+			if tag.Key != key || tag.Value == "" || seen[tag.Value] {
+				continue
+			}
+			seen[tag.Value] = true
+			values = append(values, tag.Value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,45 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestHandleTagValues(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got := req.URL.Query().Get("entitySelector"); got != "type(SERVICE)" {
+			t.Errorf("entitySelector = %q", got)
+		}
+		if got := req.URL.Query().Get("fields"); got != "tags" {
+			t.Errorf("fields = %q", got)
+		}
+		_, _ = rw.Write([]byte(`{"entities": [
+			{"entityId": "SERVICE-1", "tags": [{"key": "team", "value": "payments"}, {"key": "env", "value": "prod"}]},
+			{"entityId": "SERVICE-2", "tags": [{"key": "team", "value": "checkout"}, {"key": "team"}]},
+			{"entityId": "SERVICE-3", "tags": [{"key": "team", "value": "payments"}]}
+		]}`))
+	})
+
+	rec := httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tags/team/values?entitySelector=type(SERVICE)", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+	}
+
+	var values []string
+	if err := json.Unmarshal(rec.Body.Bytes(), &values); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"checkout", "payments"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("values = %v, want %v", values, want)
+	}
+
+	rec = httptest.NewRecorder()
+	ds.newResourceMux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tags/team/values", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected missing entitySelector to be rejected, got %d", rec.Code)
+	}
+}
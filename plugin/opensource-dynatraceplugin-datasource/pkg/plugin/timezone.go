@@ -0,0 +1,89 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	// Embed the timezone database so dashboard timezones resolve on hosts without tzdata
+	_ "time/tzdata"
+)
+
+// maxLocalBuckets bounds the number of requests made for a timezone aligned
+// query; longer ranges fall back to UTC aligned buckets.
+const maxLocalBuckets = 100
+
+// dashboardLocation resolves the dashboard timezone sent with a query. Empty,
+// "utc" and "browser" (which the backend cannot know) resolve to UTC.
+func dashboardLocation(timezone string) (*time.Location, error) {
+	switch strings.ToLower(timezone) {
+	case "", "utc", "browser":
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q: %w", timezone, err)
+	}
+	return loc, nil
+}
+
+// localBuckets splits the range into calendar buckets of days days, aligned
+// to local midnight in loc. Weekly buckets start on Monday.
+func localBuckets(fromMs, toMs int64, loc *time.Location, days int) [][2]time.Time {
+	from := time.UnixMilli(fromMs).In(loc)
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	if days%7 == 0 {
+		offset := (int(start.Weekday()) + 6) % 7
+		start = start.AddDate(0, 0, -offset)
+	}
+
+	to := time.UnixMilli(toMs)
+	var buckets [][2]time.Time
+	for start.Before(to) {
+		end := start.AddDate(0, 0, days)
+		buckets = append(buckets, [2]time.Time{start, end})
+		start = end
+	}
+	return buckets
+}
+
+// queryLocalBuckets queries one data point per local calendar bucket, using
+// resolution "Inf" over every bucket so that Dynatrace applies the metric's
+// own aggregation. The results are merged into one series per dimension set,
+// with each data point stamped at the start of its bucket.
+func (d *Datasource) queryLocalBuckets(ctx context.Context, metricSelector string, buckets [][2]time.Time, extra url.Values) (*DynatraceMetricsResponse, error) {
+	responses := make([]*DynatraceMetricsResponse, 0, len(buckets))
+	for _, bucket := range buckets {
+		resp, err := d.queryDynatraceAPI(ctx, metricSelector, bucket[0].UnixMilli(), bucket[1].UnixMilli(), "Inf", extra)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range resp.Result {
+			for _, dataSet := range result.Data {
+				for i := range dataSet.Timestamps {
+					dataSet.Timestamps[i] = bucket[0].UnixMilli()
+				}
+			}
+		}
+		responses = append(responses, resp)
+	}
+	return mergeMetricsResponses(responses)
+}
+
+// dimensionKey returns a stable key for a dimension map.
+func dimensionKey(dimensions map[string]string) string {
+	keys := make([]string, 0, len(dimensions))
+	for key := range dimensions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%s;", key, dimensions[key])
+	}
+	return b.String()
+}
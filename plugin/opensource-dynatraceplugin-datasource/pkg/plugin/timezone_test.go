@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestDashboardLocation(t *testing.T) {
+	for _, tz := range []string{"", "utc", "browser"} {
+		if loc, err := dashboardLocation(tz); err != nil || loc != time.UTC {
+			t.Errorf("dashboardLocation(%q) = %v, %v, want UTC", tz, loc, err)
+		}
+	}
+	if loc, err := dashboardLocation("Europe/Vienna"); err != nil || loc.String() != "Europe/Vienna" {
+		t.Errorf("dashboardLocation(Europe/Vienna) = %v, %v", loc, err)
+	}
+	if _, err := dashboardLocation("Nowhere/Special"); err == nil {
+		t.Error("expected unknown timezone to fail")
+	}
+}
+
+func TestLocalBuckets(t *testing.T) {
+	vienna, _ := time.LoadLocation("Europe/Vienna")
+	from := time.Date(2024, 3, 30, 15, 0, 0, 0, vienna) // Saturday before the DST change
+	to := time.Date(2024, 4, 1, 12, 0, 0, 0, vienna)
+
+	daily := localBuckets(from.UnixMilli(), to.UnixMilli(), vienna, 1)
+	if len(daily) != 3 {
+		t.Fatalf("expected 3 daily buckets, got %d", len(daily))
+	}
+	if want := time.Date(2024, 3, 30, 0, 0, 0, 0, vienna); !daily[0][0].Equal(want) {
+		t.Errorf("first bucket starts at %v, want local midnight %v", daily[0][0], want)
+	}
+	if got := daily[1][1].Sub(daily[1][0]); got != 23*time.Hour {
+		t.Errorf("DST day should last 23h, got %v", got)
+	}
+
+	weekly := localBuckets(from.UnixMilli(), to.UnixMilli(), vienna, 7)
+	if len(weekly) != 2 || weekly[0][0].Weekday() != time.Monday {
+		t.Errorf("expected weekly buckets starting on Monday, got %v", weekly)
+	}
+}
+
+func TestQueryMetricsAlignsDailyBucketsToTimezone(t *testing.T) {
+	vienna, _ := time.LoadLocation("Europe/Vienna")
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, vienna)
+	to := time.Date(2024, 6, 3, 0, 0, 0, 0, vienna)
+
+	var requests []string
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		query := req.URL.Query()
+		if query.Get("resolution") != "Inf" {
+			t.Errorf("resolution = %s, want Inf", query.Get("resolution"))
+		}
+		requests = append(requests, query.Get("from"))
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {"dt.entity.host": "HOST-1"}, "timestamps": [0], "values": [10]},
+			{"dimensionMap": {"dt.entity.host": "HOST-2"}, "timestamps": [0], "values": [20]}
+		]}]}`))
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON: []byte(fmt.Sprintf(`{"metricSelector": "builtin:host.cpu.usage", "resolution": "1d", "timezone": "Europe/Vienna", "customFrom": "%d", "customTo": "%d"}`,
+			from.UnixMilli(), to.UnixMilli())),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+
+	if len(requests) != 2 || requests[0] != fmt.Sprint(from.UnixMilli()) {
+		t.Fatalf("expected one request per local day, got %v", requests)
+	}
+	if len(resp.Frames) != 2 {
+		t.Fatalf("expected one frame per host, got %d", len(resp.Frames))
+	}
+	times := resp.Frames[0].Fields[0]
+	if times.Len() != 2 || !times.At(1).(time.Time).Equal(from.AddDate(0, 0, 1)) {
+		t.Errorf("expected points at local midnight, got %v", times.At(1))
+	}
+}
@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// defaultTokenExpiryWarning is how long before its expiry a token is warned
+// about when tokenExpiryWarningDays is not configured.
+const defaultTokenExpiryWarning = 14 * 24 * time.Hour
+
+// tokenExpiryWarning returns a warning when a token expires within the
+// datasource's warning window, or an empty string for tokens that never
+// expire or expire later.
+func (d *Datasource) tokenExpiryWarning(lookup *DynatraceTokenLookup, now time.Time) string {
+	if lookup == nil || lookup.ExpirationDate == nil || d.tokenExpiryWindow <= 0 {
+		return ""
+	}
+	expiry := *lookup.ExpirationDate
+	remaining := expiry.Sub(now)
+	switch {
+	case remaining <= 0:
+		return fmt.Sprintf("API token expired on %s", expiry.UTC().Format("2006-01-02"))
+	case remaining > d.tokenExpiryWindow:
+		return ""
+	case remaining < 24*time.Hour:
+		return fmt.Sprintf("API token expires in less than a day (%s); renew it to avoid dashboard outages", expiry.UTC().Format(time.RFC3339))
+	default:
+		return fmt.Sprintf("API token expires in %d days (%s); renew it to avoid dashboard outages", int(remaining/(24*time.Hour)), expiry.UTC().Format("2006-01-02"))
+	}
+}
+
+// addTokenExpiryNotice warns on a query's frames when the cached token lookup
+// shows the token expiring soon. It never looks the token up itself.
+func (d *Datasource) addTokenExpiryNotice(ctx context.Context) {
+	if warning := d.tokenExpiryWarning(d.tokens.cached(), time.Now()); warning != "" {
+		addQueryNotice(ctx, data.Notice{Severity: data.NoticeSeverityWarning, Text: warning})
+	}
+}
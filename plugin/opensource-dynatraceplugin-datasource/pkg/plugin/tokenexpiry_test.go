@@ -0,0 +1,109 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestTokenExpiryWarning(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	ds := &Datasource{tokenExpiryWindow: defaultTokenExpiryWarning}
+	expiring := func(d time.Duration) *DynatraceTokenLookup {
+		expiry := now.Add(d)
+		return &DynatraceTokenLookup{ExpirationDate: &expiry}
+	}
+
+	tests := []struct {
+		name   string
+		lookup *DynatraceTokenLookup
+		want   string
+	}{
+		{"not looked up", nil, ""},
+		{"never expires", &DynatraceTokenLookup{}, ""},
+		{"expires later", expiring(30 * 24 * time.Hour), ""},
+		{"expires soon", expiring(3*24*time.Hour + time.Hour), "API token expires in 3 days (2024-03-04)"},
+		{"expires today", expiring(time.Hour), "API token expires in less than a day"},
+		{"expired", expiring(-time.Hour), "API token expired on 2024-03-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ds.tokenExpiryWarning(tt.lookup, now)
+			if tt.want == "" && got != "" || !strings.HasPrefix(got, tt.want) {
+				t.Errorf("warning = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	ds.tokenExpiryWindow = -1
+	if got := ds.tokenExpiryWarning(expiring(time.Hour), now); got != "" {
+		t.Errorf("disabled warning = %q", got)
+	}
+}
+
+func TestCheckHealthTokenExpiry(t *testing.T) {
+	expiry := time.Now().Add(2 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/health":
+			rw.WriteHeader(http.StatusOK)
+		case "/api/v2/apiTokens/lookup":
+			_, _ = rw.Write([]byte(`{"id": "dt0c01.ABC", "enabled": true, "expirationDate": "` + expiry + `"}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+	ds.tokenExpiryWindow = defaultTokenExpiryWarning
+
+	result, err := ds.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != backend.HealthStatusOk || !strings.Contains(result.Message, "Warning: API token expires in") {
+		t.Errorf("result = %v: %s", result.Status, result.Message)
+	}
+
+	var details struct {
+		Environments []environmentHealth `json:"environments"`
+	}
+	if err := json.Unmarshal(result.JSONDetails, &details); err != nil {
+		t.Fatal(err)
+	}
+	if details.Environments[0].Warning == "" {
+		t.Errorf("environment = %+v, want an expiry warning", details.Environments[0])
+	}
+}
+
+func TestQueryTokenExpiryNotice(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"result": [{"metricId": "builtin:host.cpu.usage", "data": [
+			{"dimensionMap": {}, "timestamps": [1000], "values": [1.5]}
+		]}]}`))
+	})
+	ds.tokenExpiryWindow = defaultTokenExpiryWarning
+	expiry := time.Now().Add(time.Hour)
+	ds.tokens.lookup.ExpirationDate = &expiry
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		TimeRange: backend.TimeRange{From: time.Now().Add(-time.Hour), To: time.Now()},
+		JSON:      []byte(`{"metricSelector": "builtin:host.cpu.usage"}`),
+	})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	if len(resp.Frames) == 0 || resp.Frames[0].Meta == nil {
+		t.Fatalf("expected an expiry notice, got %+v", resp.Frames)
+	}
+	for _, notice := range resp.Frames[0].Meta.Notices {
+		if strings.Contains(notice.Text, "API token expires") {
+			return
+		}
+	}
+	t.Errorf("no expiry notice in %+v", resp.Frames[0].Meta.Notices)
+}
@@ -0,0 +1,169 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// queryScopes lists the API token scopes needed by each query type.
+var queryScopes = map[string][]string{
+	"":                    {"metrics.read"},
+	queryTypeMetrics:      {"metrics.read"},
+	queryTypeAdvanced:     {"metrics.read"},
+	queryTypeBilling:      {"metrics.read"},
+	queryTypeProblems:     {"problems.read"},
+	queryTypeProblem:      {"problems.read"},
+	queryTypeProblemCount: {"problems.read"},
+	queryTypeLogs:         {"logs.read"},
+	queryTypeLogsVolume:   {"logs.read"},
+	queryTypeEntityCount:  {"entities.read"},
+	queryTypeEntities:     {"entities.read"},
+	queryTypeEvents:       {"events.read"},
+	queryTypeUSQL:         {"DTAQLAccess"},
+	queryTypeSLO:          {"slo.read", "metrics.read"},
+	queryTypeApdex:        {"metrics.read"},
+	queryTypeSessionsGeo:  {"DTAQLAccess"},
+	queryTypeHostUnits:    {"metrics.read", "entities.read"},
+	queryTypeServiceFlow:  {"metrics.read", "entities.read"},
+	queryTypeDeployments:  {"events.read"},
+	queryTypeReleases:     {"releases.read", "entities.read"},
+	queryTypeSecurity:     {"securityProblems.read"},
+	queryTypeAttacks:      {"attacks.read"},
+	queryTypeAvailability: {"entities.read", "events.read"},
+	queryTypeSynthetic:    {"metrics.read"},
+	queryTypeActiveGates:  {"activeGates.read"},
+	queryTypeNetworkZones: {"networkZones.read"},
+	queryTypeMetricEvents: {"settings.read"},
+	queryTypeSettings:     {"settings.read"},
+}
+
+// DynatraceTokenLookup is the response of /api/v2/apiTokens/lookup
+type DynatraceTokenLookup struct {
+	Id      string   `json:"id"`
+	Name    string   `json:"name"`
+	Enabled bool     `json:"enabled"`
+	Scopes  []string `json:"scopes"`
+	// ExpirationDate is nil for tokens that never expire
+	ExpirationDate *time.Time `json:"expirationDate"`
+}
+
+// tokenLookupTTL is how long a token lookup is cached before the token's
+// scopes are looked up again, so scope changes are picked up without a
+// restart. Failed lookups are retried sooner.
+const (
+	tokenLookupTTL        = 15 * time.Minute
+	tokenLookupFailureTTL = time.Minute
+)
+
+// tokenCache holds the token lookup of the datasource's API token, or the
+// error of the last failed lookup, until expires.
+type tokenCache struct {
+	mu      sync.Mutex
+	lookup  *DynatraceTokenLookup
+	err     error
+	expires time.Time
+}
+
+// flush drops the cached token lookup.
+func (c *tokenCache) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lookup = nil
+	c.err = nil
+	c.expires = time.Time{}
+}
+
+// cached returns the cached token lookup, or nil if the token has not been
+// looked up yet.
+func (c *tokenCache) cached() *DynatraceTokenLookup {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lookup
+}
+
+// tokenLookup returns the cached token lookup, fetching it on first use and
+// once the cached lookup expires. Failures are cached as well so that every
+// panel of a dashboard doesn't repeat a doomed lookup.
+func (d *Datasource) tokenLookup(ctx context.Context) (*DynatraceTokenLookup, error) {
+	d.tokens.mu.Lock()
+	defer d.tokens.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(d.tokens.expires) {
+		return d.tokens.lookup, d.tokens.err
+	}
+
+	var lookup DynatraceTokenLookup
+	if err := d.post(ctx, "/api/v2/apiTokens/lookup", nil, map[string]string{"token": d.apiToken}, &lookup); err != nil {
+		// A canceled query says nothing about the token
+		if ctx.Err() == nil {
+			d.tokens.lookup, d.tokens.err = nil, err
+			d.tokens.expires = now.Add(tokenLookupFailureTTL)
+		}
+		return nil, err
+	}
+	d.tokens.lookup, d.tokens.err = &lookup, nil
+	d.tokens.expires = now.Add(tokenLookupTTL)
+	return &lookup, nil
+}
+
+// checkQueryScopes verifies that the API token has the scopes needed by a
+// query type, so queries the token can't serve fail immediately instead of
+// sending requests Dynatrace rejects. Rejected and disabled tokens fail every
+// query. If the token cannot be looked up otherwise the check is skipped and
+// the query fails on its own if a scope is missing.
+func (d *Datasource) checkQueryScopes(ctx context.Context, queryType string) error {
+	if len(queryScopes[queryType]) == 0 {
+		return nil
+	}
+
+	lookup, err := d.tokenLookup(ctx)
+	var apiErr *apiError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized:
+		return errors.New("token was rejected by Dynatrace; check the datasource's API token")
+	case err != nil:
+		log.DefaultLogger.Debug("Skipping token scope check", "error", err)
+		return nil
+	case !lookup.Enabled:
+		return errors.New("token is disabled")
+	}
+	return missingScopesError(missingScopes(lookup, queryType))
+}
+
+// missingScopes returns the scopes a query type needs that the token lacks,
+// sorted.
+func missingScopes(lookup *DynatraceTokenLookup, queryType string) []string {
+	granted := map[string]bool{}
+	for _, scope := range lookup.Scopes {
+		granted[scope] = true
+	}
+	var missing []string
+	for _, scope := range queryScopes[queryType] {
+		if !granted[scope] {
+			missing = append(missing, scope)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// missingScopesError describes missing scopes, nil if none are missing.
+func missingScopesError(missing []string) error {
+	switch len(missing) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("token missing %s scope", missing[0])
+	default:
+		return fmt.Errorf("token missing %s scopes", strings.Join(missing, ", "))
+	}
+}
@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryMissingScope(t *testing.T) {
+	lookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/apiTokens/lookup" {
+			t.Errorf("query should not run without the scope, got %s", req.URL.Path)
+			return
+		}
+		lookups++
+		_, _ = rw.Write([]byte(`{"id": "dt0c01.ABC", "enabled": true, "scopes": ["metrics.read"]}`))
+	}))
+	defer server.Close()
+	ds := &Datasource{apiUrl: server.URL, apiToken: "test-token"}
+
+	for i := 0; i < 2; i++ {
+		resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+			RefID:     "A",
+			QueryType: queryTypeProblems,
+			JSON:      []byte(`{}`),
+		})
+		if resp.Status != backend.StatusForbidden {
+			t.Errorf("status = %d, want %d", resp.Status, backend.StatusForbidden)
+		}
+		if resp.Error == nil || !strings.Contains(resp.Error.Error(), "token missing problems.read scope") {
+			t.Errorf("error = %v", resp.Error)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("token lookup should be cached, got %d lookups", lookups)
+	}
+}
+
+func TestCheckQueryScopesLookupFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	ds := &Datasource{apiUrl: server.URL, apiToken: "test-token"}
+
+	if err := ds.checkQueryScopes(context.Background(), queryTypeLogs); err != nil {
+		t.Errorf("a failed lookup should skip the check, got %v", err)
+	}
+	if err := ds.checkQueryScopes(context.Background(), queryTypeConstant); err != nil {
+		t.Errorf("constant queries need no scope, got %v", err)
+	}
+}
+
+func TestTokenLookupTTL(t *testing.T) {
+	lookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		lookups++
+		if lookups == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = rw.Write([]byte(`{"id": "dt0c01.ABC", "enabled": true, "scopes": ["logs.read"]}`))
+	}))
+	defer server.Close()
+	ds := &Datasource{apiUrl: server.URL, apiToken: "test-token"}
+
+	// Failures are cached too, so the second check doesn't look up again
+	for i := 0; i < 2; i++ {
+		if err := ds.checkQueryScopes(context.Background(), queryTypeLogs); err != nil {
+			t.Fatalf("a failed lookup should skip the check, got %v", err)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("failed lookup should be cached, got %d lookups", lookups)
+	}
+
+	ds.tokens.expires = time.Now().Add(-time.Second)
+	if err := ds.checkQueryScopes(context.Background(), queryTypeProblems); err == nil || !strings.Contains(err.Error(), "problems.read") {
+		t.Errorf("error = %v, want missing problems.read", err)
+	}
+	if lookups != 2 {
+		t.Errorf("expired lookup should be refreshed, got %d lookups", lookups)
+	}
+}
+
+func TestCheckQueryScopesRejectedToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"rejected", http.StatusUnauthorized, `{"error": {"code": 401}}`, "token was rejected"},
+		{"disabled", http.StatusOK, `{"id": "dt0c01.ABC", "enabled": false, "scopes": ["logs.read"]}`, "token is disabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(tt.status)
+				_, _ = rw.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			ds := &Datasource{apiUrl: server.URL, apiToken: "test-token"}
+
+			if err := ds.checkQueryScopes(context.Background(), queryTypeLogs); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// defaultTraceLimit bounds the number of traces listed by a service search.
+const defaultTraceLimit = 100
+
+// traceSpanFields are span attributes mapped to dedicated trace frame fields
+// and therefore left out of the span tags.
+var traceSpanFields = map[string]bool{
+	"trace.id":       true,
+	"span.id":        true,
+	"span.parent_id": true,
+	"span.name":      true,
+	"service.name":   true,
+	"start_time":     true,
+	"end_time":       true,
+	"duration":       true,
+}
+
+// queryTraces fetches spans from Grail. With a trace ID it returns the full
+// trace as a Grafana trace frame; with a service name it lists matching traces
+// as a table whose traceID links into the trace view.
+func (d *Datasource) queryTraces(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	switch {
+	case qm.TraceId != "":
+		dql := fmt.Sprintf("fetch spans\n| filter trace.id == toUid(%s)\n| sort start_time asc", dqlString(qm.TraceId))
+		result, err := d.executeDQL(ctx, dql, fromMs, toMs, dqlOptions{})
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace spans: %v", err))
+		}
+		frame := traceFrame(result.Records)
+		frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTrace, ExecutedQueryString: dql}
+		return backend.DataResponse{Frames: data.Frames{frame}}
+
+	case qm.TraceService != "":
+		limit := qm.TraceLimit
+		if limit <= 0 {
+			limit = defaultTraceLimit
+		}
+		dql := fmt.Sprintf("fetch spans\n| filter service.name == %s and isNull(span.parent_id)\n| sort start_time desc\n| limit %d\n| fields trace.id, span.name, service.name, start_time, duration",
+			dqlString(qm.TraceService), limit)
+		result, err := d.executeDQL(ctx, dql, fromMs, toMs, dqlOptions{})
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace spans: %v", err))
+		}
+		frame := traceListFrame(result.Records)
+		addFieldLinks(frame, "traceID", d.traceLink(fromMs, toMs))
+		frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable, ExecutedQueryString: dql}
+		return backend.DataResponse{Frames: data.Frames{frame}}
+
+	default:
+		return backend.ErrDataResponse(backend.StatusBadRequest, "traceId or traceService is required")
+	}
+}
+
+// traceKeyValue is the key/value pair format used by Grafana trace frames for tags.
+type traceKeyValue struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// traceFrame converts span records into Grafana's trace data frame format.
+func traceFrame(records []map[string]interface{}) *data.Frame {
+	frame := data.NewFrame("trace",
+		data.NewField("traceID", nil, []string{}),
+		data.NewField("spanID", nil, []string{}),
+		data.NewField("parentSpanID", nil, []*string{}),
+		data.NewField("operationName", nil, []string{}),
+		data.NewField("serviceName", nil, []string{}),
+		data.NewField("serviceTags", nil, []json.RawMessage{}),
+		data.NewField("startTime", nil, []float64{}),
+		data.NewField("duration", nil, []float64{}),
+		data.NewField("tags", nil, []json.RawMessage{}),
+	)
+
+	for _, r := range records {
+		var parentSpanId *string
+		if parent := recordString(r, "span.parent_id"); parent != "" {
+			parentSpanId = &parent
+		}
+
+		startMs := 0.0
+		if start, ok := recordTime(r, "start_time"); ok {
+			startMs = float64(start.UnixNano()) / float64(time.Millisecond)
+		}
+		durationMs := float64(recordDuration(r, "duration")) / float64(time.Millisecond)
+
+		var serviceTags, tags []traceKeyValue
+		keys := make([]string, 0, len(r))
+		for key := range r {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if traceSpanFields[key] || r[key] == nil {
+				continue
+			}
+			kv := traceKeyValue{Key: key, Value: r[key]}
+			if strings.HasPrefix(key, "service.") || strings.HasPrefix(key, "host.") || strings.HasPrefix(key, "k8s.") {
+				serviceTags = append(serviceTags, kv)
+			} else {
+				tags = append(tags, kv)
+			}
+		}
+
+		frame.AppendRow(
+			recordString(r, "trace.id"),
+			recordString(r, "span.id"),
+			parentSpanId,
+			recordString(r, "span.name"),
+			recordString(r, "service.name"),
+			mustMarshalJSON(serviceTags),
+			startMs,
+			durationMs,
+			mustMarshalJSON(tags),
+		)
+	}
+
+	return frame
+}
+
+// traceListFrame converts root span records into a table of traces.
+func traceListFrame(records []map[string]interface{}) *data.Frame {
+	frame := data.NewFrame("traces",
+		data.NewField("traceID", nil, []string{}),
+		data.NewField("traceName", nil, []string{}),
+		data.NewField("serviceName", nil, []string{}),
+		data.NewField("startTime", nil, []time.Time{}),
+		data.NewField("duration", nil, []float64{}).SetConfig(&data.FieldConfig{Unit: "ms"}),
+	)
+
+	for _, r := range records {
+		start, _ := recordTime(r, "start_time")
+		frame.AppendRow(
+			recordString(r, "trace.id"),
+			recordString(r, "span.name"),
+			recordString(r, "service.name"),
+			start,
+			float64(recordDuration(r, "duration"))/float64(time.Millisecond),
+		)
+	}
+
+	return frame
+}
+
+// mustMarshalJSON marshals v, falling back to an empty JSON array.
+func mustMarshalJSON(v interface{}) json.RawMessage {
+	raw, err := json.Marshal(v)
+	if err != nil || string(raw) == "null" {
+		return json.RawMessage("[]")
+	}
+	return raw
+}
@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestQueryTracesById(t *testing.T) {
+	polled := false
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got := req.Header.Get("Authorization"); got != "Bearer platform-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		switch req.URL.Path {
+		case dqlExecutePath:
+			body, _ := io.ReadAll(req.Body)
+			var execute dqlExecuteRequest
+			_ = json.Unmarshal(body, &execute)
+			if !strings.Contains(execute.Query, `toUid("abc123")`) {
+				t.Errorf("unexpected query %q", execute.Query)
+			}
+			_, _ = rw.Write([]byte(`{"state": "RUNNING", "requestToken": "tok"}`))
+		case dqlPollPath:
+			polled = true
+			if got := req.URL.Query().Get("request-token"); got != "tok" {
+				t.Errorf("request-token = %q", got)
+			}
+			_, _ = rw.Write([]byte(`{"state": "SUCCEEDED", "result": {"records": [
+				{"trace.id": "abc123", "span.id": "s1", "span.name": "GET /cart", "service.name": "frontend", "start_time": "2024-01-01T00:00:00.000000000Z", "duration": "2000000", "http.status_code": "200"},
+				{"trace.id": "abc123", "span.id": "s2", "span.parent_id": "s1", "span.name": "SELECT", "service.name": "db", "start_time": "2024-01-01T00:00:00.001000000Z", "duration": 500000}
+			]}}`))
+		default:
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeTraces,
+		JSON:      []byte(`{"useDashboardTime": true, "traceId": "abc123"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	if !polled {
+		t.Error("expected the running query to be polled")
+	}
+
+	frame := resp.Frames[0]
+	if rows, _ := frame.RowLen(); rows != 2 {
+		t.Fatalf("expected 2 spans, got %d", rows)
+	}
+	parent, _ := frame.FieldByName("parentSpanID")
+	if parent.At(0).(*string) != nil || *parent.At(1).(*string) != "s1" {
+		t.Errorf("unexpected parent span IDs")
+	}
+	duration, _ := frame.FieldByName("duration")
+	if duration.At(0) != 2.0 || duration.At(1) != 0.5 {
+		t.Errorf("durations = %v, %v, want 2, 0.5", duration.At(0), duration.At(1))
+	}
+	tags, _ := frame.FieldByName("tags")
+	if got := string(tags.At(0).(json.RawMessage)); got != `[{"key":"http.status_code","value":"200"}]` {
+		t.Errorf("tags = %s", got)
+	}
+}
+
+func TestDqlString(t *testing.T) {
+	if got := dqlString(`a"b\c`); got != `"a\"b\\c"` {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestQueryTracesByService(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"state": "SUCCEEDED", "result": {"records": [
+			{"trace.id": "abc123", "span.name": "GET /cart", "service.name": "frontend", "start_time": "2024-01-01T00:00:00Z", "duration": 2000000}
+		]}}`))
+	})
+	ds.platformUrl = ds.apiUrl
+	ds.platformToken = "platform-token"
+	ds.settings.UID = "dt-uid"
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeTraces,
+		JSON:      []byte(`{"useDashboardTime": true, "traceService": "frontend"}`),
+	})
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	traceId, _ := resp.Frames[0].FieldByName("traceID")
+	if traceId == nil || traceId.At(0) != "abc123" {
+		t.Fatalf("unexpected trace list %v", resp.Frames[0].Fields)
+	}
+	if traceId.Config == nil || len(traceId.Config.Links) != 1 {
+		t.Fatal("traceID should link to the trace view")
+	}
+	state, err := url.QueryUnescape(strings.TrimPrefix(traceId.Config.Links[0].URL, "/explore?left="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"queryType":"traces"`, `"traceId":"${__value.raw}"`, `"uid":"dt-uid"`} {
+		if !strings.Contains(state, want) {
+			t.Errorf("explore state %s should contain %s", state, want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package plugin
+
+import (
+	"fmt"
+)
+
+// Series transformations computed by the backend after the API response is decoded.
+const (
+	transformDelta      = "delta"
+	transformDerivative = "derivative"
+	transformCumulative = "cumulative"
+)
+
+// validateTransform checks the transform option of a metrics query.
+func validateTransform(transform string) error {
+	switch transform {
+	case "", transformDelta, transformDerivative, transformCumulative:
+		return nil
+	default:
+		return fmt.Errorf("unsupported transform: %s", transform)
+	}
+}
+
+// applyTransform transforms a series. delta is the difference to the previous
+// point and derivative that difference per second; the first point has no
+// predecessor and is dropped, and points next to a null are null.
+// cumulative is the running total over the range, null where the series is.
+func applyTransform(transform string, timestamps []int64, values []*float64) ([]int64, []*float64) {
+	switch transform {
+	case transformDelta, transformDerivative:
+		if len(values) < 2 {
+			return []int64{}, []*float64{}
+		}
+		ts := make([]int64, 0, len(values)-1)
+		out := make([]*float64, 0, len(values)-1)
+		for i := 1; i < len(values); i++ {
+			seconds := float64(timestamps[i]-timestamps[i-1]) / 1000
+			if transform == transformDerivative && seconds <= 0 {
+				continue
+			}
+			ts = append(ts, timestamps[i])
+			if values[i] == nil || values[i-1] == nil {
+				out = append(out, nil)
+				continue
+			}
+			diff := *values[i] - *values[i-1]
+			if transform == transformDerivative {
+				diff /= seconds
+			}
+			out = append(out, &diff)
+		}
+		return ts, out
+	case transformCumulative:
+		out := make([]*float64, len(values))
+		total := 0.0
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			total += *v
+			running := total
+			out[i] = &running
+		}
+		return timestamps, out
+	default:
+		return timestamps, values
+	}
+}
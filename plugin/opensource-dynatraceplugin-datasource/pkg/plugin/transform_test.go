@@ -0,0 +1,55 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+func TestApplyTransform(t *testing.T) {
+	timestamps := []int64{0, 60000, 120000, 180000}
+	values := float64Ptrs(10, 70, 70, 40)
+	gappy := float64Ptrs(10, math.NaN(), 70, 40)
+
+	tests := []struct {
+		transform  string
+		values     []*float64
+		timestamps string
+		want       string
+	}{
+		{"", values, "[0 60000 120000 180000]", "[10 70 70 40]"},
+		{transformDelta, values, "[60000 120000 180000]", "[60 0 -30]"},
+		{transformDerivative, values, "[60000 120000 180000]", "[1 0 -0.5]"},
+		{transformCumulative, values, "[0 60000 120000 180000]", "[10 80 150 190]"},
+		{transformDelta, gappy, "[60000 120000 180000]", "[null null -30]"},
+		{transformCumulative, gappy, "[0 60000 120000 180000]", "[10 null 80 120]"},
+	}
+	for _, tt := range tests {
+		ts, out := applyTransform(tt.transform, timestamps, tt.values)
+		if fmt.Sprint(ts) != tt.timestamps || formatValues(out) != tt.want {
+			t.Errorf("%q: got %v %s, want %s %s", tt.transform, ts, formatValues(out), tt.timestamps, tt.want)
+		}
+	}
+
+	if ts, out := applyTransform(transformDelta, []int64{0}, float64Ptrs(1)); len(ts) != 0 || len(out) != 0 {
+		t.Errorf("a single point has no delta")
+	}
+}
+
+func TestQueryMetricsRejectsUnknownTransform(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		t.Errorf("unexpected request %s", req.URL.Path)
+	})
+
+	resp := ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID: "A",
+		JSON:  []byte(`{"metricSelector": "builtin:host.cpu.usage", "transform": "integral"}`),
+	})
+	if resp.Status != backend.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.Status, backend.StatusBadRequest)
+	}
+}
@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"os"
+	"strings"
+)
+
+// Build information of the plugin binary, see SetBuildInfo.
+var (
+	buildVersion string
+	buildCommit  string
+)
+
+// SetBuildInfo records the plugin version and commit that the build compiles
+// into the main package.
+func SetBuildInfo(version, commit string) {
+	buildVersion, buildCommit = version, commit
+}
+
+// pluginVersion returns the version of the plugin binary, "dev" for binaries
+// built without build info.
+func pluginVersion() string {
+	if buildVersion == "" {
+		return "dev"
+	}
+	return buildVersion
+}
+
+// userAgent returns the User-Agent sent on every request, identifying the
+// plugin and Grafana versions in Dynatrace audit logs, followed by the
+// optional userAgentSuffix setting. Grafana passes its version to plugins in
+// the GF_VERSION environment variable.
+func userAgent(suffix string) string {
+	parts := []string{"grafana-dynatrace-plugin/" + pluginVersion()}
+	if version := os.Getenv("GF_VERSION"); version != "" {
+		parts = append(parts, "grafana/"+version)
+	}
+	if suffix = strings.TrimSpace(suffix); suffix != "" {
+		parts = append(parts, suffix)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,37 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestUserAgent(t *testing.T) {
+	t.Setenv("GF_VERSION", "")
+	if got := userAgent(""); got != "grafana-dynatrace-plugin/dev" {
+		t.Errorf("userAgent = %q", got)
+	}
+
+	SetBuildInfo("1.2.3", "abc123")
+	defer SetBuildInfo("", "")
+	t.Setenv("GF_VERSION", "9.3.2")
+	if got := userAgent(" team-observability "); got != "grafana-dynatrace-plugin/1.2.3 grafana/9.3.2 team-observability" {
+		t.Errorf("userAgent = %q", got)
+	}
+}
+
+func TestRequestsSendUserAgent(t *testing.T) {
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if got := req.Header.Get("User-Agent"); got != "grafana-dynatrace-plugin/dev grafana/9.3.2" {
+			t.Errorf("User-Agent = %q", got)
+		}
+		_, _ = rw.Write([]byte(`{}`))
+	})
+	t.Setenv("GF_VERSION", "9.3.2")
+	ds.userAgent = userAgent("")
+
+	var out map[string]interface{}
+	if err := ds.get(context.Background(), "/api/v2/problems", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,255 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Result types of the "usql" query type.
+const (
+	usqlResultTable      = "table"
+	usqlResultPie        = "pie"
+	usqlResultTimeSeries = "timeseries"
+)
+
+// usqlTimeLayouts are the layouts of DATETIME() buckets in USQL results.
+var usqlTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02 15", "2006-01-02", "2006-01"}
+
+// DynatraceUSQLResult is the response of the user session query language table API
+type DynatraceUSQLResult struct {
+	ExtrapolationLevel int             `json:"extrapolationLevel"`
+	ColumnNames        []string        `json:"columnNames"`
+	Values             [][]interface{} `json:"values"`
+}
+
+// queryUSQL runs a user session query and returns its result in the frame
+// shape of its result type: a table, a labeled value per row for pie charts,
+// or time series bucketed by the first column. The API doesn't report what
+// a query computes, so without usqlResultType results whose first column is
+// a time are returned as time series and all others as a table.
+func (d *Datasource) queryUSQL(ctx context.Context, query backend.DataQuery, qm queryModel) backend.DataResponse {
+	if qm.USQLQuery == "" {
+		return backend.ErrDataResponse(backend.StatusBadRequest, "usqlQuery is required")
+	}
+	switch qm.USQLResultType {
+	case "", usqlResultTable, usqlResultPie, usqlResultTimeSeries:
+	default:
+		return backend.ErrDataResponse(backend.StatusBadRequest, fmt.Sprintf("invalid usqlResultType %q, expected table, pie or timeseries", qm.USQLResultType))
+	}
+	fromMs, toMs, err := timeRange(qm, query)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+	}
+
+	result, err := d.fetchUSQL(ctx, qm.USQLQuery, fromMs, toMs, 0)
+	if err != nil {
+		return backend.ErrDataResponse(backend.StatusInternal, fmt.Sprintf("error querying Dynatrace user sessions: %v", err))
+	}
+
+	resultType := qm.USQLResultType
+	if resultType == "" {
+		resultType = usqlResultTable
+		if _, ok := usqlTimes(result); ok && len(result.Values) > 0 {
+			resultType = usqlResultTimeSeries
+		}
+	}
+
+	var frames data.Frames
+	switch resultType {
+	case usqlResultPie:
+		frames = data.Frames{usqlPieFrame(result)}
+	case usqlResultTimeSeries:
+		frame, err := usqlTimeSeriesFrame(result)
+		if err != nil {
+			return backend.ErrDataResponse(backend.StatusBadRequest, err.Error())
+		}
+		frames = data.Frames{frame}
+	default:
+		table := usqlTableFrame(result)
+		table.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+		frames = data.Frames{table}
+	}
+
+	if frames[0].Meta == nil {
+		frames[0].Meta = &data.FrameMeta{}
+	}
+	frames[0].Meta.ExecutedQueryString = qm.USQLQuery
+	if result.ExtrapolationLevel > 1 {
+		frames[0].AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     fmt.Sprintf("Results are extrapolated from 1 in %d user sessions", result.ExtrapolationLevel),
+		})
+	}
+	return backend.DataResponse{Frames: frames}
+}
+
+// fetchUSQL runs a user session query over the given time range. pageSize
+// sets the number of rows returned, leaving the API default when 0.
+func (d *Datasource) fetchUSQL(ctx context.Context, usqlQuery string, fromMs, toMs int64, pageSize int) (*DynatraceUSQLResult, error) {
+	params := url.Values{}
+	params.Set("query", usqlQuery)
+	params.Set("startTimestamp", fmt.Sprintf("%d", fromMs))
+	params.Set("endTimestamp", fmt.Sprintf("%d", toMs))
+	if pageSize > 0 {
+		params.Set("pageSize", strconv.Itoa(pageSize))
+	}
+
+	log.DefaultLogger.Debug("Querying Dynatrace user sessions", "query", d.logPayload(usqlQuery), "from", fromMs, "to", toMs)
+
+	var result DynatraceUSQLResult
+	if err := d.get(ctx, "/api/v1/userSessionQueryLanguage/table", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// usqlTableFrame converts a USQL result into a table with the columns in
+// query order, typed like DQL records.
+func usqlTableFrame(result *DynatraceUSQLResult) *data.Frame {
+	frame := dqlFrame(usqlRecords(result))
+	frame.Name = "usql"
+	ordered := make(data.Fields, 0, len(frame.Fields))
+	for _, name := range result.ColumnNames {
+		if field, _ := frame.FieldByName(name); field != nil {
+			ordered = append(ordered, field)
+		}
+	}
+	frame.Fields = ordered
+	return frame
+}
+
+// usqlPieFrame converts a USQL result into one value field per row and
+// numeric column, labeled by the other columns of the row, the shape the
+// pie chart renders as slices.
+func usqlPieFrame(result *DynatraceUSQLResult) *data.Frame {
+	table := usqlTableFrame(result)
+	var numbers, labels []*data.Field
+	for _, field := range table.Fields {
+		if field.Type() == data.FieldTypeNullableFloat64 {
+			numbers = append(numbers, field)
+		} else {
+			labels = append(labels, field)
+		}
+	}
+
+	frame := data.NewFrame("usql")
+	for row := 0; row < table.Rows(); row++ {
+		rowLabels := data.Labels{}
+		var names []string
+		for _, field := range labels {
+			value := ""
+			if v, ok := field.ConcreteAt(row); ok {
+				value = fmt.Sprint(v)
+			}
+			rowLabels[field.Name] = value
+			names = append(names, value)
+		}
+		for _, number := range numbers {
+			name := strings.Join(names, " / ")
+			if len(numbers) > 1 || name == "" {
+				name = strings.TrimSpace(name + " " + number.Name)
+			}
+			field := data.NewField(number.Name, rowLabels, []*float64{number.At(row).(*float64)})
+			field.Config = &data.FieldConfig{DisplayNameFromDS: name}
+			frame.Fields = append(frame.Fields, field)
+		}
+	}
+	return frame
+}
+
+// usqlTimeSeriesFrame converts a USQL result bucketed by its first column
+// into a wide time series frame, with a series per numeric column and
+// combination of the other columns.
+func usqlTimeSeriesFrame(result *DynatraceUSQLResult) (*data.Frame, error) {
+	times, ok := usqlTimes(result)
+	if !ok {
+		return nil, fmt.Errorf("the first column of a timeseries result must be a time bucket, e.g. DATETIME(startTime, \"yyyy-MM-dd HH:mm\", \"5m\")")
+	}
+
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return times[order[i]].Before(times[order[j]]) })
+
+	// The values of the other columns, ordered by time
+	sortedTimes := make([]time.Time, len(order))
+	rest := &DynatraceUSQLResult{ColumnNames: result.ColumnNames[1:], Values: make([][]interface{}, len(order))}
+	for i, row := range order {
+		sortedTimes[i] = times[row]
+		rest.Values[i] = result.Values[row][1:]
+	}
+	values := usqlTableFrame(rest)
+	long := data.NewFrame("usql", append(data.Fields{data.NewField("time", nil, sortedTimes)}, values.Fields...)...)
+	if long.TimeSeriesSchema().Type != data.TimeSeriesTypeLong {
+		return long, nil
+	}
+	return data.LongToWide(long, nil)
+}
+
+// usqlTimes parses the first column of a USQL result as times, either epoch
+// milliseconds or formatted DATETIME() buckets.
+func usqlTimes(result *DynatraceUSQLResult) ([]time.Time, bool) {
+	if len(result.ColumnNames) == 0 {
+		return nil, false
+	}
+	// Numbers are only times in columns that say so, counts are numbers too
+	numbersAreTimes := strings.Contains(strings.ToLower(result.ColumnNames[0]), "time")
+	times := make([]time.Time, len(result.Values))
+	for i, row := range result.Values {
+		if len(row) == 0 {
+			return nil, false
+		}
+		switch v := row[0].(type) {
+		case float64:
+			if !numbersAreTimes {
+				return nil, false
+			}
+			times[i] = time.UnixMilli(int64(v))
+		case string:
+			t, ok := parseUSQLTime(v)
+			if !ok {
+				return nil, false
+			}
+			times[i] = t
+		default:
+			return nil, false
+		}
+	}
+	return times, true
+}
+
+// parseUSQLTime parses a formatted USQL time bucket.
+func parseUSQLTime(s string) (time.Time, bool) {
+	for _, layout := range usqlTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// usqlRecords converts the rows of a USQL result into records keyed by
+// column name.
+func usqlRecords(result *DynatraceUSQLResult) []map[string]interface{} {
+	records := make([]map[string]interface{}, len(result.Values))
+	for i, row := range result.Values {
+		record := make(map[string]interface{}, len(result.ColumnNames))
+		for c, name := range result.ColumnNames {
+			if c < len(row) {
+				record[name] = row[c]
+			}
+		}
+		records[i] = record
+	}
+	return records
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+func runUSQLQuery(t *testing.T, body, queryJSON string) backend.DataResponse {
+	t.Helper()
+	ds := newTestDatasource(t, func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v1/userSessionQueryLanguage/table" {
+			t.Errorf("unexpected path %s", req.URL.Path)
+		}
+		if req.URL.Query().Get("query") == "" {
+			t.Error("expected a query parameter")
+		}
+		_, _ = rw.Write([]byte(body))
+	})
+	return ds.query(context.Background(), backend.PluginContext{}, backend.DataQuery{
+		RefID:     "A",
+		QueryType: queryTypeUSQL,
+		JSON:      []byte(queryJSON),
+	})
+}
+
+func TestQueryUSQLTable(t *testing.T) {
+	resp := runUSQLQuery(t, `{"extrapolationLevel": 2, "columnNames": ["city", "count(*)"], "values": [["Vienna", 12], ["Linz", 3]]}`,
+		`{"useDashboardTime": true, "usqlQuery": "SELECT city, COUNT(*) FROM usersession GROUP BY city"}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	if frame.Fields[0].Name != "city" || frame.Fields[1].Name != "count(*)" {
+		t.Errorf("fields = %s, %s, want query order", frame.Fields[0].Name, frame.Fields[1].Name)
+	}
+	if frame.Meta.PreferredVisualization != data.VisTypeTable {
+		t.Errorf("visualization = %s, want table", frame.Meta.PreferredVisualization)
+	}
+	if len(frame.Meta.Notices) != 1 {
+		t.Errorf("expected an extrapolation notice, got %+v", frame.Meta.Notices)
+	}
+}
+
+func TestQueryUSQLPie(t *testing.T) {
+	resp := runUSQLQuery(t, `{"columnNames": ["browserFamily", "count(*)"], "values": [["Chrome", 40], ["Firefox", 10]]}`,
+		`{"useDashboardTime": true, "usqlQuery": "SELECT browserFamily, COUNT(*) FROM usersession GROUP BY browserFamily", "usqlResultType": "pie"}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	if len(frame.Fields) != 2 {
+		t.Fatalf("expected a field per row, got %d", len(frame.Fields))
+	}
+	firefox := frame.Fields[1]
+	if firefox.Config.DisplayNameFromDS != "Firefox" || *firefox.At(0).(*float64) != 10 {
+		t.Errorf("slice = %s: %v, want Firefox: 10", firefox.Config.DisplayNameFromDS, *firefox.At(0).(*float64))
+	}
+}
+
+func TestQueryUSQLTimeSeries(t *testing.T) {
+	resp := runUSQLQuery(t, `{"columnNames": ["DATETIME(startTime, \"yyyy-MM-dd HH:mm\", \"5m\")", "country", "count(*)"], "values": [
+		["2026-01-01 10:05", "AT", 4],
+		["2026-01-01 10:00", "AT", 2],
+		["2026-01-01 10:00", "DE", 7]
+	]}`, `{"useDashboardTime": true, "usqlQuery": "SELECT DATETIME(startTime, \"yyyy-MM-dd HH:mm\", \"5m\"), country, COUNT(*) FROM usersession GROUP BY 1, country"}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+
+	frame := resp.Frames[0]
+	if len(frame.Fields) != 3 {
+		t.Fatalf("expected time and a series per country, got %d fields", len(frame.Fields))
+	}
+	if got := frame.Fields[0].At(0).(time.Time); !got.Equal(time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("first bucket = %v", got)
+	}
+	at := frame.Fields[1]
+	if at.Labels["country"] != "AT" || *at.At(1).(*float64) != 4 {
+		t.Errorf("AT series = %v %v", at.Labels, at.At(1))
+	}
+}
+
+func TestQueryUSQLTimeSeriesRequiresTimeColumn(t *testing.T) {
+	resp := runUSQLQuery(t, `{"columnNames": ["city", "count(*)"], "values": [["Vienna", 12]]}`,
+		`{"useDashboardTime": true, "usqlQuery": "SELECT city, COUNT(*) FROM usersession GROUP BY city", "usqlResultType": "timeseries"}`)
+	if resp.Error == nil {
+		t.Fatal("expected an error for a result without time buckets")
+	}
+}
@@ -1,8 +1,8 @@
-import React, { ChangeEvent } from 'react';
-import { InlineField, Input, InlineSwitch, Select, TextArea } from '@grafana/ui';
+import React, { ChangeEvent, useEffect, useState } from 'react';
+import { Button, InlineField, Input, InlineSwitch, Select, TextArea } from '@grafana/ui';
 import { QueryEditorProps, SelectableValue } from '@grafana/data';
 import { DataSource } from '../datasource';
-import { MyDataSourceOptions, MyQuery } from '../types';
+import { DQLProgress, MyDataSourceOptions, MyQuery } from '../types';
 
 type Props = QueryEditorProps<DataSource, MyQuery, MyDataSourceOptions>;
 
@@ -15,7 +15,37 @@ const RESOLUTION_OPTIONS: Array<SelectableValue<string>> = [
   { label: '1 day', value: '1d' },
 ];
 
-export function QueryEditor({ query, onChange, onRunQuery }: Props) {
+// DQLProgressRow shows the progress of the running DQL query and lets the
+// user cancel it.
+function DQLProgressRow({ datasource, refId }: { datasource: DataSource; refId: string }) {
+  const [progress, setProgress] = useState<DQLProgress | undefined>();
+
+  useEffect(() => {
+    const subscription = datasource.dqlProgress(refId).subscribe(setProgress);
+    return () => subscription.unsubscribe();
+  }, [datasource, refId]);
+
+  if (!progress) {
+    return null;
+  }
+  return (
+    <div className="gf-form">
+      <InlineField label="DQL Progress" labelWidth={20}>
+        <span style={{ lineHeight: '32px' }}>
+          {progress.state} {progress.progress}% · {(progress.scannedBytes / 1e9).toFixed(2)} GB scanned ·{' '}
+          {progress.scannedRecords} records scanned
+        </span>
+      </InlineField>
+      {!progress.done && (
+        <Button variant="secondary" size="sm" icon="times" onClick={() => datasource.cancelDQL(refId)}>
+          Cancel
+        </Button>
+      )}
+    </div>
+  );
+}
+
+export function QueryEditor({ datasource, query, onChange, onRunQuery }: Props) {
   // Metric Selector handler (primary field)
   const onMetricSelectorChange = (event: ChangeEvent<HTMLTextAreaElement>) => {
     onChange({ ...query, metricSelector: event.target.value });
@@ -52,6 +82,8 @@ export function QueryEditor({ query, onChange, onRunQuery }: Props) {
   return (
     <div className="gf-form-group">
       <h6 className="page-heading">Dynatrace Metric Query</h6>
+
+      {query.queryType === 'dql' && <DQLProgressRow datasource={datasource} refId={query.refId} />}
       
       <div className="gf-form">
         <InlineField 
@@ -1,9 +1,27 @@
-import { DataSourceInstanceSettings, CoreApp } from '@grafana/data';
-import { DataSourceWithBackend } from '@grafana/runtime';
+import {
+  DataFrameJSON,
+  DataQueryRequest,
+  DataQueryResponse,
+  DataSourceInstanceSettings,
+  DataSourceWithSupplementaryQueriesSupport,
+  CoreApp,
+  LiveChannelAddress,
+  LiveChannelScope,
+  SupplementaryQueryType,
+  isLiveChannelMessageEvent,
+} from '@grafana/data';
+import { DataSourceWithBackend, getGrafanaLiveSrv } from '@grafana/runtime';
+import { BehaviorSubject, Observable, distinctUntilChanged, filter, map, switchMap } from 'rxjs';
 
-import { MyQuery, MyDataSourceOptions, DEFAULT_QUERY } from './types';
+import { DQLProgress, MyQuery, MyDataSourceOptions, DEFAULT_QUERY } from './types';
+
+export class DataSource
+  extends DataSourceWithBackend<MyQuery, MyDataSourceOptions>
+  implements DataSourceWithSupplementaryQueriesSupport<MyQuery>
+{
+  // Run ID of the latest run of each DQL query, by refId
+  private dqlRunIds = new BehaviorSubject<Record<string, string>>({});
 
-export class DataSource extends DataSourceWithBackend<MyQuery, MyDataSourceOptions> {
   constructor(instanceSettings: DataSourceInstanceSettings<MyDataSourceOptions>) {
     super(instanceSettings);
   }
@@ -11,4 +29,109 @@ export class DataSource extends DataSourceWithBackend<MyQuery, MyDataSourceOptio
   getDefaultQuery(_: CoreApp): Partial<MyQuery> {
     return DEFAULT_QUERY;
   }
+
+  // Send the dashboard timezone with every query so the backend can align daily
+  // and weekly buckets to it; a timezone set on the query itself wins.
+  // DQL queries get a new run ID each time so that their progress channel is
+  // known, and can be subscribed to, before the query returns.
+  query(request: DataQueryRequest<MyQuery>): Observable<DataQueryResponse> {
+    const timezone = resolveTimezone(request.timezone);
+    const runIds = { ...this.dqlRunIds.value };
+    const targets = request.targets.map((query) => {
+      const target = query.timezone ? query : { ...query, timezone };
+      if (target.queryType !== 'dql') {
+        return target;
+      }
+      const dqlRunId = newRunId();
+      runIds[target.refId] = dqlRunId;
+      return { ...target, dqlRunId };
+    });
+    this.dqlRunIds.next(runIds);
+    return super.query({ ...request, targets });
+  }
+
+  // dqlProgress follows the progress of the latest run of the DQL query refId,
+  // switching to each new run.
+  dqlProgress(refId: string): Observable<DQLProgress> {
+    return this.dqlRunIds.pipe(
+      map((runIds) => runIds[refId]),
+      filter((runId): runId is string => Boolean(runId)),
+      distinctUntilChanged(),
+      switchMap((runId) => getGrafanaLiveSrv().getStream<DataFrameJSON>(this.dqlChannel(runId))),
+      filter(isLiveChannelMessageEvent),
+      map((event) => dqlProgressFromFrame(event.message))
+    );
+  }
+
+  // cancelDQL cancels the latest run of the DQL query refId.
+  async cancelDQL(refId: string): Promise<void> {
+    const runId = this.dqlRunIds.value[refId];
+    if (runId) {
+      await getGrafanaLiveSrv().publish(this.dqlChannel(runId), { cancel: true });
+    }
+  }
+
+  private dqlChannel(runId: string): LiveChannelAddress {
+    return { scope: LiveChannelScope.DataSource, namespace: this.uid, path: `dql/progress/${runId}` };
+  }
+
+  // Explore shows a logs volume histogram above the results of logs queries;
+  // the backend computes it with the "logsVolume" query type.
+  getSupportedSupplementaryQueryTypes(): SupplementaryQueryType[] {
+    return [SupplementaryQueryType.LogsVolume];
+  }
+
+  getSupplementaryQuery(type: SupplementaryQueryType, query: MyQuery): MyQuery | undefined {
+    if (type !== SupplementaryQueryType.LogsVolume || query.queryType !== 'logs') {
+      return undefined;
+    }
+    return { ...query, refId: `log-volume-${query.refId}`, queryType: 'logsVolume', live: false };
+  }
+
+  getDataProvider(
+    type: SupplementaryQueryType,
+    request: DataQueryRequest<MyQuery>
+  ): Observable<DataQueryResponse> | undefined {
+    const targets = request.targets
+      .map((query) => this.getSupplementaryQuery(type, query))
+      .filter((query): query is MyQuery => query !== undefined);
+    if (targets.length === 0) {
+      return undefined;
+    }
+    return this.query({ ...request, targets });
+  }
+}
+
+// resolveTimezone turns the dashboard timezone of a request into an IANA name.
+// "browser" is only known here, so it is resolved to the browser's timezone.
+function resolveTimezone(timezone: string | undefined): string | undefined {
+  if (!timezone || timezone === 'browser') {
+    return Intl.DateTimeFormat().resolvedOptions().timeZone;
+  }
+  return timezone;
+}
+
+// newRunId returns a random ID for a run of a DQL query.
+function newRunId(): string {
+  if (typeof crypto !== 'undefined' && typeof crypto.randomUUID === 'function') {
+    return crypto.randomUUID();
+  }
+  return Array.from({ length: 32 }, () => Math.floor(Math.random() * 16).toString(16)).join('');
+}
+
+// dqlProgressFromFrame reads the single row of a DQL progress frame.
+function dqlProgressFromFrame(frame: DataFrameJSON): DQLProgress {
+  const fields = frame.schema?.fields ?? [];
+  const value = (name: string) => {
+    const index = fields.findIndex((field) => field.name === name);
+    return index < 0 ? undefined : frame.data?.values[index]?.[0];
+  };
+  return {
+    state: String(value('state') ?? ''),
+    progress: Number(value('progress') ?? 0),
+    scannedBytes: Number(value('scannedBytes') ?? 0),
+    scannedRecords: Number(value('scannedRecords') ?? 0),
+    records: Number(value('records') ?? 0),
+    done: Boolean(value('done')),
+  };
 }
@@ -12,8 +12,10 @@ export interface MyQuery extends DataQuery {
   // Kept for backward compatibility
   metricId?: string;
   
-  // DEPRECATED: Use filters in metricSelector instead
-  // Entity selector to filter metrics (e.g., "type(HOST),entityName.equals(myhost)")
+  // Entity selector (e.g., "type(HOST),entityName.equals(myhost)") scoping
+  // entity-based query types such as "deployments", "availability", "entities" and "events", and
+  // limiting "problems" to problems affecting the selected entities.
+  // DEPRECATED for metrics queries: use filters in metricSelector instead
   entitySelector?: string;
   
   // Use dashboard time range instead of custom time range
@@ -29,6 +31,277 @@ export interface MyQuery extends DataQuery {
   // Label Chart - field from labels to use for chart legend
   // (e.g., "dt.entity.service_method.name")
   labelChart?: string;
+
+  // Name fields after the metric ID and attach all dimensions as labels, ignoring labelChart;
+  // legends are then set with Grafana display name overrides
+  rawLabels?: boolean;
+
+  // Dashboard timezone (e.g., "Europe/Vienna"); daily and weekly buckets are aligned to it.
+  // Filled in from the dashboard by DataSource.query unless set
+  timezone?: string;
+
+  // Insert null points for buckets without data between from and to
+  fillGaps?: boolean;
+
+  // Snap timestamps to resolution boundaries, e.g. for alert rules
+  alignTimestamps?: boolean;
+
+  // Drop the trailing bucket that Dynatrace is still filling
+  dropPartialBucket?: boolean;
+
+  // Series transformation computed in the backend
+  transform?: 'delta' | 'derivative' | 'cumulative';
+
+  // Keep only series with the listed values of a dimension, or drop series with the listed
+  // values (e.g., { "dt.entity.host": ["HOST-1", "HOST-2"] })
+  includeLabels?: Record<string, string[]>;
+  excludeLabels?: Record<string, string[]>;
+
+  // Join all series on the time column into a single frame, e.g. for table panels and CSV export
+  join?: 'outer' | 'inner';
+
+  // "long" returns a single frame with a row per series and timestamp, with a metric column; the
+  // dimensions in dimensionOrder come first, the others follow sorted by name. "numeric" returns a
+  // single value per series over the whole range, labeled with its dimensions and metric, for
+  // server-side expressions and recorded queries; it can't be combined with baseline or anomalies
+  format?: 'long' | 'numeric';
+  dimensionOrder?: string[];
+
+  // Position of the time field in emitted frames
+  fieldOrder?: 'timeFirst' | 'valueFirst';
+
+  // Exclude the most recent N minutes; overrides the datasource setting
+  dataDelayMinutes?: number;
+
+  // Entity metadata to attach to each series as extra labels, or as columns of the "entities" table
+  enrichment?: EntityEnrichment;
+
+  // Attach representative traces as exemplars (service response time metrics only)
+  exemplars?: boolean;
+
+  // Add "lower" and "upper" fields with a week-over-week band: the expected range of each series
+  // computed from the same time in the preceding weeks (baselineWeeks, default 4, at most 12).
+  // This is not the Davis baseline, which the metrics API doesn't expose; a notice on the
+  // result says so and counts the extra metrics queries
+  baseline?: boolean;
+  baselineWeeks?: number;
+
+  // Add an "anomalous" field marking when metric events defined on the metric were open,
+  // and a "threshold" field for static thresholds
+  anomalies?: boolean;
+
+  // Problems selector (e.g., "status(\"open\")"), used by the "problems" query type
+  problemSelector?: string;
+
+  // Alerting profile ID; only problems the profile would notify about are returned
+  alertingProfile?: string;
+
+  // Security problems selector of the "securityProblems" query type (e.g., status("OPEN"))
+  securityProblemSelector?: string;
+
+  // "riskLevel" returns open vulnerabilities over time per risk level instead of a table
+  securityAggregation?: string;
+
+  // Browser (SYNTHETIC_TEST-...) or HTTP (HTTP_CHECK-...) monitor of the "synthetic" query type,
+  // returning availability per location and the pass/fail status of each execution
+  syntheticMonitorId?: string;
+
+  // "location" returns the availability and the duration of the monitor per location instead,
+  // to compare performance across locations on a single panel
+  syntheticSplitBy?: 'location';
+
+  // Return the DNS, connect, TLS and response time of each request of an HTTP monitor as a
+  // frame per request step with a field per phase, e.g. for stacked waterfall bars
+  syntheticTimings?: boolean;
+
+  // Add the latitude and longitude of each location as labels of the availability series, and a
+  // table with the coordinates and mean availability per location for the geomap panel
+  syntheticGeo?: boolean;
+
+  // Attack selector of the "attacks" query type (e.g., state("EXPLOITED"))
+  attackSelector?: string;
+
+  // Problem filters combined with problemSelector by the "problems" query type; severities
+  // are also counted by the "problemCount" query type (e.g., ["AVAILABILITY", "ERROR"])
+  problemSeverities?: Array<
+    'AVAILABILITY' | 'ERROR' | 'PERFORMANCE' | 'RESOURCE_CONTENTION' | 'CUSTOM_ALERT' | 'MONITORING_UNAVAILABLE' | 'INFO'
+  >;
+  problemImpacts?: Array<'APPLICATION' | 'SERVICES' | 'INFRASTRUCTURE' | 'ENVIRONMENT'>;
+  problemStatus?: 'open' | 'closed';
+
+  // Management zone of the "problems" query type (e.g., mzName("prod") or mzId(123))
+  mzSelector?: string;
+
+  // Problem ID to look up, used by the "problem" query type
+  problemId?: string;
+
+  // Event types listed by the "events" query type (e.g., ["PROCESS_RESTART", "CUSTOM_CONFIGURATION"])
+  eventTypes?: string[];
+
+  // Event property values the listed events must have (e.g., { "dt.event.is_rootcause_relevant": "true" })
+  eventProperties?: Record<string, string>;
+
+  // Consumption preset used by the "billing" query type
+  // (e.g., "ddu", "ddu.metrics.byEntity", "dem.synthetic")
+  billingPreset?: string;
+
+  // Maximum number of series returned by split billing presets (default 20)
+  billingLimit?: number;
+
+  // Grouping for the "hostUnits" query type: "hostGroup" (default) or "managementZone"
+  hostUnitsGroupBy?: 'hostGroup' | 'managementZone';
+
+  // Monitoring mode for the "hostUnits" query type: "fullStack" (default) or "infrastructure"
+  hostUnitsMode?: 'fullStack' | 'infrastructure';
+
+  // Entity types counted by the "entityCount" query type (e.g., ["HOST", "SERVICE"])
+  entityTypes?: string[];
+
+  // Management zone name used to scope entity queries and the "problemCount" query type
+  managementZone?: string;
+
+  // Logs search query (e.g., 'status="ERROR" AND log.source="/var/log/app.log"'), used by the "logs" query type
+  logQuery?: string;
+
+  // Maximum number of log records returned (default 1000), capped by the datasource maxResults
+  logLimit?: number;
+
+  // Timestamp order of log records: "asc" returns the oldest records, "desc" the most recent ones
+  // newest first; by default the most recent records are returned oldest first
+  logSort?: 'asc' | 'desc';
+
+  // Keys of JSON log bodies promoted to fields and labels; nested keys are addressed with dots
+  // (e.g., ["level", "http.status"]) and keys named like a log column (e.g., "timestamp") are
+  // prefixed with "json."
+  logJsonFields?: string[];
+
+  // Tail new log records live in Explore and logs panels
+  live?: boolean;
+
+  // SLO evaluated over the dashboard time range by the "slo" query type; attainment carries
+  // thresholds at the SLO's target and warning values
+  sloId?: string;
+
+  // "timeseries" evaluates the SLO's metric expression at the query resolution to plot attainment
+  // over time; by default the attainment, status and error budget are returned as a single row
+  sloFormat?: 'timeseries';
+
+  // Web application (e.g., "APPLICATION-1234") whose Apdex rating and satisfied, tolerating and
+  // frustrated user action counts the "apdex" query type returns
+  applicationId?: string;
+
+  // User session query of the "usql" query type (e.g., "SELECT browserFamily, COUNT(*) FROM usersession GROUP BY browserFamily")
+  usqlQuery?: string;
+
+  // Frame shape of the user session query result; when unset, results bucketed by a first time column
+  // (e.g., DATETIME(startTime, "yyyy-MM-dd HH:mm", "5m")) are returned as time series, others as a table
+  usqlResultType?: 'table' | 'pie' | 'timeseries';
+
+  // Grouping of the user session and user action counts of the "sessionsGeo" query type; countries
+  // carry their ISO code in a countryCode field for the geomap panel. geoLimit caps the countries
+  // or regions returned, those with the most sessions first (default 1000, at most 5000)
+  geoGroupBy?: 'country' | 'region';
+  geoLimit?: number;
+
+  // Trace ID to show in the trace view, used by the "traces" query type
+  traceId?: string;
+
+  // Service name to list recent traces for, used by the "traces" query type
+  traceService?: string;
+
+  // Maximum number of traces listed for a service (default 100)
+  traceLimit?: number;
+
+  // Service entity ID (e.g., "SERVICE-1234"), used by the "serviceFlow" query type
+  serviceId?: string;
+
+  // Full metrics query parameters as JSON or query string, used by the "advanced" query type
+  // (e.g., "metricSelector=builtin:host.cpu.usage&mzSelector=mzName(\"prod\")")
+  queryText?: string;
+
+  // DQL query run on Grail by the "dql" query type (e.g., "fetch logs | filter loglevel == \"ERROR\"");
+  // progress is published on the Live channel "dql/progress/<dqlRunId>", and { "cancel": true } published
+  // to that channel by the same user cancels the query
+  dqlQuery?: string;
+  // ID of a single run of a DQL query, set by the datasource for every run so that the editor can follow
+  // its progress before it returns; see DataSource.dqlProgress
+  dqlRunId?: string;
+
+  // DQL request options, capped by the datasource limits
+  maxResultRecords?: number;
+  scanLimitGbytes?: number;
+  // Read 1 in N records: 1, 10, 100, 1000, 10000 or 100000
+  samplingRatio?: number;
+
+  // Releases selector of the "releases" query type, e.g. product("easytravel")
+  releasesSelector?: string;
+
+  // Business events aggregated by the "bizevents" query type, e.g. "com.shop.order"
+  bizEventType?: string;
+  // DQL filter condition applied to the business events, e.g. "amount > 100"
+  bizFilter?: string;
+  // Aggregation: count (default), sum, avg, min or max
+  bizAggregation?: string;
+  // Field aggregated by sum, avg, min and max, e.g. "revenue"
+  bizField?: string;
+  // Field to split series by
+  bizGroupBy?: string;
+  // "timeseries" (default) or "table"
+  bizFormat?: string;
+
+  // Value of the flat series returned by the "constant" query type (e.g., an SLO target)
+  constant?: number;
+
+  // Math over other queries (e.g., "($A / $B) * 100"), used by the "expression" query type
+  expression?: string;
+
+  // Additional Dynatrace API parameters appended to the metrics request (e.g., { "mzSelector": "mzId(123)" }),
+  // used by the "metrics", "advanced" and "billing" query types
+  extraParams?: Record<string, string>;
+
+  // Sorting and paging of the "problems", "securityProblems" and "entities" tables; the sort
+  // field is passed to the Dynatrace API (e.g., "startTime", or "name" for entities) and
+  // pageSize 0 returns all rows
+  sortField?: string;
+  sortDirection?: 'asc' | 'desc';
+  pageSize?: number;
+  pageOffset?: number;
+
+  // Fields kept in table frames, in order (e.g., ["displayId", "title", "status"] for problems); fields
+  // their data links refer to are kept too, hidden
+  columns?: string[];
+
+  // API version targeted by the query: "v2" (default) or "platform" to use the
+  // environment API through the platform URL and token
+  apiVersion?: 'v2' | 'platform';
+
+  // Set to false to skip request coalescing, selector validation against cached metric descriptors
+  // and cached synthetic locations, and to send Cache-Control: no-cache; there is no response cache.
+  // Useful to verify the latest data during incidents
+  cache?: boolean;
+
+  // Settings 2.0 schema (e.g., "builtin:deployment.oneagent.updates"), used by the "settings" query type
+  settingsSchemaId?: string;
+
+  // Comma separated settings scopes (e.g., "environment"), optional
+  settingsScope?: string;
+}
+
+/**
+ * Entity metadata attached to metric series as additional labels
+ */
+export interface EntityEnrichment {
+  // Add a "hostGroup" label
+  hostGroup?: boolean;
+
+  // Add a "managementZones" label with comma-separated zone names
+  managementZones?: boolean;
+
+  // Tag keys to add as "tag.<key>" labels
+  tags?: string[];
+
+  // Entity properties to add as labels (e.g., "osType", "cloudType")
+  properties?: string[];
 }
 
 export const DEFAULT_QUERY: Partial<MyQuery> = {
@@ -40,12 +313,82 @@ export const DEFAULT_QUERY: Partial<MyQuery> = {
 /**
  * These are options configured for each DataSource instance
  */
+// Progress of a running DQL query, as published on its Live channel
+export interface DQLProgress {
+  state: string;
+  progress: number;
+  scannedBytes: number;
+  scannedRecords: number;
+  records: number;
+  done: boolean;
+}
+
 export interface MyDataSourceOptions extends DataSourceJsonData {
   // Base URL for Dynatrace API (e.g., "http://localhost:8080")
   apiUrl?: string;
   
   // Skip TLS certificate verification (insecure)
   tlsSkipVerify?: boolean;
+
+  // Dynatrace web UI URL used for deep links; defaults to apiUrl
+  uiUrl?: string;
+
+  // Dynatrace platform URL used for Grail/DQL queries (e.g., "https://abc123.apps.dynatrace.com")
+  platformUrl?: string;
+
+  // Maximum number of simultaneous requests to Dynatrace across all panels and streams (0 = unlimited)
+  maxConcurrentRequests?: number;
+
+  // Exclude the most recent N minutes from metrics queries to allow for ingestion latency
+  dataDelayMinutes?: number;
+
+  // Maximum number of pages walked by paginated problems, entities and events requests (default 100)
+  maxPages?: number;
+
+  // Maximum number of metric series or log records returned by a query (default 10000)
+  maxResults?: number;
+
+  // Warn in health checks and on query results when the API token expires within this many days (default 14, negative disables)
+  tokenExpiryWarningDays?: number;
+
+  // Queries slower than this many seconds are logged at warning level with their details (default 5)
+  slowQueryThresholdSeconds?: number;
+
+  // Environment variables to read settings from instead, for provisioned datasources
+  // (e.g., apiTokenEnv: "DYNATRACE_API_TOKEN"); they take precedence over the values above
+  apiUrlEnv?: string;
+  platformUrlEnv?: string;
+  tlsSkipVerifyEnv?: string;
+  apiTokenEnv?: string;
+  platformTokenEnv?: string;
+  tlsCertificateEnv?: string;
+
+  // Ceilings of DQL request options (0 = unlimited); also the defaults when a query sets none
+  dqlMaxResultRecords?: number;
+  dqlMaxScanLimitGbytes?: number;
+
+  // Gigabytes Grail may scan per period for this datasource before DQL queries are rejected (0 = unlimited)
+  grailBudgetGbytes?: number;
+  grailBudgetPeriod?: 'hour' | 'day';
+
+  // Allow the /metrics/ingest resource route to push line-protocol metrics to Dynatrace
+  enableMetricIngest?: boolean;
+
+  // Allow the /events resource route to mirror Grafana annotations into Dynatrace events
+  enableEventIngest?: boolean;
+
+  // Log only hashes and lengths of queries, selectors and request URLs, for regulated environments
+  disablePayloadLogging?: boolean;
+
+  // Appended to the User-Agent of requests to Dynatrace (e.g., "team-observability"), after the plugin and Grafana versions
+  userAgentSuffix?: string;
+
+  // Send X-Grafana-Dashboard-Uid, X-Grafana-Panel-Id and X-Grafana-User headers so API usage can be traced to dashboards
+  forwardContextHeaders?: boolean;
+
+  // Regex rules applied to log bodies, dimension values, labels and USQL/DQL results before they are returned,
+  // e.g. [{ pattern: "[\\w.]+@[\\w.]+", replacement: "[email]" }]; the replacement defaults to "[REDACTED]"
+  redactionRules?: Array<{ pattern: string; replacement?: string }>;
 }
 
 /**
@@ -57,4 +400,7 @@ export interface MySecureJsonData {
   
   // TLS client certificate (PEM format)
   tlsCertificate?: string;
+
+  // Dynatrace platform token (bearer) used for Grail/DQL queries
+  platformToken?: string;
 }